@@ -10,6 +10,9 @@ require (
 )
 
 require (
+	github.com/rtemka/agg/openapi v0.0.0
 	go.uber.org/atomic v1.10.0 // indirect
 	go.uber.org/multierr v1.8.0 // indirect
 )
+
+replace github.com/rtemka/agg/openapi => ../openapi
@@ -3,6 +3,7 @@ package api
 
 import (
 	"context"
+	_ "embed"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -15,6 +16,7 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/rtemka/agg/comments/domain"
 	"github.com/rtemka/agg/comments/pkg/sqlite"
+	"github.com/rtemka/agg/openapi"
 
 	"go.uber.org/zap"
 )
@@ -25,6 +27,12 @@ var (
 	ErrNoNewsID = errors.New("invalid input: 'news_id' not found in query parameters")
 )
 
+// specJSON - спецификация API в формате OpenAPI 3,
+// при изменении маршрутов ее нужно обновить.
+//
+//go:embed openapi.json
+var specJSON []byte
+
 type ctxKey int
 
 const (
@@ -56,6 +64,7 @@ type API struct {
 	router *mux.Router
 	repo   domain.Repository
 	logger *zap.Logger
+	spec   *openapi.Spec // спецификация, по которой проверяются запросы.
 }
 
 // New возвращает [*API].
@@ -64,6 +73,7 @@ func New(db domain.Repository, logger *zap.Logger) *API {
 		router: mux.NewRouter(),
 		logger: logger,
 		repo:   db,
+		spec:   openapi.MustLoad(specJSON),
 	}
 	api.endpoints()
 	return &api
@@ -81,7 +91,9 @@ func (api *API) endpoints() {
 		api.wideEventLogMiddleware,
 		api.closerMiddleware,
 		api.headersMiddleware,
+		api.spec.Validator(api.WriteJSONError),
 	)
+	api.router.HandleFunc("/openapi.json", api.spec.Handler()).Methods(http.MethodGet, http.MethodOptions)
 	api.router.HandleFunc("/comments", api.handleCommentCreate()).Methods(http.MethodPost, http.MethodOptions)
 	api.router.HandleFunc("/comments", api.handleCommentRead()).Methods(http.MethodGet, http.MethodOptions)
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	_ "embed"
 	"encoding/json"
 	"errors"
 	"io"
@@ -9,6 +10,7 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/rtemka/agg/openapi"
 
 	"go.uber.org/zap"
 )
@@ -18,6 +20,12 @@ var (
 	ErrBadInput = errors.New("invalid input")
 )
 
+// specJSON - спецификация API в формате OpenAPI 3,
+// при изменении маршрутов ее нужно обновить.
+//
+//go:embed openapi.json
+var specJSON []byte
+
 type ctxKey int
 
 const (
@@ -48,6 +56,7 @@ func (w *wideResponseWriter) Write(b []byte) (int, error) {
 type API struct {
 	router *mux.Router
 	logger *zap.Logger
+	spec   *openapi.Spec // спецификация, по которой проверяются запросы.
 }
 
 // New возвращает [*API].
@@ -55,6 +64,7 @@ func NewApi(logger *zap.Logger) *API {
 	api := API{
 		router: mux.NewRouter(),
 		logger: logger,
+		spec:   openapi.MustLoad(specJSON),
 	}
 	api.endpoints()
 	return &api
@@ -72,7 +82,9 @@ func (api *API) endpoints() {
 		api.wideEventLogMiddleware,
 		api.closerMiddleware,
 		api.headersMiddleware,
+		api.spec.Validator(api.WriteJSONError),
 	)
+	api.router.HandleFunc("/openapi.json", api.spec.Handler()).Methods(http.MethodGet, http.MethodOptions)
 	api.router.HandleFunc("/comments", api.handleCommentCheck()).Methods(http.MethodPost, http.MethodOptions)
 }
 
@@ -9,6 +9,9 @@ require (
 )
 
 require (
+	github.com/rtemka/agg/openapi v0.0.0
 	go.uber.org/atomic v1.7.0 // indirect
 	go.uber.org/multierr v1.6.0 // indirect
 )
+
+replace github.com/rtemka/agg/openapi => ../openapi
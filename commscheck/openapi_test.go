@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// TestAPI_openapi_routes проверяет, что маршруты API совпадают со спецификацией.
+func TestAPI_openapi_routes(t *testing.T) {
+	api := NewApi(zap.NewNop())
+
+	if err := api.spec.CheckRouter(api.router); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAPI_openapi_validation(t *testing.T) {
+	api := NewApi(zap.NewNop())
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		wantCode int
+	}{
+		{name: "spec", method: http.MethodGet, path: "/openapi.json", wantCode: http.StatusOK},
+		{name: "allowed", method: http.MethodPost, path: "/comments", body: `{"text": "good comment"}`, wantCode: http.StatusOK},
+		{name: "banned", method: http.MethodPost, path: "/comments", body: `{"text": "qwerty"}`, wantCode: http.StatusBadRequest},
+		{name: "no_text", method: http.MethodPost, path: "/comments", body: `{"news_id": 1}`, wantCode: http.StatusBadRequest},
+		{name: "bad_text", method: http.MethodPost, path: "/comments", body: `{"text": 1}`, wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			api.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantCode {
+				t.Errorf("API() = response code %d, want %d, body %s", rr.Code, tt.wantCode, rr.Body)
+			}
+		})
+	}
+}
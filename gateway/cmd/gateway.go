@@ -13,6 +13,7 @@ import (
 
 	"github.com/joho/godotenv"
 	"github.com/rtemka/agg/gateway/pkg/api"
+	"github.com/rtemka/agg/gateway/pkg/newsclient"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -23,6 +24,8 @@ const (
 	newsServiceEnv       = "NEWS_ADDR"
 	commentsServiceEnv   = "COMMENTS_ADDR"
 	commsCheckServiceEnv = "COMMENTS_CHECK_ADDR"
+	// необязательная, адрес gRPC API сервиса новостей.
+	newsGRPCEnv = "NEWS_GRPC_ADDR"
 )
 
 func main() {
@@ -49,11 +52,20 @@ func run() error {
 	_, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// gRPC клиент сервиса новостей
+	var nc *newsclient.Client
+	if addr, ok := os.LookupEnv(newsGRPCEnv); ok && addr != "" {
+		if nc, err = newsclient.New(addr); err != nil {
+			return err
+		}
+		defer nc.Close()
+	}
+
 	var wg sync.WaitGroup
 	wg.Add(1)
 
 	servers := []*http.Server{
-		startRestServer(zl, em, &wg),
+		startRestServer(zl, em, nc, &wg),
 	}
 
 	// логика закрытия сервера
@@ -101,9 +113,11 @@ func envs(envs ...string) (map[string]string, error) {
 }
 
 // startRestServer запускает сервер REST API.
-func startRestServer(logger *zap.Logger, env map[string]string, wg *sync.WaitGroup) *http.Server {
+// Если nc не nil, то новость по id запрашивается через gRPC API сервиса новостей.
+func startRestServer(logger *zap.Logger, env map[string]string, nc *newsclient.Client, wg *sync.WaitGroup) *http.Server {
 	// REST API
 	a := api.New(logger)
+	a.News = nc
 	a.Services[api.NewsServiceName] = env[newsServiceEnv]
 	a.Services[api.CommentsServiceName] = env[commentsServiceEnv]
 	a.Services[api.CommsCheckServiceName] = env[commsCheckServiceEnv]
@@ -7,16 +7,26 @@ type NewsFullDetailed struct {
 	PubDate     int64     `json:"pubTime,omitempty"`
 	Description string    `json:"content,omitempty"`
 	Link        string    `json:"link,omitempty"`
+	Source      *Source   `json:"source,omitempty"`
 	Comments    []Comment `json:"comments,omitempty"`
 }
 
 // NewsShortDetailed - модель данных rss-новости
 // в компактном виде.
 type NewsShortDetailed struct {
-	Id      int64  `json:",omitempty"`
-	Title   string `json:"title,omitempty"`
-	PubDate int64  `json:"pubTime,omitempty"`
-	Link    string `json:"link,omitempty"`
+	Id      int64   `json:",omitempty"`
+	Title   string  `json:"title,omitempty"`
+	PubDate int64   `json:"pubTime,omitempty"`
+	Link    string  `json:"link,omitempty"`
+	Source  *Source `json:"source,omitempty"`
+}
+
+// Source - rss-канал, источник новости.
+type Source struct {
+	Id      int64  `json:"id,omitempty"`
+	Name    string `json:"name,omitempty"`
+	URL     string `json:"url,omitempty"`
+	FeedURL string `json:"feed_url,omitempty"`
 }
 
 // Comment - модель данных комментария к rss-новости.
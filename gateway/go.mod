@@ -6,9 +6,26 @@ require (
 	github.com/gorilla/mux v1.8.0
 	github.com/joho/godotenv v1.4.0
 	go.uber.org/zap v1.21.0
+	google.golang.org/grpc v1.58.3
 )
 
 require (
+	github.com/golang/protobuf v1.5.3 // indirect
+	golang.org/x/net v0.12.0 // indirect
+	golang.org/x/sys v0.10.0 // indirect
+	golang.org/x/text v0.11.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20230711160842-782d3b101e98 // indirect
+	google.golang.org/protobuf v1.31.0 // indirect
+)
+
+require (
+	github.com/rtemka/agg/news v0.0.0
+	github.com/rtemka/agg/openapi v0.0.0
 	go.uber.org/atomic v1.7.0 // indirect
 	go.uber.org/multierr v1.6.0 // indirect
 )
+
+replace (
+	github.com/rtemka/agg/news => ../newsservice
+	github.com/rtemka/agg/openapi => ../openapi
+)
@@ -4,6 +4,9 @@ package api
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"io"
@@ -11,11 +14,14 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/rtemka/agg/gateway/domain"
+	"github.com/rtemka/agg/gateway/pkg/newsclient"
+	"github.com/rtemka/agg/openapi"
 
 	"go.uber.org/zap"
 )
@@ -31,6 +37,12 @@ const (
 	CommsCheckServiceName = "commscheck"
 )
 
+// specJSON - спецификация API в формате OpenAPI 3,
+// при изменении маршрутов ее нужно обновить.
+//
+//go:embed openapi.json
+var specJSON []byte
+
 type ctxKey int
 
 const (
@@ -56,6 +68,14 @@ func (w *wideResponseWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// Flush отправляет клиенту буферизованные данные,
+// нужен для потоковых ответов.
+func (w *wideResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 // REST API.
 type API struct {
 	router *mux.Router
@@ -65,6 +85,10 @@ type API struct {
 	// После создания объекта API предполагается, что пользователь
 	// установит сетевые адреса сервисов.
 	Services map[string]string
+	// News - gRPC клиент сервиса новостей. Если он установлен,
+	// то новость по id запрашивается через него, а не по REST.
+	News *newsclient.Client
+	spec *openapi.Spec // спецификация, по которой проверяются запросы.
 }
 
 // New возвращает [*API].
@@ -73,6 +97,7 @@ func New(logger *zap.Logger) *API {
 		router:   mux.NewRouter(),
 		logger:   logger,
 		Services: map[string]string{NewsServiceName: "", CommentsServiceName: ""},
+		spec:     openapi.MustLoad(specJSON),
 	}
 	api.endpoints()
 	rand.Seed(time.Now().UnixNano())
@@ -92,10 +117,18 @@ func (api *API) endpoints() {
 		api.closerMiddleware,
 		api.headersMiddleware,
 		api.secHeadersMiddleware,
+		api.spec.Validator(api.WriteJSONError),
 	)
+	api.router.HandleFunc("/openapi.json", api.spec.Handler()).Methods(http.MethodGet, http.MethodOptions)
 	api.router.HandleFunc("/news/latest", api.handleNewsLatest()).Methods(http.MethodGet, http.MethodOptions)
 	api.router.HandleFunc("/news", api.handleNewsLatest()).Methods(http.MethodGet, http.MethodOptions)
+	api.router.HandleFunc("/news/stats", api.handleNewsStats()).Methods(http.MethodGet, http.MethodOptions)
+	api.router.HandleFunc("/news/export", api.handleNewsExport()).Methods(http.MethodGet, http.MethodOptions)
+	api.router.HandleFunc("/news/stream", api.handleNewsStream()).Methods(http.MethodGet, http.MethodOptions)
+	api.router.HandleFunc("/news.rss", api.handleNewsFeed()).Methods(http.MethodGet, http.MethodOptions)
+	api.router.HandleFunc("/news.atom", api.handleNewsFeed()).Methods(http.MethodGet, http.MethodOptions)
 	api.router.HandleFunc("/news/{id}", api.handleNewsDitailed()).Methods(http.MethodGet, http.MethodOptions)
+	api.router.HandleFunc("/news/{id}/related", api.handleNewsRelated()).Methods(http.MethodGet, http.MethodOptions)
 	api.router.HandleFunc("/comments", api.handleCommentCreate()).Methods(http.MethodPost, http.MethodOptions)
 }
 
@@ -160,6 +193,8 @@ func (api *API) headersMiddleware(next http.Handler) http.Handler {
 }
 
 // secHeadersMiddleware устанавливает строгие заголовки безопасности для всех ответов.
+// Cache-Control: no-store остается только у ответов, для которых ни сервис,
+// ни шлюз не задали свой Cache-Control (ошибки, комментарии).
 func (api *API) secHeadersMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("X-Content-Type-Options", "nosniff")
@@ -199,6 +234,140 @@ func (api *API) handleNewsLatest() http.HandlerFunc {
 	}
 }
 
+// handleNewsStats перенаправляет запрос статистики в сервис новостей.
+func (api *API) handleNewsStats() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		u := api.serviceURL(r, NewsServiceName, NewsServiceName+"/stats")
+
+		api.forwardReq(&u, http.MethodGet, nil, w, r)
+	}
+}
+
+// handleNewsRelated перенаправляет запрос похожих новостей в сервис новостей.
+func (api *API) handleNewsRelated() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		u := api.serviceURL(r, NewsServiceName, NewsServiceName+strings.TrimPrefix(r.URL.Path, "/news"))
+
+		api.forwardReq(&u, http.MethodGet, nil, w, r)
+	}
+}
+
+// exportHeaders - заголовки ответа выгрузки, которые передаются клиенту.
+var exportHeaders = []string{"Content-Type", "Content-Encoding", "Content-Disposition", "Vary"}
+
+// streamHeaders - заголовки ответа потока новостей, которые передаются клиенту.
+var streamHeaders = []string{"Content-Type", "Cache-Control", "X-Accel-Buffering"}
+
+// handleNewsExport передает потоком выгрузку новостей из сервиса новостей.
+// Выгрузка может идти долго, поэтому запрос ограничен не таймаутом,
+// а временем жизни запроса клиента. Сжатый ответ передается как есть.
+func (api *API) handleNewsExport() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		u := api.serviceURL(r, NewsServiceName, NewsServiceName+"/export")
+
+		api.forwardStream(&u, []string{"Accept-Encoding"}, exportHeaders, w, r)
+	}
+}
+
+// handleNewsStream передает клиенту поток новых новостей (Server-Sent Events)
+// из сервиса новостей. Last-Event-ID клиента передается сервису,
+// чтобы после переподключения поток продолжился с пропущенных новостей.
+func (api *API) handleNewsStream() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		u := api.serviceURL(r, NewsServiceName, NewsServiceName+"/stream")
+
+		api.forwardStream(&u, []string{"Accept", "Last-Event-ID"}, streamHeaders, w, r)
+	}
+}
+
+// feedReqHeaders - заголовки запроса ленты, которые передаются
+// сервису новостей: условный запрос и адрес шлюза для ссылок ленты.
+var feedReqHeaders = []string{"If-None-Match", "If-Modified-Since", "X-Forwarded-Host", "X-Forwarded-Proto"}
+
+// feedHeaders - заголовки ответа ленты, которые передаются клиенту.
+var feedHeaders = []string{"Content-Type", "Cache-Control", "ETag", "Last-Modified"}
+
+// handleNewsFeed передает клиенту ленту новостей RSS или Atom из сервиса новостей.
+// Ссылка ленты на себя строится сервисом по адресу шлюза.
+func (api *API) handleNewsFeed() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		u := api.serviceURL(r, NewsServiceName, strings.TrimPrefix(r.URL.Path, "/"))
+
+		if r.Header.Get("X-Forwarded-Host") == "" {
+			r.Header.Set("X-Forwarded-Host", r.Host)
+		}
+		if r.Header.Get("X-Forwarded-Proto") == "" && r.TLS != nil {
+			r.Header.Set("X-Forwarded-Proto", "https")
+		}
+
+		api.forwardStream(&u, feedReqHeaders, feedHeaders, w, r)
+	}
+}
+
+// forwardStream передает клиенту ответ сервиса по мере получения.
+// reqHeaders - заголовки запроса клиента, которые передаются сервису,
+// respHeaders - заголовки ответа сервиса, которые передаются клиенту.
+// Трейлеры ответа сервиса передаются клиенту, если ответ получен целиком.
+func (api *API) forwardStream(u *url.URL, reqHeaders, respHeaders []string, w http.ResponseWriter, r *http.Request) {
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, u.String(), nil)
+	if err != nil {
+		api.WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+	copyHeaders(req.Header, r.Header, reqHeaders)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		api.WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	copyHeaders(w.Header(), resp.Header, respHeaders)
+	if resp.StatusCode == http.StatusNotModified {
+		w.Header().Del("Content-Type")
+	}
+	for h := range resp.Trailer {
+		w.Header().Add("Trailer", h)
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err == io.EOF {
+			// трейлеры известны только после чтения всего ответа
+			for h, v := range resp.Trailer {
+				w.Header()[h] = v
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
 func (api *API) handleCommentCreate() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		u := api.serviceURL(r, CommsCheckServiceName, CommentsServiceName)
@@ -234,6 +403,14 @@ func (api *API) handleNewsDitailed() http.HandlerFunc {
 		nsu := api.serviceURL(r, NewsServiceName, NewsServiceName+strings.TrimPrefix(r.URL.Path, "/news"))
 		csu := api.serviceURL(r, CommentsServiceName, CommentsServiceName)
 		urls := map[url.URL]requester{nsu: nf, csu: cf}
+		if api.News != nil {
+			id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+			if err != nil {
+				api.WriteJSONError(w, err, http.StatusBadRequest)
+				return
+			}
+			urls[nsu] = api.grpcNewsFunc(id)
+		}
 
 		ch := make(chan any, len(urls))
 
@@ -258,7 +435,11 @@ func (api *API) handleNewsDitailed() http.HandlerFunc {
 			v := <-ch
 			switch r := v.(type) {
 			case error:
-				api.WriteJSONError(w, r, http.StatusInternalServerError)
+				code := http.StatusInternalServerError
+				if errors.Is(r, newsclient.ErrNotFound) {
+					code = http.StatusNotFound
+				}
+				api.WriteJSONError(w, r, code)
 				return
 			case domain.NewsFullDetailed:
 				news = r
@@ -272,13 +453,35 @@ func (api *API) handleNewsDitailed() http.HandlerFunc {
 
 		news.Comments = domain.ToTree(comments)
 
-		api.WriteJSON(w, news, http.StatusOK)
+		api.writeJSONCached(w, r, news, cacheDetailed)
 	}
 }
 
+// cacheReqHeaders - заголовки условного запроса, которые передаются сервису.
+var cacheReqHeaders = []string{"If-None-Match", "If-Modified-Since"}
+
+// cacheHeaders - заголовки кэширования ответа сервиса,
+// которые передаются клиенту как есть.
+var cacheHeaders = []string{"Cache-Control", "ETag", "Last-Modified"}
+
+// cacheDetailed - Cache-Control новости с комментариями: комментарии
+// добавляются в любой момент, поэтому ответ каждый раз проверяется по ETag.
+const cacheDetailed = "no-cache"
+
+// forwardReq перенаправляет запрос в сервис и передает клиенту его ответ
+// вместе с заголовками кэширования, условный запрос клиента передается сервису.
 func (api *API) forwardReq(u *url.URL, method string, body io.Reader, w http.ResponseWriter, r *http.Request) {
+	c, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(c, method, u.String(), body)
+	if err != nil {
+		api.WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+	copyHeaders(req.Header, r.Header, cacheReqHeaders)
 
-	resp, err := makeRequest(u, method, body)
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		api.WriteJSONError(w, err, http.StatusInternalServerError)
 		return
@@ -287,10 +490,50 @@ func (api *API) forwardReq(u *url.URL, method string, body io.Reader, w http.Res
 		_ = resp.Body.Close()
 	}()
 
+	copyHeaders(w.Header(), resp.Header, cacheHeaders)
+	if resp.StatusCode == http.StatusNotModified {
+		w.Header().Del("Content-Type")
+	}
 	w.WriteHeader(resp.StatusCode)
 	_, _ = io.Copy(w, resp.Body)
 }
 
+// copyHeaders копирует из src в dst заданные заголовки.
+func copyHeaders(dst, src http.Header, names []string) {
+	for _, h := range names {
+		if v := src.Get(h); v != "" {
+			dst.Set(h, v)
+		}
+	}
+}
+
+// writeJSONCached пишет data в JSON со статусом 200, заголовками
+// ETag (по содержимому ответа) и Cache-Control. Если ETag совпадает
+// с If-None-Match запроса, то отвечает 304 без тела.
+func (api *API) writeJSONCached(w http.ResponseWriter, r *http.Request, data any, cacheControl string) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(data); err != nil {
+		api.WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", cacheControl)
+
+	for _, tag := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		if tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/"); tag == etag || tag == "*" {
+			w.Header().Del("Content-Type")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+}
+
 func makeRequest(u *url.URL, method string, body io.Reader) (*http.Response, error) {
 	c, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -305,6 +548,14 @@ func makeRequest(u *url.URL, method string, body io.Reader) (*http.Response, err
 
 type requester func(context.Context, *url.URL) (any, error)
 
+// grpcNewsFunc возвращает requester, который запрашивает новость id
+// через gRPC клиент сервиса новостей, адрес запроса не используется.
+func (api *API) grpcNewsFunc(id int64) requester {
+	return func(ctx context.Context, _ *url.URL) (any, error) {
+		return api.News.News(ctx, id)
+	}
+}
+
 func requestFunc[T any](f func(r io.ReadCloser) (T, error)) requester {
 
 	return func(ctx context.Context, u *url.URL) (any, error) {
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// TestAPI_openapi_routes проверяет, что маршруты API совпадают со спецификацией.
+func TestAPI_openapi_routes(t *testing.T) {
+	api := New(zap.NewNop())
+
+	if err := api.spec.CheckRouter(api.router); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestAPI_openapi_validation проверяет, что неверные запросы
+// отклоняются шлюзом, не доходя до сервисов.
+func TestAPI_openapi_validation(t *testing.T) {
+	api := New(zap.NewNop())
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		wantCode int
+	}{
+		{name: "spec", method: http.MethodGet, path: "/openapi.json", wantCode: http.StatusOK},
+		{name: "bad_page_size", method: http.MethodGet, path: "/news?pageSize=0", wantCode: http.StatusBadRequest},
+		{name: "bad_sort", method: http.MethodGet, path: "/news/latest?sortBy=author", wantCode: http.StatusBadRequest},
+		{name: "bad_id", method: http.MethodGet, path: "/news/abc", wantCode: http.StatusNotFound},
+		{name: "bad_limit", method: http.MethodGet, path: "/news/1/related?limit=100", wantCode: http.StatusBadRequest},
+		{name: "bad_json", method: http.MethodPost, path: "/comments", body: `{"news_id": 1,`, wantCode: http.StatusBadRequest},
+		{name: "no_news_id", method: http.MethodPost, path: "/comments", body: `{"text": "a"}`, wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			api.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantCode {
+				t.Errorf("API() = response code %d, want %d, body %s", rr.Code, tt.wantCode, rr.Body)
+			}
+		})
+	}
+}
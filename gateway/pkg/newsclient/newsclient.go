@@ -0,0 +1,71 @@
+// пакет newsclient - клиент gRPC API сервиса новостей для шлюза.
+//
+// Поверх сгенерированного клиента github.com/rtemka/agg/news/pkg/newspb
+// переводит сообщения в модели шлюза из пакета domain.
+package newsclient
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rtemka/agg/gateway/domain"
+	"github.com/rtemka/agg/news/pkg/newspb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// ErrNotFound - новости с таким id нет.
+var ErrNotFound = errors.New("news not found")
+
+// Client - клиент gRPC API сервиса новостей.
+// Безопасен для конкурентного использования.
+type Client struct {
+	conn *grpc.ClientConn
+	news newspb.NewsServiceClient
+}
+
+// New возвращает клиент сервиса новостей по адресу addr (host:port).
+// Соединение устанавливается при первом запросе и восстанавливается
+// после разрыва.
+func New(addr string) (*Client, error) {
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, news: newspb.NewNewsServiceClient(conn)}, nil
+}
+
+// News возвращает новость по id. Если новости нет, то возвращается ErrNotFound.
+func (c *Client) News(ctx context.Context, id int64) (domain.NewsFullDetailed, error) {
+	n, err := c.news.GetNews(ctx, &newspb.GetNewsRequest{Id: id})
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return domain.NewsFullDetailed{}, ErrNotFound
+		}
+		return domain.NewsFullDetailed{}, err
+	}
+	return news(n), nil
+}
+
+// Close закрывает соединение с сервисом.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// news переводит сообщение в модель новости шлюза.
+func news(n *newspb.News) domain.NewsFullDetailed {
+	res := domain.NewsFullDetailed{
+		Id:          n.GetId(),
+		Title:       n.GetTitle(),
+		PubDate:     n.GetPubTime(),
+		Description: n.GetContent(),
+		Link:        n.GetLink(),
+	}
+	// у новости без источника id источника 0
+	if s := n.GetSource(); s.GetId() != 0 {
+		res.Source = &domain.Source{Id: s.GetId(), Name: s.GetName(), URL: s.GetUrl(), FeedURL: s.GetFeedUrl()}
+	}
+	return res
+}
@@ -0,0 +1,73 @@
+package newsclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/rtemka/agg/gateway/domain"
+	"github.com/rtemka/agg/news/pkg/newspb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newsServer - сервис новостей с одной новостью и одной новостью без источника.
+type newsServer struct {
+	newspb.UnimplementedNewsServiceServer
+}
+
+func (newsServer) GetNews(_ context.Context, req *newspb.GetNewsRequest) (*newspb.News, error) {
+	switch req.GetId() {
+	case 1:
+		return &newspb.News{Id: 1, Title: "Вышел go 1.19", PubTime: 100, Content: "go", Link: "https://test.com/1",
+			Source: &newspb.Source{Id: 2, Name: "test", Url: "https://test.com", FeedUrl: "https://test.com/rss"}}, nil
+	case 2:
+		return &newspb.News{Id: 2, Title: "Курс рубля", Link: "https://test.com/2"}, nil
+	}
+	return nil, status.Errorf(codes.NotFound, "news id=%d not found", req.GetId())
+}
+
+func TestClient_News(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	newspb.RegisterNewsServiceServer(srv, newsServer{})
+	go func() { _ = srv.Serve(l) }()
+	defer srv.Stop()
+
+	c, err := New(l.Addr().String())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer c.Close()
+
+	tests := []struct {
+		name    string
+		id      int64
+		want    domain.NewsFullDetailed
+		wantErr error
+	}{
+		{name: "source", id: 1, want: domain.NewsFullDetailed{Id: 1, Title: "Вышел go 1.19", PubDate: 100,
+			Description: "go", Link: "https://test.com/1",
+			Source: &domain.Source{Id: 2, Name: "test", URL: "https://test.com", FeedURL: "https://test.com/rss"}}},
+		{name: "no_source", id: 2, want: domain.NewsFullDetailed{Id: 2, Title: "Курс рубля", Link: "https://test.com/2"}},
+		{name: "not_found", id: 3, wantErr: ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.News(context.Background(), tt.id)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("News() error = %v, want = %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("News() got = %+v, want = %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -6,20 +6,32 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
+	_ "time/tzdata" // часовые пояса для ?tz=, если в образе нет базы IANA
 
 	"github.com/joho/godotenv"
 	"github.com/rtemka/agg/news/pkg/api"
+	"github.com/rtemka/agg/news/pkg/broadcast"
+	"github.com/rtemka/agg/news/pkg/grpcapi"
 	"github.com/rtemka/agg/news/pkg/rsscollector"
+	"github.com/rtemka/agg/news/pkg/savedsearch"
 	"github.com/rtemka/agg/news/pkg/storage"
 	"github.com/rtemka/agg/news/pkg/storage/postgres"
+	"github.com/rtemka/agg/news/pkg/storage/postgres/migrate"
+	"github.com/rtemka/agg/news/pkg/storage/retention"
+	"github.com/rtemka/agg/news/pkg/storage/spool"
+	"github.com/rtemka/agg/news/pkg/storage/sqlite"
 	"github.com/rtemka/agg/news/pkg/storage/streamwriter"
+	"github.com/rtemka/agg/news/pkg/webhook"
+	"google.golang.org/grpc"
 )
 
 // имя подсистемы для логирования
@@ -27,19 +39,64 @@ var (
 	rsscolName = fmt.Sprintf("%16s", "[RSS Collector] ")
 	dwName     = fmt.Sprintf("%16s", "[DB Writer] ")
 	apiName    = fmt.Sprintf("%16s", "[WEB API] ")
+	migName    = fmt.Sprintf("%16s", "[Migrate] ")
+	retName    = fmt.Sprintf("%16s", "[Retention] ")
+	whName     = fmt.Sprintf("%16s", "[Webhooks] ")
+	ssName     = fmt.Sprintf("%16s", "[Searches] ")
+	grpcName   = fmt.Sprintf("%16s", "[gRPC API] ")
 )
 
 // переменная окружения.
 const (
-	portEnv   = "NEWS_PORT"
-	newsDBEnv = "NEWS_DB_URL"
+	portEnv      = "NEWS_PORT"
+	newsDBEnv    = "NEWS_DB_URL"
+	dbMigrateEnv = "NEWS_DB_MIGRATE"  // если "true", то миграции применяются при запуске.
+	spoolDirEnv  = "NEWS_SPOOL_DIR"   // каталог спула новостей на время недоступности БД.
+	adminEnv     = "NEWS_ADMIN_TOKEN" // токен административного API, без него /admin выключено.
+	grpcPortEnv  = "NEWS_GRPC_PORT"   // адрес gRPC API, без него gRPC API выключено.
 )
 
+// sqliteScheme - схема NEWS_DB_URL для хранилища SQLite,
+// например sqlite://./news.db, иначе используется Postgres.
+const sqliteScheme = "sqlite://"
+
 // config - структура для хранения конфигурации
 // передаваемой в качестве аргумента коммандной строки
 type config struct {
-	Links        []string `json:"rss"`            // массив ссылок для опроса
-	SurveyPeriod int      `json:"request_period"` // период опроса ссылок в минутах
+	Links        []string        `json:"rss"`            // массив ссылок для опроса
+	SurveyPeriod int             `json:"request_period"` // период опроса ссылок в минутах
+	Retention    retentionConfig `json:"retention"`      // срок хранения новостей
+}
+
+// retentionConfig - настройки удаления устаревших новостей,
+// если сроки не заданы, то новости хранятся бессрочно.
+type retentionConfig struct {
+	MaxAgeDays int            `json:"max_age_days"` // срок хранения новостей в днях
+	Sources    map[string]int `json:"sources"`      // сроки хранения в днях по адресу rss-канала
+	ArchiveDir string         `json:"archive_dir"`  // каталог архива удаленных новостей
+	Period     int            `json:"period"`       // период удаления в минутах
+}
+
+// defaultRetentionPeriod - период удаления
+// устаревших новостей по умолчанию.
+const defaultRetentionPeriod = time.Hour
+
+// policy возвращает правила хранения новостей.
+func (c *retentionConfig) policy() retention.Policy {
+	const day = 24 * time.Hour
+
+	p := retention.Policy{
+		MaxAge:     time.Duration(c.MaxAgeDays) * day,
+		ArchiveDir: c.ArchiveDir,
+	}
+	if len(c.Sources) > 0 {
+		p.Sources = make(map[string]time.Duration, len(c.Sources))
+		for feed, days := range c.Sources {
+			p.Sources[feed] = time.Duration(days) * day
+		}
+	}
+
+	return p
 }
 
 // readConfig функция для чтения файла конфигурации
@@ -64,10 +121,15 @@ func main() {
 
 func run() error {
 	if len(os.Args) == 1 {
-		return fmt.Errorf("usage: %s <path-to-config-file>", os.Args[0])
+		return fmt.Errorf("usage: %s <path-to-config-file>\n       %s migrate up|down|status",
+			os.Args[0], os.Args[0])
 	}
 	_ = godotenv.Load() // загружаем переменные окружения
 
+	if os.Args[1] == "migrate" {
+		return runMigrate(os.Args[2:])
+	}
+
 	config, err := readConfig(os.Args[1])
 	if err != nil {
 		return err
@@ -84,14 +146,44 @@ func run() error {
 	}
 	defer db.Close()
 
+	// схему SQLite хранилище создает само при подключении
+	if os.Getenv(dbMigrateEnv) == "true" && !strings.HasPrefix(em[newsDBEnv], sqliteScheme) {
+		if err := migrateUp(em[newsDBEnv]); err != nil {
+			return err
+		}
+	}
+
 	// логгеры для подсистем
 	rsslog := log.New(os.Stdout, rsscolName, log.Lmsgprefix|log.LstdFlags)
 	dbwriterlog := log.New(os.Stdout, dwName, log.Lmsgprefix|log.LstdFlags)
 	apilog := log.New(os.Stdout, apiName, log.Lmsgprefix|log.LstdFlags)
-
-	collector := rsscollector.New(rsslog).DebugMode(true)               // RSS-обходчик
-	sw := streamwriter.NewStreamWriter(dbwriterlog, db).DebugMode(true) // объект пишуший в БД
-	webapi := api.New(db, apilog)                                       // REST API
+	retlog := log.New(os.Stdout, retName, log.Lmsgprefix|log.LstdFlags)
+	whlog := log.New(os.Stdout, whName, log.Lmsgprefix|log.LstdFlags)
+	sslog := log.New(os.Stdout, ssName, log.Lmsgprefix|log.LstdFlags)
+	grpclog := log.New(os.Stdout, grpcName, log.Lmsgprefix|log.LstdFlags)
+
+	gsrv := grpc.NewServer()                                             // gRPC API
+	collector := rsscollector.New(rsslog).DebugMode(true)                // RSS-обходчик
+	sw := streamwriter.NewStreamWriter(dbwriterlog, db).DebugMode(true)  // объект пишуший в БД
+	webapi := api.New(db, apilog).WithWebhooks(db).WithSavedSearches(db) // REST API
+	policy := config.Retention.policy()
+	cleaner := retention.New(retlog, db, policy) // удаление устаревших новостей
+	dispatcher := webhook.New(whlog, db, db)     // рассылка новых новостей подписчикам
+	events := broadcast.New()                    // сигналы о новых новостях для /news/stream
+	matcher := savedsearch.New(sslog, db, db)    // совпадения новых новостей с сохраненными поисками
+	sw.WithNotifier(dispatcher).WithNotifier(events).WithNotifier(matcher)
+	webapi.WithBroadcaster(events).WithAdmin(collector, os.Getenv(adminEnv))
+	grpcapi.New(db, grpclog).WithBroadcaster(events).WithAdmin(collector, os.Getenv(adminEnv)).Register(gsrv)
+
+	// спул для новостей, которые не удалось записать в БД
+	if dir := os.Getenv(spoolDirEnv); dir != "" {
+		sp, err := spool.Open(dir, spool.Options{})
+		if err != nil {
+			return err
+		}
+		defer sp.Close()
+		sw.WithSpool(sp)
+	}
 
 	// конфигурируем сервер
 	srv := &http.Server{
@@ -100,6 +192,16 @@ func run() error {
 		IdleTimeout:       3 * time.Minute,
 		ReadHeaderTimeout: time.Minute,
 	}
+	// Shutdown ждет окончания запросов, а потоки /news/stream сами не заканчиваются
+	srv.RegisterOnShutdown(webapi.CloseStreams)
+
+	// gRPC API включается адресом в NEWS_GRPC_PORT
+	var glis net.Listener
+	if addr := os.Getenv(grpcPortEnv); addr != "" {
+		if glis, err = net.Listen("tcp", addr); err != nil {
+			return err
+		}
+	}
 
 	// создаем контекст для регулирования закрытия всех подсистем
 	ctx, cancel := context.WithCancel(context.Background())
@@ -130,6 +232,42 @@ func run() error {
 		wg.Done()
 	}()
 
+	// рассылаем новые новости подписчикам
+	wg.Add(1)
+	go func() {
+		dispatcher.Run(ctx)
+		wg.Done()
+	}()
+
+	// сопоставляем новые новости с сохраненными поисками
+	wg.Add(1)
+	go func() {
+		matcher.Run(ctx)
+		wg.Done()
+	}()
+
+	// слушаем уведомления БД о новостях, добавленных другими экземплярами
+	if l, ok := db.(broadcast.Listener); ok {
+		wg.Add(1)
+		go func() {
+			events.Listen(ctx, l, apilog)
+			wg.Done()
+		}()
+	}
+
+	// удаляем устаревшие новости
+	if policy.Enabled() {
+		period := defaultRetentionPeriod
+		if config.Retention.Period > 0 {
+			period = time.Minute * time.Duration(config.Retention.Period)
+		}
+		wg.Add(1)
+		go func() {
+			cleaner.Run(ctx, period)
+			wg.Done()
+		}()
+	}
+
 	// сервер
 	go func() {
 		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
@@ -141,6 +279,18 @@ func run() error {
 	}()
 	log.Println(apiName, "server started at", srv.Addr)
 
+	// gRPC сервер на своем порту
+	if glis != nil {
+		wg.Add(1)
+		go func() {
+			if err := gsrv.Serve(glis); err != nil {
+				log.Println(grpcName, err)
+			}
+			wg.Done()
+		}()
+		log.Println(grpcName, "server started at", glis.Addr())
+	}
+
 	// ловим сигналы прерывания типа CTRL-C
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGHUP, syscall.SIGTERM, syscall.SIGQUIT)
@@ -148,10 +298,11 @@ func run() error {
 		s := <-stop // получили сигнал прерывания
 		log.Println("got os signal", s)
 
-		// закрываем сервер
+		// закрываем серверы
 		if err := srv.Shutdown(ctx); err != nil {
 			log.Fatal(err)
 		}
+		stopGRPC(gsrv, grpcStopTimeout)
 
 		cancel() // закрываем контекст приложения
 	}()
@@ -161,6 +312,126 @@ func run() error {
 	return nil
 }
 
+// grpcStopTimeout - сколько gRPC сервер ждет завершения запросов
+// при остановке, потоки новостей сами не завершаются.
+const grpcStopTimeout = 5 * time.Second
+
+// stopGRPC останавливает gRPC сервер, дожидаясь завершения
+// запросов не дольше timeout, затем закрывает соединения.
+func stopGRPC(gs *grpc.Server, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		gs.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		gs.Stop()
+	}
+}
+
+// runMigrate выполняет подкоманду migrate up|down|status.
+func runMigrate(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: %s migrate up|down|status", os.Args[0])
+	}
+
+	em, err := envs(newsDBEnv)
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(em[newsDBEnv], sqliteScheme) {
+		return errors.New("migrations are supported only for postgres, sqlite schema is created on start")
+	}
+
+	switch args[0] {
+	case "up":
+		return migrateUp(em[newsDBEnv])
+	case "down":
+		return migrateDown(em[newsDBEnv])
+	case "status":
+		return migrateStatus(em[newsDBEnv])
+	default:
+		return fmt.Errorf("unknown migrate command %q, must be either: up, down or status", args[0])
+	}
+}
+
+// migrateUp применяет все еще не примененные миграции.
+func migrateUp(connstr string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	m, err := migrate.Connect(ctx, connstr)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	applied, err := m.Up(ctx)
+	if errors.Is(err, migrate.ErrNoChange) {
+		log.Println(migName, "database schema is up to date")
+		return nil
+	}
+	for _, mig := range applied {
+		log.Println(migName, "applied", mig)
+	}
+
+	return err
+}
+
+// migrateDown откатывает последнюю примененную миграцию.
+func migrateDown(connstr string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	m, err := migrate.Connect(ctx, connstr)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	reverted, err := m.Down(ctx)
+	if errors.Is(err, migrate.ErrNoChange) {
+		log.Println(migName, "no migrations to revert")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	log.Println(migName, "reverted", reverted)
+
+	return nil
+}
+
+// migrateStatus печатает состояние миграций.
+func migrateStatus(connstr string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	m, err := migrate.Connect(ctx, connstr)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range statuses {
+		if s.Applied {
+			fmt.Printf("%-32s applied at %s\n", s.Migration, s.AppliedAt.Format(time.RFC3339))
+		} else {
+			fmt.Printf("%-32s pending\n", s.Migration)
+		}
+	}
+
+	return nil
+}
+
 // envs собирает ожидаемые переменные окружения,
 // возвращает ошибку, если какая-либо из переменных env не задана.
 func envs(envs ...string) (map[string]string, error) {
@@ -176,7 +447,20 @@ func envs(envs ...string) (map[string]string, error) {
 
 var ErrRetryExceeded = errors.New("connect DB: number of retries exceeded")
 
-func connectDB(connstr string, retries int, interval time.Duration) (storage.Storage, error) {
+// database - хранилище новостей, подписок и сохраненных поисков,
+// реализуется всеми хранилищами пакета storage.
+type database interface {
+	storage.Storage
+	storage.WebhookStore
+	storage.SavedSearchStore
+}
+
+// connectDB подключается к БД, выбирая хранилище
+// по схеме строки подключения.
+func connectDB(connstr string, retries int, interval time.Duration) (database, error) {
+	if strings.HasPrefix(connstr, sqliteScheme) {
+		return sqlite.New(strings.TrimPrefix(connstr, sqliteScheme))
+	}
 
 	for i := 0; i < retries; i++ {
 		db, err := postgres.New(connstr)
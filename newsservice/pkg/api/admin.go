@@ -0,0 +1,108 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/rtemka/agg/news/pkg/rsscollector"
+	"github.com/rtemka/agg/news/pkg/service"
+)
+
+// errUnauthorized - запрос к административному API без верного токена.
+var errUnauthorized = errors.New("unauthorized: admin token required")
+
+// PollAllResponse - ответ на внеочередной опрос всех каналов.
+type PollAllResponse struct {
+	Polled int `json:"polled"` // количество каналов, кроме приостановленных.
+}
+
+// WithAdmin включает административное API: управление опросом каналов
+// /admin/feeds (если feeds не nil), подписками /webhooks и сохраненными
+// поисками /searches (если заданы их хранилища, см. WithWebhooks и
+// WithSavedSearches). Запросы к нему
+// должны содержать заголовок Authorization: Bearer <token>, без токена
+// API выключено.
+func (api *API) WithAdmin(feeds service.FeedController, token string) *API {
+	api.feeds = feeds
+	api.adminToken = token
+	return api
+}
+
+// admin пропускает к обработчику next только запросы с токеном
+// администратора. Если административное API выключено, отвечает 404.
+func (api *API) admin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if api.adminToken == "" {
+			api.WriteJSON(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		if !service.Authorized(r.Header.Get("Authorization"), api.adminToken) {
+			api.logger.Printf("[ERROR] admin: unauthorized request path=%s remote=%s", r.URL.Path, r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			api.WriteJSONError(w, errUnauthorized, http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// withFeeds пропускает к обработчику next, только если
+// задано управление опросом каналов, иначе отвечает 404.
+func (api *API) withFeeds(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if api.feeds == nil {
+			api.WriteJSON(w, "not found", http.StatusNotFound)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// feedsHandler возвращает состояние опроса всех каналов.
+func (api *API) feedsHandler(w http.ResponseWriter, r *http.Request) {
+	api.WriteJSON(w, api.feeds.Feeds(), http.StatusOK)
+}
+
+// pollAllHandler запрашивает внеочередной опрос всех каналов,
+// кроме приостановленных, и не ждет его окончания.
+func (api *API) pollAllHandler(w http.ResponseWriter, r *http.Request) {
+	n := api.feeds.PollAll()
+	api.logger.Printf("[INFO] admin: poll all feeds, polled=%d", n)
+	api.WriteJSON(w, PollAllResponse{Polled: n}, http.StatusAccepted)
+}
+
+// feedActionHandler возвращает обработчик, который выполняет
+// действие action над каналом по id из пути запроса
+// и отвечает состоянием канала с кодом code.
+func (api *API) feedActionHandler(name string, action func(service.FeedController, int) error, code int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			api.WriteJSON(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		if err := action(api.feeds, id); err != nil {
+			if errors.Is(err, rsscollector.ErrUnknownFeed) {
+				api.WriteJSON(w, "not found", http.StatusNotFound)
+				return
+			}
+			api.logger.Printf("[ERROR] admin: %s feed id=%d: %v", name, id, err)
+			api.WriteJSONError(w, ErrInternal, http.StatusInternalServerError)
+			return
+		}
+		api.logger.Printf("[INFO] admin: %s feed id=%d", name, id)
+
+		f, err := api.feeds.Feed(id)
+		if err != nil {
+			api.WriteJSONError(w, ErrInternal, http.StatusInternalServerError)
+			return
+		}
+
+		api.WriteJSON(w, f, code)
+	}
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rtemka/agg/news/pkg/rsscollector"
+)
+
+// testFeeds - управление опросом каналов без опроса.
+type testFeeds struct {
+	feeds []rsscollector.Feed
+	polls map[int]int
+}
+
+func (tf *testFeeds) Feeds() []rsscollector.Feed {
+	return tf.feeds
+}
+
+func (tf *testFeeds) Feed(id int) (rsscollector.Feed, error) {
+	if id < 1 || id > len(tf.feeds) {
+		return rsscollector.Feed{}, rsscollector.ErrUnknownFeed
+	}
+	return tf.feeds[id-1], nil
+}
+
+func (tf *testFeeds) PollNow(id int) error {
+	if _, err := tf.Feed(id); err != nil {
+		return err
+	}
+	tf.polls[id]++
+	return nil
+}
+
+func (tf *testFeeds) PollAll() int {
+	n := 0
+	for _, f := range tf.feeds {
+		if !f.Paused {
+			tf.polls[f.Id]++
+			n++
+		}
+	}
+	return n
+}
+
+func (tf *testFeeds) Pause(id int) error {
+	if _, err := tf.Feed(id); err != nil {
+		return err
+	}
+	tf.feeds[id-1].Paused = true
+	return nil
+}
+
+func (tf *testFeeds) Resume(id int) error {
+	if _, err := tf.Feed(id); err != nil {
+		return err
+	}
+	tf.feeds[id-1].Paused = false
+	return nil
+}
+
+func TestApi_admin(t *testing.T) {
+	const token = "secret"
+
+	feeds := &testFeeds{
+		feeds: []rsscollector.Feed{{Id: 1, URL: "https://test.com/rss"}, {Id: 2, URL: "https://test.com/atom"}},
+		polls: make(map[int]int),
+	}
+	api := New(testDB(t, 1), log.New(io.Discard, "", 0))
+
+	serve := func(method, path, auth string) *http.Response {
+		req := httptest.NewRequest(method, path, nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rr := httptest.NewRecorder()
+		api.r.ServeHTTP(rr, req)
+		return rr.Result()
+	}
+
+	// без токена административное API выключено
+	if resp := serve(http.MethodGet, "/admin/feeds", "Bearer "); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("GET /admin/feeds got response code = %d, want = %d", resp.StatusCode, http.StatusNotFound)
+	}
+
+	api.WithAdmin(feeds, token)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		auth       string
+		wantCode   int
+		wantPaused bool
+	}{
+		{name: "no_token", method: http.MethodPost, path: "/admin/feeds/1/pause", wantCode: http.StatusUnauthorized},
+		{name: "bad_token", method: http.MethodPost, path: "/admin/feeds/1/pause", auth: "Bearer secret2",
+			wantCode: http.StatusUnauthorized},
+		{name: "not_bearer", method: http.MethodPost, path: "/admin/feeds/1/pause", auth: "Basic secret",
+			wantCode: http.StatusUnauthorized},
+		{name: "no_scheme", method: http.MethodPost, path: "/admin/feeds/1/pause", auth: token,
+			wantCode: http.StatusUnauthorized},
+		{name: "pause", method: http.MethodPost, path: "/admin/feeds/1/pause", auth: "Bearer " + token,
+			wantCode: http.StatusOK, wantPaused: true},
+		{name: "poll_paused", method: http.MethodPost, path: "/admin/feeds/1/poll", auth: "Bearer " + token,
+			wantCode: http.StatusAccepted, wantPaused: true},
+		{name: "unknown_feed", method: http.MethodPost, path: "/admin/feeds/3/poll", auth: "Bearer " + token,
+			wantCode: http.StatusNotFound},
+		{name: "bad_id", method: http.MethodPost, path: "/admin/feeds/abc/resume", auth: "Bearer " + token,
+			wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := serve(tt.method, tt.path, tt.auth)
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("%s %s got response code = %d, want = %d", tt.method, tt.path, resp.StatusCode, tt.wantCode)
+			}
+			if tt.wantCode == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") == "" {
+				t.Errorf("%s %s got no WWW-Authenticate header", tt.method, tt.path)
+			}
+			if tt.wantCode >= http.StatusBadRequest {
+				return
+			}
+
+			var f rsscollector.Feed
+			if err := json.NewDecoder(resp.Body).Decode(&f); err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if f.Id != 1 || f.Paused != tt.wantPaused {
+				t.Errorf("%s %s got = %+v, want feed 1 paused = %v", tt.method, tt.path, f, tt.wantPaused)
+			}
+		})
+	}
+
+	// приостановленный канал не опрашивается вместе со всеми
+	resp := serve(http.MethodPost, "/admin/feeds/poll", "Bearer "+token)
+	var pa PollAllResponse
+	if err := json.NewDecoder(resp.Body).Decode(&pa); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if resp.StatusCode != http.StatusAccepted || pa.Polled != 1 || feeds.polls[1] != 1 || feeds.polls[2] != 1 {
+		t.Fatalf("POST /admin/feeds/poll got = %d, %+v, polls = %v, want 1 polled", resp.StatusCode, pa, feeds.polls)
+	}
+
+	if resp = serve(http.MethodPost, "/admin/feeds/1/resume", "Bearer "+token); resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /admin/feeds/1/resume got response code = %d, want = %d", resp.StatusCode, http.StatusOK)
+	}
+
+	resp = serve(http.MethodGet, "/admin/feeds", "Bearer "+token)
+	var list []rsscollector.Feed
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if len(list) != 2 || list[0].Paused {
+		t.Fatalf("GET /admin/feeds got = %+v, want 2 feeds, none paused", list)
+	}
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	_ "embed"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,12 +11,16 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 
 	"strconv"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/rtemka/agg/news/pkg/broadcast"
+	"github.com/rtemka/agg/news/pkg/service"
 	"github.com/rtemka/agg/news/pkg/storage"
+	"github.com/rtemka/agg/openapi"
 )
 
 type stor = storage.Storage
@@ -28,6 +33,12 @@ var (
 	ErrBadInput = errors.New("invalid input")
 )
 
+// specJSON - спецификация API в формате OpenAPI 3,
+// при изменении маршрутов ее нужно обновить.
+//
+//go:embed openapi.json
+var specJSON []byte
+
 type ctxKey int
 
 const (
@@ -36,31 +47,77 @@ const (
 
 // параметр запроса.
 const (
-	pageQP    = "page"
-	excludeQP = "exc"
-	sortByQP  = "sortBy"
-	dateQP    = "date"
-	dateEndQP = "dateEnd"
-	searchQP  = "s"
+	pageQP     = "page"
+	pageSizeQP = "pageSize"
+	excludeQP  = "exc"
+	sortByQP   = "sortBy"
+	dateQP     = "date"
+	dateEndQP  = "dateEnd"
+	searchQP   = "s"
+	matchQP    = "match"
+	sourceQP   = "source"        // id источников через запятую.
+	exclSrcQP  = "excludeSource" // id исключаемых источников через запятую.
+	intervalQP = "interval"      // интервал статистики: day или hour.
+	limitQP    = "limit"         // количество похожих новостей.
+	daysQP     = "days"          // окно поиска похожих новостей в днях.
+	sinceQP    = "since"         // относительный период: 30m, 24h, 7d, 2w.
+	tzQP       = "tz"            // часовой пояс границ дней: Europe/Moscow или +03:00.
+)
+
+// maxRelatedDays - максимальное окно поиска похожих новостей в днях.
+const maxRelatedDays = 365
+
+// statsTTL - сколько хранится в кэше посчитанная статистика.
+const statsTTL = 30 * time.Second
+
+// режим поиска в параметре ?match=.
+const (
+	matchAuto     = "auto"     // полнотекстовый, если ничего не найдено - нечеткий.
+	matchFullText = "fulltext" // только полнотекстовый.
+	matchFuzzy    = "fuzzy"    // только нечеткий.
 )
 
 const (
 	layoutDate = "2006-01-02" // YYYY-MM-DD
+	layoutTZ   = "-07:00"     // смещение часового пояса в ?tz=.
 )
 
+// maxSince - максимальный период в ?since=.
+const maxSince = 100 * 365 * 24 * time.Hour
+
+// StatsResponse - ответ /news/stats.
+type StatsResponse struct {
+	Interval string `json:"interval"` // интервал, по которому сгруппированы Counts.
+	storage.Stats
+}
+
 type Pagination struct {
 	TotalPages  int `json:"total_pages"`
 	PageSize    int `json:"page_size"`
 	CurrentPage int `json:"page_number"`
 	PageData    any `json:"page"`
+	// Match - режим, которым выполнен поиск.
+	Match string `json:"match,omitempty"`
+	// Suggestion - исправленный поисковый запрос,
+	// если по исходному найдено мало.
+	Suggestion string `json:"did_you_mean,omitempty"`
 }
 
 // API приложения.
 type API struct {
-	r         *mux.Router
-	db        stor
-	logger    *log.Logger
-	debugMode bool
+	r          *mux.Router
+	db         stor
+	logger     *log.Logger
+	debugMode  bool
+	stats      *ttlCache[StatsResponse] // статистика по строке запроса.
+	events     *broadcast.Broadcaster   // сигналы о новых новостях для потока.
+	spec       *openapi.Spec            // спецификация, по которой проверяются запросы.
+	webhooks   storage.WebhookStore     // подписки для /webhooks.
+	searches   storage.SavedSearchStore // сохраненные поиски для /searches.
+	feeds      service.FeedController   // управление опросом каналов для /admin.
+	adminToken string                   // токен администратора, без него /admin выключено.
+	closing    chan struct{}            // закрывается при остановке сервера, завершает потоки.
+	closeOnce  sync.Once
 }
 
 // Возвращает новый объект *API
@@ -70,6 +127,9 @@ func New(storage stor, logger *log.Logger) *API {
 		db:        storage,
 		logger:    logger,
 		debugMode: false,
+		stats:     newTTLCache[StatsResponse](statsTTL),
+		spec:      openapi.MustLoad(specJSON),
+		closing:   make(chan struct{}),
 	}
 	api.endpoints()
 	return &api
@@ -92,10 +152,50 @@ func (api *API) endpoints() {
 		api.logRequestMiddleware,
 		api.closerMiddleware,
 		api.headersMiddleware,
+		api.spec.Validator(api.writeValidationError),
 	)
+	// спецификация API
+	api.r.HandleFunc("/openapi.json", api.spec.Handler()).Methods(http.MethodGet, http.MethodOptions)
 	// получить новости
 	api.r.HandleFunc("/news", api.itemsHandler).Methods(http.MethodGet, http.MethodOptions)
+	// статистика по новостям, регистрируется, как и выгрузка, до /news/{id}
+	api.r.HandleFunc("/news/stats", api.statsHandler).Methods(http.MethodGet, http.MethodOptions)
+	// потоковая выгрузка новостей
+	api.r.HandleFunc("/news/export", api.exportHandler).Methods(http.MethodGet, http.MethodOptions)
+	// ленты новостей RSS 2.0 и Atom
+	api.r.HandleFunc("/news.rss", api.feedHandler(feedRSS)).Methods(http.MethodGet, http.MethodOptions)
+	api.r.HandleFunc("/news.atom", api.feedHandler(feedAtom)).Methods(http.MethodGet, http.MethodOptions)
+	// поток новых новостей (Server-Sent Events)
+	api.r.HandleFunc("/news/stream", api.streamHandler).Methods(http.MethodGet, http.MethodOptions)
 	api.r.HandleFunc("/news/{id}", api.itemHandler).Methods(http.MethodGet, http.MethodOptions)
+	api.r.HandleFunc("/news/{id}/related", api.relatedHandler).Methods(http.MethodGet, http.MethodOptions)
+	// подписки на новые новости
+	// подписчикам отправляются запросы на любой адрес, поэтому подписки
+	// управляются только администратором
+	api.r.HandleFunc("/webhooks", api.admin(api.withWebhooks(api.webhooksHandler))).Methods(http.MethodGet, http.MethodOptions)
+	api.r.HandleFunc("/webhooks", api.admin(api.withWebhooks(api.addWebhookHandler))).Methods(http.MethodPost)
+	api.r.HandleFunc("/webhooks/{id}", api.admin(api.withWebhooks(api.webhookHandler))).Methods(http.MethodGet, http.MethodOptions)
+	api.r.HandleFunc("/webhooks/{id}", api.admin(api.withWebhooks(api.deleteWebhookHandler))).Methods(http.MethodDelete)
+	api.r.HandleFunc("/webhooks/{id}/deliveries",
+		api.admin(api.withWebhooks(api.deliveriesHandler))).Methods(http.MethodGet, http.MethodOptions)
+	// сохраненные поиски и их новые новости
+	// владелец поиска не проверяется, поэтому поиски управляются
+	// только администратором (например, шлюзом от имени пользователя)
+	api.r.HandleFunc("/searches", api.admin(api.withSearches(api.savedSearchesHandler))).Methods(http.MethodGet, http.MethodOptions)
+	api.r.HandleFunc("/searches", api.admin(api.withSearches(api.addSavedSearchHandler))).Methods(http.MethodPost)
+	api.r.HandleFunc("/searches/{id}", api.admin(api.withSearches(api.savedSearchHandler))).Methods(http.MethodGet, http.MethodOptions)
+	api.r.HandleFunc("/searches/{id}", api.admin(api.withSearches(api.deleteSavedSearchHandler))).Methods(http.MethodDelete)
+	api.r.HandleFunc("/searches/{id}/new", api.admin(api.withSearches(api.savedSearchNewHandler))).Methods(http.MethodGet, http.MethodOptions)
+	api.r.HandleFunc("/searches/{id}/read", api.admin(api.withSearches(api.markSavedSearchReadHandler))).Methods(http.MethodPost)
+	// управление опросом rss-каналов, только с токеном администратора
+	api.r.HandleFunc("/admin/feeds", api.admin(api.withFeeds(api.feedsHandler))).Methods(http.MethodGet, http.MethodOptions)
+	api.r.HandleFunc("/admin/feeds/poll", api.admin(api.withFeeds(api.pollAllHandler))).Methods(http.MethodPost)
+	api.r.HandleFunc("/admin/feeds/{id}/poll", api.admin(api.withFeeds(
+		api.feedActionHandler("poll", service.FeedController.PollNow, http.StatusAccepted)))).Methods(http.MethodPost)
+	api.r.HandleFunc("/admin/feeds/{id}/pause", api.admin(api.withFeeds(
+		api.feedActionHandler("pause", service.FeedController.Pause, http.StatusOK)))).Methods(http.MethodPost)
+	api.r.HandleFunc("/admin/feeds/{id}/resume", api.admin(api.withFeeds(
+		api.feedActionHandler("resume", service.FeedController.Resume, http.StatusOK)))).Methods(http.MethodPost)
 }
 
 func (api *API) headersMiddleware(next http.Handler) http.Handler {
@@ -104,6 +204,8 @@ func (api *API) headersMiddleware(next http.Handler) http.Handler {
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
+		// обработчики кэшируемых ответов заменяют его своим
+		w.Header().Set("Cache-Control", cacheNone)
 		next.ServeHTTP(w, r)
 	})
 }
@@ -139,6 +241,17 @@ func (api *API) logRequestMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// writeValidationError отвечает на запрос, не прошедший проверку по спецификации,
+// 404 - так же, как обработчики, не нашедшие ресурс.
+func (api *API) writeValidationError(w http.ResponseWriter, err error, code int) {
+	if code == http.StatusNotFound {
+		api.WriteJSON(w, "not found", http.StatusNotFound)
+		return
+	}
+	api.logger.Printf("[ERROR] validate request: %v", err)
+	api.WriteJSONError(w, err, code)
+}
+
 func (api *API) WriteJSONError(w http.ResponseWriter, err error, code int) {
 	w.WriteHeader(code)
 	msg := map[string]string{"error": err.Error()}
@@ -176,56 +289,191 @@ func (api *API) itemHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	api.WriteJSON(w, it, http.StatusOK)
+	api.writeJSONCached(w, r, it, time.Unix(it.PubDate, 0), cacheItem)
 }
 
-// itemsHandler возвращает все новости.
-func (api *API) itemsHandler(w http.ResponseWriter, r *http.Request) {
+// relatedHandler возвращает новости, похожие на новость по id,
+// опубликованные в пределах ?days= дней от нее.
+func (api *API) relatedHandler(w http.ResponseWriter, r *http.Request) {
 
-	f, err := api.parseQP(r.URL)
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
 	if err != nil {
-		api.WriteJSONError(w, err, http.StatusBadRequest)
+		api.WriteJSON(w, "not found", http.StatusNotFound)
 		return
 	}
 
+	params := r.URL.Query()
+
+	limit := storage.RelatedLimit
+	if qp := params.Get(limitQP); qp != "" {
+		limit, err = strconv.Atoi(qp)
+		if err != nil || limit < 1 || limit > storage.MaxRelatedLimit {
+			api.WriteJSONError(w, fmt.Errorf("bad %q parameter: must be: %s=NUM, where NUM is between 1 and %d",
+				limitQP, limitQP, storage.MaxRelatedLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	window := int64(storage.RelatedWindow)
+	if qp := params.Get(daysQP); qp != "" {
+		days, err := strconv.Atoi(qp)
+		if err != nil || days < 1 || days > maxRelatedDays {
+			api.WriteJSONError(w, fmt.Errorf("bad %q parameter: must be: %s=NUM, where NUM is between 1 and %d",
+				daysQP, daysQP, maxRelatedDays), http.StatusBadRequest)
+			return
+		}
+		window = int64(days) * storage.Day.Seconds()
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	total, err := api.db.CountItems(ctx, f)
+	it, err := api.db.Item(ctx, id)
+	if err != nil {
+		if it == (item{}) {
+			api.WriteJSON(w, "not found", http.StatusNotFound)
+			return
+		}
+		api.WriteJSONError(w, ErrInternal, http.StatusInternalServerError)
+		return
+	}
+
+	items, err := api.db.Related(ctx, id, window, limit)
 	if err != nil {
+		api.logger.Printf("[ERROR] related: %v", err)
 		api.WriteJSONError(w, ErrInternal, http.StatusInternalServerError)
 		return
 	}
 
-	items, err := api.db.Items(ctx, f)
+	if len(items) == 0 {
+		api.WriteJSON(w, items, http.StatusNoContent)
+		return
+	}
+
+	api.writeJSONCached(w, r, items, lastPubDate(items), cacheItem)
+}
+
+// itemsHandler возвращает все новости. Параметры ?fields= и ?view=
+// ограничивают поля новостей и длину описания.
+func (api *API) itemsHandler(w http.ResponseWriter, r *http.Request) {
+
+	f, err := api.parseQP(r.URL)
+	if err != nil {
+		api.WriteJSONError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	fields, err := viewQParser(r.URL.Query(), &f)
+	if err != nil {
+		api.WriteJSONError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// в режиме ?match=auto (по умолчанию), если полнотекстовый
+	// поиск ничего не нашел, то пробуем нечеткий
+	auto := r.URL.Query().Get(matchQP) != matchFullText
+	items, total, err := service.Items(ctx, api.db, &f, auto)
 	if err != nil {
 		api.WriteJSONError(w, ErrInternal, http.StatusInternalServerError)
 		return
 	}
 
+	q, _ := f.Query()      // синтаксис уже проверен в parseQP
+	search := q.HasTerms() // одни исключения - не поиск
+
 	p := Pagination{
-		TotalPages: func() int {
-			t := total / storage.PageSize
-			tf := float64(total) / storage.PageSize
-			if tf > float64(t) {
-				return t + 1
-			}
-			return t
-		}(),
-		PageSize:    storage.PageSize,
+		TotalPages:  f.TotalPages(total),
+		PageSize:    f.Limit(),
 		CurrentPage: f.Page,
 		PageData:    items,
 	}
+	if fields != nil {
+		p.PageData = itemViews(items, fields)
+	}
+
+	if search {
+		p.Match = f.Match.String()
+		if f.Match == storage.MatchFuzzy || total == 0 {
+			p.Suggestion = api.suggest(ctx, q.Terms())
+		}
+	}
 
 	if len(items) == 0 {
 		api.WriteJSON(w, p, http.StatusNoContent)
 		return
 	}
 
-	api.WriteJSON(w, p, http.StatusOK)
+	api.writeJSONCached(w, r, p, lastPubDate(items), cacheList)
+}
+
+// statsHandler возвращает сводную статистику по новостям,
+// отобранным теми же параметрами, что и в /news.
+func (api *API) statsHandler(w http.ResponseWriter, r *http.Request) {
+	f, err := api.parseQP(r.URL)
+	if err != nil {
+		api.WriteJSONError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	interval := storage.Day
+	switch v := r.URL.Query().Get(intervalQP); v {
+	case "", storage.Day.String():
+	case storage.Hour.String():
+		interval = storage.Hour
+	default:
+		api.WriteJSONError(w, fmt.Errorf("bad %q parameter, must be either: '%s' or '%s'",
+			intervalQP, storage.Day, storage.Hour), http.StatusBadRequest)
+		return
+	}
+
+	// страница и id запроса на статистику не влияют
+	params := r.URL.Query()
+	for _, p := range []string{pageQP, pageSizeQP, "request-id"} {
+		params.Del(p)
+	}
+	key := params.Encode()
+
+	if resp, ok := api.stats.get(key); ok {
+		api.writeJSONCached(w, r, resp, time.Time{}, cacheList)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stats, err := api.db.Stats(ctx, f, interval)
+	if err != nil {
+		api.logger.Printf("[ERROR] stats: %v", err)
+		api.WriteJSONError(w, ErrInternal, http.StatusInternalServerError)
+		return
+	}
+
+	resp := StatsResponse{Interval: interval.String(), Stats: stats}
+	api.stats.set(key, resp)
+
+	api.writeJSONCached(w, r, resp, time.Time{}, cacheList)
+}
+
+// suggest возвращает исправленный поисковый запрос
+// или пустую строку, если исправлять нечего.
+func (api *API) suggest(ctx context.Context, terms []string) string {
+	sugg, err := api.db.Suggest(ctx, terms)
+	if err != nil {
+		api.logger.Printf("[ERROR] suggest: %v", err)
+		return ""
+	}
+
+	s := strings.Join(sugg, " ")
+	if s == strings.Join(terms, " ") {
+		return ""
+	}
+	return s
 }
 
-// parseQP - парсит параметеры запроса: ?page=NUM.
+// parseQP - парсит параметеры запроса: ?page=NUM&pageSize=NUM.
 // Возвращает фильтр.
 func (api *API) parseQP(u *url.URL) (filter, error) {
 	var (
@@ -245,6 +493,17 @@ func (api *API) parseQP(u *url.URL) (filter, error) {
 		f.Page = 1
 	}
 
+	if qp, ok := params[pageSizeQP]; ok {
+		f.PageSize, err = strconv.Atoi(qp[0])
+		if err != nil || f.PageSize < storage.MinPageSize || f.PageSize > storage.MaxPageSize {
+			api.logger.Printf("[ERROR] parse query param: %q=%q", pageSizeQP, qp[0])
+			return f, fmt.Errorf("bad %q parameter: must be: %s=NUM, where NUM is between %d and %d",
+				pageSizeQP, pageSizeQP, storage.MinPageSize, storage.MaxPageSize)
+		}
+	} else {
+		f.PageSize = storage.PageSize
+	}
+
 	if qp, ok := params[sortByQP]; ok {
 		f.SortBy, err = sortQParser(qp[0])
 		if err != nil {
@@ -253,35 +512,94 @@ func (api *API) parseQP(u *url.URL) (filter, error) {
 		}
 	}
 
+	loc := time.UTC
+	if qp, ok := params[tzQP]; ok {
+		if loc, err = tzQParser(qp[0]); err != nil {
+			api.logger.Printf("[ERROR] parse query param: %v", err)
+			return f, fmt.Errorf("bad %q parameter: must be a time zone name (Europe/Moscow) or an offset (+03:00)", tzQP)
+		}
+	}
+
 	if qp, ok := params[dateQP]; ok {
-		f.Date, err = timeQParser(qp[0], layoutDate)
+		if _, ok := params[sinceQP]; ok {
+			return f, fmt.Errorf("bad %q parameter: you can't use it together with %q", sinceQP, dateQP)
+		}
+		f.Date, f.EndDate, err = timeQParser(qp[0], loc)
 		if err != nil {
 			api.logger.Printf("[ERROR] parse query param: %v", err)
-			return f, fmt.Errorf("bad %q parameter: must be of the form: YYYY-MM-DD", dateQP)
+			return f, fmt.Errorf("bad %q parameter: must be of the form: [gte:|gt:|lte:|lt:]YYYY-MM-DD "+
+				"or [gte:|gt:|lte:|lt:]RFC3339 timestamp", dateQP)
 		}
 	}
 
-	if qp, ok := params[dateEndQP]; ok {
-		f.EndDate, err = timeQParser(qp[0], layoutDate)
+	if qp, ok := params[sinceQP]; ok {
+		d, err := sinceQParser(qp[0])
 		if err != nil {
 			api.logger.Printf("[ERROR] parse query param: %v", err)
-			return f, fmt.Errorf("bad %q parameter: must be of the form: YYYY-MM-DD", dateEndQP)
+			return f, fmt.Errorf("bad %q parameter: must be: %s=NUM(m|h|d|w), e.g. %s=24h or %s=7d",
+				sinceQP, sinceQP, sinceQP, sinceQP)
 		}
-		if strings.Contains(f.EndDate.Operator, ">") || f.EndDate.Operator == "=" {
-			return f, fmt.Errorf("bad %#q parameter: only '<' or '<=' is allowed", dateEndQP)
+		f.Date = timefilter{Value: time.Now().Add(-d).Unix(), Operator: ">="}
+	}
+
+	if qp, ok := params[dateEndQP]; ok {
+		f.EndDate, err = endTimeQParser(qp[0], loc)
+		if err != nil {
+			api.logger.Printf("[ERROR] parse query param: %v", err)
+			return f, fmt.Errorf("bad %q parameter: must be of the form: [lte:|lt:]YYYY-MM-DD "+
+				"or [lte:|lt:]RFC3339 timestamp", dateEndQP)
 		}
-		if f.Date.Value == 0 {
-			return f, fmt.Errorf("bad %q parameter: you can't use it alone without %q,"+
-				"if you want to search by date use just %s=[lte:gte]YYYY-MM-DD", dateEndQP, dateQP, dateQP)
+	}
+
+	if qp, ok := params[matchQP]; ok {
+		switch qp[0] {
+		case matchAuto, matchFullText:
+			f.Match = storage.MatchFullText
+		case matchFuzzy:
+			f.Match = storage.MatchFuzzy
+		default:
+			return f, fmt.Errorf("bad %q parameter, must be either: '%s', '%s' or '%s'",
+				matchQP, matchAuto, matchFullText, matchFuzzy)
 		}
 	}
 
+	if f.Sources, err = idsQParser(params[sourceQP]); err != nil {
+		api.logger.Printf("[ERROR] parse query param: %v", err)
+		return f, fmt.Errorf("bad %q parameter: must be: %s=ID[,ID...]", sourceQP, sourceQP)
+	}
+
+	if f.ExcludeSources, err = idsQParser(params[exclSrcQP]); err != nil {
+		api.logger.Printf("[ERROR] parse query param: %v", err)
+		return f, fmt.Errorf("bad %q parameter: must be: %s=ID[,ID...]", exclSrcQP, exclSrcQP)
+	}
+
 	f.TitleSearch = append(f.TitleSearch, params[searchQP]...)
 	f.Exclude = append(f.Exclude, params[excludeQP]...)
 
+	// проверяем синтаксис поискового запроса
+	if _, err = f.Query(); err != nil {
+		api.logger.Printf("[ERROR] parse query param: %v", err)
+		return f, fmt.Errorf("bad %q or %q parameter: %w", searchQP, excludeQP, err)
+	}
+
 	return f, nil
 }
 
+// idsQParser - парсит id из параметров вида ?source=1,2&source=3
+func idsQParser(qp []string) ([]int64, error) {
+	var ids []int64
+	for _, v := range qp {
+		for _, s := range strings.Split(v, ",") {
+			id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+			if err != nil || id < 1 {
+				return nil, fmt.Errorf("bad id %q", s)
+			}
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
 func sortQParser(s string) (storage.Sort, error) {
 	switch s {
 	case "":
@@ -298,25 +616,126 @@ func sortQParser(s string) (storage.Sort, error) {
 	}
 }
 
-// timeQParser - парсит параметер запроса ?date=[gte:lte:]YYYY-MM-DD
-func timeQParser(qp, layout string) (timefilter, error) {
-	if strings.HasPrefix(qp, "gte:") || strings.HasPrefix(qp, "lte:") {
+// timeQParser - парсит параметр запроса ?date=[gte:|gt:|lte:|lt:]ДАТА, где ДАТА -
+// день YYYY-MM-DD в часовом поясе loc или время в RFC 3339. День без оператора -
+// это весь день, поэтому кроме начала возвращается и конец дня для EndDate.
+// Операторы с днем сравнивают с границами дня: gt:2022-08-01 - со 2 августа.
+func timeQParser(qp string, loc *time.Location) (from, to timefilter, err error) {
+	op, v := splitOperator(qp)
+	t, day, err := parseTime(v, loc)
+	if err != nil {
+		return from, to, err
+	}
 
-		split := strings.SplitN(qp, ":", 2)
-		// ожидаем в виде ?date=[gte:lte:]2012-12-31
-		t, err := time.Parse(layout, split[1])
-		if err != nil {
-			return timefilter{}, err
+	if !day {
+		if op == "" {
+			op = "gte"
 		}
-		return timefilter{Value: t.Unix(), Operator: operator(split[0])}, nil
+		return timefilter{Value: t.Unix(), Operator: operator(op)}, to, nil
+	}
+
+	next := t.AddDate(0, 0, 1) // начало следующего дня с учетом перехода на летнее время
+	switch op {
+	case "":
+		return timefilter{Value: t.Unix(), Operator: ">="}, timefilter{Value: next.Unix(), Operator: "<"}, nil
+	case "gt":
+		return timefilter{Value: next.Unix(), Operator: ">="}, to, nil
+	case "lte":
+		return timefilter{Value: next.Unix(), Operator: "<"}, to, nil
+	default:
+		return timefilter{Value: t.Unix(), Operator: operator(op)}, to, nil
 	}
+}
 
-	t, err := time.Parse(layout, qp) // ожидаем в виде ?date=2012-12-31
+// endTimeQParser - парсит параметр запроса ?dateEnd=[lte:|lt:]ДАТА,
+// день без оператора или с lte: включается в период целиком.
+func endTimeQParser(qp string, loc *time.Location) (timefilter, error) {
+	op, v := splitOperator(qp)
+	if op == "gt" || op == "gte" {
+		return timefilter{}, fmt.Errorf("operator %q is not allowed", op)
+	}
+	t, day, err := parseTime(v, loc)
 	if err != nil {
 		return timefilter{}, err
 	}
 
-	return timefilter{Value: t.Unix(), Operator: "="}, nil
+	switch {
+	case op == "lt":
+		return timefilter{Value: t.Unix(), Operator: "<"}, nil
+	case day:
+		return timefilter{Value: t.AddDate(0, 0, 1).Unix(), Operator: "<"}, nil
+	default:
+		return timefilter{Value: t.Unix(), Operator: "<="}, nil
+	}
+}
+
+// splitOperator отделяет оператор сравнения от даты: gte:2012-12-31.
+func splitOperator(qp string) (op, v string) {
+	if before, after, ok := strings.Cut(qp, ":"); ok {
+		switch before {
+		case "gte", "gt", "lte", "lt":
+			return before, after
+		}
+	}
+	return "", qp
+}
+
+// parseTime парсит день YYYY-MM-DD в часовом поясе loc
+// или время в RFC 3339, day сообщает, что передан день.
+func parseTime(v string, loc *time.Location) (t time.Time, day bool, err error) {
+	if len(v) == len(layoutDate) {
+		t, err = time.ParseInLocation(layoutDate, v, loc)
+		return t, true, err
+	}
+	// "+" смещения в незакодированном URL приходит пробелом
+	t, err = time.Parse(time.RFC3339, strings.Replace(v, " ", "+", 1))
+	return t, false, err
+}
+
+// tzQParser - парсит параметр запроса ?tz=: имя часового пояса
+// из базы IANA (Europe/Moscow, UTC) или смещение (+03:00).
+func tzQParser(s string) (*time.Location, error) {
+	if s == "" || s == "Local" {
+		return nil, fmt.Errorf("bad time zone %q", s)
+	}
+	if s[0] == '+' || s[0] == '-' || s[0] == ' ' {
+		s = strings.Replace(s, " ", "+", 1)
+		t, err := time.Parse(layoutTZ, s)
+		if err != nil {
+			return nil, err
+		}
+		_, offset := t.Zone()
+		return time.FixedZone(s, offset), nil
+	}
+	return time.LoadLocation(s)
+}
+
+// sinceQParser - парсит параметр запроса ?since=NUM(m|h|d|w).
+func sinceQParser(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, fmt.Errorf("empty period")
+	}
+
+	var unit time.Duration
+	switch s[len(s)-1] {
+	case 'm':
+		unit = time.Minute
+	case 'h':
+		unit = time.Hour
+	case 'd':
+		unit = 24 * time.Hour
+	case 'w':
+		unit = 7 * 24 * time.Hour
+	default:
+		return 0, fmt.Errorf("bad period unit in %q", s)
+	}
+
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n < 1 || time.Duration(n) > maxSince/unit {
+		return 0, fmt.Errorf("bad period %q", s)
+	}
+
+	return time.Duration(n) * unit, nil
 }
 
 func operator(o string) string {
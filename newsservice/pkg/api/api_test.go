@@ -1,44 +1,546 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
-
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/rtemka/agg/news/pkg/storage"
 	"github.com/rtemka/agg/news/pkg/storage/memdb"
 )
 
+// sampleItem можно использовать для тестов
+var sampleItem = item{
+	Id:          1,
+	Title:       "sample item",
+	PubDate:     5555555,
+	Description: "sample discription",
+	Link:        "https://test.com",
+}
+
+// testDB возвращает хранилище с n новостями, новость
+// с наибольшим id - самая свежая.
+func testDB(t *testing.T, n int) *memdb.MemDB {
+	db := memdb.New()
+	items := make([]item, 0, n)
+	for i := 1; i <= n; i++ {
+		items = append(items, item{
+			Title:       fmt.Sprintf("новость go %d", i),
+			PubDate:     int64(5555555 + i),
+			Description: "sample discription",
+			Link:        fmt.Sprintf("https://test.com/%d", i),
+		})
+	}
+	if _, err := db.AddItems(context.Background(), items); err != nil {
+		t.Fatalf("AddItems() error = %v", err)
+	}
+	return db
+}
+
+func TestApi_itemHandler(t *testing.T) {
+	db := testDB(t, 3)
+	api := New(db, log.New(io.Discard, "", 0))
+
+	want, err := db.Item(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("Item() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		wantCode int
+	}{
+		{name: "found", path: "/news/2", wantCode: http.StatusOK},
+		{name: "not_found", path: "/news/1000", wantCode: http.StatusNotFound},
+		{name: "bad_id", path: "/news/abc", wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			rr := httptest.NewRecorder()
+
+			api.r.ServeHTTP(rr, req)
+
+			resp := rr.Result()
+
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("Api.itemHandler() got response code = %d, want = %d", resp.StatusCode, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var got item
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatalf("Api.itemHandler() got error = %v", err)
+			}
+			if got != want {
+				t.Errorf("Api.itemHandler() got = %v, want = %v", got, want)
+			}
+		})
+	}
+}
+
 func TestApi_itemsHandler(t *testing.T) {
-	api := New(memdb.New(), log.New(io.Discard, "", 0))
-	wantLen := 10
+	const total = 250
+	db := testDB(t, total)
+	api := New(db, log.New(io.Discard, "", 0))
+
+	newest, err := db.Item(context.Background(), total)
+	if err != nil {
+		t.Fatalf("Item() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		wantLen  int
+	}{
+		{name: "default_page_size", query: "", wantCode: http.StatusOK, wantLen: storage.PageSize},
+		{name: "custom_page_size", query: "?pageSize=20", wantCode: http.StatusOK, wantLen: 20},
+		{name: "max_page_size", query: fmt.Sprintf("?pageSize=%d", storage.MaxPageSize),
+			wantCode: http.StatusOK, wantLen: storage.MaxPageSize},
+		{name: "page_size_too_big", query: fmt.Sprintf("?pageSize=%d", storage.MaxPageSize+1),
+			wantCode: http.StatusBadRequest},
+		{name: "page_size_zero", query: "?pageSize=0", wantCode: http.StatusBadRequest},
+		{name: "page_size_not_a_number", query: "?pageSize=ten", wantCode: http.StatusBadRequest},
+		{name: "search", query: "?s=%22%D0%B1%D0%B0%D0%B7%D0%B0+%D0%B4%D0%B0%D0%BD%D0%BD%D1%8B%D1%85%22+OR+go*",
+			wantCode: http.StatusOK, wantLen: storage.PageSize},
+		{name: "search_unterminated_quote", query: "?s=%22go", wantCode: http.StatusBadRequest},
+		{name: "search_only_exclusions", query: "?s=-go", wantCode: http.StatusBadRequest},
+		{name: "search_exclusion_in_or", query: "?s=go+OR+-rust", wantCode: http.StatusBadRequest},
+		{name: "exclude_only", query: "?exc=go", wantCode: http.StatusNoContent},
+		{name: "exclude_source", query: "?excludeSource=1,2&excludeSource=3",
+			wantCode: http.StatusOK, wantLen: storage.PageSize},
+		{name: "source_not_a_number", query: "?source=kommersant", wantCode: http.StatusBadRequest},
+		{name: "source_empty_id", query: "?source=1,", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/news"+tt.query, nil)
+			rr := httptest.NewRecorder()
+
+			api.r.ServeHTTP(rr, req)
+
+			resp := rr.Result()
+
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("Api.itemsHandler() got response code = %d, want = %d", resp.StatusCode, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var items []item
+			p := Pagination{PageData: &items}
+			if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+				t.Fatalf("Api.itemsHandler() got error = %v", err)
+			}
+
+			if len(items) != tt.wantLen {
+				t.Errorf("Api.itemsHandler() got items = %d, want = %d", len(items), tt.wantLen)
+			}
+
+			if p.PageSize != tt.wantLen {
+				t.Errorf("Api.itemsHandler() got page size = %d, want = %d", p.PageSize, tt.wantLen)
+			}
+
+			if len(items) > 0 && tt.query == "" {
+				if items[0] != newest {
+					t.Errorf("Api.itemsHandler() got items[0] = %v, want = %v", items[0], newest)
+				}
+			}
+		})
+	}
+}
+
+// TestApi_itemsHandler_exclude проверяет, что исключения
+// без поисковых фраз только отсеивают новости.
+func TestApi_itemsHandler_exclude(t *testing.T) {
+	api := New(testDB(t, 3), log.New(io.Discard, "", 0))
+
+	for _, query := range []string{"?exc=1", "?exc=-1", "?exc=1&match=fuzzy"} {
+		t.Run(query, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/news"+query, nil)
+			rr := httptest.NewRecorder()
+			api.r.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Api.itemsHandler() got response code = %d, want = %d", rr.Code, http.StatusOK)
+			}
+
+			var items []item
+			p := Pagination{PageData: &items}
+			if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+				t.Fatalf("Api.itemsHandler() got error = %v", err)
+			}
+
+			// это не поиск: без режима поиска и фрагментов
+			if len(items) != 2 || items[0].Id != 3 || items[1].Id != 2 || p.Match != "" || items[0].TitleSnippet != "" {
+				t.Fatalf("Api.itemsHandler() got = %+v, want news 3 and 2 without search", p)
+			}
+		})
+	}
+}
+
+// fuzzyDB - хранилище, в котором полнотекстовый
+// поиск ничего не находит, а нечеткий находит.
+type fuzzyDB struct {
+	*memdb.MemDB
+}
+
+func (db fuzzyDB) CountItems(_ context.Context, f filter) (int, error) {
+	if f.Match == storage.MatchFuzzy {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (db fuzzyDB) Items(_ context.Context, f filter) ([]item, error) {
+	if f.Match == storage.MatchFuzzy {
+		return []item{sampleItem}, nil
+	}
+	return nil, nil
+}
+
+func (db fuzzyDB) Suggest(_ context.Context, _ []string) ([]string, error) {
+	return []string{"sample"}, nil
+}
+
+func TestApi_itemsHandler_fuzzy(t *testing.T) {
+	api := New(fuzzyDB{memdb.New()}, log.New(io.Discard, "", 0))
 
-	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/news/%d", wantLen), nil)
-	rr := httptest.NewRecorder()
+	tests := []struct {
+		name           string
+		query          string
+		wantCode       int
+		wantMatch      string
+		wantSuggestion string
+	}{
+		{name: "auto_fallback", query: "?s=sampel", wantCode: http.StatusOK,
+			wantMatch: "fuzzy", wantSuggestion: "sample"},
+		{name: "explicit_fuzzy", query: "?s=sampel&match=fuzzy", wantCode: http.StatusOK,
+			wantMatch: "fuzzy", wantSuggestion: "sample"},
+		{name: "fulltext_only", query: "?s=sampel&match=fulltext", wantCode: http.StatusNoContent},
+		{name: "bad_match", query: "?s=sampel&match=exact", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/news"+tt.query, nil)
+			rr := httptest.NewRecorder()
+
+			api.r.ServeHTTP(rr, req)
+
+			resp := rr.Result()
+
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("Api.itemsHandler() got response code = %d, want = %d", resp.StatusCode, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var p Pagination
+			if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+				t.Fatalf("Api.itemsHandler() got error = %v", err)
+			}
+
+			if p.Match != tt.wantMatch {
+				t.Errorf("Api.itemsHandler() got match = %q, want = %q", p.Match, tt.wantMatch)
+			}
+			if p.Suggestion != tt.wantSuggestion {
+				t.Errorf("Api.itemsHandler() got suggestion = %q, want = %q", p.Suggestion, tt.wantSuggestion)
+			}
+		})
+	}
+}
+
+func TestApi_itemsHandler_dates(t *testing.T) {
+	db := memdb.New()
+	_, err := db.AddItems(context.Background(), []item{
+		{Title: "новость 1", PubDate: time.Date(2022, 7, 31, 22, 30, 0, 0, time.UTC).Unix(), Link: "https://test.com/1"},
+		{Title: "новость 2", PubDate: time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC).Unix(), Link: "https://test.com/2"},
+		{Title: "новость 3", PubDate: time.Date(2022, 8, 1, 22, 0, 0, 0, time.UTC).Unix(), Link: "https://test.com/3"},
+		{Title: "новость 4", PubDate: time.Now().Add(-time.Hour).Unix(), Link: "https://test.com/4"},
+	})
+	if err != nil {
+		t.Fatalf("AddItems() error = %v", err)
+	}
+	api := New(db, log.New(io.Discard, "", 0))
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		wantIDs  []int64
+	}{
+		{name: "day", query: "date=2022-08-01", wantCode: http.StatusOK, wantIDs: []int64{3, 2}},
+		{name: "day_tz", query: "date=2022-08-01&tz=Europe/Moscow", wantCode: http.StatusOK, wantIDs: []int64{2, 1}},
+		{name: "day_tz_offset", query: "date=2022-08-01&tz=%2B03:00", wantCode: http.StatusOK, wantIDs: []int64{2, 1}},
+		{name: "day_gt", query: "date=gt:2022-07-31", wantCode: http.StatusOK, wantIDs: []int64{4, 3, 2}},
+		{name: "day_lt", query: "date=lt:2022-08-01", wantCode: http.StatusOK, wantIDs: []int64{1}},
+		{name: "day_lte", query: "date=lte:2022-07-31", wantCode: http.StatusOK, wantIDs: []int64{1}},
+		{name: "days_range", query: "date=2022-07-31&dateEnd=2022-08-01", wantCode: http.StatusOK, wantIDs: []int64{3, 2, 1}},
+		{name: "timestamp", query: "date=2022-08-01T12:00:00Z", wantCode: http.StatusOK, wantIDs: []int64{4, 3, 2}},
+		{name: "timestamp_gt", query: "date=gt:2022-08-01T12:00:00Z", wantCode: http.StatusOK, wantIDs: []int64{4, 3}},
+		// "+" не закодирован и приходит пробелом
+		{name: "timestamp_offset", query: "date=2022-08-01T15:00:00+03:00", wantCode: http.StatusOK, wantIDs: []int64{4, 3, 2}},
+		{name: "end_only", query: "dateEnd=lte:2022-08-01T12:00:00Z", wantCode: http.StatusOK, wantIDs: []int64{2, 1}},
+		{name: "end_lt", query: "date=2022-07-31&dateEnd=lt:2022-08-01T12:00:00Z", wantCode: http.StatusOK, wantIDs: []int64{1}},
+		{name: "since", query: "since=2h", wantCode: http.StatusOK, wantIDs: []int64{4}},
+		{name: "since_weeks", query: "since=2w&dateEnd=2022-08-01", wantCode: http.StatusNoContent},
+		{name: "since_and_date", query: "since=24h&date=2022-08-01", wantCode: http.StatusBadRequest},
+		{name: "since_zero", query: "since=0h", wantCode: http.StatusBadRequest},
+		{name: "since_unit", query: "since=5y", wantCode: http.StatusBadRequest},
+		{name: "end_gte", query: "dateEnd=gte:2022-08-01", wantCode: http.StatusBadRequest},
+		{name: "bad_tz", query: "date=2022-08-01&tz=Mars/Olympus", wantCode: http.StatusBadRequest},
+		{name: "bad_day", query: "date=2022-8-1", wantCode: http.StatusBadRequest},
+	}
 
-	api.r.ServeHTTP(rr, req)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/news?sortBy=date&"+tt.query, nil)
+			rr := httptest.NewRecorder()
 
-	resp := rr.Result()
+			api.r.ServeHTTP(rr, req)
 
-	if resp.StatusCode != http.StatusOK {
-		t.Errorf("Api.itemsHandler() got response code = %d, want = %d", resp.StatusCode, http.StatusOK)
+			resp := rr.Result()
+
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("Api.itemsHandler() got response code = %d, want = %d, body = %s",
+					resp.StatusCode, tt.wantCode, rr.Body)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var items []item
+			p := Pagination{PageData: &items}
+			if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+				t.Fatalf("Api.itemsHandler() got error = %v", err)
+			}
+
+			var ids []int64
+			for _, it := range items {
+				ids = append(ids, it.Id)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("Api.itemsHandler() got ids = %v, want = %v", ids, tt.wantIDs)
+			}
+		})
 	}
-	var items []item
-	if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
-		t.Fatalf("Api.itemsHandler() got error = %v", err)
+}
+
+func TestApi_itemsHandler_fields(t *testing.T) {
+	db := testDB(t, 3)
+	long := strings.Repeat("я", ShortDescription+50)
+	if err := db.AddItem(context.Background(), item{Title: "новость go 4", PubDate: 5555559,
+		Description: long, Link: "https://test.com/4"}); err != nil {
+		t.Fatalf("AddItem() error = %v", err)
 	}
+	api := New(db, log.New(io.Discard, "", 0))
 
-	if len(items) != wantLen {
-		t.Errorf("Api.itemsHandler() got items = %d, want = %d", len(items), wantLen)
+	tests := []struct {
+		name        string
+		query       string
+		wantCode    int
+		wantKeys    []string
+		wantContent int // длина описания первой новости в символах.
+	}{
+		{name: "all", query: "", wantCode: http.StatusOK,
+			wantKeys: []string{"content", "id", "link", "pubTime", "source", "title"}, wantContent: len([]rune(long))},
+		{name: "short", query: "?view=short", wantCode: http.StatusOK,
+			wantKeys: []string{"content", "id", "link", "pubTime", "source", "title"}, wantContent: ShortDescription},
+		{name: "full", query: "?view=full&fields=content", wantCode: http.StatusOK,
+			wantKeys: []string{"content", "id"}, wantContent: len([]rune(long))},
+		{name: "fields", query: "?fields=title,link&fields=source", wantCode: http.StatusOK,
+			wantKeys: []string{"id", "link", "source", "title"}},
+		{name: "id_only", query: "?fields=id", wantCode: http.StatusOK, wantKeys: []string{"id"}},
+		{name: "fields_short", query: "?fields=title,content&view=short", wantCode: http.StatusOK,
+			wantKeys: []string{"content", "id", "title"}, wantContent: ShortDescription},
+		{name: "snippets", query: "?fields=titleSnippet&s=go", wantCode: http.StatusOK,
+			wantKeys: []string{"id", "titleSnippet"}},
+		{name: "unknown_field", query: "?fields=title,author", wantCode: http.StatusBadRequest},
+		{name: "empty_field", query: "?fields=title,", wantCode: http.StatusBadRequest},
+		{name: "bad_view", query: "?view=compact", wantCode: http.StatusBadRequest},
 	}
 
-	if len(items) > 0 {
-		if items[0] != memdb.SampleItem {
-			t.Errorf("Api.itemsHandler() got items[0] = %v, want = %v", items[0], memdb.SampleItem)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/news"+tt.query, nil)
+			rr := httptest.NewRecorder()
+
+			api.r.ServeHTTP(rr, req)
+
+			resp := rr.Result()
+
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("Api.itemsHandler() got response code = %d, want = %d", resp.StatusCode, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			if resp.Header.Get("Last-Modified") == "" {
+				t.Errorf("Api.itemsHandler() got no Last-Modified header")
+			}
+
+			var items []map[string]any
+			p := Pagination{PageData: &items}
+			if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+				t.Fatalf("Api.itemsHandler() got error = %v", err)
+			}
+			if len(items) != 4 {
+				t.Fatalf("Api.itemsHandler() got items = %d, want = 4", len(items))
+			}
+
+			var keys []string
+			for k := range items[0] {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			if !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("Api.itemsHandler() got fields = %v, want = %v", keys, tt.wantKeys)
+			}
+
+			if content, _ := items[0]["content"].(string); len([]rune(content)) != tt.wantContent {
+				t.Errorf("Api.itemsHandler() got content length = %d, want = %d", len([]rune(content)), tt.wantContent)
+			}
+		})
+	}
+}
+
+func TestApi_statsHandler(t *testing.T) {
+	const total = 30
+	db := testDB(t, total)
+	api := New(db, log.New(io.Discard, "", 0))
+
+	get := func(query string) (*http.Response, StatsResponse) {
+		req := httptest.NewRequest(http.MethodGet, "/news/stats"+query, nil)
+		rr := httptest.NewRecorder()
+
+		api.r.ServeHTTP(rr, req)
+
+		var got StatsResponse
+		resp := rr.Result()
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatalf("Api.statsHandler() got error = %v", err)
+			}
+		}
+		return resp, got
+	}
+
+	tests := []struct {
+		name         string
+		query        string
+		wantCode     int
+		wantTotal    int
+		wantInterval string
+	}{
+		{name: "all", query: "", wantCode: http.StatusOK, wantTotal: total, wantInterval: "day"},
+		{name: "by_hour", query: "?interval=hour", wantCode: http.StatusOK, wantTotal: total, wantInterval: "hour"},
+		{name: "search", query: "?s=go+-11", wantCode: http.StatusOK, wantTotal: total - 1, wantInterval: "day"},
+		{name: "bad_interval", query: "?interval=week", wantCode: http.StatusBadRequest},
+		{name: "bad_filter", query: "?source=abc", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, got := get(tt.query)
+
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("Api.statsHandler() got response code = %d, want = %d", resp.StatusCode, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			if got.Total != tt.wantTotal {
+				t.Errorf("Api.statsHandler() got total = %d, want = %d", got.Total, tt.wantTotal)
+			}
+			if got.Interval != tt.wantInterval {
+				t.Errorf("Api.statsHandler() got interval = %q, want = %q", got.Interval, tt.wantInterval)
+			}
+			if len(got.Terms) == 0 || got.Terms[0] != (storage.TermCount{Term: "новость", Count: tt.wantTotal}) {
+				t.Errorf("Api.statsHandler() got terms = %v", got.Terms)
+			}
+		})
+	}
+
+	t.Run("cached", func(t *testing.T) {
+		err := db.AddItem(context.Background(), item{Title: "новость", PubDate: 5555555, Link: "https://test.com/new"})
+		if err != nil {
+			t.Fatalf("AddItem() error = %v", err)
 		}
+
+		// страница на статистику не влияет, поэтому ответ берется из кэша
+		_, got := get("?page=2")
+		if got.Total != total {
+			t.Errorf("Api.statsHandler() got total = %d, want cached = %d", got.Total, total)
+		}
+	})
+}
+
+func TestApi_relatedHandler(t *testing.T) {
+	db := testDB(t, 30)
+	api := New(db, log.New(io.Discard, "", 0))
+
+	tests := []struct {
+		name     string
+		path     string
+		wantCode int
+		wantLen  int
+	}{
+		{name: "default_limit", path: "/news/5/related", wantCode: http.StatusOK, wantLen: storage.RelatedLimit},
+		{name: "limit", path: "/news/5/related?limit=2&days=1", wantCode: http.StatusOK, wantLen: 2},
+		{name: "not_found", path: "/news/1000/related", wantCode: http.StatusNotFound},
+		{name: "bad_limit", path: "/news/5/related?limit=0", wantCode: http.StatusBadRequest},
+		{name: "bad_days", path: "/news/5/related?days=year", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			rr := httptest.NewRecorder()
+
+			api.r.ServeHTTP(rr, req)
+
+			resp := rr.Result()
+
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("Api.relatedHandler() got response code = %d, want = %d", resp.StatusCode, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var items []item
+			if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
+				t.Fatalf("Api.relatedHandler() got error = %v", err)
+			}
+
+			if len(items) != tt.wantLen {
+				t.Errorf("Api.relatedHandler() got items = %d, want = %d", len(items), tt.wantLen)
+			}
+			for _, it := range items {
+				if it.Id == 5 {
+					t.Errorf("Api.relatedHandler() got the item itself")
+				}
+			}
+		})
 	}
 }
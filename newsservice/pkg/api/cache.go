@@ -0,0 +1,62 @@
+package api
+
+import (
+	"sync"
+	"time"
+)
+
+// cacheMaxSize - при превышении размера кэш
+// очищается от устаревших записей.
+const cacheMaxSize = 1024
+
+// ttlCache - кэш, записи которого устаревают через ttl.
+// Безопасен для конкурентного использования.
+type ttlCache[T any] struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cacheEntry[T]
+}
+
+type cacheEntry[T any] struct {
+	val     T
+	expires time.Time
+}
+
+func newTTLCache[T any](ttl time.Duration) *ttlCache[T] {
+	return &ttlCache[T]{ttl: ttl, entries: make(map[string]cacheEntry[T])}
+}
+
+// get возвращает неустаревшее значение по ключу.
+func (c *ttlCache[T]) get(key string) (T, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		var zero T
+		return zero, false
+	}
+	return e.val, true
+}
+
+// set сохраняет значение по ключу.
+func (c *ttlCache[T]) set(key string, val T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if len(c.entries) >= cacheMaxSize {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	// если все записи свежие, то новая запись не вытесняет их,
+	// а просто не сохраняется
+	if len(c.entries) >= cacheMaxSize {
+		return
+	}
+
+	c.entries[key] = cacheEntry[T]{val: val, expires: now.Add(c.ttl)}
+}
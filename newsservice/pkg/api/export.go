@@ -0,0 +1,211 @@
+package api
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// параметр запроса выгрузки.
+const (
+	formatQP = "format" // формат выгрузки: ndjson или csv.
+	afterQP  = "after"  // id последней полученной новости для продолжения выгрузки.
+)
+
+// формат выгрузки в параметре ?format=.
+const (
+	formatNDJSON = "ndjson"
+	formatCSV    = "csv"
+)
+
+// exportStatusTrailer - трейлер ответа с итогом выгрузки:
+// exportComplete, если выгружено все, или exportFailed.
+const (
+	exportStatusTrailer = "X-Export-Status"
+	exportComplete      = "complete"
+	exportFailed        = "error"
+)
+
+// exportFlushEvery - через сколько новостей
+// выгруженное отправляется клиенту.
+const exportFlushEvery = 100
+
+// csvHeader - колонки выгрузки в CSV.
+var csvHeader = []string{"id", "title", "content", "pubTime", "link",
+	"source_id", "source_name", "source_url", "source_feed_url"}
+
+// exportEncoder пишет новости в формате выгрузки.
+type exportEncoder interface {
+	encode(item) error
+	flush() error
+}
+
+type ndjsonEncoder struct {
+	enc *json.Encoder
+}
+
+func (e ndjsonEncoder) encode(it item) error {
+	return e.enc.Encode(it)
+}
+
+func (e ndjsonEncoder) flush() error {
+	return nil
+}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (e csvEncoder) encode(it item) error {
+	return e.w.Write([]string{
+		strconv.FormatInt(it.Id, 10), it.Title, it.Description,
+		strconv.FormatInt(it.PubDate, 10), it.Link,
+		strconv.FormatInt(it.Source.Id, 10), it.Source.Name, it.Source.URL, it.Source.FeedURL,
+	})
+}
+
+func (e csvEncoder) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// exportHandler выгружает потоком все новости, отобранные теми же
+// параметрами, что и в /news, по возрастанию id. Выгрузку можно
+// продолжить с последней полученной новости параметром ?after=ID.
+// Если клиент принимает gzip, то ответ сжимается.
+//
+// Ответ отправляется до окончания выгрузки, поэтому ее итог сообщается
+// трейлером X-Export-Status: complete или error. Если трейлера нет или он
+// не complete, то выгрузка оборвалась, и ее можно продолжить с ?after=ID.
+func (api *API) exportHandler(w http.ResponseWriter, r *http.Request) {
+	f, err := api.parseQP(r.URL)
+	if err != nil {
+		api.WriteJSONError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	params := r.URL.Query()
+
+	if qp := params.Get(afterQP); qp != "" {
+		f.AfterID, err = strconv.ParseInt(qp, 10, 64)
+		if err != nil || f.AfterID < 0 {
+			api.WriteJSONError(w, fmt.Errorf("bad %q parameter: must be: %s=ID", afterQP, afterQP),
+				http.StatusBadRequest)
+			return
+		}
+	}
+
+	format := params.Get(formatQP)
+	switch format {
+	case "":
+		format = formatNDJSON
+	case formatNDJSON, formatCSV:
+	default:
+		api.WriteJSONError(w, fmt.Errorf("bad %q parameter, must be either: '%s' or '%s'",
+			formatQP, formatNDJSON, formatCSV), http.StatusBadRequest)
+		return
+	}
+
+	var out io.Writer = w
+	var gz *gzip.Writer
+	var enc exportEncoder
+	started := false
+
+	// start отправляет заголовки ответа перед первой новостью,
+	// до этого об ошибке еще можно сообщить статусом ответа
+	start := func() error {
+		started = true
+
+		h := w.Header()
+		h.Add("Vary", "Accept-Encoding")
+		h.Set("Trailer", exportStatusTrailer)
+		if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			h.Set("Content-Encoding", "gzip")
+			gz = gzip.NewWriter(w)
+			out = gz
+		}
+
+		if format == formatCSV {
+			h.Set("Content-Type", "text/csv; charset=utf-8")
+			h.Set("Content-Disposition", `attachment; filename="news.csv"`)
+			cw := csv.NewWriter(out)
+			enc = csvEncoder{w: cw}
+			w.WriteHeader(http.StatusOK)
+			return cw.Write(csvHeader)
+		}
+
+		h.Set("Content-Type", "application/x-ndjson")
+		h.Set("Content-Disposition", `attachment; filename="news.ndjson"`)
+		enc = ndjsonEncoder{enc: json.NewEncoder(out)}
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+
+	flush := func() error {
+		if err := enc.flush(); err != nil {
+			return err
+		}
+		if gz != nil {
+			if err := gz.Flush(); err != nil {
+				return err
+			}
+		}
+		if fl, ok := w.(http.Flusher); ok {
+			fl.Flush()
+		}
+		return nil
+	}
+
+	var n int
+	err = api.db.Export(r.Context(), f, func(it item) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+
+		if err := enc.encode(it); err != nil {
+			return err
+		}
+
+		if n++; n%exportFlushEvery == 0 {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		api.logger.Printf("[ERROR] export: items_sent=%d error=%v", n, err)
+		if !started {
+			api.WriteJSONError(w, ErrInternal, http.StatusInternalServerError)
+			return
+		}
+		// сжатый ответ остается незавершенным
+		w.Header().Set(exportStatusTrailer, exportFailed)
+		return
+	}
+
+	if !started {
+		if err := start(); err != nil {
+			api.logger.Printf("[ERROR] export: %v", err)
+			return
+		}
+	}
+	if err := flush(); err != nil {
+		api.logger.Printf("[ERROR] export: %v", err)
+		w.Header().Set(exportStatusTrailer, exportFailed)
+		return
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			api.logger.Printf("[ERROR] export: %v", err)
+			w.Header().Set(exportStatusTrailer, exportFailed)
+			return
+		}
+	}
+	w.Header().Set(exportStatusTrailer, exportComplete)
+}
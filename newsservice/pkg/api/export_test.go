@@ -0,0 +1,191 @@
+package api
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/rtemka/agg/news/pkg/storage/memdb"
+)
+
+func TestApi_exportHandler(t *testing.T) {
+	const total = 250
+	db := testDB(t, total)
+	api := New(db, log.New(io.Discard, "", 0))
+
+	tests := []struct {
+		name      string
+		query     string
+		gzip      bool
+		wantCode  int
+		wantType  string
+		wantFirst int64
+		wantLen   int
+	}{
+		{name: "ndjson", query: "", wantCode: http.StatusOK,
+			wantType: "application/x-ndjson", wantFirst: 1, wantLen: total},
+		{name: "resume", query: "?after=200", wantCode: http.StatusOK,
+			wantType: "application/x-ndjson", wantFirst: 201, wantLen: 50},
+		{name: "gzip", query: "?format=ndjson", gzip: true, wantCode: http.StatusOK,
+			wantType: "application/x-ndjson", wantFirst: 1, wantLen: total},
+		{name: "csv", query: "?format=csv&s=-12+go", wantCode: http.StatusOK,
+			wantType: "text/csv; charset=utf-8", wantFirst: 1, wantLen: total - 1},
+		{name: "empty", query: "?after=1000", wantCode: http.StatusOK,
+			wantType: "application/x-ndjson", wantLen: 0},
+		{name: "bad_format", query: "?format=xml", wantCode: http.StatusBadRequest},
+		{name: "bad_after", query: "?after=-1", wantCode: http.StatusBadRequest},
+		{name: "bad_filter", query: "?pageSize=ten", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/news/export"+tt.query, nil)
+			if tt.gzip {
+				req.Header.Set("Accept-Encoding", "gzip")
+			}
+			rr := httptest.NewRecorder()
+
+			api.r.ServeHTTP(rr, req)
+
+			resp := rr.Result()
+
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("Api.exportHandler() got response code = %d, want = %d", resp.StatusCode, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			if ct := resp.Header.Get("Content-Type"); ct != tt.wantType {
+				t.Fatalf("Api.exportHandler() got Content-Type = %q, want = %q", ct, tt.wantType)
+			}
+
+			var body io.Reader = resp.Body
+			if tt.gzip {
+				if ce := resp.Header.Get("Content-Encoding"); ce != "gzip" {
+					t.Fatalf("Api.exportHandler() got Content-Encoding = %q, want = gzip", ce)
+				}
+				gz, err := gzip.NewReader(resp.Body)
+				if err != nil {
+					t.Fatalf("gzip.NewReader() error = %v", err)
+				}
+				body = gz
+			}
+
+			var ids []int64
+			if tt.wantType == "text/csv; charset=utf-8" {
+				ids = csvIDs(t, body)
+			} else {
+				ids = ndjsonIDs(t, body)
+			}
+
+			if len(ids) != tt.wantLen {
+				t.Fatalf("Api.exportHandler() got items = %d, want = %d", len(ids), tt.wantLen)
+			}
+			if len(ids) > 0 && ids[0] != tt.wantFirst {
+				t.Fatalf("Api.exportHandler() got first id = %d, want = %d", ids[0], tt.wantFirst)
+			}
+			if st := resp.Trailer.Get(exportStatusTrailer); st != exportComplete {
+				t.Fatalf("Api.exportHandler() got %s = %q, want = %q", exportStatusTrailer, st, exportComplete)
+			}
+		})
+	}
+}
+
+// brokenExportDB - хранилище, выгрузка из которого
+// обрывается после after новостей.
+type brokenExportDB struct {
+	*memdb.MemDB
+	after int
+}
+
+func (db brokenExportDB) Export(ctx context.Context, f filter, fn func(item) error) error {
+	n := 0
+	err := db.MemDB.Export(ctx, f, func(it item) error {
+		if n == db.after {
+			return errors.New("connection lost")
+		}
+		n++
+		return fn(it)
+	})
+	if err != nil {
+		return err
+	}
+	return errors.New("connection lost")
+}
+
+func TestApi_exportHandler_broken(t *testing.T) {
+	tests := []struct {
+		name        string
+		after       int
+		wantCode    int
+		wantTrailer string
+	}{
+		{name: "before_start", after: 0, wantCode: http.StatusInternalServerError},
+		{name: "midway", after: 150, wantCode: http.StatusOK, wantTrailer: exportFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := New(brokenExportDB{MemDB: testDB(t, 250), after: tt.after}, log.New(io.Discard, "", 0))
+
+			req := httptest.NewRequest(http.MethodGet, "/news/export", nil)
+			rr := httptest.NewRecorder()
+
+			api.r.ServeHTTP(rr, req)
+
+			resp := rr.Result()
+
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("Api.exportHandler() got response code = %d, want = %d", resp.StatusCode, tt.wantCode)
+			}
+			if st := resp.Trailer.Get(exportStatusTrailer); st != tt.wantTrailer {
+				t.Fatalf("Api.exportHandler() got %s = %q, want = %q", exportStatusTrailer, st, tt.wantTrailer)
+			}
+		})
+	}
+}
+
+// ndjsonIDs читает id новостей из выгрузки в NDJSON.
+func ndjsonIDs(t *testing.T, r io.Reader) []int64 {
+	var ids []int64
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		var it item
+		if err := json.Unmarshal(sc.Bytes(), &it); err != nil {
+			t.Fatalf("Api.exportHandler() got bad line %q: %v", sc.Text(), err)
+		}
+		ids = append(ids, it.Id)
+	}
+	return ids
+}
+
+// csvIDs читает id новостей из выгрузки в CSV.
+func csvIDs(t *testing.T, r io.Reader) []int64 {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		t.Fatalf("Api.exportHandler() got bad csv: %v", err)
+	}
+	if len(records) == 0 || records[0][0] != csvHeader[0] {
+		t.Fatalf("Api.exportHandler() got no csv header")
+	}
+
+	var ids []int64
+	for _, rec := range records[1:] {
+		id, err := strconv.ParseInt(rec[0], 10, 64)
+		if err != nil {
+			t.Fatalf("Api.exportHandler() got bad id %q", rec[0])
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
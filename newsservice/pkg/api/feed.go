@@ -0,0 +1,243 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// формат ленты новостей.
+const (
+	feedRSS  = "rss"
+	feedAtom = "atom"
+)
+
+// feedTitle - заголовок ленты, к нему добавляется поисковый запрос.
+const feedTitle = "Агрегатор новостей"
+
+// feedContentType - тип ответа по формату ленты.
+var feedContentType = map[string]string{
+	feedRSS:  "application/rss+xml; charset=utf-8",
+	feedAtom: "application/atom+xml; charset=utf-8",
+}
+
+// rssFeed - лента в формате RSS 2.0.
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Self          atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string     `xml:"title"`
+	Link        string     `xml:"link"`
+	Description string     `xml:"description"`
+	GUID        rssGUID    `xml:"guid"`
+	PubDate     string     `xml:"pubDate"`
+	Source      *rssSource `xml:"source,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// rssSource - rss-канал, из которого получена новость.
+type rssSource struct {
+	URL  string `xml:"url,attr"`
+	Name string `xml:",chardata"`
+}
+
+// atomFeed - лента в формате Atom (RFC 4287).
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Link    atomLink    `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title     string      `xml:"title"`
+	ID        string      `xml:"id"`
+	Updated   string      `xml:"updated"`
+	Published string      `xml:"published"`
+	Link      atomLink    `xml:"link"`
+	Author    *atomAuthor `xml:"author,omitempty"`
+	Content   atomText    `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// feedHandler возвращает обработчик ленты новостей в формате format,
+// отобранных теми же параметрами, что и в /news, так что на сохраненный
+// поиск можно подписаться в любой программе чтения лент.
+// Дата обновления ленты - дата самой свежей новости в ней. Поддерживаются
+// условные запросы: If-None-Match по ETag ленты и If-Modified-Since.
+func (api *API) feedHandler(format string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f, err := api.parseQP(r.URL)
+		if err != nil {
+			api.WriteJSONError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		items, err := api.db.Items(ctx, f)
+		if err != nil {
+			api.logger.Printf("[ERROR] feed: %v", err)
+			api.WriteJSONError(w, ErrInternal, http.StatusInternalServerError)
+			return
+		}
+
+		updated := lastPubDate(items)
+
+		title := feedTitle
+		if len(f.TitleSearch) > 0 {
+			title += ": " + strings.Join(f.TitleSearch, " ")
+		}
+		self := feedURL(r)
+
+		var feed any
+		switch format {
+		case feedAtom:
+			feed = atomFeedOf(title, self, updated, items)
+		default:
+			feed = rssFeedOf(title, self, updated, items)
+		}
+
+		var buf bytes.Buffer
+		buf.WriteString(xml.Header)
+		if err := xml.NewEncoder(&buf).Encode(feed); err != nil {
+			api.logger.Printf("[ERROR] feed: %v", err)
+			api.WriteJSONError(w, ErrInternal, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", feedContentType[format])
+		api.writeCached(w, r, buf.Bytes(), updated, cacheFeed)
+	}
+}
+
+// rssFeedOf собирает ленту RSS 2.0.
+func rssFeedOf(title, self string, updated time.Time, items []item) rssFeed {
+	feed := rssFeed{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:       title,
+			Link:        self,
+			Description: "Новости из rss-каналов агрегатора",
+			Self:        atomLink{Href: self, Rel: "self", Type: feedContentType[feedRSS]},
+			Items:       make([]rssItem, 0, len(items)),
+		},
+	}
+	if !updated.IsZero() {
+		feed.Channel.LastBuildDate = updated.UTC().Format(time.RFC1123Z)
+	}
+
+	for _, it := range items {
+		ri := rssItem{
+			Title:       it.Title,
+			Link:        it.Link,
+			Description: it.Description,
+			GUID:        rssGUID{IsPermaLink: true, Value: it.Link},
+			PubDate:     time.Unix(it.PubDate, 0).UTC().Format(time.RFC1123Z),
+		}
+		if it.Source.FeedURL != "" {
+			ri.Source = &rssSource{URL: it.Source.FeedURL, Name: it.Source.Name}
+		}
+		feed.Channel.Items = append(feed.Channel.Items, ri)
+	}
+
+	return feed
+}
+
+// atomFeedOf собирает ленту Atom. В пустой ленте датой
+// обновления указывается начало эпохи UNIX: поле обязательное.
+func atomFeedOf(title, self string, updated time.Time, items []item) atomFeed {
+	if updated.IsZero() {
+		updated = time.Unix(0, 0)
+	}
+
+	feed := atomFeed{
+		Title:   title,
+		ID:      self,
+		Updated: updated.UTC().Format(time.RFC3339),
+		Author:  atomAuthor{Name: feedTitle},
+		Link:    atomLink{Href: self, Rel: "self", Type: feedContentType[feedAtom]},
+		Entries: make([]atomEntry, 0, len(items)),
+	}
+
+	for _, it := range items {
+		pub := time.Unix(it.PubDate, 0).UTC().Format(time.RFC3339)
+		e := atomEntry{
+			Title:     it.Title,
+			ID:        it.Link,
+			Updated:   pub,
+			Published: pub,
+			Link:      atomLink{Href: it.Link, Rel: "alternate"},
+			Content:   atomText{Type: "html", Body: it.Description},
+		}
+		if it.Source.Name != "" {
+			e.Author = &atomAuthor{Name: it.Source.Name, URI: it.Source.URL}
+		}
+		feed.Entries = append(feed.Entries, e)
+	}
+
+	return feed
+}
+
+// feedURL возвращает адрес ленты, по которому ее запросил клиент,
+// с учетом заголовков X-Forwarded-*, которые ставит шлюз.
+func feedURL(r *http.Request) string {
+	u := url.URL{Scheme: "http", Host: r.Host, Path: r.URL.Path}
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+	if p := r.Header.Get("X-Forwarded-Proto"); p != "" {
+		u.Scheme = p
+	}
+	if h := r.Header.Get("X-Forwarded-Host"); h != "" {
+		u.Host = h
+	}
+
+	// id запроса у каждого запроса свой
+	q := r.URL.Query()
+	q.Del("request-id")
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestApi_feedHandler(t *testing.T) {
+	api := New(testDB(t, 3), log.New(io.Discard, "", 0))
+
+	serve := func(path string, header http.Header) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rr := httptest.NewRecorder()
+		api.r.ServeHTTP(rr, req)
+		return rr.Result()
+	}
+
+	// новости 5555556 - 5555558, самая свежая - 3-я
+	lastMod := time.Unix(5555558, 0).UTC()
+
+	t.Run("rss", func(t *testing.T) {
+		resp := serve("/news.rss?s=go&request-id=1", nil)
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != feedContentType[feedRSS] {
+			t.Fatalf("Api.feedHandler() got response code = %d, content type = %q",
+				resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(resp.Body)
+		var feed rssFeed
+		if err := xml.Unmarshal(body, &feed); err != nil {
+			t.Fatalf("Decode() error = %v", err)
+		}
+		ch := feed.Channel
+		if feed.Version != "2.0" || ch.Title != feedTitle+": go" || len(ch.Items) != 3 {
+			t.Fatalf("Api.feedHandler() got feed = %+v", feed)
+		}
+		// id запроса в адрес ленты не попадает
+		for _, want := range []string{
+			`<link>http://example.com/news.rss?s=go</link>`,
+			`<atom:link href="http://example.com/news.rss?s=go" rel="self"`,
+		} {
+			if !bytes.Contains(body, []byte(want)) {
+				t.Errorf("Api.feedHandler() got body = %s, want it to contain %s", body, want)
+			}
+		}
+		if ch.LastBuildDate != lastMod.Format(time.RFC1123Z) {
+			t.Errorf("Api.feedHandler() got lastBuildDate = %q, want = %q",
+				ch.LastBuildDate, lastMod.Format(time.RFC1123Z))
+		}
+		if it := ch.Items[0]; it.Link != "https://test.com/3" || it.GUID.Value != it.Link {
+			t.Errorf("Api.feedHandler() got first item = %+v", it)
+		}
+		if got := resp.Header.Get("Last-Modified"); got != lastMod.Format(http.TimeFormat) {
+			t.Errorf("Api.feedHandler() got Last-Modified = %q", got)
+		}
+	})
+
+	t.Run("atom", func(t *testing.T) {
+		resp := serve("/news.atom", nil)
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != feedContentType[feedAtom] {
+			t.Fatalf("Api.feedHandler() got response code = %d, content type = %q",
+				resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		var feed atomFeed
+		if err := xml.NewDecoder(resp.Body).Decode(&feed); err != nil {
+			t.Fatalf("Decode() error = %v", err)
+		}
+		if feed.Updated != lastMod.Format(time.RFC3339) || len(feed.Entries) != 3 || feed.ID == "" {
+			t.Fatalf("Api.feedHandler() got feed = %+v", feed)
+		}
+		if e := feed.Entries[0]; e.ID != "https://test.com/3" || e.Content.Type != "html" {
+			t.Errorf("Api.feedHandler() got first entry = %+v", e)
+		}
+	})
+
+	t.Run("conditional_get", func(t *testing.T) {
+		etag := serve("/news.rss", nil).Header.Get("ETag")
+		if etag == "" {
+			t.Fatalf("Api.feedHandler() got no ETag")
+		}
+
+		tests := []struct {
+			name     string
+			header   http.Header
+			wantCode int
+		}{
+			{name: "etag", header: http.Header{"If-None-Match": {`"x", ` + etag}}, wantCode: http.StatusNotModified},
+			{name: "etag_changed", header: http.Header{"If-None-Match": {`"x"`}}, wantCode: http.StatusOK},
+			{name: "modified_since", header: http.Header{"If-Modified-Since": {lastMod.Format(http.TimeFormat)}},
+				wantCode: http.StatusNotModified},
+			{name: "modified", header: http.Header{"If-Modified-Since": {lastMod.Add(-time.Second).Format(http.TimeFormat)}},
+				wantCode: http.StatusOK},
+			// If-None-Match важнее If-Modified-Since
+			{name: "etag_over_date", header: http.Header{"If-None-Match": {`"x"`},
+				"If-Modified-Since": {lastMod.Format(http.TimeFormat)}}, wantCode: http.StatusOK},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				resp := serve("/news.rss", tt.header)
+				if resp.StatusCode != tt.wantCode {
+					t.Fatalf("Api.feedHandler() got response code = %d, want = %d", resp.StatusCode, tt.wantCode)
+				}
+			})
+		}
+	})
+
+	t.Run("bad_request", func(t *testing.T) {
+		if resp := serve("/news.atom?pageSize=0", nil); resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("Api.feedHandler() got response code = %d, want = %d", resp.StatusCode, http.StatusBadRequest)
+		}
+	})
+}
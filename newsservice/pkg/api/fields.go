@@ -0,0 +1,122 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/rtemka/agg/news/pkg/storage"
+)
+
+// параметр запроса списка новостей.
+const (
+	fieldsQP = "fields" // поля новостей через запятую.
+	viewQP   = "view"   // вид списка: short или full.
+)
+
+// вид списка новостей в параметре ?view=.
+const (
+	viewFull  = "full"  // новости целиком.
+	viewShort = "short" // описание обрезано до ShortDescription символов.
+)
+
+// ShortDescription - длина описания новости в символах при ?view=short.
+const ShortDescription = 200
+
+// itemFields - поля новости по именам в JSON, которые можно
+// запросить в ?fields=, и поля для их выборки из БД.
+var itemFields = map[string]storage.Fields{
+	"id":             0, // выбирается всегда.
+	"title":          storage.FieldTitle,
+	"pubTime":        storage.FieldPubDate,
+	"content":        storage.FieldDescription,
+	"link":           storage.FieldLink,
+	"titleSnippet":   storage.FieldSnippets,
+	"contentSnippet": storage.FieldSnippets,
+	"source":         storage.FieldSource,
+}
+
+// fieldNames - имена полей из itemFields для сообщения об ошибке.
+const fieldNames = "id, title, pubTime, content, link, titleSnippet, contentSnippet, source"
+
+// viewQParser - парсит параметры запроса ?fields=title,link&view=short
+// и задает в фильтре поля новостей и длину описания. Возвращает
+// запрошенные поля по именам в JSON, если все поля - nil.
+func viewQParser(params url.Values, f *filter) (map[string]bool, error) {
+	if qp, ok := params[viewQP]; ok {
+		switch qp[0] {
+		case viewFull:
+		case viewShort:
+			f.DescriptionLimit = ShortDescription
+		default:
+			return nil, fmt.Errorf("bad %q parameter, must be either: '%s' or '%s'", viewQP, viewShort, viewFull)
+		}
+	}
+
+	qp, ok := params[fieldsQP]
+	if !ok {
+		return nil, nil
+	}
+
+	names := make(map[string]bool)
+	for _, v := range qp {
+		for _, name := range strings.Split(v, ",") {
+			fs, ok := itemFields[name]
+			if !ok {
+				return nil, fmt.Errorf("bad %q parameter: unknown field %q, must be one of: %s",
+					fieldsQP, name, fieldNames)
+			}
+			names[name] = true
+			f.Fields |= fs
+		}
+	}
+	// дата публикации нужна для Last-Modified, даже если ее не запросили
+	f.Fields |= storage.FieldPubDate
+
+	return names, nil
+}
+
+// itemView - новость в ответе только с запрошенными полями,
+// поля в том же порядке, что и у новости целиком.
+type itemView struct {
+	Id             int64           `json:"id"`
+	Title          *string         `json:"title,omitempty"`
+	PubDate        *int64          `json:"pubTime,omitempty"`
+	Description    *string         `json:"content,omitempty"`
+	Link           *string         `json:"link,omitempty"`
+	TitleSnippet   *string         `json:"titleSnippet,omitempty"`
+	ContentSnippet *string         `json:"contentSnippet,omitempty"`
+	Source         *storage.Source `json:"source,omitempty"`
+}
+
+// itemViews возвращает новости только с полями names.
+// Фрагменты, как и у новости целиком, выводятся, только если они есть.
+func itemViews(items []item, names map[string]bool) []itemView {
+	views := make([]itemView, len(items))
+	for i := range items {
+		it, v := &items[i], &views[i]
+		v.Id = it.Id
+		if names["title"] {
+			v.Title = &it.Title
+		}
+		if names["pubTime"] {
+			v.PubDate = &it.PubDate
+		}
+		if names["content"] {
+			v.Description = &it.Description
+		}
+		if names["link"] {
+			v.Link = &it.Link
+		}
+		if names["titleSnippet"] && it.TitleSnippet != "" {
+			v.TitleSnippet = &it.TitleSnippet
+		}
+		if names["contentSnippet"] && it.ContentSnippet != "" {
+			v.ContentSnippet = &it.ContentSnippet
+		}
+		if names["source"] {
+			v.Source = &it.Source
+		}
+	}
+	return views
+}
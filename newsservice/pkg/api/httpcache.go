@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Cache-Control ответов по маршрутам. Ответы с ошибками, выгрузка,
+// поток и подписки не кэшируются (no-store задается в headersMiddleware).
+const (
+	// список новостей и статистика меняются с каждой записью в БД.
+	cacheList = "public, max-age=30"
+	// новость и похожие на нее меняются редко.
+	cacheItem = "public, max-age=300"
+	// программы чтения лент сами опрашивают их не чаще раза в несколько минут.
+	cacheFeed = "public, max-age=300"
+	// cacheNone - ответ не кэшируется.
+	cacheNone = "no-store"
+)
+
+// writeJSONCached пишет data в JSON, как WriteJSON со статусом 200,
+// но с заголовками кэширования, см. writeCached.
+func (api *API) writeJSONCached(w http.ResponseWriter, r *http.Request, data any, modified time.Time, cacheControl string) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(data); err != nil {
+		api.logger.Printf("[ERROR] encode response: %v", err)
+		api.WriteJSONError(w, ErrInternal, http.StatusInternalServerError)
+		return
+	}
+	api.writeCached(w, r, buf.Bytes(), modified, cacheControl)
+}
+
+// writeCached пишет тело ответа со статусом 200 и заголовками ETag (по
+// содержимому ответа), Last-Modified (если modified задано) и Cache-Control.
+// Если у клиента актуальная версия ответа, то отвечает 304 без тела.
+func (api *API) writeCached(w http.ResponseWriter, r *http.Request, body []byte, modified time.Time, cacheControl string) {
+	etag := bodyETag(body)
+
+	h := w.Header()
+	h.Set("ETag", etag)
+	h.Set("Cache-Control", cacheControl)
+	if !modified.IsZero() {
+		h.Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(r, etag, modified) {
+		h.Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// bodyETag возвращает сильный ETag тела ответа.
+func bodyETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// lastPubDate возвращает дату самой свежей из новостей,
+// нулевое время - новостей нет.
+func lastPubDate(items []item) time.Time {
+	var t time.Time
+	for _, it := range items {
+		if pub := time.Unix(it.PubDate, 0); pub.After(t) {
+			t = pub
+		}
+	}
+	return t
+}
+
+// notModified сообщает, что у клиента актуальная версия ответа:
+// If-None-Match совпадает с etag, а без него If-Modified-Since
+// не раньше modified (RFC 9110, раздел 13.2.2).
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || modified.IsZero() {
+		return false
+	}
+	return !modified.Truncate(time.Second).After(ims)
+}
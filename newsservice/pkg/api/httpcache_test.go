@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestApi_httpCache(t *testing.T) {
+	db := testDB(t, 3)
+	api := New(db, log.New(io.Discard, "", 0))
+
+	serve := func(path string, header http.Header) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rr := httptest.NewRecorder()
+		api.r.ServeHTTP(rr, req)
+		return rr.Result()
+	}
+
+	tests := []struct {
+		name         string
+		path         string
+		wantCache    string
+		wantModified time.Time
+	}{
+		{name: "news", path: "/news", wantCache: cacheList, wantModified: time.Unix(5555558, 0)},
+		{name: "item", path: "/news/2", wantCache: cacheItem, wantModified: time.Unix(5555557, 0)},
+		{name: "related", path: "/news/2/related", wantCache: cacheItem, wantModified: time.Unix(5555558, 0)},
+		{name: "stats", path: "/news/stats", wantCache: cacheList},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := serve(tt.path, nil)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("GET %s got response code = %d, want = %d", tt.path, resp.StatusCode, http.StatusOK)
+			}
+			etag := resp.Header.Get("ETag")
+			if len(etag) < 3 || etag[0] != '"' {
+				t.Fatalf("GET %s got ETag = %q, want strong ETag", tt.path, etag)
+			}
+			if got := resp.Header.Get("Cache-Control"); got != tt.wantCache {
+				t.Errorf("GET %s got Cache-Control = %q, want = %q", tt.path, got, tt.wantCache)
+			}
+			wantModified := ""
+			if !tt.wantModified.IsZero() {
+				wantModified = tt.wantModified.UTC().Format(http.TimeFormat)
+			}
+			if got := resp.Header.Get("Last-Modified"); got != wantModified {
+				t.Errorf("GET %s got Last-Modified = %q, want = %q", tt.path, got, wantModified)
+			}
+
+			// повторный запрос с тем же ETag
+			resp = serve(tt.path, http.Header{"If-None-Match": {etag}})
+			if resp.StatusCode != http.StatusNotModified {
+				t.Fatalf("GET %s with If-None-Match got response code = %d, want = %d",
+					tt.path, resp.StatusCode, http.StatusNotModified)
+			}
+			if b, _ := io.ReadAll(resp.Body); len(b) != 0 || resp.Header.Get("ETag") != etag {
+				t.Errorf("GET %s with If-None-Match got body = %q, ETag = %q", tt.path, b, resp.Header.Get("ETag"))
+			}
+		})
+	}
+
+	// ETag меняется вместе с ответом
+	etag := serve("/news", nil).Header.Get("ETag")
+	if _, err := db.AddItems(context.Background(), []item{{Title: "новость 4", Link: "https://test.com/4", PubDate: 1}}); err != nil {
+		t.Fatalf("AddItems() error = %v", err)
+	}
+	if resp := serve("/news", http.Header{"If-None-Match": {etag}}); resp.StatusCode != http.StatusOK ||
+		resp.Header.Get("ETag") == etag {
+		t.Fatalf("GET /news after AddItems() got response code = %d, ETag = %q", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+
+	// ошибки и некэшируемые ответы
+	for _, path := range []string{"/news/100", "/news?pageSize=0", "/webhooks", "/news/export"} {
+		if got := serve(path, nil).Header.Get("Cache-Control"); got != cacheNone {
+			t.Errorf("GET %s got Cache-Control = %q, want = %q", path, got, cacheNone)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rtemka/agg/openapi"
+)
+
+// TestApi_openapi_routes проверяет, что маршруты API совпадают со спецификацией.
+func TestApi_openapi_routes(t *testing.T) {
+	api := New(testDB(t, 1), log.New(io.Discard, "", 0))
+
+	if err := api.spec.CheckRouter(api.r); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApi_openapi(t *testing.T) {
+	db := testDB(t, 3)
+	api := New(db, log.New(io.Discard, "", 0)).WithWebhooks(db).WithAdmin(nil, "secret")
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		rr := httptest.NewRecorder()
+		api.r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve(http.MethodGet, "/openapi.json", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json got response code = %d, want = %d", rr.Code, http.StatusOK)
+	}
+	if _, err := openapi.Load(rr.Body.Bytes()); err != nil {
+		t.Fatalf("GET /openapi.json got invalid spec: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		body      string
+		wantCode  int
+		wantError string
+	}{
+		{name: "bad_sort", method: http.MethodGet, path: "/news?sortBy=author",
+			wantCode: http.StatusBadRequest, wantError: `bad "sortBy" parameter: must be one of: 'date', 'title', 'match'`},
+		{name: "bad_date", method: http.MethodGet, path: "/news/stats?date=2022-1-1",
+			wantCode: http.StatusBadRequest, wantError: `bad "date" parameter`},
+		{name: "bad_format", method: http.MethodGet, path: "/news/export?format=xml",
+			wantCode: http.StatusBadRequest, wantError: `bad "format" parameter`},
+		{name: "bad_header", method: http.MethodGet, path: "/news/stream?lastEventId=-1",
+			wantCode: http.StatusBadRequest, wantError: `bad "lastEventId" parameter: must be at least 0`},
+		{name: "bad_path", method: http.MethodGet, path: "/news/0", wantCode: http.StatusNotFound},
+		{name: "bad_json", method: http.MethodPost, path: "/webhooks", body: `{"url": `,
+			wantCode: http.StatusBadRequest, wantError: "bad request body: invalid JSON"},
+		{name: "no_body", method: http.MethodPost, path: "/webhooks",
+			wantCode: http.StatusBadRequest, wantError: "bad request body: is required"},
+		{name: "bad_field", method: http.MethodPost, path: "/webhooks", body: `{"url": "https://hooks.test.com", "sources": ["1"]}`,
+			wantCode: http.StatusBadRequest, wantError: `bad request body: "sources[0]": must be a number`},
+		{name: "ok", method: http.MethodPost, path: "/webhooks", body: `{"url": "https://hooks.test.com"}`,
+			wantCode: http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := serve(tt.method, tt.path, tt.body)
+			if rr.Code != tt.wantCode {
+				t.Fatalf("%s %s got response code = %d, want = %d, body = %s",
+					tt.method, tt.path, rr.Code, tt.wantCode, rr.Body)
+			}
+			if tt.wantError == "" {
+				return
+			}
+			var msg map[string]string
+			if err := json.NewDecoder(rr.Body).Decode(&msg); err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if !strings.HasPrefix(msg["error"], tt.wantError) {
+				t.Errorf("%s %s got error = %q, want = %q", tt.method, tt.path, msg["error"], tt.wantError)
+			}
+		})
+	}
+}
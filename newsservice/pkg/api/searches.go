@@ -0,0 +1,345 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/rtemka/agg/news/pkg/storage"
+)
+
+// Новости сохраненного поиска.
+const (
+	SearchNewLimit    = 50  // количество новых новостей по умолчанию.
+	MaxSearchNewLimit = 500 // максимальное количество новых новостей.
+)
+
+// Ограничения сохраненного поиска.
+const (
+	maxSearchName = 200 // максимальная длина названия в символах.
+	maxSearchBody = 64 << 10
+)
+
+// ownerQP - владелец сохраненных поисков в ?owner=.
+const ownerQP = "owner"
+
+// SavedSearchRequest - запрос на сохранение поиска,
+// поля повторяют параметры /news.
+type SavedSearchRequest struct {
+	Name           string   `json:"name"`
+	Owner          string   `json:"owner"`
+	Terms          []string `json:"terms"`           // поисковые фразы, как в ?s=.
+	Exclude        []string `json:"exclude"`         // исключения, как в ?exc=.
+	Match          string   `json:"match"`           // режим поиска, как в ?match=.
+	Sources        []int64  `json:"sources"`         // id источников, как в ?source=.
+	ExcludeSources []int64  `json:"exclude_sources"` // id источников, как в ?excludeSource=.
+}
+
+// SavedSearchResponse - сохраненный поиск
+// с количеством непрочитанных новостей.
+type SavedSearchResponse struct {
+	Id             int64    `json:"id"`
+	Name           string   `json:"name"`
+	Owner          string   `json:"owner"`
+	Terms          []string `json:"terms,omitempty"`
+	Exclude        []string `json:"exclude,omitempty"`
+	Match          string   `json:"match"`
+	Sources        []int64  `json:"sources,omitempty"`
+	ExcludeSources []int64  `json:"exclude_sources,omitempty"`
+	Created        int64    `json:"created"`   // время создания в UNIX формате.
+	LastRead       int64    `json:"last_read"` // id последней прочитанной новости.
+	Unread         int      `json:"unread"`    // количество непрочитанных новостей.
+}
+
+// MarkReadRequest - отметка новостей сохраненного поиска прочитанными.
+type MarkReadRequest struct {
+	// LastID - id последней прочитанной новости,
+	// если 0, то прочитаны все новости поиска.
+	LastID int64 `json:"last_id"`
+}
+
+// savedSearchResponse возвращает сохраненный поиск для ответа.
+func savedSearchResponse(s storage.SavedSearch) SavedSearchResponse {
+	return SavedSearchResponse{
+		Id:             s.Id,
+		Name:           s.Name,
+		Owner:          s.Owner,
+		Terms:          s.Filter.TitleSearch,
+		Exclude:        s.Filter.Exclude,
+		Match:          s.Filter.Match.String(),
+		Sources:        s.Filter.Sources,
+		ExcludeSources: s.Filter.ExcludeSources,
+		Created:        s.Created,
+		LastRead:       s.LastRead,
+		Unread:         s.Unread,
+	}
+}
+
+// WithSavedSearches включает сохраненные поиски /searches, без
+// хранилища сохраненных поисков /searches отвечает 404.
+func (api *API) WithSavedSearches(store storage.SavedSearchStore) *API {
+	api.searches = store
+	return api
+}
+
+// withSearches пропускает к обработчику next, только если
+// задано хранилище сохраненных поисков, иначе отвечает 404.
+func (api *API) withSearches(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if api.searches == nil {
+			api.WriteJSON(w, "not found", http.StatusNotFound)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// savedSearchID возвращает сохраненный поиск по id из пути запроса,
+// если поиска нет, то отвечает 404 и возвращает false.
+func (api *API) savedSearchID(ctx context.Context, w http.ResponseWriter, r *http.Request) (storage.SavedSearch, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		api.WriteJSON(w, "not found", http.StatusNotFound)
+		return storage.SavedSearch{}, false
+	}
+
+	s, err := api.searches.SavedSearch(ctx, id)
+	if err != nil {
+		if s.Id == 0 {
+			api.WriteJSON(w, "not found", http.StatusNotFound)
+			return s, false
+		}
+		api.WriteJSONError(w, ErrInternal, http.StatusInternalServerError)
+		return s, false
+	}
+
+	return s, true
+}
+
+// savedSearchesHandler возвращает сохраненные поиски
+// владельца ?owner= (без параметра - всех) с количеством
+// непрочитанных новостей.
+func (api *API) savedSearchesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	searches, err := api.searches.SavedSearches(ctx, r.URL.Query().Get(ownerQP))
+	if err != nil {
+		api.logger.Printf("[ERROR] saved searches: %v", err)
+		api.WriteJSONError(w, ErrInternal, http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]SavedSearchResponse, len(searches))
+	for i := range searches {
+		resp[i] = savedSearchResponse(searches[i])
+	}
+
+	api.WriteJSON(w, resp, http.StatusOK)
+}
+
+// savedSearchHandler возвращает сохраненный поиск по id.
+func (api *API) savedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	s, ok := api.savedSearchID(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	api.WriteJSON(w, savedSearchResponse(s), http.StatusOK)
+}
+
+// addSavedSearchHandler сохраняет поиск. Совпадения
+// записываются только для новостей, добавленных после этого.
+func (api *API) addSavedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	var req SavedSearchRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSearchBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		api.WriteJSONError(w, fmt.Errorf("bad request body: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	s, err := req.savedSearch()
+	if err != nil {
+		api.WriteJSONError(w, err, http.StatusBadRequest)
+		return
+	}
+	s.Created = time.Now().Unix()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// новости, добавленные до сохранения поиска, не считаются новыми
+	latest, err := api.db.Items(ctx, filter{Page: 1, PageSize: 1, SortBy: storage.ID})
+	if err != nil {
+		api.logger.Printf("[ERROR] add saved search: %v", err)
+		api.WriteJSONError(w, ErrInternal, http.StatusInternalServerError)
+		return
+	}
+	if len(latest) > 0 {
+		s.LastRead = latest[0].Id
+	}
+
+	if s.Id, err = api.searches.AddSavedSearch(ctx, s); err != nil {
+		api.logger.Printf("[ERROR] add saved search: %v", err)
+		api.WriteJSONError(w, ErrInternal, http.StatusInternalServerError)
+		return
+	}
+
+	api.WriteJSON(w, savedSearchResponse(s), http.StatusCreated)
+}
+
+// savedSearch проверяет запрос и возвращает сохраненный поиск.
+func (req *SavedSearchRequest) savedSearch() (storage.SavedSearch, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxSearchName {
+		return storage.SavedSearch{}, fmt.Errorf("bad %q field: must be from 1 to %d characters", "name", maxSearchName)
+	}
+
+	f := filter{TitleSearch: req.Terms, Exclude: req.Exclude,
+		Sources: req.Sources, ExcludeSources: req.ExcludeSources}
+
+	switch req.Match {
+	case "", matchAuto, matchFullText:
+		f.Match = storage.MatchFullText
+	case matchFuzzy:
+		f.Match = storage.MatchFuzzy
+	default:
+		return storage.SavedSearch{}, fmt.Errorf("bad %q field, must be either: '%s', '%s' or '%s'",
+			"match", matchAuto, matchFullText, matchFuzzy)
+	}
+
+	if _, err := f.Query(); err != nil {
+		return storage.SavedSearch{}, fmt.Errorf("bad %q or %q field: %w", "terms", "exclude", err)
+	}
+
+	for field, ids := range map[string][]int64{"sources": req.Sources, "exclude_sources": req.ExcludeSources} {
+		for _, id := range ids {
+			if id < 1 {
+				return storage.SavedSearch{}, fmt.Errorf("bad %q field: bad id %d", field, id)
+			}
+		}
+	}
+
+	return storage.SavedSearch{Name: name, Owner: req.Owner, Filter: f}, nil
+}
+
+// deleteSavedSearchHandler удаляет сохраненный поиск вместе с совпадениями.
+func (api *API) deleteSavedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	s, ok := api.savedSearchID(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	if err := api.searches.DeleteSavedSearch(ctx, s.Id); err != nil {
+		api.logger.Printf("[ERROR] delete saved search: %v", err)
+		api.WriteJSONError(w, ErrInternal, http.StatusInternalServerError)
+		return
+	}
+
+	api.WriteJSON(w, nil, http.StatusNoContent)
+}
+
+// savedSearchNewHandler возвращает непрочитанные новости сохраненного
+// поиска, последние добавленные первыми, не больше ?limit= новостей.
+// Новости не отмечаются прочитанными, для этого есть /searches/{id}/read.
+func (api *API) savedSearchNewHandler(w http.ResponseWriter, r *http.Request) {
+	limit := SearchNewLimit
+	if qp := r.URL.Query().Get(limitQP); qp != "" {
+		var err error
+		limit, err = strconv.Atoi(qp)
+		if err != nil || limit < 1 || limit > MaxSearchNewLimit {
+			api.WriteJSONError(w, fmt.Errorf("bad %q parameter: must be: %s=NUM, where NUM is between 1 and %d",
+				limitQP, limitQP, MaxSearchNewLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	s, ok := api.savedSearchID(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	items, err := api.searches.SearchMatches(ctx, s.Id, s.LastRead, limit)
+	if err != nil {
+		api.logger.Printf("[ERROR] saved search new: %v", err)
+		api.WriteJSONError(w, ErrInternal, http.StatusInternalServerError)
+		return
+	}
+	if items == nil {
+		items = []item{}
+	}
+
+	api.WriteJSON(w, items, http.StatusOK)
+}
+
+// markSavedSearchReadHandler отмечает прочитанными новости сохраненного
+// поиска до last_id включительно, без тела запроса - все новости.
+func (api *API) markSavedSearchReadHandler(w http.ResponseWriter, r *http.Request) {
+	var req MarkReadRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSearchBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		api.WriteJSONError(w, fmt.Errorf("bad request body: %w", err), http.StatusBadRequest)
+		return
+	}
+	if req.LastID < 0 {
+		api.WriteJSONError(w, fmt.Errorf("bad %q field: must be at least 0", "last_id"), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	s, ok := api.savedSearchID(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	lastID := req.LastID
+	if lastID == 0 {
+		latest, err := api.searches.SearchMatches(ctx, s.Id, s.LastRead, 1)
+		if err != nil {
+			api.logger.Printf("[ERROR] mark saved search read: %v", err)
+			api.WriteJSONError(w, ErrInternal, http.StatusInternalServerError)
+			return
+		}
+		if len(latest) == 0 {
+			api.WriteJSON(w, savedSearchResponse(s), http.StatusOK)
+			return
+		}
+		lastID = latest[0].Id
+	}
+
+	if err := api.searches.MarkSearchRead(ctx, s.Id, lastID); err != nil {
+		api.logger.Printf("[ERROR] mark saved search read: %v", err)
+		api.WriteJSONError(w, ErrInternal, http.StatusInternalServerError)
+		return
+	}
+
+	s, err := api.searches.SavedSearch(ctx, s.Id)
+	if err != nil {
+		api.logger.Printf("[ERROR] mark saved search read: %v", err)
+		api.WriteJSONError(w, ErrInternal, http.StatusInternalServerError)
+		return
+	}
+
+	api.WriteJSON(w, savedSearchResponse(s), http.StatusOK)
+}
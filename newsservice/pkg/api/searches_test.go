@@ -0,0 +1,143 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestApi_savedSearches(t *testing.T) {
+	db := testDB(t, 3)
+	api := New(db, log.New(io.Discard, "", 0))
+
+	auth := "Bearer secret"
+	serve := func(method, path, body string) *http.Response {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", auth)
+		rr := httptest.NewRecorder()
+		api.r.ServeHTTP(rr, req)
+		return rr.Result()
+	}
+
+	// без хранилища сохраненных поисков /searches выключено
+	api.WithAdmin(nil, "secret")
+	if resp := serve(http.MethodGet, "/searches", ""); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("GET /searches got response code = %d, want = %d", resp.StatusCode, http.StatusNotFound)
+	}
+	api.WithSavedSearches(db)
+
+	// поиски управляются только администратором
+	auth = "Bearer secret2"
+	if resp := serve(http.MethodGet, "/searches", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("GET /searches got response code = %d, want = %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+	auth = ""
+	if resp := serve(http.MethodPost, "/searches", `{"name": "go"}`); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("POST /searches got response code = %d, want = %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+	auth = "Bearer secret"
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+	}{
+		{name: "ok", body: `{"name": "go", "owner": "user1", "terms": ["go"], "exclude": ["rust"], "sources": [1]}`, wantCode: http.StatusCreated},
+		{name: "no_name", body: `{"name": " ", "terms": ["go"]}`, wantCode: http.StatusBadRequest},
+		{name: "bad_terms", body: `{"name": "go", "terms": ["-go"]}`, wantCode: http.StatusBadRequest},
+		{name: "bad_match", body: `{"name": "go", "match": "exact"}`, wantCode: http.StatusBadRequest},
+		{name: "bad_sources", body: `{"name": "go", "exclude_sources": [0]}`, wantCode: http.StatusBadRequest},
+		{name: "unknown_field", body: `{"name": "go", "tags": ["спорт"]}`, wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := serve(http.MethodPost, "/searches", tt.body)
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("Api.addSavedSearchHandler() got response code = %d, want = %d", resp.StatusCode, tt.wantCode)
+			}
+		})
+	}
+
+	// новости, добавленные до сохранения поиска, прочитаны
+	resp := serve(http.MethodPost, "/searches", `{"name": "все go", "owner": "user2", "terms": ["go"], "match": "fuzzy"}`)
+	var created SavedSearchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if created.Id != 2 || created.LastRead != 3 || created.Unread != 0 || created.Match != matchFuzzy || created.Created == 0 {
+		t.Fatalf("Api.addSavedSearchHandler() got = %+v, want id 2 with last read 3", created)
+	}
+
+	res, err := db.AddItems(context.Background(), []item{
+		{Title: "новость go 4", PubDate: 5555559, Link: "https://test.com/4"},
+		{Title: "новость go 5", PubDate: 5555560, Link: "https://test.com/5"},
+	})
+	if err != nil {
+		t.Fatalf("AddItems() error = %v", err)
+	}
+	if err := db.AddSearchMatches(context.Background(), map[int64][]int64{
+		created.Id: {res.Added[0].Id, res.Added[1].Id},
+	}); err != nil {
+		t.Fatalf("AddSearchMatches() error = %v", err)
+	}
+
+	resp = serve(http.MethodGet, "/searches?owner=user2", "")
+	var list []SavedSearchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if len(list) != 1 || list[0].Id != created.Id || list[0].Unread != 2 {
+		t.Fatalf("Api.savedSearchesHandler() got = %+v, want search 2 with 2 unread", list)
+	}
+
+	resp = serve(http.MethodGet, "/searches/2/new?limit=1", "")
+	var items []item
+	if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if len(items) != 1 || items[0].Id != 5 {
+		t.Fatalf("Api.savedSearchNewHandler() got = %+v, want item 5", items)
+	}
+
+	// прочитана только новость 4, без тела - все новости
+	for _, tt := range []struct {
+		body       string
+		wantRead   int64
+		wantUnread int
+	}{
+		{body: `{"last_id": 4}`, wantRead: 4, wantUnread: 1},
+		{body: ``, wantRead: 5, wantUnread: 0},
+		{body: ``, wantRead: 5, wantUnread: 0},
+	} {
+		resp = serve(http.MethodPost, "/searches/2/read", tt.body)
+		var s SavedSearchResponse
+		if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+			t.Fatalf("Decode() error = %v", err)
+		}
+		if resp.StatusCode != http.StatusOK || s.LastRead != tt.wantRead || s.Unread != tt.wantUnread {
+			t.Fatalf("Api.markSavedSearchReadHandler(%q) got = %d, %+v, want last read %d and %d unread",
+				tt.body, resp.StatusCode, s, tt.wantRead, tt.wantUnread)
+		}
+	}
+
+	if resp = serve(http.MethodGet, "/searches/2/new?limit=0", ""); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Api.savedSearchNewHandler() got response code = %d, want = %d", resp.StatusCode, http.StatusBadRequest)
+	}
+	if resp = serve(http.MethodDelete, "/searches/2", ""); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Api.deleteSavedSearchHandler() got response code = %d, want = %d", resp.StatusCode, http.StatusNoContent)
+	}
+	for _, path := range []string{"/searches/2", "/searches/2/new", "/searches/abc"} {
+		if resp = serve(http.MethodGet, path, ""); resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("GET %s got response code = %d, want = %d", path, resp.StatusCode, http.StatusNotFound)
+		}
+	}
+	if resp = serve(http.MethodPost, "/searches/2/read", ""); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Api.markSavedSearchReadHandler() got response code = %d, want = %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rtemka/agg/news/pkg/broadcast"
+	"github.com/rtemka/agg/news/pkg/service"
+)
+
+// lastEventIDQP - id последней полученной новости, если
+// клиент не может передать заголовок Last-Event-ID.
+const lastEventIDQP = "lastEventId"
+
+// Поток новостей.
+const (
+	// streamPing - как часто в поток пишется комментарий,
+	// чтобы прокси не закрывали простаивающее соединение.
+	streamPing = 15 * time.Second
+	// streamRetry - через сколько миллисекунд
+	// клиенту переподключаться после обрыва.
+	streamRetry = 3000
+)
+
+// streamEvent - тип события с новостью.
+const streamEvent = "news"
+
+// WithBroadcaster задает источник сигналов о новых новостях
+// для /news/stream, без него поток проверяет новости раз в service.StreamPoll.
+func (api *API) WithBroadcaster(b *broadcast.Broadcaster) *API {
+	api.events = b
+	return api
+}
+
+// CloseStreams завершает открытые потоки /news/stream. http.Server.Shutdown
+// не прерывает запросы и ждет их окончания, поэтому CloseStreams нужно
+// зарегистрировать через http.Server.RegisterOnShutdown. Клиенты
+// переподключатся к другому экземпляру с заголовком Last-Event-ID.
+func (api *API) CloseStreams() {
+	api.closeOnce.Do(func() { close(api.closing) })
+}
+
+// streamHandler передает клиенту новые новости, отобранные теми же
+// параметрами, что и в /news, в формате Server-Sent Events. id события -
+// наибольший id переданной новости, так что после переподключения с
+// заголовком Last-Event-ID (или параметром ?lastEventId=) поток продолжается
+// с пропущенных новостей (см. service.Cursor). Без него передаются только
+// новости, добавленные после подключения.
+func (api *API) streamHandler(w http.ResponseWriter, r *http.Request) {
+	f, err := api.parseQP(r.URL)
+	if err != nil {
+		api.WriteJSONError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	var after int64
+	last := r.Header.Get("Last-Event-ID")
+	if last == "" {
+		last = r.URL.Query().Get(lastEventIDQP)
+	}
+	if last != "" {
+		if after, err = strconv.ParseInt(last, 10, 64); err != nil || after < 0 {
+			api.WriteJSONError(w, fmt.Errorf("bad Last-Event-ID: must be a news id"), http.StatusBadRequest)
+			return
+		}
+	} else if after, err = service.LastID(r.Context(), api.db); err != nil {
+		api.logger.Printf("[ERROR] stream: %v", err)
+		api.WriteJSONError(w, ErrInternal, http.StatusInternalServerError)
+		return
+	}
+	cur := service.NewCursor(api.db, f, after, last != "")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		api.WriteJSONError(w, ErrInternal, http.StatusInternalServerError)
+		return
+	}
+
+	var wake <-chan struct{}
+	if api.events != nil {
+		ch, unsubscribe := api.events.Subscribe()
+		defer unsubscribe()
+		wake = ch
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx не должен копить поток
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetry)
+	flusher.Flush()
+
+	poll := time.NewTicker(service.StreamPoll)
+	defer poll.Stop()
+	ping := time.NewTicker(streamPing)
+	defer ping.Stop()
+
+	ctx := r.Context()
+	for {
+		// сначала отправляем то, что уже есть после последней новости
+		if err := api.streamItems(ctx, w, cur); err != nil {
+			if ctx.Err() == nil {
+				api.logger.Printf("[ERROR] stream: after_id=%d error=%v", cur.Last(), err)
+			}
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-api.closing:
+			return
+		case <-wake:
+		case <-poll.C:
+		case <-ping.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// streamItems пишет событиями новости, появившиеся
+// после прошлого вызова, по возрастанию id.
+func (api *API) streamItems(ctx context.Context, w http.ResponseWriter, cur *service.Cursor) error {
+	return cur.Next(ctx, func(it item) error {
+		b, err := json.Marshal(it)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", cur.Last(), streamEvent, b)
+		return err
+	})
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rtemka/agg/news/pkg/broadcast"
+)
+
+// sseEvent - событие потока.
+type sseEvent struct {
+	id   int64
+	item item
+}
+
+// readEvents читает события потока в канал, пока поток не закрыт.
+func readEvents(t *testing.T, body io.Reader) <-chan sseEvent {
+	ch := make(chan sseEvent)
+	go func() {
+		defer close(ch)
+		var ev sseEvent
+		sc := bufio.NewScanner(body)
+		for sc.Scan() {
+			line := sc.Text()
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				ev.id, _ = strconv.ParseInt(strings.TrimPrefix(line, "id: "), 10, 64)
+			case strings.HasPrefix(line, "data: "):
+				if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev.item); err != nil {
+					t.Errorf("Unmarshal() error = %v", err)
+				}
+			case line == "" && ev.id != 0:
+				ch <- ev
+				ev = sseEvent{}
+			}
+		}
+	}()
+	return ch
+}
+
+func nextEvent(t *testing.T, ch <-chan sseEvent) sseEvent {
+	select {
+	case ev, ok := <-ch:
+		if !ok {
+			t.Fatalf("stream is closed")
+		}
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no event in stream")
+	}
+	return sseEvent{}
+}
+
+func TestApi_streamHandler(t *testing.T) {
+	db := testDB(t, 3)
+	b := broadcast.New()
+	srv := httptest.NewServer(New(db, log.New(io.Discard, "", 0)).WithBroadcaster(b).Router())
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	open := func(path, lastID string) <-chan sseEvent {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+path, nil)
+		if err != nil {
+			t.Fatalf("NewRequest() error = %v", err)
+		}
+		if lastID != "" {
+			req.Header.Set("Last-Event-ID", lastID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+			t.Fatalf("Api.streamHandler() got response code = %d, content type = %q",
+				resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return readEvents(t, resp.Body)
+	}
+
+	// продолжение после новости 1 и новые новости
+	resumed := open("/news/stream", "1")
+	for _, want := range []int64{2, 3} {
+		if ev := nextEvent(t, resumed); ev.id != want || ev.item.Id != want {
+			t.Fatalf("Api.streamHandler() got event = %+v, want id = %d", ev, want)
+		}
+	}
+
+	// без Last-Event-ID - только новые новости, с фильтром как в /news
+	filtered := open("/news/stream?s=погода", "")
+	for b.Subscribers() != 2 {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	res, err := db.AddItems(context.Background(), []item{
+		{Title: "новость 4", Link: "https://test.com/4"},
+		{Title: "погода 5", Link: "https://test.com/5"},
+	})
+	if err != nil {
+		t.Fatalf("AddItems() error = %v", err)
+	}
+	b.Notify(res.Added)
+
+	for _, want := range []int64{4, 5} {
+		if ev := nextEvent(t, resumed); ev.id != want {
+			t.Fatalf("Api.streamHandler() got event = %+v, want id = %d", ev, want)
+		}
+	}
+	if ev := nextEvent(t, filtered); ev.id != 5 || ev.item.Title != "погода 5" {
+		t.Fatalf("Api.streamHandler() got event = %+v, want id = 5", ev)
+	}
+}
+
+func TestApi_streamHandler_bad_request(t *testing.T) {
+	api := New(testDB(t, 1), log.New(io.Discard, "", 0))
+
+	for _, path := range []string{"/news/stream?lastEventId=abc", "/news/stream?pageSize=0"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rr := httptest.NewRecorder()
+		api.r.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("GET %s got response code = %d, want = %d", path, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestApi_streamHandler_shutdown(t *testing.T) {
+	api := New(testDB(t, 1), log.New(io.Discard, "", 0))
+	srv := httptest.NewUnstartedServer(api.Router())
+	srv.Config.RegisterOnShutdown(api.CloseStreams)
+	srv.Start()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/news/stream")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	defer resp.Body.Close()
+	events := readEvents(t, resp.Body)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// остановка сервера не ждет, пока клиент отключится от потока
+	if err := srv.Config.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Fatalf("got event, want closed stream")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("stream is not closed")
+	}
+}
@@ -0,0 +1,231 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rtemka/agg/news/pkg/storage"
+	"github.com/rtemka/agg/news/pkg/webhook"
+)
+
+// Журнал доставки.
+const (
+	DeliveriesLimit    = 50  // количество записей журнала по умолчанию.
+	MaxDeliveriesLimit = 500 // максимальное количество записей журнала.
+)
+
+// maxWebhookBody - максимальный размер тела запроса на подписку.
+const maxWebhookBody = 64 << 10
+
+// errCategories - у новостей нет категорий, поэтому
+// фильтр по категориям отклоняется, а не игнорируется.
+var errCategories = errors.New("filtering by categories is not supported: news have no categories")
+
+// WebhookRequest - запрос на подписку.
+type WebhookRequest struct {
+	URL        string   `json:"url"`
+	Secret     string   `json:"secret"`     // если пустой, то создается случайный.
+	Terms      []string `json:"terms"`      // поисковые фразы, как в ?s=.
+	Sources    []int64  `json:"sources"`    // id источников, как в ?source=.
+	Categories []string `json:"categories"` // не поддерживается, см. errCategories.
+}
+
+// WithWebhooks включает управление подписками /webhooks,
+// без хранилища подписок /webhooks отвечает 404.
+func (api *API) WithWebhooks(store storage.WebhookStore) *API {
+	api.webhooks = store
+	return api
+}
+
+// withWebhooks пропускает к обработчику next, только
+// если задано хранилище подписок, иначе отвечает 404.
+func (api *API) withWebhooks(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if api.webhooks == nil {
+			api.WriteJSON(w, "not found", http.StatusNotFound)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// webhookID возвращает подписку по id из пути запроса, если подписки
+// нет, то отвечает 404 и возвращает false.
+func (api *API) webhookID(ctx context.Context, w http.ResponseWriter, r *http.Request) (storage.Webhook, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		api.WriteJSON(w, "not found", http.StatusNotFound)
+		return storage.Webhook{}, false
+	}
+
+	wh, err := api.webhooks.Webhook(ctx, id)
+	if err != nil {
+		if wh.Id == 0 {
+			api.WriteJSON(w, "not found", http.StatusNotFound)
+			return wh, false
+		}
+		api.WriteJSONError(w, ErrInternal, http.StatusInternalServerError)
+		return wh, false
+	}
+
+	return wh, true
+}
+
+// webhooksHandler возвращает все подписки без секретов.
+func (api *API) webhooksHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	webhooks, err := api.webhooks.Webhooks(ctx)
+	if err != nil {
+		api.logger.Printf("[ERROR] webhooks: %v", err)
+		api.WriteJSONError(w, ErrInternal, http.StatusInternalServerError)
+		return
+	}
+
+	if webhooks == nil {
+		webhooks = []storage.Webhook{}
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+
+	api.WriteJSON(w, webhooks, http.StatusOK)
+}
+
+// webhookHandler возвращает подписку по id без секрета.
+func (api *API) webhookHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	wh, ok := api.webhookID(ctx, w, r)
+	if !ok {
+		return
+	}
+	wh.Secret = ""
+
+	api.WriteJSON(w, wh, http.StatusOK)
+}
+
+// addWebhookHandler добавляет подписку. Секрет подписи
+// возвращается только в ответе на этот запрос.
+func (api *API) addWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var req WebhookRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		api.WriteJSONError(w, fmt.Errorf("bad request body: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	wh, err := req.webhook()
+	if err != nil {
+		api.WriteJSONError(w, err, http.StatusBadRequest)
+		return
+	}
+	if wh.Secret == "" {
+		if wh.Secret, err = webhook.NewSecret(); err != nil {
+			api.logger.Printf("[ERROR] add webhook: %v", err)
+			api.WriteJSONError(w, ErrInternal, http.StatusInternalServerError)
+			return
+		}
+	}
+	wh.Created = time.Now().Unix()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if wh.Id, err = api.webhooks.AddWebhook(ctx, wh); err != nil {
+		api.logger.Printf("[ERROR] add webhook: %v", err)
+		api.WriteJSONError(w, ErrInternal, http.StatusInternalServerError)
+		return
+	}
+
+	api.WriteJSON(w, wh, http.StatusCreated)
+}
+
+// webhook проверяет запрос и возвращает подписку.
+func (req *WebhookRequest) webhook() (storage.Webhook, error) {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return storage.Webhook{}, fmt.Errorf("bad %q field: must be an absolute http or https URL", "url")
+	}
+
+	if len(req.Categories) > 0 {
+		return storage.Webhook{}, errCategories
+	}
+
+	f := filter{TitleSearch: req.Terms}
+	if _, err := f.Query(); err != nil {
+		return storage.Webhook{}, fmt.Errorf("bad %q field: %w", "terms", err)
+	}
+
+	for _, id := range req.Sources {
+		if id < 1 {
+			return storage.Webhook{}, fmt.Errorf("bad %q field: bad id %d", "sources", id)
+		}
+	}
+
+	return storage.Webhook{URL: req.URL, Secret: req.Secret, Terms: req.Terms, Sources: req.Sources}, nil
+}
+
+// deleteWebhookHandler удаляет подписку вместе с журналом доставки.
+func (api *API) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	wh, ok := api.webhookID(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	if err := api.webhooks.DeleteWebhook(ctx, wh.Id); err != nil {
+		api.logger.Printf("[ERROR] delete webhook: %v", err)
+		api.WriteJSONError(w, ErrInternal, http.StatusInternalServerError)
+		return
+	}
+
+	api.WriteJSON(w, nil, http.StatusNoContent)
+}
+
+// deliveriesHandler возвращает журнал доставки по подписке,
+// последние попытки первыми, не больше ?limit= записей.
+func (api *API) deliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	limit := DeliveriesLimit
+	if qp := r.URL.Query().Get(limitQP); qp != "" {
+		var err error
+		limit, err = strconv.Atoi(qp)
+		if err != nil || limit < 1 || limit > MaxDeliveriesLimit {
+			api.WriteJSONError(w, fmt.Errorf("bad %q parameter: must be: %s=NUM, where NUM is between 1 and %d",
+				limitQP, limitQP, MaxDeliveriesLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	wh, ok := api.webhookID(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	deliveries, err := api.webhooks.Deliveries(ctx, wh.Id, limit)
+	if err != nil {
+		api.logger.Printf("[ERROR] deliveries: %v", err)
+		api.WriteJSONError(w, ErrInternal, http.StatusInternalServerError)
+		return
+	}
+	if deliveries == nil {
+		deliveries = []storage.Delivery{}
+	}
+
+	api.WriteJSON(w, deliveries, http.StatusOK)
+}
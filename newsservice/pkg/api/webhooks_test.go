@@ -0,0 +1,113 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rtemka/agg/news/pkg/storage"
+	"github.com/rtemka/agg/news/pkg/storage/memdb"
+)
+
+func TestApi_webhooks(t *testing.T) {
+	db := memdb.New()
+	api := New(db, log.New(io.Discard, "", 0)).WithWebhooks(db)
+
+	auth := "Bearer secret"
+	serve := func(method, path, body string) *http.Response {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", auth)
+		rr := httptest.NewRecorder()
+		api.r.ServeHTTP(rr, req)
+		return rr.Result()
+	}
+
+	// подписки управляются только администратором
+	if resp := serve(http.MethodGet, "/webhooks", ""); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("GET /webhooks got response code = %d, want = %d", resp.StatusCode, http.StatusNotFound)
+	}
+	api.WithAdmin(nil, "secret")
+	auth = "Bearer secret2"
+	if resp := serve(http.MethodPost, "/webhooks", `{"url": "http://127.0.0.1/"}`); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("POST /webhooks got response code = %d, want = %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+	auth = "Bearer secret"
+	// без управления каналами /admin/feeds выключено
+	if resp := serve(http.MethodGet, "/admin/feeds", ""); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("GET /admin/feeds got response code = %d, want = %d", resp.StatusCode, http.StatusNotFound)
+	}
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+	}{
+		{name: "ok", body: `{"url": "https://hooks.test.com/news", "terms": ["go"], "sources": [1]}`, wantCode: http.StatusCreated},
+		{name: "bad_url", body: `{"url": "hooks.test.com"}`, wantCode: http.StatusBadRequest},
+		{name: "bad_terms", body: `{"url": "https://hooks.test.com", "terms": ["-go"]}`, wantCode: http.StatusBadRequest},
+		{name: "bad_sources", body: `{"url": "https://hooks.test.com", "sources": [0]}`, wantCode: http.StatusBadRequest},
+		{name: "categories", body: `{"url": "https://hooks.test.com", "categories": ["спорт"]}`, wantCode: http.StatusBadRequest},
+		{name: "unknown_field", body: `{"url": "https://hooks.test.com", "tags": ["спорт"]}`, wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := serve(http.MethodPost, "/webhooks", tt.body)
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("Api.addWebhookHandler() got response code = %d, want = %d", resp.StatusCode, tt.wantCode)
+			}
+		})
+	}
+
+	// секрет создается и возвращается только при создании
+	resp := serve(http.MethodPost, "/webhooks", `{"url": "https://hooks.test.com/2"}`)
+	var created storage.Webhook
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if created.Id != 2 || created.Secret == "" || created.Created == 0 {
+		t.Fatalf("Api.addWebhookHandler() got = %+v, want id 2 with secret", created)
+	}
+
+	resp = serve(http.MethodGet, "/webhooks", "")
+	var list []storage.Webhook
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if len(list) != 2 || list[1].Secret != "" || list[0].Terms[0] != "go" {
+		t.Fatalf("Api.webhooksHandler() got = %+v", list)
+	}
+
+	err := db.AddDelivery(context.Background(), storage.Delivery{WebhookID: 2, Event: "e", Items: []int64{1}, Attempt: 1, Status: 200})
+	if err != nil {
+		t.Fatalf("AddDelivery() error = %v", err)
+	}
+	resp = serve(http.MethodGet, "/webhooks/2/deliveries?limit=10", "")
+	var ds []storage.Delivery
+	if err := json.NewDecoder(resp.Body).Decode(&ds); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if len(ds) != 1 || ds[0].Event != "e" {
+		t.Fatalf("Api.deliveriesHandler() got = %+v", ds)
+	}
+
+	if resp = serve(http.MethodGet, "/webhooks/2/deliveries?limit=0", ""); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Api.deliveriesHandler() got response code = %d, want = %d", resp.StatusCode, http.StatusBadRequest)
+	}
+	if resp = serve(http.MethodDelete, "/webhooks/2", ""); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Api.deleteWebhookHandler() got response code = %d, want = %d", resp.StatusCode, http.StatusNoContent)
+	}
+	for _, path := range []string{"/webhooks/2", "/webhooks/2/deliveries", "/webhooks/abc"} {
+		if resp = serve(http.MethodGet, path, ""); resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("GET %s got response code = %d, want = %d", path, resp.StatusCode, http.StatusNotFound)
+		}
+	}
+	if resp = serve(http.MethodDelete, "/webhooks/2", ""); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Api.deleteWebhookHandler() got response code = %d, want = %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
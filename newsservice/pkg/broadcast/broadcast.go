@@ -0,0 +1,103 @@
+// пакет broadcast сообщает подписчикам, что в БД появились новые новости.
+//
+// Сами новости не передаются: подписчик, получив сигнал, читает из БД
+// новости после последней полученной. Поэтому сигналы можно терять и
+// склеивать, а источником сигналов может быть как запись новостей в
+// этом процессе (Notify), так и уведомления БД (Listen), общие для
+// нескольких экземпляров сервиса.
+package broadcast
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/rtemka/agg/news/pkg/storage"
+)
+
+// listenRetry - пауза перед повторным подключением к уведомлениям БД.
+const listenRetry = 5 * time.Second
+
+// Listener - источник уведомлений о новых новостях в БД,
+// Listen вызывает fn на каждое уведомление, пока не закрыт контекст
+// или не разорвано подключение.
+type Listener interface {
+	Listen(ctx context.Context, fn func()) error
+}
+
+// Broadcaster рассылает подписчикам сигналы о новых новостях.
+// Безопасен для конкурентного использования.
+type Broadcaster struct {
+	mu   sync.Mutex
+	subs map[chan struct{}]struct{}
+}
+
+// New возвращает новый объект *Broadcaster.
+func New() *Broadcaster {
+	return &Broadcaster{subs: make(map[chan struct{}]struct{})}
+}
+
+// Subscribe возвращает канал сигналов и функцию отписки.
+// Пока подписчик не прочитал сигнал, новые с ним склеиваются.
+func (b *Broadcaster) Subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.subs, ch)
+		b.mu.Unlock()
+	}
+}
+
+// Subscribers возвращает количество подписчиков.
+func (b *Broadcaster) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+// Wake сигналит всем подписчикам, не блокируясь.
+func (b *Broadcaster) Wake() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Notify сигналит подписчикам о добавленных новостях,
+// так *Broadcaster получает новости от streamwriter.
+func (b *Broadcaster) Notify(items []storage.Item) {
+	if len(items) > 0 {
+		b.Wake()
+	}
+}
+
+// Listen сигналит подписчикам на каждое уведомление l, при разрыве
+// переподключается, пока не закрыт контекст. После переподключения
+// подписчики будятся, чтобы не пропустить новости за время разрыва.
+func (b *Broadcaster) Listen(ctx context.Context, l Listener, log *log.Logger) {
+	for {
+		err := l.Listen(ctx, b.Wake)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("[ERROR] broadcast: listen error=%v, retry in %s", err, listenRetry)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(listenRetry):
+		}
+		b.Wake()
+	}
+}
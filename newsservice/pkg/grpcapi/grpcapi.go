@@ -0,0 +1,384 @@
+// пакет grpcapi реализует gRPC API сервиса новостей (см. pkg/newspb)
+// поверх того же хранилища и фильтра, что и REST API, общая с ним
+// логика - в пакете service.
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/rtemka/agg/news/pkg/broadcast"
+	"github.com/rtemka/agg/news/pkg/newspb"
+	"github.com/rtemka/agg/news/pkg/rsscollector"
+	"github.com/rtemka/agg/news/pkg/service"
+	"github.com/rtemka/agg/news/pkg/storage"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type item = storage.Item
+type filter = storage.Filter
+
+// ShortDescription - длина описания новости в символах при VIEW_SHORT.
+const ShortDescription = 200
+
+// Server - gRPC сервер новостей.
+type Server struct {
+	newspb.UnimplementedNewsServiceServer
+	db         storage.Storage
+	logger     *log.Logger
+	events     *broadcast.Broadcaster // сигналы о новых новостях для StreamNews.
+	feeds      service.FeedController // управление опросом каналов.
+	adminToken string                 // токен администратора, без него управление выключено.
+}
+
+// New возвращает новый объект *Server.
+func New(db storage.Storage, logger *log.Logger) *Server {
+	return &Server{db: db, logger: logger}
+}
+
+// WithBroadcaster задает источник сигналов о новых новостях
+// для StreamNews, без него поток проверяет новости раз в service.StreamPoll.
+func (s *Server) WithBroadcaster(b *broadcast.Broadcaster) *Server {
+	s.events = b
+	return s
+}
+
+// WithAdmin включает управление опросом каналов. Запросы к нему должны
+// содержать метаданные authorization: Bearer <token>, без токена
+// управление выключено.
+func (s *Server) WithAdmin(feeds service.FeedController, token string) *Server {
+	s.feeds = feeds
+	s.adminToken = token
+	return s
+}
+
+// Register регистрирует сервер новостей на gRPC сервере gs.
+func (s *Server) Register(gs *grpc.Server) {
+	newspb.RegisterNewsServiceServer(gs, s)
+}
+
+// ListNews возвращает страницу новостей, отобранных фильтром.
+func (s *Server) ListNews(ctx context.Context, req *newspb.ListNewsRequest) (*newspb.ListNewsResponse, error) {
+	f, err := parseFilter(req.GetFilter())
+	if err != nil {
+		return nil, err
+	}
+
+	f.Page = int(req.GetPage())
+	if f.Page < 0 {
+		return nil, status.Error(codes.InvalidArgument, "page must not be negative")
+	}
+	if f.Page == 0 {
+		f.Page = 1
+	}
+
+	f.PageSize = int(req.GetPageSize())
+	if f.PageSize == 0 {
+		f.PageSize = storage.PageSize
+	}
+	if f.PageSize < storage.MinPageSize || f.PageSize > storage.MaxPageSize {
+		return nil, status.Errorf(codes.InvalidArgument, "page_size must be between %d and %d",
+			storage.MinPageSize, storage.MaxPageSize)
+	}
+
+	switch req.GetSortBy() {
+	case newspb.Sort_SORT_DEFAULT, newspb.Sort_SORT_DATE, newspb.Sort_SORT_TITLE,
+		newspb.Sort_SORT_RANK, newspb.Sort_SORT_ID:
+		f.SortBy = storage.Sort(req.GetSortBy())
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown sort_by %d", req.GetSortBy())
+	}
+
+	switch req.GetView() {
+	case newspb.View_VIEW_FULL:
+	case newspb.View_VIEW_SHORT:
+		f.DescriptionLimit = ShortDescription
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown view %d", req.GetView())
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// в режиме MATCH_AUTO (по умолчанию), если полнотекстовый
+	// поиск ничего не нашел, то пробуем нечеткий
+	auto := req.GetFilter().GetMatch() == newspb.Match_MATCH_AUTO
+	items, total, err := service.Items(ctx, s.db, &f, auto)
+	if err != nil {
+		return nil, s.internal("list news", err)
+	}
+
+	q, _ := f.Query()      // синтаксис уже проверен в parseFilter
+	search := q.HasTerms() // одни исключения - не поиск
+
+	resp := &newspb.ListNewsResponse{
+		News:       make([]*newspb.News, len(items)),
+		TotalPages: int32(f.TotalPages(total)),
+		PageSize:   int32(f.Limit()),
+		Page:       int32(f.Page),
+	}
+	for i := range items {
+		resp.News[i] = news(items[i])
+	}
+	if search {
+		resp.Match = match(f.Match)
+	}
+
+	return resp, nil
+}
+
+// GetNews возвращает новость по id.
+func (s *Server) GetNews(ctx context.Context, req *newspb.GetNewsRequest) (*newspb.News, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	it, err := s.db.Item(ctx, req.GetId())
+	if err != nil {
+		if it == (item{}) {
+			return nil, status.Errorf(codes.NotFound, "news id=%d not found", req.GetId())
+		}
+		return nil, s.internal("get news", err)
+	}
+
+	return news(it), nil
+}
+
+// StreamNews передает новости, отобранные фильтром, после after_id
+// по возрастанию id, а затем новые новости по мере добавления.
+// Без after_id передаются только новости, добавленные после начала потока.
+// Новости, зафиксированные в БД не по порядку id, тоже передаются
+// (см. service.Cursor), поэтому id пришедших новостей могут убывать:
+// продолжать поток нужно с наибольшего полученного id.
+func (s *Server) StreamNews(req *newspb.StreamNewsRequest, stream newspb.NewsService_StreamNewsServer) error {
+	f, err := parseFilter(req.GetFilter())
+	if err != nil {
+		return err
+	}
+
+	ctx := stream.Context()
+
+	after := req.GetAfterId()
+	if after < 0 {
+		return status.Error(codes.InvalidArgument, "after_id must not be negative")
+	}
+	resume := after > 0
+	if !resume {
+		if after, err = service.LastID(ctx, s.db); err != nil {
+			return s.internal("stream news", err)
+		}
+	}
+	cur := service.NewCursor(s.db, f, after, resume)
+
+	var wake <-chan struct{}
+	if s.events != nil {
+		ch, unsubscribe := s.events.Subscribe()
+		defer unsubscribe()
+		wake = ch
+	}
+
+	poll := time.NewTicker(service.StreamPoll)
+	defer poll.Stop()
+
+	for {
+		// сначала отправляем то, что уже есть после последней новости
+		err := cur.Next(ctx, func(it item) error {
+			return stream.Send(news(it))
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return status.FromContextError(ctx.Err()).Err()
+			}
+			return s.internal("stream news", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-wake:
+		case <-poll.C:
+		}
+	}
+}
+
+// ListFeeds возвращает состояние опроса всех каналов.
+func (s *Server) ListFeeds(ctx context.Context, _ *newspb.ListFeedsRequest) (*newspb.ListFeedsResponse, error) {
+	if err := s.admin(ctx); err != nil {
+		return nil, err
+	}
+
+	feeds := s.feeds.Feeds()
+	resp := &newspb.ListFeedsResponse{Feeds: make([]*newspb.Feed, len(feeds))}
+	for i := range feeds {
+		resp.Feeds[i] = feed(feeds[i])
+	}
+	return resp, nil
+}
+
+// PollFeed запрашивает внеочередной опрос канала,
+// даже приостановленного, и не ждет его окончания.
+func (s *Server) PollFeed(ctx context.Context, req *newspb.FeedRequest) (*newspb.Feed, error) {
+	return s.feedAction(ctx, "poll", req.GetId(), service.FeedController.PollNow)
+}
+
+// PollAllFeeds запрашивает внеочередной опрос всех каналов,
+// кроме приостановленных, и не ждет его окончания.
+func (s *Server) PollAllFeeds(ctx context.Context, _ *newspb.PollAllFeedsRequest) (*newspb.PollAllFeedsResponse, error) {
+	if err := s.admin(ctx); err != nil {
+		return nil, err
+	}
+
+	n := s.feeds.PollAll()
+	s.logger.Printf("[INFO] admin: poll all feeds, polled=%d", n)
+	return &newspb.PollAllFeedsResponse{Polled: int32(n)}, nil
+}
+
+// PauseFeed приостанавливает плановый опрос канала.
+func (s *Server) PauseFeed(ctx context.Context, req *newspb.FeedRequest) (*newspb.Feed, error) {
+	return s.feedAction(ctx, "pause", req.GetId(), service.FeedController.Pause)
+}
+
+// ResumeFeed возобновляет плановый опрос канала.
+func (s *Server) ResumeFeed(ctx context.Context, req *newspb.FeedRequest) (*newspb.Feed, error) {
+	return s.feedAction(ctx, "resume", req.GetId(), service.FeedController.Resume)
+}
+
+// feedAction выполняет действие action над каналом id
+// и возвращает состояние канала.
+func (s *Server) feedAction(ctx context.Context, name string, id int32,
+	action func(service.FeedController, int) error) (*newspb.Feed, error) {
+
+	if err := s.admin(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := action(s.feeds, int(id)); err != nil {
+		if errors.Is(err, rsscollector.ErrUnknownFeed) {
+			return nil, status.Errorf(codes.NotFound, "feed id=%d not found", id)
+		}
+		return nil, s.internal(name+" feed", err)
+	}
+	s.logger.Printf("[INFO] admin: %s feed id=%d", name, id)
+
+	f, err := s.feeds.Feed(int(id))
+	if err != nil {
+		return nil, s.internal(name+" feed", err)
+	}
+	return feed(f), nil
+}
+
+// admin проверяет, что запрос содержит токен администратора.
+// Если управление каналами выключено, возвращает Unimplemented.
+func (s *Server) admin(ctx context.Context) error {
+	if s.feeds == nil || s.adminToken == "" {
+		return status.Error(codes.Unimplemented, "admin API is disabled")
+	}
+
+	var auth string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("authorization"); len(v) > 0 {
+			auth = v[0]
+		}
+	}
+	if !service.Authorized(auth, s.adminToken) {
+		s.logger.Printf("[ERROR] admin: unauthorized request")
+		return status.Error(codes.Unauthenticated, "admin token required")
+	}
+
+	return nil
+}
+
+// internal логирует ошибку хранилища и возвращает
+// клиенту ошибку без подробностей.
+func (s *Server) internal(op string, err error) error {
+	s.logger.Printf("[ERROR] %s: %v", op, err)
+	return status.Error(codes.Internal, "internal server error")
+}
+
+// parseFilter переводит фильтр запроса в фильтр хранилища.
+func parseFilter(pf *newspb.Filter) (filter, error) {
+	f := filter{
+		TitleSearch:    pf.GetSearch(),
+		Exclude:        pf.GetExclude(),
+		Sources:        pf.GetSources(),
+		ExcludeSources: pf.GetExcludeSources(),
+	}
+
+	switch pf.GetMatch() {
+	case newspb.Match_MATCH_AUTO, newspb.Match_MATCH_FULLTEXT:
+		f.Match = storage.MatchFullText
+	case newspb.Match_MATCH_FUZZY:
+		f.Match = storage.MatchFuzzy
+	default:
+		return f, status.Errorf(codes.InvalidArgument, "unknown match %d", pf.GetMatch())
+	}
+
+	for _, ids := range [][]int64{f.Sources, f.ExcludeSources} {
+		for _, id := range ids {
+			if id < 1 {
+				return f, status.Errorf(codes.InvalidArgument, "bad source id %d", id)
+			}
+		}
+	}
+
+	if pf.GetFrom() < 0 || pf.GetTo() < 0 {
+		return f, status.Error(codes.InvalidArgument, "from and to must not be negative")
+	}
+	if pf.GetFrom() > 0 {
+		f.Date = storage.TimeFilter{Value: pf.GetFrom(), Operator: ">="}
+	}
+	if pf.GetTo() > 0 {
+		f.EndDate = storage.TimeFilter{Value: pf.GetTo(), Operator: "<"}
+	}
+
+	// проверяем синтаксис поискового запроса
+	if _, err := f.Query(); err != nil {
+		return f, status.Errorf(codes.InvalidArgument, "bad search or exclude: %v", err)
+	}
+
+	return f, nil
+}
+
+// news переводит новость хранилища в сообщение.
+func news(it item) *newspb.News {
+	return &newspb.News{
+		Id:             it.Id,
+		Title:          it.Title,
+		PubTime:        it.PubDate,
+		Content:        it.Description,
+		Link:           it.Link,
+		TitleSnippet:   it.TitleSnippet,
+		ContentSnippet: it.ContentSnippet,
+		Source: &newspb.Source{
+			Id:      it.Source.Id,
+			Name:    it.Source.Name,
+			Url:     it.Source.URL,
+			FeedUrl: it.Source.FeedURL,
+		},
+	}
+}
+
+// match переводит режим поиска хранилища в сообщение.
+func match(m storage.Match) newspb.Match {
+	if m == storage.MatchFuzzy {
+		return newspb.Match_MATCH_FUZZY
+	}
+	return newspb.Match_MATCH_FULLTEXT
+}
+
+// feed переводит состояние опроса канала в сообщение.
+func feed(f rsscollector.Feed) *newspb.Feed {
+	return &newspb.Feed{
+		Id:        int32(f.Id),
+		Url:       f.URL,
+		Paused:    f.Paused,
+		Polls:     uint32(f.Polls),
+		Errors:    uint32(f.Errors),
+		LastPoll:  f.LastPoll,
+		LastError: f.LastError,
+	}
+}
@@ -0,0 +1,306 @@
+package grpcapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rtemka/agg/news/pkg/broadcast"
+	"github.com/rtemka/agg/news/pkg/newspb"
+	"github.com/rtemka/agg/news/pkg/rsscollector"
+	"github.com/rtemka/agg/news/pkg/storage"
+	"github.com/rtemka/agg/news/pkg/storage/memdb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func testDB(t *testing.T, n int) *memdb.MemDB {
+	db := memdb.New()
+	items := make([]item, 0, n)
+	for i := 1; i <= n; i++ {
+		items = append(items, item{
+			Title:       fmt.Sprintf("новость go %d", i),
+			PubDate:     int64(5555555 + i),
+			Description: "sample discription",
+			Link:        fmt.Sprintf("https://test.com/%d", i),
+		})
+	}
+	if _, err := db.AddItems(context.Background(), items); err != nil {
+		t.Fatalf("AddItems() error = %v", err)
+	}
+	return db
+}
+
+// testClient запускает сервер srv в памяти и возвращает клиента к нему.
+func testClient(t *testing.T, srv *Server) newspb.NewsServiceClient {
+	lis := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	srv.Register(gs)
+	go func() { _ = gs.Serve(lis) }()
+	t.Cleanup(gs.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("DialContext() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return newspb.NewNewsServiceClient(conn)
+}
+
+func TestServer_ListNews(t *testing.T) {
+	client := testClient(t, New(testDB(t, 25), log.New(io.Discard, "", 0)))
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		req       *newspb.ListNewsRequest
+		wantCode  codes.Code
+		wantLen   int
+		wantPages int32
+		wantMatch newspb.Match
+	}{
+		{name: "default", req: &newspb.ListNewsRequest{}, wantLen: storage.PageSize, wantPages: 3},
+		{name: "last_page", req: &newspb.ListNewsRequest{Page: 3, PageSize: 10}, wantLen: 5, wantPages: 3},
+		{name: "dates", req: &newspb.ListNewsRequest{Filter: &newspb.Filter{From: 5555556, To: 5555559}},
+			wantLen: 3, wantPages: 1},
+		{name: "search", req: &newspb.ListNewsRequest{Filter: &newspb.Filter{Search: []string{"новость 12"}}},
+			wantLen: 1, wantPages: 1, wantMatch: newspb.Match_MATCH_FULLTEXT},
+		{name: "bad_page_size", req: &newspb.ListNewsRequest{PageSize: storage.MaxPageSize + 1},
+			wantCode: codes.InvalidArgument},
+		{name: "bad_source", req: &newspb.ListNewsRequest{Filter: &newspb.Filter{Sources: []int64{0}}},
+			wantCode: codes.InvalidArgument},
+		{name: "bad_search", req: &newspb.ListNewsRequest{Filter: &newspb.Filter{Search: []string{`"новость`}}},
+			wantCode: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.ListNews(ctx, tt.req)
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("ListNews() error = %v, want code = %v", err, tt.wantCode)
+			}
+			if err != nil {
+				return
+			}
+			if len(resp.News) != tt.wantLen || resp.TotalPages != tt.wantPages || resp.Match != tt.wantMatch {
+				t.Errorf("ListNews() got news = %d, pages = %d, match = %v, want = %d, %d, %v",
+					len(resp.News), resp.TotalPages, resp.Match, tt.wantLen, tt.wantPages, tt.wantMatch)
+			}
+		})
+	}
+
+	// описание обрезается так же, как ?view=short
+	resp, err := client.ListNews(ctx, &newspb.ListNewsRequest{PageSize: 1, View: newspb.View_VIEW_SHORT})
+	if err != nil {
+		t.Fatalf("ListNews() error = %v", err)
+	}
+	if len(resp.News) != 1 || resp.News[0].Content != "sample discription" {
+		t.Fatalf("ListNews() got = %v, want 1 news with content", resp.News)
+	}
+}
+
+func TestServer_GetNews(t *testing.T) {
+	db := testDB(t, 3)
+	client := testClient(t, New(db, log.New(io.Discard, "", 0)))
+
+	want, err := db.Item(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("Item() error = %v", err)
+	}
+
+	got, err := client.GetNews(context.Background(), &newspb.GetNewsRequest{Id: 2})
+	if err != nil {
+		t.Fatalf("GetNews() error = %v", err)
+	}
+	if got.Id != want.Id || got.Title != want.Title || got.PubTime != want.PubDate || got.Link != want.Link {
+		t.Fatalf("GetNews() got = %v, want = %+v", got, want)
+	}
+
+	_, err = client.GetNews(context.Background(), &newspb.GetNewsRequest{Id: 100})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("GetNews() error = %v, want code = %v", err, codes.NotFound)
+	}
+}
+
+func TestServer_StreamNews(t *testing.T) {
+	db := testDB(t, 3)
+	events := broadcast.New()
+	client := testClient(t, New(db, log.New(io.Discard, "", 0)).WithBroadcaster(events))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// продолжение после новости 1, поиск отбирает из новых только go
+	stream, err := client.StreamNews(ctx, &newspb.StreamNewsRequest{
+		Filter:  &newspb.Filter{Search: []string{"go"}},
+		AfterId: 1,
+	})
+	if err != nil {
+		t.Fatalf("StreamNews() error = %v", err)
+	}
+
+	recv := func() int64 {
+		t.Helper()
+		n, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv() error = %v", err)
+		}
+		return n.Id
+	}
+
+	for _, want := range []int64{2, 3} {
+		if got := recv(); got != want {
+			t.Fatalf("Recv() got id = %d, want = %d", got, want)
+		}
+	}
+
+	for events.Subscribers() == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	added := []item{
+		{Title: "новость rust", PubDate: 5555600, Link: "https://test.com/rust"},
+		{Title: "новость go 4", PubDate: 5555601, Link: "https://test.com/4"},
+	}
+	if _, err := db.AddItems(context.Background(), added); err != nil {
+		t.Fatalf("AddItems() error = %v", err)
+	}
+	events.Wake()
+
+	if got := recv(); got != 5 {
+		t.Fatalf("Recv() got id = %d, want = %d", got, 5)
+	}
+}
+
+// testFeeds - управление опросом каналов без опроса.
+type testFeeds struct {
+	feeds []rsscollector.Feed
+}
+
+func (tf *testFeeds) Feeds() []rsscollector.Feed {
+	return tf.feeds
+}
+
+func (tf *testFeeds) Feed(id int) (rsscollector.Feed, error) {
+	if id < 1 || id > len(tf.feeds) {
+		return rsscollector.Feed{}, rsscollector.ErrUnknownFeed
+	}
+	return tf.feeds[id-1], nil
+}
+
+func (tf *testFeeds) PollNow(id int) error {
+	if _, err := tf.Feed(id); err != nil {
+		return err
+	}
+	tf.feeds[id-1].Polls++
+	return nil
+}
+
+func (tf *testFeeds) PollAll() int {
+	n := 0
+	for i := range tf.feeds {
+		if !tf.feeds[i].Paused {
+			tf.feeds[i].Polls++
+			n++
+		}
+	}
+	return n
+}
+
+func (tf *testFeeds) Pause(id int) error {
+	if _, err := tf.Feed(id); err != nil {
+		return err
+	}
+	tf.feeds[id-1].Paused = true
+	return nil
+}
+
+func (tf *testFeeds) Resume(id int) error {
+	if _, err := tf.Feed(id); err != nil {
+		return err
+	}
+	tf.feeds[id-1].Paused = false
+	return nil
+}
+
+func TestServer_feeds(t *testing.T) {
+	const token = "secret"
+
+	feeds := &testFeeds{
+		feeds: []rsscollector.Feed{{Id: 1, URL: "https://test.com/rss"}, {Id: 2, URL: "https://test.com/atom"}},
+	}
+	srv := New(testDB(t, 1), log.New(io.Discard, "", 0))
+	client := testClient(t, srv)
+
+	auth := func(v string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "authorization", v)
+	}
+
+	// без токена управление каналами выключено
+	if _, err := client.ListFeeds(auth("Bearer "), &newspb.ListFeedsRequest{}); status.Code(err) != codes.Unimplemented {
+		t.Fatalf("ListFeeds() error = %v, want code = %v", err, codes.Unimplemented)
+	}
+
+	srv.WithAdmin(feeds, token)
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		call       func(context.Context, *newspb.FeedRequest, ...grpc.CallOption) (*newspb.Feed, error)
+		id         int32
+		wantCode   codes.Code
+		wantPaused bool
+	}{
+		{name: "no_token", ctx: context.Background(), call: client.PauseFeed, id: 1, wantCode: codes.Unauthenticated},
+		{name: "bad_token", ctx: auth("Bearer secret2"), call: client.PauseFeed, id: 1, wantCode: codes.Unauthenticated},
+		{name: "no_scheme", ctx: auth(token), call: client.PauseFeed, id: 1, wantCode: codes.Unauthenticated},
+		{name: "pause", ctx: auth("Bearer " + token), call: client.PauseFeed, id: 1, wantPaused: true},
+		{name: "poll_paused", ctx: auth("Bearer " + token), call: client.PollFeed, id: 1, wantPaused: true},
+		{name: "unknown_feed", ctx: auth("Bearer " + token), call: client.PollFeed, id: 3, wantCode: codes.NotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := tt.call(tt.ctx, &newspb.FeedRequest{Id: tt.id})
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("got error = %v, want code = %v", err, tt.wantCode)
+			}
+			if err != nil {
+				return
+			}
+			if f.Id != 1 || f.Paused != tt.wantPaused {
+				t.Errorf("got = %v, want feed 1 paused = %v", f, tt.wantPaused)
+			}
+		})
+	}
+
+	// приостановленный канал не опрашивается вместе со всеми
+	pa, err := client.PollAllFeeds(auth("Bearer "+token), &newspb.PollAllFeedsRequest{})
+	if err != nil || pa.Polled != 1 {
+		t.Fatalf("PollAllFeeds() got = %v, error = %v, want 1 polled", pa, err)
+	}
+
+	if _, err := client.ResumeFeed(auth("Bearer "+token), &newspb.FeedRequest{Id: 1}); err != nil {
+		t.Fatalf("ResumeFeed() error = %v", err)
+	}
+
+	list, err := client.ListFeeds(auth("Bearer "+token), &newspb.ListFeedsRequest{})
+	if err != nil {
+		t.Fatalf("ListFeeds() error = %v", err)
+	}
+	if len(list.Feeds) != 2 || list.Feeds[0].Paused || list.Feeds[0].Polls != 1 || list.Feeds[1].Polls != 1 {
+		t.Fatalf("ListFeeds() got = %v, want 2 feeds, none paused, 1 poll each", list.Feeds)
+	}
+}
@@ -0,0 +1,100 @@
+// пакет idqueue - очередь id новостей, добавленных в БД, для фоновых
+// обработчиков (сохраненные поиски, вебхуки).
+//
+// Очередь не ограничена и не теряет id: Push не блокирует запись
+// новостей, а обработчик забирает id пачками и при ошибке БД
+// возвращает их обратно. Хранятся только id, так что очередь растет
+// не больше, чем на количество новостей, записанных за время, пока
+// обработчик не успевает или БД недоступна.
+package idqueue
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rtemka/agg/news/pkg/storage"
+)
+
+// Queue - очередь id новостей.
+// Безопасна для конкурентного использования.
+type Queue struct {
+	mu   sync.Mutex
+	ids  []int64
+	wake chan struct{}
+}
+
+// New возвращает новый объект *Queue.
+func New() *Queue {
+	return &Queue{wake: make(chan struct{}, 1)}
+}
+
+// Push добавляет в очередь id новостей, не блокируясь.
+func (q *Queue) Push(items []storage.Item) {
+	if len(items) == 0 {
+		return
+	}
+
+	q.mu.Lock()
+	for i := range items {
+		q.ids = append(q.ids, items[i].Id)
+	}
+	q.mu.Unlock()
+
+	q.signal()
+}
+
+// Requeue возвращает id в начало очереди,
+// например после ошибки БД при их обработке.
+func (q *Queue) Requeue(ids []int64) {
+	if len(ids) == 0 {
+		return
+	}
+
+	q.mu.Lock()
+	q.ids = append(append(make([]int64, 0, len(ids)+len(q.ids)), ids...), q.ids...)
+	q.mu.Unlock()
+
+	q.signal()
+}
+
+// Next ждет, пока в очереди появятся id, и забирает до n первых
+// из них. Возвращает false, если контекст закрыт раньше.
+func (q *Queue) Next(ctx context.Context, n int) ([]int64, bool) {
+	for {
+		q.mu.Lock()
+		if len(q.ids) > 0 {
+			if n > len(q.ids) {
+				n = len(q.ids)
+			}
+			ids := append([]int64(nil), q.ids[:n]...)
+			q.ids = q.ids[n:]
+			if len(q.ids) == 0 {
+				q.ids = nil // отпускает память после всплеска
+			}
+			q.mu.Unlock()
+			return ids, true
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, false
+		case <-q.wake:
+		}
+	}
+}
+
+// Len возвращает количество id в очереди.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.ids)
+}
+
+// signal будит ожидающего в Next, сигналы склеиваются.
+func (q *Queue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
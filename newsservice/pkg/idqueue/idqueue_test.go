@@ -0,0 +1,61 @@
+package idqueue
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rtemka/agg/news/pkg/storage"
+)
+
+func items(ids ...int64) []storage.Item {
+	res := make([]storage.Item, len(ids))
+	for i, id := range ids {
+		res[i].Id = id
+	}
+	return res
+}
+
+func TestQueue(t *testing.T) {
+	q := New()
+	ctx := context.Background()
+
+	q.Push(items(1, 2, 3))
+	q.Push(nil)
+	q.Push(items(4))
+
+	got, ok := q.Next(ctx, 2)
+	if !ok || !reflect.DeepEqual(got, []int64{1, 2}) {
+		t.Fatalf("Next() got = %v, %v, want = [1 2], true", got, ok)
+	}
+
+	q.Requeue(got)
+	if q.Len() != 4 {
+		t.Fatalf("Len() got = %d, want = 4", q.Len())
+	}
+
+	got, ok = q.Next(ctx, 10)
+	if !ok || !reflect.DeepEqual(got, []int64{1, 2, 3, 4}) {
+		t.Fatalf("Next() got = %v, %v, want = [1 2 3 4], true", got, ok)
+	}
+}
+
+func TestQueue_Next_wait(t *testing.T) {
+	q := New()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if got, ok := q.Next(ctx, 1); ok {
+		t.Fatalf("Next() got = %v, want to wait until context is done", got)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		q.Push(items(5))
+	}()
+	got, ok := q.Next(context.Background(), 1)
+	if !ok || !reflect.DeepEqual(got, []int64{5}) {
+		t.Fatalf("Next() got = %v, %v, want = [5], true", got, ok)
+	}
+}
@@ -0,0 +1,5 @@
+// Package newspb содержит gRPC API сервиса новостей,
+// сгенерированное из news.proto.
+package newspb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative news.proto
@@ -0,0 +1,414 @@
+// API сервиса новостей для gRPC, повторяет REST API:
+// новости по фильтрам, одна новость, поток новых новостей
+// и управление опросом rss-каналов.
+//
+// После изменения нужно заново сгенерировать код (см. generate.go).
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: news.proto
+
+package newspb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	NewsService_ListNews_FullMethodName     = "/news.v1.NewsService/ListNews"
+	NewsService_GetNews_FullMethodName      = "/news.v1.NewsService/GetNews"
+	NewsService_StreamNews_FullMethodName   = "/news.v1.NewsService/StreamNews"
+	NewsService_ListFeeds_FullMethodName    = "/news.v1.NewsService/ListFeeds"
+	NewsService_PollFeed_FullMethodName     = "/news.v1.NewsService/PollFeed"
+	NewsService_PollAllFeeds_FullMethodName = "/news.v1.NewsService/PollAllFeeds"
+	NewsService_PauseFeed_FullMethodName    = "/news.v1.NewsService/PauseFeed"
+	NewsService_ResumeFeed_FullMethodName   = "/news.v1.NewsService/ResumeFeed"
+)
+
+// NewsServiceClient is the client API for NewsService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type NewsServiceClient interface {
+	// ListNews возвращает страницу новостей, отобранных фильтром, как /news.
+	ListNews(ctx context.Context, in *ListNewsRequest, opts ...grpc.CallOption) (*ListNewsResponse, error)
+	// GetNews возвращает новость по id, как /news/{id}.
+	GetNews(ctx context.Context, in *GetNewsRequest, opts ...grpc.CallOption) (*News, error)
+	// StreamNews передает новости после after_id и затем новые
+	// новости по мере добавления, как /news/stream.
+	StreamNews(ctx context.Context, in *StreamNewsRequest, opts ...grpc.CallOption) (NewsService_StreamNewsClient, error)
+	// Управление опросом rss-каналов, как /admin/feeds. Запросы должны
+	// содержать метаданные authorization: Bearer <токен администратора>.
+	ListFeeds(ctx context.Context, in *ListFeedsRequest, opts ...grpc.CallOption) (*ListFeedsResponse, error)
+	PollFeed(ctx context.Context, in *FeedRequest, opts ...grpc.CallOption) (*Feed, error)
+	PollAllFeeds(ctx context.Context, in *PollAllFeedsRequest, opts ...grpc.CallOption) (*PollAllFeedsResponse, error)
+	PauseFeed(ctx context.Context, in *FeedRequest, opts ...grpc.CallOption) (*Feed, error)
+	ResumeFeed(ctx context.Context, in *FeedRequest, opts ...grpc.CallOption) (*Feed, error)
+}
+
+type newsServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewNewsServiceClient(cc grpc.ClientConnInterface) NewsServiceClient {
+	return &newsServiceClient{cc}
+}
+
+func (c *newsServiceClient) ListNews(ctx context.Context, in *ListNewsRequest, opts ...grpc.CallOption) (*ListNewsResponse, error) {
+	out := new(ListNewsResponse)
+	err := c.cc.Invoke(ctx, NewsService_ListNews_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *newsServiceClient) GetNews(ctx context.Context, in *GetNewsRequest, opts ...grpc.CallOption) (*News, error) {
+	out := new(News)
+	err := c.cc.Invoke(ctx, NewsService_GetNews_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *newsServiceClient) StreamNews(ctx context.Context, in *StreamNewsRequest, opts ...grpc.CallOption) (NewsService_StreamNewsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &NewsService_ServiceDesc.Streams[0], NewsService_StreamNews_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &newsServiceStreamNewsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type NewsService_StreamNewsClient interface {
+	Recv() (*News, error)
+	grpc.ClientStream
+}
+
+type newsServiceStreamNewsClient struct {
+	grpc.ClientStream
+}
+
+func (x *newsServiceStreamNewsClient) Recv() (*News, error) {
+	m := new(News)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *newsServiceClient) ListFeeds(ctx context.Context, in *ListFeedsRequest, opts ...grpc.CallOption) (*ListFeedsResponse, error) {
+	out := new(ListFeedsResponse)
+	err := c.cc.Invoke(ctx, NewsService_ListFeeds_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *newsServiceClient) PollFeed(ctx context.Context, in *FeedRequest, opts ...grpc.CallOption) (*Feed, error) {
+	out := new(Feed)
+	err := c.cc.Invoke(ctx, NewsService_PollFeed_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *newsServiceClient) PollAllFeeds(ctx context.Context, in *PollAllFeedsRequest, opts ...grpc.CallOption) (*PollAllFeedsResponse, error) {
+	out := new(PollAllFeedsResponse)
+	err := c.cc.Invoke(ctx, NewsService_PollAllFeeds_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *newsServiceClient) PauseFeed(ctx context.Context, in *FeedRequest, opts ...grpc.CallOption) (*Feed, error) {
+	out := new(Feed)
+	err := c.cc.Invoke(ctx, NewsService_PauseFeed_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *newsServiceClient) ResumeFeed(ctx context.Context, in *FeedRequest, opts ...grpc.CallOption) (*Feed, error) {
+	out := new(Feed)
+	err := c.cc.Invoke(ctx, NewsService_ResumeFeed_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// NewsServiceServer is the server API for NewsService service.
+// All implementations must embed UnimplementedNewsServiceServer
+// for forward compatibility
+type NewsServiceServer interface {
+	// ListNews возвращает страницу новостей, отобранных фильтром, как /news.
+	ListNews(context.Context, *ListNewsRequest) (*ListNewsResponse, error)
+	// GetNews возвращает новость по id, как /news/{id}.
+	GetNews(context.Context, *GetNewsRequest) (*News, error)
+	// StreamNews передает новости после after_id и затем новые
+	// новости по мере добавления, как /news/stream.
+	StreamNews(*StreamNewsRequest, NewsService_StreamNewsServer) error
+	// Управление опросом rss-каналов, как /admin/feeds. Запросы должны
+	// содержать метаданные authorization: Bearer <токен администратора>.
+	ListFeeds(context.Context, *ListFeedsRequest) (*ListFeedsResponse, error)
+	PollFeed(context.Context, *FeedRequest) (*Feed, error)
+	PollAllFeeds(context.Context, *PollAllFeedsRequest) (*PollAllFeedsResponse, error)
+	PauseFeed(context.Context, *FeedRequest) (*Feed, error)
+	ResumeFeed(context.Context, *FeedRequest) (*Feed, error)
+	mustEmbedUnimplementedNewsServiceServer()
+}
+
+// UnimplementedNewsServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedNewsServiceServer struct {
+}
+
+func (UnimplementedNewsServiceServer) ListNews(context.Context, *ListNewsRequest) (*ListNewsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListNews not implemented")
+}
+func (UnimplementedNewsServiceServer) GetNews(context.Context, *GetNewsRequest) (*News, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetNews not implemented")
+}
+func (UnimplementedNewsServiceServer) StreamNews(*StreamNewsRequest, NewsService_StreamNewsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamNews not implemented")
+}
+func (UnimplementedNewsServiceServer) ListFeeds(context.Context, *ListFeedsRequest) (*ListFeedsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListFeeds not implemented")
+}
+func (UnimplementedNewsServiceServer) PollFeed(context.Context, *FeedRequest) (*Feed, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PollFeed not implemented")
+}
+func (UnimplementedNewsServiceServer) PollAllFeeds(context.Context, *PollAllFeedsRequest) (*PollAllFeedsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PollAllFeeds not implemented")
+}
+func (UnimplementedNewsServiceServer) PauseFeed(context.Context, *FeedRequest) (*Feed, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PauseFeed not implemented")
+}
+func (UnimplementedNewsServiceServer) ResumeFeed(context.Context, *FeedRequest) (*Feed, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResumeFeed not implemented")
+}
+func (UnimplementedNewsServiceServer) mustEmbedUnimplementedNewsServiceServer() {}
+
+// UnsafeNewsServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to NewsServiceServer will
+// result in compilation errors.
+type UnsafeNewsServiceServer interface {
+	mustEmbedUnimplementedNewsServiceServer()
+}
+
+func RegisterNewsServiceServer(s grpc.ServiceRegistrar, srv NewsServiceServer) {
+	s.RegisterService(&NewsService_ServiceDesc, srv)
+}
+
+func _NewsService_ListNews_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListNewsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NewsServiceServer).ListNews(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NewsService_ListNews_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NewsServiceServer).ListNews(ctx, req.(*ListNewsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NewsService_GetNews_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetNewsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NewsServiceServer).GetNews(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NewsService_GetNews_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NewsServiceServer).GetNews(ctx, req.(*GetNewsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NewsService_StreamNews_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamNewsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(NewsServiceServer).StreamNews(m, &newsServiceStreamNewsServer{stream})
+}
+
+type NewsService_StreamNewsServer interface {
+	Send(*News) error
+	grpc.ServerStream
+}
+
+type newsServiceStreamNewsServer struct {
+	grpc.ServerStream
+}
+
+func (x *newsServiceStreamNewsServer) Send(m *News) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _NewsService_ListFeeds_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListFeedsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NewsServiceServer).ListFeeds(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NewsService_ListFeeds_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NewsServiceServer).ListFeeds(ctx, req.(*ListFeedsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NewsService_PollFeed_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FeedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NewsServiceServer).PollFeed(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NewsService_PollFeed_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NewsServiceServer).PollFeed(ctx, req.(*FeedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NewsService_PollAllFeeds_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PollAllFeedsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NewsServiceServer).PollAllFeeds(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NewsService_PollAllFeeds_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NewsServiceServer).PollAllFeeds(ctx, req.(*PollAllFeedsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NewsService_PauseFeed_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FeedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NewsServiceServer).PauseFeed(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NewsService_PauseFeed_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NewsServiceServer).PauseFeed(ctx, req.(*FeedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NewsService_ResumeFeed_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FeedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NewsServiceServer).ResumeFeed(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NewsService_ResumeFeed_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NewsServiceServer).ResumeFeed(ctx, req.(*FeedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// NewsService_ServiceDesc is the grpc.ServiceDesc for NewsService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var NewsService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "news.v1.NewsService",
+	HandlerType: (*NewsServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListNews",
+			Handler:    _NewsService_ListNews_Handler,
+		},
+		{
+			MethodName: "GetNews",
+			Handler:    _NewsService_GetNews_Handler,
+		},
+		{
+			MethodName: "ListFeeds",
+			Handler:    _NewsService_ListFeeds_Handler,
+		},
+		{
+			MethodName: "PollFeed",
+			Handler:    _NewsService_PollFeed_Handler,
+		},
+		{
+			MethodName: "PollAllFeeds",
+			Handler:    _NewsService_PollAllFeeds_Handler,
+		},
+		{
+			MethodName: "PauseFeed",
+			Handler:    _NewsService_PauseFeed_Handler,
+		},
+		{
+			MethodName: "ResumeFeed",
+			Handler:    _NewsService_ResumeFeed_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamNews",
+			Handler:       _NewsService_StreamNews_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "news.proto",
+}
@@ -3,6 +3,7 @@ package rsscollector
 import (
 	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -21,15 +22,40 @@ import (
 type container = storage.ItemContainer
 type item = storage.Item
 
+// ErrUnknownFeed - нет rss-канала с таким id.
+var ErrUnknownFeed = errors.New("rsscollector: unknown feed")
+
 // Collector объект для обхода rss-ссылок
 type Collector struct {
 	logger *log.Logger
 	poll   poller
+	mu     sync.RWMutex
+	feeds  []*feed // каналы, опрашиваемые Poll, id канала - индекс + 1.
 	// когда установлен в true, логгирует промежуточные итоги,
 	// по-умолчанию false
 	debugMode bool
 }
 
+// Feed - состояние опроса rss-канала.
+type Feed struct {
+	Id        int    `json:"id"` // номер канала, как в логе: unit #001.
+	URL       string `json:"url"`
+	Paused    bool   `json:"paused"`               // плановые опросы приостановлены.
+	Polls     uint   `json:"polls"`                // количество опросов.
+	Errors    uint   `json:"errors"`               // количество неудачных опросов.
+	LastPoll  int64  `json:"last_poll"`            // время последнего опроса в UNIX формате.
+	LastError string `json:"last_error,omitempty"` // ошибка последнего опроса.
+}
+
+// feed - rss-канал, который опрашивает горутина Poll.
+type feed struct {
+	mu    sync.Mutex
+	state Feed
+	// trigger - сигнал внеочередного опроса, сигналы,
+	// пришедшие до начала опроса, объединяются в один.
+	trigger chan struct{}
+}
+
 // Новый объект *Collector
 func New(logger *log.Logger) *Collector {
 	return &Collector{
@@ -46,6 +72,8 @@ func (c *Collector) DebugMode(on bool) *Collector {
 }
 
 // Poll опрашивает переданные rss-ссылки c заданным интервалом времени.
+// Каналами можно управлять по id (номер ссылки с 1) через PollNow,
+// PollAll, Pause и Resume.
 func (c *Collector) Poll(ctx context.Context, interval time.Duration, links []string) (<-chan container, <-chan error, error) {
 
 	if len(links) == 0 {
@@ -54,6 +82,7 @@ func (c *Collector) Poll(ctx context.Context, interval time.Duration, links []st
 
 	dests := make([]<-chan container, len(links)) // по каналу для каждой rss-ссылки
 	errs := make([]<-chan error, len(links))      // каналы ошибок для каждой горутины
+	feeds := make([]*feed, len(links))
 
 	// Fan-Out мультиплексируем каналы
 	for i := 0; i < len(links); i++ {
@@ -62,25 +91,25 @@ func (c *Collector) Poll(ctx context.Context, interval time.Duration, links []st
 		dests[i] = ch
 		ech := make(chan error)
 		errs[i] = ech
+		feeds[i] = &feed{state: Feed{Id: i + 1, URL: links[i]}, trigger: make(chan struct{}, 1)}
 
-		go func(id int, values chan<- container, errors chan<- error, url string) {
+		go func(f *feed, values chan<- container, errors chan<- error) {
 
-			var fails uint // считаем ошибки во время работы горутины
-			var polls uint // считаем опросы горутины
+			id, url := f.state.Id, f.state.URL
 
 			defer func() {
-				c.logTotal(id, url, polls, fails) // лог общего итога
+				st := f.snapshot()
+				c.logTotal(id, url, st.Polls, st.Errors) // лог общего итога
 				close(values)
 				close(errors)
 			}()
 
 			poll := func() {
-				polls++
 				v, err := c.poll(ctx, url) // выполняем опрос
+				f.polled(err)
 				if err == nil {
 					values <- v
 				} else {
-					fails++
 					errors <- fmt.Errorf("rsscollector: poll: %w", err)
 				}
 				c.log(id, url, len(v.Items), err) // лог промежуточных итогов
@@ -88,21 +117,157 @@ func (c *Collector) Poll(ctx context.Context, interval time.Duration, links []st
 
 			poll() // первый опрос сразу
 
+			timer := time.NewTimer(interval)
+			defer timer.Stop()
+
 			for {
 				select {
-				case <-time.After(interval):
+				case <-timer.C:
+					if !f.snapshot().Paused {
+						poll()
+					}
+					timer.Reset(interval)
+				case <-f.trigger:
+					// после внеочередного опроса период отсчитывается заново
 					poll()
+					if !timer.Stop() {
+						<-timer.C
+					}
+					timer.Reset(interval)
 				case <-ctx.Done():
 					errors <- ctx.Err()
 					return
 				}
 			}
-		}(i+1, ch, ech, links[i])
+		}(feeds[i], ch, ech)
 	}
 
+	c.mu.Lock()
+	c.feeds = feeds
+	c.mu.Unlock()
+
 	return merge(dests...), merge(errs...), nil // Fan-In (демультиплексируем каналы)
 }
 
+// Feeds возвращает состояние опроса каналов,
+// до вызова Poll список пуст.
+func (c *Collector) Feeds() []Feed {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	feeds := make([]Feed, len(c.feeds))
+	for i, f := range c.feeds {
+		feeds[i] = f.snapshot()
+	}
+	return feeds
+}
+
+// Feed возвращает состояние опроса канала по id.
+func (c *Collector) Feed(id int) (Feed, error) {
+	f, err := c.feed(id)
+	if err != nil {
+		return Feed{}, err
+	}
+	return f.snapshot(), nil
+}
+
+// PollNow запрашивает внеочередной опрос канала, в том числе
+// приостановленного. Не ждет окончания опроса.
+func (c *Collector) PollNow(id int) error {
+	f, err := c.feed(id)
+	if err != nil {
+		return err
+	}
+	f.poll()
+	return nil
+}
+
+// PollAll запрашивает внеочередной опрос всех каналов,
+// кроме приостановленных. Возвращает количество каналов.
+func (c *Collector) PollAll() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	n := 0
+	for _, f := range c.feeds {
+		if !f.snapshot().Paused {
+			f.poll()
+			n++
+		}
+	}
+	return n
+}
+
+// Pause приостанавливает плановые опросы канала.
+func (c *Collector) Pause(id int) error {
+	return c.setPaused(id, true)
+}
+
+// Resume возобновляет плановые опросы канала,
+// следующий опрос - в свое время.
+func (c *Collector) Resume(id int) error {
+	return c.setPaused(id, false)
+}
+
+func (c *Collector) setPaused(id int, paused bool) error {
+	f, err := c.feed(id)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	f.state.Paused = paused
+	f.mu.Unlock()
+
+	if paused {
+		c.logger.Printf("[INFO] unit #%03d >> paused; task=%s", id, f.state.URL)
+	} else {
+		c.logger.Printf("[INFO] unit #%03d >> resumed; task=%s", id, f.state.URL)
+	}
+	return nil
+}
+
+// feed возвращает канал по id.
+func (c *Collector) feed(id int) (*feed, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if id < 1 || id > len(c.feeds) {
+		return nil, ErrUnknownFeed
+	}
+	return c.feeds[id-1], nil
+}
+
+// snapshot возвращает копию состояния канала.
+func (f *feed) snapshot() Feed {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.state
+}
+
+// polled учитывает опрос канала с ошибкой err.
+func (f *feed) polled(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.state.Polls++
+	f.state.LastPoll = time.Now().Unix()
+	f.state.LastError = ""
+	if err != nil {
+		f.state.Errors++
+		f.state.LastError = err.Error()
+	}
+}
+
+// poll запрашивает внеочередной опрос канала, не блокируя
+// вызывающего: если опрос уже запрошен, то новый не нужен.
+func (f *feed) poll() {
+	select {
+	case f.trigger <- struct{}{}:
+	default:
+	}
+}
+
 func (c *Collector) log(id int, url string, received int, err error) {
 	if err != nil {
 		c.logger.Printf("[ERROR] unit #%03d >> error=%v; task=%s", id, err, url)
@@ -185,7 +350,10 @@ func poll(ctx context.Context, url string) (container, error) {
 
 	chain := bodyCloser(statusChecker(xmlEnforcer(decfunc))) // цепочка обработчиков ответа
 
-	return cont, request(chain)
+	err = request(chain)
+	cont.SetSource(url) // связываем новости с каналом
+
+	return cont, err
 }
 
 // decoderWithSettings возвращает *xml.Decoder с настройками
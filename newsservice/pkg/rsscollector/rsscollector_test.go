@@ -10,11 +10,16 @@ import (
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/rtemka/agg/news/pkg/storage"
 )
 
 const xmlblob = `
-		<rss>
+		<rss xmlns:atom="http://www.w3.org/2005/Atom">
 			<channel>
+				<title>Тестовый канал</title>
+				<atom:link href="https://test.com/rss" rel="self"/>
+				<link>https://test.com</link>
 				<item>
 					<title>Тестовый заголовок</title>
 					<link>https://test.com</link>
@@ -41,6 +46,8 @@ func Test_poll(t *testing.T) {
 	}))
 	defer ts.Close()
 
+	want.Source = storage.Source{Name: "Тестовый канал", URL: "https://test.com", FeedURL: ts.URL}
+
 	got, err := poll(context.Background(), ts.URL)
 	if err != nil {
 		t.Fatalf("poll() error = %v", err)
@@ -127,3 +134,94 @@ func TestCollector_Poll(t *testing.T) {
 		}
 	})
 }
+
+func TestCollector_control(t *testing.T) {
+	var m sync.Mutex
+	polls := 0
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.Lock()
+		polls++
+		m.Unlock()
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprintln(w, xmlblob)
+	}))
+	defer ts.Close()
+
+	collector := New(log.New(io.Discard, "", 0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	// плановый опрос только первый, остальные - внеочередные
+	values, errs, err := collector.Poll(ctx, time.Hour, []string{ts.URL, ts.URL})
+	if err != nil {
+		t.Fatalf("Collector.Poll() error = %v", err)
+	}
+	received := make(chan struct{}, 10)
+	go func() {
+		for range values {
+			received <- struct{}{}
+		}
+	}()
+	go func() {
+		for range errs {
+		}
+	}()
+
+	// wait ждет n опросов
+	wait := func(n int) {
+		t.Helper()
+		for i := 0; i < n; i++ {
+			select {
+			case <-received:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Collector got no poll")
+			}
+		}
+	}
+	wait(2)
+
+	if err := collector.PollNow(1); err != nil {
+		t.Fatalf("Collector.PollNow() error = %v", err)
+	}
+	wait(1)
+
+	if err := collector.Pause(2); err != nil {
+		t.Fatalf("Collector.Pause() error = %v", err)
+	}
+	if n := collector.PollAll(); n != 1 {
+		t.Fatalf("Collector.PollAll() got = %d, want = 1", n)
+	}
+	wait(1)
+
+	// приостановленный канал опрашивается по запросу
+	if err := collector.PollNow(2); err != nil {
+		t.Fatalf("Collector.PollNow() error = %v", err)
+	}
+	wait(1)
+
+	if err := collector.Resume(2); err != nil {
+		t.Fatalf("Collector.Resume() error = %v", err)
+	}
+	if n := collector.PollAll(); n != 2 {
+		t.Fatalf("Collector.PollAll() got = %d, want = 2", n)
+	}
+	wait(2)
+
+	feeds := collector.Feeds()
+	if len(feeds) != 2 || feeds[0].Polls != 4 || feeds[1].Polls != 3 || feeds[1].Paused || feeds[0].LastPoll == 0 {
+		t.Fatalf("Collector.Feeds() got = %+v, want 2 feeds with 4 and 3 polls", feeds)
+	}
+	m.Lock()
+	if polls != 7 {
+		t.Fatalf("Collector got polls = %d, want = 7", polls)
+	}
+	m.Unlock()
+
+	for _, id := range []int{0, 3} {
+		if err := collector.PollNow(id); err != ErrUnknownFeed {
+			t.Fatalf("Collector.PollNow(%d) error = %v, want = %v", id, err, ErrUnknownFeed)
+		}
+	}
+
+	cancel()
+}
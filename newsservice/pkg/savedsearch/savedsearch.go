@@ -0,0 +1,138 @@
+// пакет savedsearch сопоставляет новые новости с сохраненными поисками.
+//
+// Новости, добавленные в БД, передаются через Notify, Matcher сверяет
+// каждую пачку новостей со всеми сохраненными поисками и записывает
+// совпадения в БД, из них складываются счетчики непрочитанного.
+// Очередь новостей не ограничена (см. пакет idqueue): пачки не
+// теряются, а при ошибке БД сопоставляются повторно.
+//
+// Поиски сверяются в БД тем же поиском хранилища, что и в /news
+// (с морфологией, нечетким поиском и фильтрами по датам): все поиски
+// сразу с новостями пачки по их id (см. storage.Storage.MatchFilters).
+package savedsearch
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/rtemka/agg/news/pkg/idqueue"
+	"github.com/rtemka/agg/news/pkg/storage"
+)
+
+// batchSize - сколько новостей из очереди сопоставляется за раз.
+const batchSize = 1000
+
+// retryDelay - пауза перед повтором после ошибки БД.
+const retryDelay = 5 * time.Second
+
+// Matcher сопоставляет новые новости с сохраненными поисками.
+type Matcher struct {
+	log      *log.Logger
+	news     storage.Storage          // новости, с которыми сверяются поиски.
+	searches storage.SavedSearchStore // поиски и их совпадения.
+	queue    *idqueue.Queue
+	retry    time.Duration // пауза перед повтором после ошибки БД.
+	// когда установлен в true, логгирует итоги каждой пачки,
+	// по-умолчанию false
+	debugMode bool
+}
+
+// New возвращает новый объект *Matcher.
+func New(log *log.Logger, news storage.Storage, searches storage.SavedSearchStore) *Matcher {
+	return &Matcher{
+		log:      log,
+		news:     news,
+		searches: searches,
+		queue:    idqueue.New(),
+		retry:    retryDelay,
+	}
+}
+
+// DebugMode переключает debug режим у *Matcher
+func (m *Matcher) DebugMode(on bool) *Matcher {
+	m.debugMode = on
+	return m
+}
+
+// Notify ставит новости в очередь сопоставления, не блокируя вызывающего.
+func (m *Matcher) Notify(items []storage.Item) {
+	m.queue.Push(items)
+}
+
+// Run сопоставляет новости из очереди, пока не закрыт контекст.
+// Если БД недоступна, новости возвращаются в очередь и
+// сопоставляются повторно после паузы.
+func (m *Matcher) Run(ctx context.Context) {
+	for {
+		ids, ok := m.queue.Next(ctx, batchSize)
+		if !ok {
+			return
+		}
+
+		err := m.match(ctx, ids)
+		if err == nil {
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		m.queue.Requeue(ids)
+		m.log.Printf("[ERROR] saved searches: items=%d queued=%d db_error=%v, retry in %s",
+			len(ids), m.queue.Len(), err, m.retry)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(m.retry):
+		}
+	}
+}
+
+// match сопоставляет новости ids со всеми поисками и записывает совпадения.
+func (m *Matcher) match(ctx context.Context, ids []int64) error {
+	searches, err := m.searches.SavedSearches(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	filters := make([]storage.Filter, 0, len(searches))
+	searchIDs := make([]int64, 0, len(searches))
+	for _, s := range searches {
+		// запрос проверяет API при сохранении поиска
+		if _, err := s.Filter.Query(); err != nil {
+			m.log.Printf("[ERROR] saved searches: id=%d filter_error=%v", s.Id, err)
+			continue
+		}
+		filters = append(filters, s.Filter)
+		searchIDs = append(searchIDs, s.Id)
+	}
+	if len(filters) == 0 {
+		return nil
+	}
+
+	matched, err := m.news.MatchFilters(ctx, ids, filters)
+	if err != nil {
+		return err
+	}
+
+	matches := make(map[int64][]int64)
+	for i, found := range matched {
+		if len(found) > 0 {
+			matches[searchIDs[i]] = found
+		}
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+	if err := m.searches.AddSearchMatches(ctx, matches); err != nil {
+		return err
+	}
+
+	if m.debugMode {
+		m.log.Printf("[DEBUG] saved searches: searches=%d items=%d matched_searches=%d",
+			len(searches), len(ids), len(matches))
+	}
+
+	return nil
+}
@@ -0,0 +1,250 @@
+package savedsearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rtemka/agg/news/pkg/storage"
+	"github.com/rtemka/agg/news/pkg/storage/memdb"
+)
+
+var (
+	ria = storage.Source{Name: "РИА Новости", FeedURL: "https://ria.ru/export/rss2/archive/index.xml"}
+	kom = storage.Source{Name: "Коммерсантъ", FeedURL: "https://www.kommersant.ru/RSS/news.xml"}
+)
+
+// testItems добавляет в БД новости и возвращает их с id.
+func testItems(t *testing.T, db *memdb.MemDB) []storage.Item {
+	res, err := db.AddItems(context.Background(), []storage.Item{
+		{Title: "Вышел go 1.19", Description: "Новая версия языка", Link: "https://test.com/1", PubDate: 100, Source: ria},
+		{Title: "Курс рубля", Description: "Биржевые новости", Link: "https://test.com/2", PubDate: 200, Source: ria},
+		{Title: "Go в банках", Description: "Язык go набирает популярность", Link: "https://test.com/3", PubDate: 300, Source: kom},
+	})
+	if err != nil {
+		t.Fatalf("AddItems() error = %v", err)
+	}
+	return res.Added
+}
+
+// addSearches добавляет в БД поиски и возвращает их id.
+func addSearches(t *testing.T, db *memdb.MemDB, filters []storage.Filter) []int64 {
+	ids := make([]int64, len(filters))
+	for i, f := range filters {
+		id, err := db.AddSavedSearch(context.Background(), storage.SavedSearch{Name: fmt.Sprint(i), Filter: f})
+		if err != nil {
+			t.Fatalf("AddSavedSearch() error = %v", err)
+		}
+		ids[i] = id
+	}
+	return ids
+}
+
+// matched возвращает id новостей, совпавших с поиском, по возрастанию.
+func matched(t *testing.T, db *memdb.MemDB, id int64) []int64 {
+	items, err := db.SearchMatches(context.Background(), id, 0, 100)
+	if err != nil {
+		t.Fatalf("SearchMatches() error = %v", err)
+	}
+	var ids []int64
+	for i := len(items) - 1; i >= 0; i-- {
+		ids = append(ids, items[i].Id)
+	}
+	return ids
+}
+
+func TestMatcher_match(t *testing.T) {
+	db := memdb.New()
+	items := testItems(t, db)
+
+	ids := addSearches(t, db, []storage.Filter{
+		{TitleSearch: []string{"go"}},
+		{TitleSearch: []string{"go"}, Exclude: []string{"банках"}},
+		{TitleSearch: []string{"рубля OR язык*"}},
+		{Sources: []int64{items[0].Source.Id}},
+		{TitleSearch: []string{"go"}, ExcludeSources: []int64{items[0].Source.Id}},
+		{TitleSearch: []string{"курс рубл"}, Match: storage.MatchFuzzy},
+		{TitleSearch: []string{"погода"}},
+		{TitleSearch: []string{"-go"}}, // ошибка, пропускается
+		{Date: storage.TimeFilter{Value: 200, Operator: ">="}},
+		{TitleSearch: []string{"go"}, EndDate: storage.TimeFilter{Value: 200, Operator: "<"}},
+	})
+
+	m := New(log.New(io.Discard, "", 0), db, db)
+	if err := m.match(context.Background(), []int64{items[0].Id, items[1].Id, items[2].Id}); err != nil {
+		t.Fatalf("match() error = %v", err)
+	}
+
+	want := [][]int64{{1, 3}, {1}, {1, 2, 3}, {1, 2}, {3}, {2}, nil, nil, {2, 3}, {1}}
+	for i, id := range ids {
+		if got := matched(t, db, id); !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("match() search %d got = %v, want = %v", i, got, want[i])
+		}
+	}
+}
+
+// TestMatcher_match_bulk сверяет совпадения с поиском
+// новостей через Items по каждому поиску отдельно.
+func TestMatcher_match_bulk(t *testing.T) {
+	db := memdb.New()
+	items := testItems(t, db)
+
+	words := []string{"go", "рубля", "язык", "банках", "новости", "погода"}
+	var filters []storage.Filter
+	for i := 0; i < 300; i++ {
+		terms := []string{words[i%len(words)]}
+		if i%3 == 0 {
+			terms = append(terms, words[(i/len(words))%len(words)])
+		}
+		if i%5 == 0 {
+			terms = []string{fmt.Sprintf("%s OR %s", words[i%len(words)], words[(i+1)%len(words)])}
+		}
+		filters = append(filters, storage.Filter{TitleSearch: terms})
+	}
+	ids := addSearches(t, db, filters)
+
+	itemIDs := make([]int64, len(items))
+	for i := range items {
+		itemIDs[i] = items[i].Id
+	}
+	m := New(log.New(io.Discard, "", 0), db, db)
+	if err := m.match(context.Background(), itemIDs); err != nil {
+		t.Fatalf("match() error = %v", err)
+	}
+
+	for i, f := range filters {
+		f.IDs, f.SortBy, f.PageSize = itemIDs, storage.ID, storage.MaxPageSize
+		found, err := db.Items(context.Background(), f)
+		if err != nil {
+			t.Fatalf("Items() error = %v", err)
+		}
+		var want []int64
+		for j := len(found) - 1; j >= 0; j-- {
+			want = append(want, found[j].Id)
+		}
+		if got := matched(t, db, ids[i]); !reflect.DeepEqual(got, want) {
+			t.Fatalf("match() search %v got = %v, want = %v", f.TitleSearch, got, want)
+		}
+	}
+}
+
+func TestMatcher_Run(t *testing.T) {
+	db := memdb.New()
+	ctx := context.Background()
+
+	id, err := db.AddSavedSearch(ctx, storage.SavedSearch{Name: "go", Filter: storage.Filter{TitleSearch: []string{"go"}}})
+	if err != nil {
+		t.Fatalf("AddSavedSearch() error = %v", err)
+	}
+
+	m := New(log.New(io.Discard, "", 0), db, db)
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		m.Run(runCtx)
+		close(done)
+	}()
+
+	m.Notify(testItems(t, db))
+
+	var s storage.SavedSearch
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Unread < 2 {
+		if s, err = db.SavedSearch(ctx, id); err != nil {
+			t.Fatalf("SavedSearch() error = %v", err)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("SavedSearch() got = %+v, want 2 unread", s)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// поиск, добавленный после запуска, тоже учитывается
+	id2, err := db.AddSavedSearch(ctx, storage.SavedSearch{Name: "рубль", Filter: storage.Filter{TitleSearch: []string{"рубля"}}})
+	if err != nil {
+		t.Fatalf("AddSavedSearch() error = %v", err)
+	}
+	res, err := db.AddItems(ctx, []storage.Item{{Title: "Курс рубля вырос", Link: "https://test.com/4", PubDate: 1}})
+	if err != nil {
+		t.Fatalf("AddItems() error = %v", err)
+	}
+	m.Notify(res.Added)
+
+	s = storage.SavedSearch{}
+	deadline = time.Now().Add(5 * time.Second)
+	for s.Unread < 1 {
+		if s, err = db.SavedSearch(ctx, id2); err != nil {
+			t.Fatalf("SavedSearch() error = %v", err)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("SavedSearch() got = %+v, want 1 unread", s)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	got, err := db.SearchMatches(ctx, id, 0, 10)
+	if err != nil || len(got) != 2 || got[0].Id != 3 || got[1].Id != 1 {
+		t.Fatalf("SearchMatches() got = %+v, %v, want items 3 and 1", got, err)
+	}
+}
+
+// flakyDB - БД, у которой первые fails вызовов MatchFilters завершаются ошибкой.
+type flakyDB struct {
+	*memdb.MemDB
+	mu    sync.Mutex
+	fails int
+}
+
+func (db *flakyDB) MatchFilters(ctx context.Context, ids []int64, filters []storage.Filter) ([][]int64, error) {
+	db.mu.Lock()
+	if db.fails > 0 {
+		db.fails--
+		db.mu.Unlock()
+		return nil, errors.New("db is down")
+	}
+	db.mu.Unlock()
+	return db.MemDB.MatchFilters(ctx, ids, filters)
+}
+
+func TestMatcher_Run_retry(t *testing.T) {
+	db := &flakyDB{MemDB: memdb.New(), fails: 2}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	id, err := db.AddSavedSearch(ctx, storage.SavedSearch{Name: "go", Filter: storage.Filter{TitleSearch: []string{"go"}}})
+	if err != nil {
+		t.Fatalf("AddSavedSearch() error = %v", err)
+	}
+
+	m := New(log.New(io.Discard, "", 0), db, db)
+	m.retry = time.Millisecond
+	// пачек больше, чем влезало в прежнюю очередь, ни одна не теряется
+	for i := 0; i < 150; i++ {
+		res, err := db.AddItems(ctx, []storage.Item{{Title: "go", Link: fmt.Sprintf("https://test.com/%d", i), PubDate: int64(i + 1)}})
+		if err != nil {
+			t.Fatalf("AddItems() error = %v", err)
+		}
+		m.Notify(res.Added)
+	}
+	go m.Run(ctx)
+
+	var s storage.SavedSearch
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Unread < 150 {
+		if s, err = db.SavedSearch(ctx, id); err != nil {
+			t.Fatalf("SavedSearch() error = %v", err)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("SavedSearch() got = %+v, want 150 unread", s)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,161 @@
+// пакет service содержит логику сервиса новостей, общую для REST
+// (пакет api) и gRPC (пакет grpcapi) API: поиск с переходом к нечеткому
+// поиску, поток новых новостей и управление опросом rss-каналов.
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+	"time"
+
+	"github.com/rtemka/agg/news/pkg/rsscollector"
+	"github.com/rtemka/agg/news/pkg/storage"
+)
+
+// dbTimeout - сколько ждать ответа хранилища.
+const dbTimeout = 5 * time.Second
+
+// StreamPoll - как часто поток проверяет новые новости, если
+// сигналов о них нет (или сигналы потерялись).
+const StreamPoll = 30 * time.Second
+
+// FeedController - управление опросом rss-каналов
+// по id канала, реализуется *rsscollector.Collector.
+type FeedController interface {
+	Feeds() []rsscollector.Feed
+	Feed(id int) (rsscollector.Feed, error)
+	PollNow(id int) error
+	PollAll() int
+	Pause(id int) error
+	Resume(id int) error
+}
+
+// Items возвращает страницу новостей, отобранных фильтром f, и общее
+// количество таких новостей (для пагинации). Если auto и полнотекстовый
+// поиск ничего не нашел, то новости ищутся нечетким поиском, режим
+// поиска в f меняется на storage.MatchFuzzy.
+func Items(ctx context.Context, db storage.Storage, f *storage.Filter, auto bool) ([]storage.Item, int, error) {
+	total, err := db.CountItems(ctx, *f)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	q, err := f.Query()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// одни исключения - не поиск
+	if auto && q.HasTerms() && f.Match == storage.MatchFullText && total == 0 {
+		f.Match = storage.MatchFuzzy
+		if total, err = db.CountItems(ctx, *f); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	items, err := db.Items(ctx, *f)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return items, total, nil
+}
+
+// LastID возвращает id последней добавленной новости, 0 - новостей нет.
+// С него начинается поток новостей, если клиент не передал, с какой
+// новости продолжить.
+func LastID(ctx context.Context, db storage.Storage) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	items, err := db.Items(ctx, storage.Filter{Page: 1, PageSize: 1, SortBy: storage.ID})
+	if err != nil || len(items) == 0 {
+		return 0, err
+	}
+	return items[0].Id, nil
+}
+
+// StreamWindow - на сколько id назад от последней переданной новости
+// поток перечитывает новости. id новостей выдаются при вставке, а видны
+// новости после фиксации транзакции, поэтому новость с меньшим id может
+// появиться в БД позже новости с большим id (например, когда пишут
+// несколько экземпляров сервиса). Окно не меньше пачки streamwriter.
+const StreamWindow = 500
+
+// Cursor - позиция в потоке новостей. Передает новости по возрастанию id,
+// каждый раз перечитывая окно StreamWindow за наибольшим переданным id
+// и пропуская уже переданные новости, так что новости, зафиксированные
+// не по порядку id, не теряются.
+type Cursor struct {
+	db    storage.Storage
+	f     storage.Filter
+	floor int64          // новости с id не больше floor не передаются.
+	skip  int64          // при первом чтении новости до skip считаются переданными.
+	last  int64          // наибольший id переданной новости.
+	sent  map[int64]bool // переданные новости из окна.
+}
+
+// NewCursor возвращает курсор потока новостей, отобранных фильтром f,
+// после новости after. Если resume, то поток продолжает прерванный:
+// новости окна за after, уже видные в БД, клиент получил, а те, что
+// появятся в нем позже, передаются.
+func NewCursor(db storage.Storage, f storage.Filter, after int64, resume bool) *Cursor {
+	c := &Cursor{db: db, f: f, floor: after, last: after, sent: make(map[int64]bool)}
+	if resume {
+		c.floor, c.skip = after-StreamWindow, after
+	}
+	return c
+}
+
+// Last возвращает наибольший id переданной новости, с него
+// клиенту нужно продолжать поток после переподключения.
+func (c *Cursor) Last() int64 {
+	return c.last
+}
+
+// Next передает send новости, появившиеся после прошлого вызова,
+// по возрастанию id. Last уже учитывает новость, переданную send.
+func (c *Cursor) Next(ctx context.Context, send func(storage.Item) error) error {
+	f := c.f
+	f.AfterID = c.last - StreamWindow
+	if f.AfterID < c.floor {
+		f.AfterID = c.floor
+	}
+	if f.AfterID < 0 {
+		f.AfterID = 0
+	}
+
+	skip := c.skip
+	c.skip = 0
+	err := c.db.Export(ctx, f, func(it storage.Item) error {
+		if c.sent[it.Id] {
+			return nil
+		}
+		c.sent[it.Id] = true
+		if it.Id <= skip {
+			return nil
+		}
+		if it.Id > c.last {
+			c.last = it.Id
+		}
+		return send(it)
+	})
+
+	// новости, вышедшие из окна, больше не перечитываются
+	for id := range c.sent {
+		if id <= c.last-StreamWindow {
+			delete(c.sent, id)
+		}
+	}
+
+	return err
+}
+
+// Authorized сообщает, что значение заголовка авторизации auth
+// ("Bearer <token>") содержит токен администратора token.
+// Пустой token означает, что административное API выключено.
+func Authorized(auth, token string) bool {
+	t := strings.TrimPrefix(auth, "Bearer ")
+	return token != "" && t != auth && subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/rtemka/agg/news/pkg/storage"
+	"github.com/rtemka/agg/news/pkg/storage/memdb"
+)
+
+func TestItems(t *testing.T) {
+	db := memdb.New()
+	ctx := context.Background()
+	if _, err := db.AddItems(ctx, []storage.Item{
+		{Title: "Курс рубля", Link: "https://test.com/1", PubDate: 1},
+		{Title: "Вышел go 1.19", Link: "https://test.com/2", PubDate: 2},
+	}); err != nil {
+		t.Fatalf("AddItems() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		f         storage.Filter
+		auto      bool
+		wantTotal int
+		wantMatch storage.Match
+	}{
+		{name: "fulltext", f: storage.Filter{TitleSearch: []string{"go"}}, auto: true, wantTotal: 1},
+		{name: "auto_fuzzy", f: storage.Filter{TitleSearch: []string{"курс рубл"}}, auto: true,
+			wantTotal: 1, wantMatch: storage.MatchFuzzy},
+		{name: "no_auto", f: storage.Filter{TitleSearch: []string{"курс рубл"}}, wantTotal: 0},
+		{name: "exclude_only", f: storage.Filter{Exclude: []string{"погода"}}, auto: true, wantTotal: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, total, err := Items(ctx, db, &tt.f, tt.auto)
+			if err != nil {
+				t.Fatalf("Items() error = %v", err)
+			}
+			if total != tt.wantTotal || len(items) != tt.wantTotal || tt.f.Match != tt.wantMatch {
+				t.Fatalf("Items() got = %d items, total = %d, match = %s, want = %d, %s",
+					len(items), total, tt.f.Match, tt.wantTotal, tt.wantMatch)
+			}
+		})
+	}
+
+	id, err := LastID(ctx, db)
+	if err != nil || id != 2 {
+		t.Fatalf("LastID() got = %d, %v, want = 2", id, err)
+	}
+}
+
+// uncommittedDB - хранилище, в котором новости hidden еще не
+// зафиксированы: они уже получили id, но выгрузка их не видит.
+type uncommittedDB struct {
+	*memdb.MemDB
+	hidden map[int64]bool
+}
+
+func (db *uncommittedDB) Export(ctx context.Context, f storage.Filter, fn func(storage.Item) error) error {
+	return db.MemDB.Export(ctx, f, func(it storage.Item) error {
+		if db.hidden[it.Id] {
+			return nil
+		}
+		return fn(it)
+	})
+}
+
+func TestCursor(t *testing.T) {
+	db := &uncommittedDB{MemDB: memdb.New(), hidden: map[int64]bool{3: true, 7: true}}
+	ctx := context.Background()
+	for i := 1; i <= 8; i++ {
+		if _, err := db.AddItems(ctx, []storage.Item{{Title: "go", Link: fmt.Sprintf("https://test.com/%d", i), PubDate: int64(i)}}); err != nil {
+			t.Fatalf("AddItems() error = %v", err)
+		}
+	}
+
+	next := func(c *Cursor, want []int64, wantLast int64) {
+		t.Helper()
+		var got []int64
+		if err := c.Next(ctx, func(it storage.Item) error {
+			got = append(got, it.Id)
+			return nil
+		}); err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) || c.Last() != wantLast {
+			t.Fatalf("Next() got = %v, last = %d, want = %v, last = %d", got, c.Last(), want, wantLast)
+		}
+	}
+
+	// новый поток после новости 1
+	fresh := NewCursor(db, storage.Filter{}, 1, false)
+	// поток продолжен после новости 5: новости до нее клиент уже получил
+	resumed := NewCursor(db, storage.Filter{}, 5, true)
+
+	next(fresh, []int64{2, 4, 5, 6, 8}, 8)
+	next(resumed, []int64{6, 8}, 8)
+
+	// новости 3 и 7 зафиксированы после новостей с большими id
+	db.hidden = nil
+	next(fresh, []int64{3, 7}, 8)
+	next(resumed, []int64{3, 7}, 8)
+	next(fresh, nil, 8)
+	next(resumed, nil, 8)
+}
+
+func TestAuthorized(t *testing.T) {
+	tests := []struct {
+		auth, token string
+		want        bool
+	}{
+		{auth: "Bearer secret", token: "secret", want: true},
+		{auth: "Bearer secret2", token: "secret", want: false},
+		{auth: "secret", token: "secret", want: false},
+		{auth: "", token: "secret", want: false},
+		{auth: "Bearer ", token: "", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := Authorized(tt.auth, tt.token); got != tt.want {
+			t.Errorf("Authorized(%q, %q) = %v, want = %v", tt.auth, tt.token, got, tt.want)
+		}
+	}
+}
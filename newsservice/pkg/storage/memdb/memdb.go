@@ -2,61 +2,843 @@ package memdb
 
 import (
 	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
 
 	"github.com/rtemka/agg/news/pkg/storage"
+	"github.com/rtemka/agg/news/pkg/storage/query"
 )
 
-// MemDB - заглушка настоящей БД
-type MemDB struct{}
+var ErrNoRows = errors.New("memdb: no rows in result set")
+
+// Настройки поиска, повторяющие настройки Postgres.
+const (
+	// порог схожести для нечеткого поиска (pg_trgm.word_similarity_threshold).
+	fuzzyThreshold = 0.6
+	// порог схожести для подсказок (pg_trgm.similarity_threshold).
+	suggestThreshold = 0.3
+	// вес совпадения в описании относительно совпадения в заголовке.
+	descriptionWeight = 0.4
+)
+
+// MemDB - хранилище новостей в памяти.
+// Безопасно для конкурентного использования.
+type MemDB struct {
+	mu     sync.RWMutex
+	items  []storage.Item   // новости в порядке добавления.
+	byLink map[string]int64 // id новости по ссылке.
+	byID   map[int64]int    // индекс новости в items по id.
+	nextID int64            // следующий свободный id.
+
+	sources      map[string]storage.Source // источники по адресу rss-канала.
+	nextSourceID int64                     // следующий свободный id источника.
+
+	webhooks       []storage.Webhook  // подписки по возрастанию id.
+	deliveries     []storage.Delivery // журнал доставки в порядке записи.
+	nextWebhookID  int64              // следующий свободный id подписки.
+	nextDeliveryID int64              // следующий свободный id записи журнала.
+
+	searches     []storage.SavedSearch // сохраненные поиски по возрастанию id.
+	matches      map[int64][]int64     // id совпавших новостей по id поиска, по возрастанию.
+	nextSearchID int64                 // следующий свободный id поиска.
+}
 
 func New() *MemDB {
-	return &MemDB{}
+	return &MemDB{
+		byLink: make(map[string]int64),
+		byID:   make(map[int64]int),
+		nextID: 1,
+
+		sources:      make(map[string]storage.Source),
+		nextSourceID: 1,
+
+		nextWebhookID:  1,
+		nextDeliveryID: 1,
+
+		matches:      make(map[int64][]int64),
+		nextSearchID: 1,
+	}
 }
 
-// SampleItem можно использовать для тестов
-var SampleItem = storage.Item{
-	Id:          1,
-	Title:       "sample item",
-	PubDate:     5555555,
-	Description: "sample discription",
-	Link:        "https://test.com",
+// Item находит по id и возвращает новость.
+func (db *MemDB) Item(_ context.Context, id int64) (storage.Item, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	i, ok := db.byID[id]
+	if !ok {
+		return storage.Item{}, ErrNoRows
+	}
+	return db.items[i], nil
 }
 
-// Item возвращает один экземпляр SampleItem
-func (db *MemDB) Item(_ context.Context, _ int64) (storage.Item, error) {
-	return SampleItem, nil
+// scored - новость с рангом совпадения с поисковым запросом.
+type scored struct {
+	item storage.Item
+	rank float64
 }
 
-// Items возвращает столько Item, сколько запрошено
-func (db *MemDB) Items(_ context.Context, _ storage.Filter) ([]storage.Item, error) {
-	items := make([]storage.Item, 0, storage.PageSize)
-	for i := 0; i < storage.PageSize; i++ {
-		items = append(items, SampleItem)
+// Items возвращает списком новости отобранные согласно фильтру.
+func (db *MemDB) Items(_ context.Context, f storage.Filter) ([]storage.Item, error) {
+	q, err := f.Query()
+	if err != nil {
+		return nil, err
 	}
+
+	db.mu.RLock()
+	matched := db.filter(&f, q)
+	db.mu.RUnlock()
+
+	sortItems(matched, f.SortBy, q.HasTerms())
+
+	matched = paginate(matched, f.Page, f.Limit())
+
+	items := make([]storage.Item, 0, len(matched))
+	for _, s := range matched {
+		it := s.item
+		if q.HasTerms() && f.Match == storage.MatchFullText {
+			it.TitleSnippet = q.Highlight(it.Title, storage.SnippetStartSel, storage.SnippetStopSel)
+			it.ContentSnippet = q.Highlight(it.Description, storage.SnippetStartSel, storage.SnippetStopSel)
+		}
+		f.Trim(&it)
+		items = append(items, it)
+	}
+
 	return items, nil
 }
 
-func (db *MemDB) CountItems(_ context.Context, _ storage.Filter) (int, error) {
-	return storage.PageSize, nil
+// CountItems возвращает количество новостей, отобранных согласно фильтру.
+func (db *MemDB) CountItems(_ context.Context, f storage.Filter) (int, error) {
+	q, err := f.Query()
+	if err != nil {
+		return 0, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return len(db.filter(&f, q)), nil
+}
+
+// filter отбирает новости согласно фильтру и запросу q.
+// Вызывается под блокировкой на чтение.
+func (db *MemDB) filter(f *storage.Filter, q *query.Query) []scored {
+	var out []scored
+
+	var fuzzy string
+	var excl *query.Query
+	if f.Match == storage.MatchFuzzy && q.HasTerms() {
+		fuzzy = strings.Join(q.Terms(), " ")
+		excl = q.Exclusions()
+	}
+
+	var ids map[int64]bool
+	if len(f.IDs) > 0 {
+		ids = make(map[int64]bool, len(f.IDs))
+		for _, id := range f.IDs {
+			ids[id] = true
+		}
+	}
+
+	for _, it := range db.items {
+		if ids != nil && !ids[it.Id] {
+			continue
+		}
+		if !f.Date.Match(it.PubDate) || !f.EndDate.Match(it.PubDate) {
+			continue
+		}
+		if len(f.Sources) > 0 && !contains(f.Sources, it.Source.Id) {
+			continue
+		}
+		if contains(f.ExcludeSources, it.Source.Id) {
+			continue
+		}
+		if f.AfterID > 0 && it.Id <= f.AfterID {
+			continue
+		}
+
+		var rank float64
+		switch {
+		case q.Empty():
+		case fuzzy != "":
+			rank = query.WordSimilarity(fuzzy, it.Title)
+			if rank < fuzzyThreshold {
+				continue
+			}
+			if excl != nil && excl.Match(words(&it)) {
+				continue
+			}
+		default:
+			if !q.Match(words(&it)) {
+				continue
+			}
+			rank = float64(q.Hits(query.Words(it.Title))) +
+				descriptionWeight*float64(q.Hits(query.Words(it.Description)))
+		}
+
+		out = append(out, scored{item: it, rank: rank})
+	}
+
+	return out
+}
+
+// words возвращает слова заголовка и описания новости.
+func words(it *storage.Item) []string {
+	return append(query.Words(it.Title), query.Words(it.Description)...)
 }
 
-// AddItem - no-op
-func (db *MemDB) AddItem(_ context.Context, _ storage.Item) error {
+// contains сообщает, есть ли id источника в ids,
+// новости без источника (id 0) не содержатся ни в одном списке.
+func contains(ids []int64, id int64) bool {
+	if id == 0 {
+		return false
+	}
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// sortItems сортирует новости так же, как Postgres.
+func sortItems(items []scored, by storage.Sort, search bool) {
+	var less func(a, b *scored) bool
+
+	switch {
+	case by == storage.Title:
+		less = func(a, b *scored) bool { return a.item.Title > b.item.Title }
+	case by == storage.Rank && search:
+		less = func(a, b *scored) bool { return a.rank > b.rank }
+	case by == storage.ID:
+		less = func(a, b *scored) bool { return a.item.Id > b.item.Id }
+	default:
+		less = func(a, b *scored) bool { return a.item.PubDate > b.item.PubDate }
+	}
+
+	sort.SliceStable(items, func(i, j int) bool { return less(&items[i], &items[j]) })
+}
+
+// paginate возвращает страницу page размером size,
+// если page < 1, то возвращаются все новости.
+func paginate(items []scored, page, size int) []scored {
+	if page < 1 {
+		return items
+	}
+	offset := (page - 1) * size
+	if offset >= len(items) {
+		return nil
+	}
+	end := offset + size
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[offset:end]
+}
+
+// AddItem добавляет новость, если новость
+// с такой ссылкой уже есть, то no-op.
+func (db *MemDB) AddItem(ctx context.Context, item storage.Item) error {
+	_, err := db.AddItems(ctx, []storage.Item{item})
+	return err
+}
+
+// AddItems добавляет новости списком, уже известные новости
+// обновляет, если они изменились, иначе пропускает.
+func (db *MemDB) AddItems(_ context.Context, items []storage.Item) (storage.AddResult, error) {
+	items, res := storage.UniqueByLink(items)
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for _, it := range items {
+		feed := it.Source.FeedURL
+		it.TitleSnippet, it.ContentSnippet = "", ""
+		it.Source = db.source(it.Source)
+
+		if id, ok := db.byLink[it.Link]; ok {
+			i := db.byID[id]
+			old := db.items[i]
+			if old.Title == it.Title && old.Description == it.Description &&
+				old.PubDate == it.PubDate && old.Source.Id == it.Source.Id {
+				res.Count(feed, storage.AddSkipped)
+				continue
+			}
+			it.Id = old.Id
+			db.items[i] = it
+			res.Count(feed, storage.AddUpdated)
+			continue
+		}
+
+		it.Id = db.nextID
+		db.nextID++
+
+		db.byLink[it.Link] = it.Id
+		db.byID[it.Id] = len(db.items)
+		db.items = append(db.items, it)
+		res.Count(feed, storage.AddInserted)
+		res.Added = append(res.Added, it)
+	}
+
+	return res, nil
+}
+
+// source возвращает сохраненный источник по адресу rss-канала,
+// добавляя его или обновляя название и адрес сайта.
+// Вызывается под блокировкой на запись.
+func (db *MemDB) source(src storage.Source) storage.Source {
+	if src.FeedURL == "" {
+		return storage.Source{}
+	}
+
+	if old, ok := db.sources[src.FeedURL]; ok {
+		src.Id = old.Id
+	} else {
+		src.Id = db.nextSourceID
+		db.nextSourceID++
+	}
+	db.sources[src.FeedURL] = src
+
+	return src
+}
+
+// DeleteItem удаляет новость по id.
+func (db *MemDB) DeleteItem(ctx context.Context, item storage.Item) error {
+	n, err := db.DeleteItems(ctx, []int64{item.Id})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRows
+	}
 	return nil
 }
 
-// AddItems - no-op
-func (db *MemDB) AddItems(_ context.Context, _ []storage.Item) error {
+// DeleteItems удаляет новости по id,
+// возвращает количество удаленных.
+func (db *MemDB) DeleteItems(_ context.Context, ids []int64) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	del := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		if _, ok := db.byID[id]; ok {
+			del[id] = true
+		}
+	}
+	if len(del) == 0 {
+		return 0, nil
+	}
+
+	kept := db.items[:0]
+	for _, it := range db.items {
+		if del[it.Id] {
+			delete(db.byLink, it.Link)
+			delete(db.byID, it.Id)
+			continue
+		}
+		db.byID[it.Id] = len(kept)
+		kept = append(kept, it)
+	}
+	db.items = kept
+
+	return len(del), nil
+}
+
+// UpdateItem обновляет новость по id.
+func (db *MemDB) UpdateItem(_ context.Context, item storage.Item) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	i, ok := db.byID[item.Id]
+	if !ok {
+		return ErrNoRows
+	}
+
+	if old := db.items[i].Link; old != item.Link {
+		delete(db.byLink, old)
+		db.byLink[item.Link] = item.Id
+	}
+	item.TitleSnippet, item.ContentSnippet = "", ""
+	item.Source = db.source(item.Source)
+	db.items[i] = item
+
 	return nil
 }
 
-// DeleteItem - no-op
-func (db *MemDB) DeleteItem(_ context.Context, _ storage.Item) error {
+// Sources возвращает все известные источники по возрастанию id.
+func (db *MemDB) Sources(_ context.Context) ([]storage.Source, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	sources := make([]storage.Source, 0, len(db.sources))
+	for _, src := range db.sources {
+		sources = append(sources, src)
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].Id < sources[j].Id })
+
+	return sources, nil
+}
+
+// Suggest подбирает для каждого слова наиболее похожее
+// из слов, встречающихся более чем в одном заголовке.
+func (db *MemDB) Suggest(_ context.Context, terms []string) ([]string, error) {
+	db.mu.RLock()
+	ndoc := make(map[string]int)
+	for _, it := range db.items {
+		seen := make(map[string]bool)
+		for _, w := range query.Words(it.Title) {
+			if !seen[w] {
+				seen[w] = true
+				ndoc[w]++
+			}
+		}
+	}
+	db.mu.RUnlock()
+
+	out := make([]string, 0, len(terms))
+	for _, t := range terms {
+		best, bestSim, bestN := t, 0.0, 0
+		for w, n := range ndoc {
+			if n < 2 {
+				continue
+			}
+			sim := query.Similarity(w, t)
+			if sim <= suggestThreshold {
+				continue
+			}
+			// при равной схожести выбираем более частое слово,
+			// при равной частоте - первое по алфавиту
+			if sim > bestSim || sim == bestSim && (n > bestN || n == bestN && w < best) {
+				best, bestSim, bestN = w, sim, n
+			}
+		}
+		out = append(out, best)
+	}
+
+	return out, nil
+}
+
+// Stats возвращает сводную статистику по новостям, отобранным фильтром.
+func (db *MemDB) Stats(_ context.Context, f storage.Filter, interval storage.Interval) (storage.Stats, error) {
+	q, err := f.Query()
+	if err != nil {
+		return storage.Stats{}, err
+	}
+
+	db.mu.RLock()
+	matched := db.filter(&f, q)
+	db.mu.RUnlock()
+
+	stats := storage.Stats{Total: len(matched)}
+
+	counts := make(map[int64]int)
+	sources := make(map[storage.Source]int)
+	terms := make(map[string]int)
+	secs := interval.Seconds()
+
+	for _, m := range matched {
+		counts[m.item.PubDate/secs*secs]++
+		sources[m.item.Source]++
+
+		seen := make(map[string]bool)
+		for _, w := range query.Words(m.item.Title) {
+			if !seen[w] && utf8.RuneCountInString(w) >= storage.MinTermLen && !storage.IsStopWord(w) {
+				seen[w] = true
+				terms[w]++
+			}
+		}
+	}
+
+	for t, n := range counts {
+		stats.Counts = append(stats.Counts, storage.TimeCount{Time: t, Count: n})
+	}
+	sort.Slice(stats.Counts, func(i, j int) bool { return stats.Counts[i].Time < stats.Counts[j].Time })
+
+	for src, n := range sources {
+		stats.Sources = append(stats.Sources, storage.SourceCount{Source: src, Count: n})
+	}
+	sort.Slice(stats.Sources, func(i, j int) bool {
+		a, b := stats.Sources[i], stats.Sources[j]
+		return a.Count > b.Count || a.Count == b.Count && a.Source.Id < b.Source.Id
+	})
+
+	for t, n := range terms {
+		stats.Terms = append(stats.Terms, storage.TermCount{Term: t, Count: n})
+	}
+	sort.Slice(stats.Terms, func(i, j int) bool {
+		a, b := stats.Terms[i], stats.Terms[j]
+		return a.Count > b.Count || a.Count == b.Count && a.Term < b.Term
+	})
+	if len(stats.Terms) > storage.TopTerms {
+		stats.Terms = stats.Terms[:storage.TopTerms]
+	}
+
+	return stats, nil
+}
+
+// Export вызывает fn для каждой новости, отобранной фильтром, по возрастанию id.
+func (db *MemDB) Export(ctx context.Context, f storage.Filter, fn func(storage.Item) error) error {
+	q, err := f.Query()
+	if err != nil {
+		return err
+	}
+
+	db.mu.RLock()
+	matched := db.filter(&f, q)
+	db.mu.RUnlock()
+
+	sort.Slice(matched, func(i, j int) bool { return matched[i].item.Id < matched[j].item.Id })
+
+	for _, m := range matched {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(m.item); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MatchFilters сопоставляет новости ids с фильтрами filters
+// и возвращает для каждого фильтра id подошедших новостей.
+func (db *MemDB) MatchFilters(_ context.Context, ids []int64, filters []storage.Filter) ([][]int64, error) {
+	matched := make([][]int64, len(filters))
+	if len(ids) == 0 {
+		return matched, nil
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	for i, f := range filters {
+		f.IDs = ids
+		q, err := f.Query()
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range db.filter(&f, q) {
+			matched[i] = append(matched[i], m.item.Id)
+		}
+		sort.Slice(matched[i], func(a, b int) bool { return matched[i][a] < matched[i][b] })
+	}
+
+	return matched, nil
+}
+
+// Related возвращает новости, похожие по тексту на новость id:
+// чем больше слов новости встречается в заголовке (и с меньшим
+// весом в описании) другой новости, тем она похожее.
+func (db *MemDB) Related(_ context.Context, id int64, window int64, limit int) ([]storage.Item, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	i, ok := db.byID[id]
+	if !ok {
+		return nil, nil
+	}
+	src := db.items[i]
+
+	srcWords := make(map[string]bool)
+	for _, w := range query.Words(src.Title + " " + src.Description) {
+		if utf8.RuneCountInString(w) >= storage.MinTermLen {
+			srcWords[w] = true
+		}
+	}
+
+	// hits считает слова новости-источника среди words
+	hits := func(words []string) int {
+		var n int
+		seen := make(map[string]bool)
+		for _, w := range words {
+			if srcWords[w] && !seen[w] {
+				seen[w] = true
+				n++
+			}
+		}
+		return n
+	}
+
+	// из новостей с одинаковым заголовком остается самая похожая
+	byTitle := make(map[string]scored)
+	srcTitle := strings.ToLower(src.Title)
+
+	for _, it := range db.items {
+		title := strings.ToLower(it.Title)
+		if it.Id == src.Id || title == srcTitle {
+			continue
+		}
+		if it.PubDate < src.PubDate-window || it.PubDate > src.PubDate+window {
+			continue
+		}
+
+		rank := float64(hits(query.Words(it.Title))) +
+			descriptionWeight*float64(hits(query.Words(it.Description)))
+		if rank == 0 {
+			continue
+		}
+
+		if old, ok := byTitle[title]; !ok || rank > old.rank || rank == old.rank && it.Id < old.item.Id {
+			byTitle[title] = scored{item: it, rank: rank}
+		}
+	}
+
+	matched := make([]scored, 0, len(byTitle))
+	for _, s := range byTitle {
+		matched = append(matched, s)
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		if a.rank != b.rank {
+			return a.rank > b.rank
+		}
+		if a.item.PubDate != b.item.PubDate {
+			return a.item.PubDate > b.item.PubDate
+		}
+		return a.item.Id < b.item.Id
+	})
+	if len(matched) > limit {
+		matched = matched[:limit]
+	}
+
+	items := make([]storage.Item, 0, len(matched))
+	for _, s := range matched {
+		items = append(items, s.item)
+	}
+
+	return items, nil
+}
+
+// Webhooks возвращает все подписки по возрастанию id.
+func (db *MemDB) Webhooks(_ context.Context) ([]storage.Webhook, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	webhooks := make([]storage.Webhook, len(db.webhooks))
+	copy(webhooks, db.webhooks)
+
+	return webhooks, nil
+}
+
+// Webhook находит по id и возвращает подписку.
+func (db *MemDB) Webhook(_ context.Context, id int64) (storage.Webhook, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	for _, wh := range db.webhooks {
+		if wh.Id == id {
+			return wh, nil
+		}
+	}
+	return storage.Webhook{}, ErrNoRows
+}
+
+// AddWebhook добавляет подписку и возвращает ее id.
+func (db *MemDB) AddWebhook(_ context.Context, wh storage.Webhook) (int64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	wh.Id = db.nextWebhookID
+	db.nextWebhookID++
+	db.webhooks = append(db.webhooks, wh)
+
+	return wh.Id, nil
+}
+
+// DeleteWebhook удаляет подписку вместе с журналом доставки.
+func (db *MemDB) DeleteWebhook(_ context.Context, id int64) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	i := sort.Search(len(db.webhooks), func(i int) bool { return db.webhooks[i].Id >= id })
+	if i == len(db.webhooks) || db.webhooks[i].Id != id {
+		return ErrNoRows
+	}
+	db.webhooks = append(db.webhooks[:i], db.webhooks[i+1:]...)
+
+	kept := db.deliveries[:0]
+	for _, d := range db.deliveries {
+		if d.WebhookID != id {
+			kept = append(kept, d)
+		}
+	}
+	db.deliveries = kept
+
+	return nil
+}
+
+// AddDelivery записывает в журнал попытку доставки.
+func (db *MemDB) AddDelivery(_ context.Context, d storage.Delivery) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	d.Id = db.nextDeliveryID
+	db.nextDeliveryID++
+	db.deliveries = append(db.deliveries, d)
+
 	return nil
 }
 
-// UpdateItem - no-op
-func (db *MemDB) UpdateItem(_ context.Context, _ storage.Item) error {
+// Deliveries возвращает до limit последних
+// попыток доставки по подписке, последние первыми.
+func (db *MemDB) Deliveries(_ context.Context, webhookID int64, limit int) ([]storage.Delivery, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var deliveries []storage.Delivery
+	for i := len(db.deliveries) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		if db.deliveries[i].WebhookID == webhookID {
+			deliveries = append(deliveries, db.deliveries[i])
+		}
+	}
+
+	return deliveries, nil
+}
+
+// SavedSearches возвращает сохраненные поиски владельца
+// (пустой owner - всех) по возрастанию id.
+func (db *MemDB) SavedSearches(_ context.Context, owner string) ([]storage.SavedSearch, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var searches []storage.SavedSearch
+	for _, s := range db.searches {
+		if owner != "" && s.Owner != owner {
+			continue
+		}
+		s.Unread = db.unread(s)
+		searches = append(searches, s)
+	}
+
+	return searches, nil
+}
+
+// unread считает непрочитанные совпадения поиска,
+// удаленные новости не учитываются.
+// Вызывается под блокировкой на чтение.
+func (db *MemDB) unread(s storage.SavedSearch) int {
+	var n int
+	for _, id := range db.matches[s.Id] {
+		if _, ok := db.byID[id]; ok && id > s.LastRead {
+			n++
+		}
+	}
+	return n
+}
+
+// search возвращает индекс поиска по id или -1.
+// Вызывается под блокировкой.
+func (db *MemDB) search(id int64) int {
+	i := sort.Search(len(db.searches), func(i int) bool { return db.searches[i].Id >= id })
+	if i == len(db.searches) || db.searches[i].Id != id {
+		return -1
+	}
+	return i
+}
+
+// SavedSearch находит по id и возвращает сохраненный поиск.
+func (db *MemDB) SavedSearch(_ context.Context, id int64) (storage.SavedSearch, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	i := db.search(id)
+	if i < 0 {
+		return storage.SavedSearch{}, ErrNoRows
+	}
+	s := db.searches[i]
+	s.Unread = db.unread(s)
+
+	return s, nil
+}
+
+// AddSavedSearch добавляет сохраненный поиск и возвращает его id.
+func (db *MemDB) AddSavedSearch(_ context.Context, s storage.SavedSearch) (int64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	s.Id = db.nextSearchID
+	db.nextSearchID++
+	s.Unread = 0
+	db.searches = append(db.searches, s)
+
+	return s.Id, nil
+}
+
+// DeleteSavedSearch удаляет сохраненный поиск вместе с совпадениями.
+func (db *MemDB) DeleteSavedSearch(_ context.Context, id int64) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	i := db.search(id)
+	if i < 0 {
+		return ErrNoRows
+	}
+	db.searches = append(db.searches[:i], db.searches[i+1:]...)
+	delete(db.matches, id)
+
+	return nil
+}
+
+// AddSearchMatches записывает совпадения новостей с сохраненными поисками.
+func (db *MemDB) AddSearchMatches(_ context.Context, matches map[int64][]int64) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for searchID, ids := range matches {
+		if db.search(searchID) < 0 {
+			continue
+		}
+		known := make(map[int64]bool, len(db.matches[searchID]))
+		for _, id := range db.matches[searchID] {
+			known[id] = true
+		}
+		for _, id := range ids {
+			if _, ok := db.byID[id]; !ok || known[id] {
+				continue
+			}
+			known[id] = true
+			db.matches[searchID] = append(db.matches[searchID], id)
+		}
+		m := db.matches[searchID]
+		sort.Slice(m, func(i, j int) bool { return m[i] < m[j] })
+	}
+
+	return nil
+}
+
+// SearchMatches возвращает до limit новостей, совпавших с поиском,
+// с id больше afterID, последние добавленные первыми.
+func (db *MemDB) SearchMatches(_ context.Context, id int64, afterID int64, limit int) ([]storage.Item, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var items []storage.Item
+	m := db.matches[id]
+	for i := len(m) - 1; i >= 0 && m[i] > afterID && len(items) < limit; i-- {
+		if j, ok := db.byID[m[i]]; ok {
+			items = append(items, db.items[j])
+		}
+	}
+
+	return items, nil
+}
+
+// MarkSearchRead отмечает прочитанными новости поиска до lastID включительно.
+func (db *MemDB) MarkSearchRead(_ context.Context, id int64, lastID int64) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	i := db.search(id)
+	if i < 0 {
+		return ErrNoRows
+	}
+	db.searches[i].LastRead = lastID
+
 	return nil
 }
 
@@ -0,0 +1,99 @@
+package memdb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/rtemka/agg/news/pkg/storage"
+	"github.com/rtemka/agg/news/pkg/storage/storagetest"
+)
+
+func TestMemDB(t *testing.T) {
+	db := New()
+
+	storagetest.Run(t, db)
+
+	// тесты, специфичные для MemDB
+
+	t.Run("Suggest()", func(t *testing.T) {
+		terms := []string{"заголвок", "неизвестное"}
+		want := []string{"заголовок", "неизвестное"}
+
+		got, err := db.Suggest(context.Background(), terms)
+		if err != nil {
+			t.Fatalf("Suggest() error = %v", err)
+		}
+
+		if len(got) != len(want) {
+			t.Fatalf("Suggest() got terms = %d, want = %d", len(got), len(want))
+		}
+
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("Suggest() got = %q, want = %q", got[i], want[i])
+			}
+		}
+	})
+
+	t.Run("UpdateItem()_DeleteItem()", func(t *testing.T) {
+		want := storagetest.Item2
+		want.Title = "Новый заголовок"
+
+		if err := db.UpdateItem(context.Background(), want); err != nil {
+			t.Fatalf("UpdateItem() error = %v", err)
+		}
+
+		got, err := db.Item(context.Background(), want.Id)
+		if err != nil {
+			t.Fatalf("Item() error = %v", err)
+		}
+		if got != want {
+			t.Fatalf("Item() got = %v, want = %v", got, want)
+		}
+
+		if err := db.DeleteItem(context.Background(), storagetest.Item1); err != nil {
+			t.Fatalf("DeleteItem() error = %v", err)
+		}
+		if _, err := db.Item(context.Background(), storagetest.Item1.Id); err != ErrNoRows {
+			t.Fatalf("Item() error = %v, want = %v", err, ErrNoRows)
+		}
+		if got, err = db.Item(context.Background(), storagetest.Item3.Id); err != nil || got != storagetest.Item3 {
+			t.Fatalf("Item() got = %v, %v, want = %v", got, err, storagetest.Item3)
+		}
+	})
+}
+
+func TestMemDB_concurrent(t *testing.T) {
+	db := New()
+
+	const writers, perWriter = 8, 50
+
+	var wg sync.WaitGroup
+	wg.Add(writers * 2)
+	for w := 0; w < writers; w++ {
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				it := storage.Item{Title: "go", PubDate: 1, Link: fmt.Sprintf("https://test.com/%d/%d", w, i)}
+				_, _ = db.AddItems(context.Background(), []storage.Item{it})
+			}
+		}(w)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				_, _ = db.Items(context.Background(), storage.Filter{Page: 1, TitleSearch: []string{"go"}})
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, err := db.CountItems(context.Background(), storage.Filter{})
+	if err != nil {
+		t.Fatalf("CountItems() error = %v", err)
+	}
+	if want := writers * perWriter; got != want {
+		t.Fatalf("CountItems() got = %d, want = %d", got, want)
+	}
+}
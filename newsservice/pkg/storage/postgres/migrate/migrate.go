@@ -0,0 +1,313 @@
+// пакет migrate применяет и откатывает версионные миграции схемы БД.
+//
+// Миграции встроены в бинарный файл и лежат в каталоге migrations
+// в виде пар файлов NNNN_имя.up.sql и NNNN_имя.down.sql, где NNNN -
+// номер версии. Номера версий идут подряд, начиная с 1.
+// Примененные версии хранятся в таблице schema_migrations.
+package migrate
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// lockKey - ключ advisory lock, которым несколько экземпляров
+// сервиса не дают друг другу применять миграции одновременно.
+const lockKey int64 = 0x6e657773 // "news"
+
+var ErrNoChange = errors.New("migrate: no change")
+
+// Migration - миграция схемы БД.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string // SQL применения.
+	Down    string // SQL отката.
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// Status - состояние миграции.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator применяет миграции к БД.
+type Migrator struct {
+	conn       *pgx.Conn
+	migrations []Migration
+}
+
+// Connect выполняет подключение к БД
+// и возвращает объект для работы с миграциями.
+func Connect(ctx context.Context, connString string) (*Migrator, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := pgx.Connect(ctx, connString)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{conn: conn, migrations: migrations}, nil
+}
+
+// Close закрывает подключение к БД.
+func (m *Migrator) Close() error {
+	return m.conn.Close(context.Background())
+}
+
+// Load читает встроенные миграции, проверяет их
+// и возвращает отсортированными по версии.
+func Load() ([]Migration, error) {
+	return load(migrationsFS, "migrations")
+}
+
+func load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+
+		version, mname, direction, err := parseFileName(name)
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: mname}
+			byVersion[version] = m
+		}
+		if m.Name != mname {
+			return nil, fmt.Errorf("migrate: version %d has different names: %q and %q", version, m.Name, mname)
+		}
+
+		if direction == "up" {
+			m.Up = string(b)
+		} else {
+			m.Down = string(b)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migrate: missing migration version %d", i+1)
+		}
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migrate: migration %s must have both up and down files", m)
+		}
+	}
+
+	return migrations, nil
+}
+
+// parseFileName разбирает имя файла вида NNNN_имя.up.sql.
+func parseFileName(name string) (version int, mname, direction string, err error) {
+	base := strings.TrimSuffix(name, ".sql")
+
+	switch {
+	case strings.HasSuffix(base, ".up"):
+		direction = "up"
+	case strings.HasSuffix(base, ".down"):
+		direction = "down"
+	default:
+		return 0, "", "", fmt.Errorf("migrate: bad file name %q: must end with .up.sql or .down.sql", name)
+	}
+	base = strings.TrimSuffix(base, "."+direction)
+
+	v, mname, ok := strings.Cut(base, "_")
+	if !ok || mname == "" {
+		return 0, "", "", fmt.Errorf("migrate: bad file name %q: must be NNNN_name.%s.sql", name, direction)
+	}
+
+	version, err = strconv.Atoi(v)
+	if err != nil || version < 1 {
+		return 0, "", "", fmt.Errorf("migrate: bad file name %q: bad version %q", name, v)
+	}
+
+	return version, mname, direction, nil
+}
+
+// Up применяет все еще не примененные миграции
+// и возвращает их. Если применять нечего, то возвращает ErrNoChange.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+
+	err := m.locked(ctx, func() error {
+		current, err := m.version(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			if mig.Version <= current {
+				continue
+			}
+
+			err := m.conn.BeginFunc(ctx, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, mig.Up); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx,
+					`INSERT INTO schema_migrations(version, name) VALUES ($1, $2);`, mig.Version, mig.Name)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migrate: up %s: %w", mig, err)
+			}
+
+			applied = append(applied, mig)
+		}
+
+		return nil
+	})
+	if err == nil && len(applied) == 0 {
+		err = ErrNoChange
+	}
+
+	return applied, err
+}
+
+// Down откатывает последнюю примененную миграцию
+// и возвращает ее. Если откатывать нечего, то возвращает ErrNoChange.
+func (m *Migrator) Down(ctx context.Context) (Migration, error) {
+	var reverted Migration
+
+	err := m.locked(ctx, func() error {
+		current, err := m.version(ctx)
+		if err != nil {
+			return err
+		}
+		if current == 0 {
+			return ErrNoChange
+		}
+		if current > len(m.migrations) {
+			return fmt.Errorf("migrate: database version %d is newer than known migrations", current)
+		}
+
+		mig := m.migrations[current-1]
+		err = m.conn.BeginFunc(ctx, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, mig.Down); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1;`, mig.Version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migrate: down %s: %w", mig, err)
+		}
+
+		reverted = mig
+		return nil
+	})
+
+	return reverted, err
+}
+
+// Status возвращает состояние всех известных миграций.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.conn.Query(ctx, `SELECT version, applied_at FROM schema_migrations;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var v int
+		var at time.Time
+		if err := rows.Scan(&v, &at); err != nil {
+			return nil, err
+		}
+		applied[v] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	out := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		at, ok := applied[mig.Version]
+		out = append(out, Status{Migration: mig, Applied: ok, AppliedAt: at})
+	}
+
+	return out, nil
+}
+
+// locked выполняет f, удерживая advisory lock
+// на время применения миграций.
+func (m *Migrator) locked(ctx context.Context, f func() error) error {
+	if _, err := m.conn.Exec(ctx, `SELECT pg_advisory_lock($1);`, lockKey); err != nil {
+		return fmt.Errorf("migrate: acquire lock: %w", err)
+	}
+	defer func() {
+		_, _ = m.conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1);`, lockKey)
+	}()
+
+	if err := m.ensureTable(ctx); err != nil {
+		return err
+	}
+
+	return f()
+}
+
+// ensureTable создает таблицу версий, если ее нет.
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	_, err := m.conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);`)
+	return err
+}
+
+// version возвращает номер последней примененной миграции.
+func (m *Migrator) version(ctx context.Context) (int, error) {
+	var v int
+	err := m.conn.QueryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations;`).Scan(&v)
+	return v, err
+}
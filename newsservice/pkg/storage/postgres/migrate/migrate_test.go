@@ -0,0 +1,75 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if len(migrations) == 0 {
+		t.Fatalf("Load() got no migrations")
+	}
+
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("Load() got version = %d, want = %d", m.Version, i+1)
+		}
+		if strings.TrimSpace(m.Up) == "" || strings.TrimSpace(m.Down) == "" {
+			t.Errorf("Load() migration %s has empty up or down SQL", m)
+		}
+	}
+}
+
+func Test_load_errors(t *testing.T) {
+	file := &fstest.MapFile{Data: []byte("SELECT 1;")}
+
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		wantMsg string
+	}{
+		{
+			name:    "missing_down",
+			files:   fstest.MapFS{"m/0001_init.up.sql": file},
+			wantMsg: "must have both up and down files",
+		},
+		{
+			name: "version_gap",
+			files: fstest.MapFS{
+				"m/0001_init.up.sql": file, "m/0001_init.down.sql": file,
+				"m/0003_next.up.sql": file, "m/0003_next.down.sql": file,
+			},
+			wantMsg: "missing migration version 2",
+		},
+		{
+			name:    "bad_direction",
+			files:   fstest.MapFS{"m/0001_init.sql": file},
+			wantMsg: "must end with .up.sql or .down.sql",
+		},
+		{
+			name:    "bad_version",
+			files:   fstest.MapFS{"m/first_init.up.sql": file},
+			wantMsg: "bad version",
+		},
+		{
+			name:    "names_differ",
+			files:   fstest.MapFS{"m/0001_init.up.sql": file, "m/0001_other.down.sql": file},
+			wantMsg: "different names",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := load(tt.files, "m")
+			if err == nil || !strings.Contains(err.Error(), tt.wantMsg) {
+				t.Fatalf("load() error = %v, want it to contain %q", err, tt.wantMsg)
+			}
+		})
+	}
+}
@@ -1,5 +1,3 @@
-DROP TABLE IF EXISTS news;
-
 -- таблица с rss-новостями
 CREATE TABLE IF NOT EXISTS news (
     id BIGSERIAL PRIMARY KEY,
@@ -11,4 +9,4 @@ CREATE TABLE IF NOT EXISTS news (
 );
 
 CREATE INDEX IF NOT EXISTS pub_date_idx ON news(pub_date DESC);
-CREATE INDEX IF NOT EXISTS title_idx ON news USING GIN (title_search);
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS title_idx ON news USING GIN (title_search);
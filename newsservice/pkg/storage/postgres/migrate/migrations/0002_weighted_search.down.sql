@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS search_idx;
+ALTER TABLE news DROP COLUMN IF EXISTS search;
+ALTER TABLE news ADD COLUMN IF NOT EXISTS title_search tsvector
+    generated always as(to_tsvector('russian', title)) stored;
+
+CREATE INDEX IF NOT EXISTS title_idx ON news USING GIN (title_search);
@@ -0,0 +1,9 @@
+-- вектор для полнотекстового поиска: заголовок (вес A) и описание (вес B)
+DROP INDEX IF EXISTS title_idx;
+ALTER TABLE news DROP COLUMN IF EXISTS title_search;
+ALTER TABLE news ADD COLUMN IF NOT EXISTS search tsvector generated always as(
+    setweight(to_tsvector('russian', title), 'A') ||
+    setweight(to_tsvector('russian', description), 'B')
+) stored;
+
+CREATE INDEX IF NOT EXISTS search_idx ON news USING GIN (search);
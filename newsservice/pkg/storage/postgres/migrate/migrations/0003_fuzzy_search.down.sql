@@ -0,0 +1,2 @@
+DROP MATERIALIZED VIEW IF EXISTS title_terms;
+DROP INDEX IF EXISTS title_trgm_idx;
@@ -0,0 +1,12 @@
+-- триграммы для нечеткого поиска
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS title_trgm_idx ON news USING GIN (title gin_trgm_ops);
+
+-- частые слова заголовков для подсказок "возможно, вы имели в виду"
+CREATE MATERIALIZED VIEW IF NOT EXISTS title_terms AS
+    SELECT word, ndoc FROM ts_stat('SELECT to_tsvector(''simple'', title) FROM news')
+    WHERE ndoc > 1;
+
+CREATE UNIQUE INDEX IF NOT EXISTS title_terms_word_idx ON title_terms(word);
+CREATE INDEX IF NOT EXISTS title_terms_trgm_idx ON title_terms USING GIN (word gin_trgm_ops);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS news_source_idx;
+ALTER TABLE news DROP COLUMN IF EXISTS source_id;
+DROP TABLE IF EXISTS sources;
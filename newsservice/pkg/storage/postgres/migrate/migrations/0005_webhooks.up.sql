@@ -0,0 +1,24 @@
+-- подписки на новые новости
+CREATE TABLE IF NOT EXISTS webhooks (
+    id BIGSERIAL PRIMARY KEY,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL DEFAULT '',
+    terms TEXT[] NOT NULL DEFAULT '{}',
+    sources BIGINT[] NOT NULL DEFAULT '{}',
+    created BIGINT NOT NULL DEFAULT 0
+);
+
+-- журнал доставки новостей по подпискам
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id BIGINT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event TEXT NOT NULL,
+    items BIGINT[] NOT NULL DEFAULT '{}',
+    attempt INTEGER NOT NULL,
+    status INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    time BIGINT NOT NULL,
+    duration_ms BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_idx ON webhook_deliveries(webhook_id, id);
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS news_added ON news;
+DROP FUNCTION IF EXISTS notify_news_added();
@@ -0,0 +1,12 @@
+-- уведомление о новых новостях для потока /news/stream всех экземпляров сервиса,
+-- одно на запрос, а не на каждую строку
+CREATE OR REPLACE FUNCTION notify_news_added() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('news_added', '');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS news_added ON news;
+CREATE TRIGGER news_added AFTER INSERT ON news
+    FOR EACH STATEMENT EXECUTE FUNCTION notify_news_added();
@@ -0,0 +1,20 @@
+-- сохраненные поиски, фильтр хранится в JSON
+CREATE TABLE IF NOT EXISTS saved_searches (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    owner TEXT NOT NULL DEFAULT '',
+    filter JSONB NOT NULL DEFAULT '{}',
+    created BIGINT NOT NULL DEFAULT 0,
+    last_read BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS saved_searches_owner_idx ON saved_searches(owner, id);
+
+-- новости, совпавшие с сохраненными поисками
+CREATE TABLE IF NOT EXISTS saved_search_matches (
+    search_id BIGINT NOT NULL REFERENCES saved_searches(id) ON DELETE CASCADE,
+    item_id BIGINT NOT NULL REFERENCES news(id) ON DELETE CASCADE,
+    PRIMARY KEY (search_id, item_id)
+);
+
+CREATE INDEX IF NOT EXISTS saved_search_matches_item_idx ON saved_search_matches(item_id);
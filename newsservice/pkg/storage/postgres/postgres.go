@@ -2,8 +2,11 @@ package postgres
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
@@ -12,14 +15,33 @@ import (
 
 var ErrNoRows = pgx.ErrNoRows
 
+// Настройки полнотекстового поиска.
+const (
+	// веса для ts_rank в порядке {D, C, B, A}: совпадение
+	// в заголовке (A) значит больше, чем в описании (B).
+	rankWeights = "{0.1, 0.2, 0.4, 1.0}"
+	// настройки ts_headline для заголовка и описания.
+	titleHeadlineOpts = "StartSel=" + storage.SnippetStartSel + ", StopSel=" + storage.SnippetStopSel +
+		", HighlightAll=true"
+	contentHeadlineOpts = "StartSel=" + storage.SnippetStartSel + ", StopSel=" + storage.SnippetStopSel +
+		", MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=\" ... \""
+)
+
 type statement struct {
 	sql  string
 	args []any
 }
 
+// termsRefreshPeriod - как часто обновляется
+// словарь частых слов заголовков для подсказок.
+const termsRefreshPeriod = 10 * time.Minute
+
 // Postgres выполняет CRUD операции с БД
 type Postgres struct {
 	db *pgxpool.Pool
+
+	termsMu        sync.Mutex
+	termsRefreshed time.Time // время последнего обновления словаря.
 }
 
 // New выполняет подключение
@@ -42,21 +64,11 @@ func (p *Postgres) Close() error {
 
 // ItemByLink находит по ссылке и возвращает rss-новость
 func (p *Postgres) ItemByLink(ctx context.Context, link string) (storage.Item, error) {
-	stmt := `
-		SELECT
-			id,
-			title,
-			description,
-			pub_date,
-			link
-		FROM news
-		WHERE link = $1;`
+	stmt := itemColumns + ` FROM news` + sourcesJoin + ` WHERE news.link = $1;`
 
 	var item storage.Item
 
-	err := p.db.QueryRow(ctx, stmt, link).Scan(
-		&item.Id, &item.Title, &item.Description,
-		&item.PubDate, &item.Link)
+	err := p.db.QueryRow(ctx, stmt, link).Scan(itemDest(&item)...)
 	if err != nil {
 		return item, err
 	}
@@ -67,20 +79,28 @@ func (p *Postgres) ItemByLink(ctx context.Context, link string) (storage.Item, e
 // Item находит по id и возвращает rss-новость
 func (p *Postgres) Item(ctx context.Context, id int64) (storage.Item, error) {
 
-	stmt := `SELECT id, title, description, pub_date, link FROM news WHERE id = $1;`
+	stmt := itemColumns + ` FROM news` + sourcesJoin + ` WHERE news.id = $1;`
 
 	var item storage.Item
 
-	return item, p.db.QueryRow(ctx, stmt, id).Scan(
-		&item.Id, &item.Title, &item.Description,
-		&item.PubDate, &item.Link)
+	err := p.db.QueryRow(ctx, stmt, id).Scan(itemDest(&item)...)
+	if err != nil {
+		return storage.Item{}, err
+	}
+
+	return item, nil
 }
 
 // CountItems возвращает количество строк, которое будет задействовано в запросе.
 func (p *Postgres) CountItems(ctx context.Context, filter storage.Filter) (int, error) {
+	s, err := newSearch(&filter)
+	if err != nil {
+		return 0, err
+	}
+
 	var stmt statement
-	stmt.sql = `SELECT COUNT(id) FROM news`
-	stmt.addWhereClause(&filter)
+	stmt.sql = `SELECT COUNT(news.id) FROM news`
+	stmt.addWhereClause(&filter, &s)
 
 	var c int
 
@@ -88,11 +108,27 @@ func (p *Postgres) CountItems(ctx context.Context, filter storage.Filter) (int,
 }
 
 // Items возвращает списком новости отобранные согласно фильтру.
+// При полнотекстовом поиске каждая новость содержит фрагменты
+// заголовка и описания с выделенными совпадениями. Из БД выбираются
+// только поля filter.Fields, описание обрезается в запросе.
 func (p *Postgres) Items(ctx context.Context, filter storage.Filter) ([]storage.Item, error) {
+	s, err := newSearch(&filter)
+	if err != nil {
+		return nil, err
+	}
+	headlines := s.tsq != "" && filter.Fields.Has(storage.FieldSnippets)
+
 	var stmt statement
-	stmt.sql = `SELECT id, title, description, pub_date, link FROM news`
-	stmt.addWhereClause(&filter)
-	stmt.addOrderBy(&filter)
+	fieldsDest := stmt.addItemColumns(&filter)
+	if headlines {
+		stmt.addHeadlines(s.tsq)
+	}
+	stmt.sql += ` FROM news`
+	if filter.Fields.Has(storage.FieldSource) {
+		stmt.sql += sourcesJoin
+	}
+	stmt.addWhereClause(&filter, &s)
+	stmt.addOrderBy(&filter, &s)
 	stmt.addLimitOffsetClause(&filter)
 
 	var items []storage.Item
@@ -107,8 +143,12 @@ func (p *Postgres) Items(ctx context.Context, filter storage.Filter) ([]storage.
 
 		var item storage.Item
 
-		err := rows.Scan(&item.Id, &item.Title,
-			&item.Description, &item.PubDate, &item.Link)
+		dest := fieldsDest(&item)
+		if headlines {
+			dest = append(dest, &item.TitleSnippet, &item.ContentSnippet)
+		}
+
+		err := rows.Scan(dest...)
 		if err != nil {
 			return nil, err
 		}
@@ -119,125 +159,976 @@ func (p *Postgres) Items(ctx context.Context, filter storage.Filter) ([]storage.
 	return items, rows.Err()
 }
 
+// itemColumns - колонки новости вместе с источником, который
+// присоединяется через sourcesJoin и может отсутствовать.
+const (
+	itemColumns = `SELECT news.id, title, description, pub_date, link,
+		COALESCE(sources.id, 0), COALESCE(sources.name, ''),
+		COALESCE(sources.url, ''), COALESCE(sources.feed_url, '')`
+	sourcesJoin = ` LEFT JOIN sources ON sources.id = news.source_id`
+)
+
+// itemDest возвращает поля новости в порядке itemColumns.
+func itemDest(item *storage.Item) []any {
+	return []any{&item.Id, &item.Title, &item.Description, &item.PubDate, &item.Link,
+		&item.Source.Id, &item.Source.Name, &item.Source.URL, &item.Source.FeedURL}
+}
+
+// itemField - поле новости и колонки, из которых оно выбирается.
+type itemField struct {
+	field storage.Fields
+	cols  string
+	dest  func(item *storage.Item) []any
+}
+
+// itemFields - поля новости в порядке itemColumns.
+var itemFields = []itemField{
+	{storage.FieldTitle, "title", func(item *storage.Item) []any { return []any{&item.Title} }},
+	{storage.FieldDescription, "description", func(item *storage.Item) []any { return []any{&item.Description} }},
+	{storage.FieldPubDate, "pub_date", func(item *storage.Item) []any { return []any{&item.PubDate} }},
+	{storage.FieldLink, "link", func(item *storage.Item) []any { return []any{&item.Link} }},
+	{storage.FieldSource, `COALESCE(sources.id, 0), COALESCE(sources.name, ''),
+		COALESCE(sources.url, ''), COALESCE(sources.feed_url, '')`,
+		func(item *storage.Item) []any {
+			return []any{&item.Source.Id, &item.Source.Name, &item.Source.URL, &item.Source.FeedURL}
+		}},
+}
+
+// addItemColumns начинает выборку с колонок полей новости, выбранных
+// фильтром, описание обрезается до f.DescriptionLimit символов.
+// Возвращает функцию, которая возвращает поля новости в порядке колонок.
+// Источник выбирается из sourcesJoin, его присоединяет вызывающий.
+func (stmt *statement) addItemColumns(f *storage.Filter) func(item *storage.Item) []any {
+	cols := []string{"news.id"}
+	var fields []itemField
+	for _, fl := range itemFields {
+		if !f.Fields.Has(fl.field) {
+			continue
+		}
+		col := fl.cols
+		if fl.field == storage.FieldDescription && f.DescriptionLimit > 0 {
+			col = fmt.Sprintf("left(description, %s)", stmt.arg(f.DescriptionLimit))
+		}
+		cols = append(cols, col)
+		fields = append(fields, fl)
+	}
+	stmt.sql = "SELECT " + strings.Join(cols, ", ")
+
+	return func(item *storage.Item) []any {
+		dest := []any{&item.Id}
+		for _, fl := range fields {
+			dest = append(dest, fl.dest(item)...)
+		}
+		return dest
+	}
+}
+
+// search - условия поиска для запроса к БД.
+type search struct {
+	tsq   string // запрос to_tsquery для полнотекстового поиска.
+	fuzzy string // строка для нечеткого поиска по заголовку.
+	excl  string // запрос to_tsquery исключений при нечетком поиске.
+}
+
+// newSearch строит условия поиска согласно фильтру.
+func newSearch(f *storage.Filter) (search, error) {
+	var s search
+
+	q, err := f.Query()
+	if err != nil || q.Empty() {
+		return s, err
+	}
+
+	// без слов для поиска новости только отсеиваются исключениями
+	if f.Match == storage.MatchFuzzy || !q.HasTerms() {
+		s.fuzzy = strings.Join(q.Terms(), " ")
+		s.excl = q.Exclusions().TSQuery()
+		return s, nil
+	}
+
+	s.tsq = q.TSQuery()
+	return s, nil
+}
+
+// arg добавляет аргумент запроса и возвращает его плейсхолдер.
+func (stmt *statement) arg(v any) string {
+	stmt.args = append(stmt.args, v)
+	return fmt.Sprintf("$%d", len(stmt.args))
+}
+
+// addHeadlines добавляет в выборку фрагменты заголовка
+// и описания с выделенными совпадениями.
+func (stmt *statement) addHeadlines(tsq string) {
+	stmt.sql += fmt.Sprintf(`,
+		ts_headline('russian', title, to_tsquery('russian', %[1]s), '%[2]s'),
+		ts_headline('russian', description, to_tsquery('russian', %[1]s), '%[3]s')`,
+		stmt.arg(tsq), titleHeadlineOpts, contentHeadlineOpts)
+}
+
 func (stmt *statement) addLimitOffsetClause(f *storage.Filter) {
-	l, o := calcLimitOffset(f.Page, storage.PageSize)
+	l, o := calcLimitOffset(f.Page, f.Limit())
 	if l > 0 {
-		stmt.sql += fmt.Sprintf(" LIMIT $%d", len(stmt.args)+1)
-		stmt.args = append(stmt.args, l)
+		stmt.sql += " LIMIT " + stmt.arg(l)
 	}
 	if o > 0 {
-		stmt.sql += fmt.Sprintf(" OFFSET $%d", len(stmt.args)+1)
-		stmt.args = append(stmt.args, o)
+		stmt.sql += " OFFSET " + stmt.arg(o)
 	}
 }
 
-func (stmt *statement) addOrderBy(f *storage.Filter) {
-	if f.SortBy == storage.Empty {
+func (stmt *statement) addOrderBy(f *storage.Filter, s *search) {
+	switch {
+	case f.SortBy == storage.Rank && s.tsq != "":
+		stmt.sql += fmt.Sprintf(" ORDER BY ts_rank('%s', search, to_tsquery('russian', %s)) DESC",
+			rankWeights, stmt.arg(s.tsq))
+	case f.SortBy == storage.Rank && s.fuzzy != "":
+		stmt.sql += fmt.Sprintf(" ORDER BY word_similarity(%s, title) DESC", stmt.arg(s.fuzzy))
+	case f.SortBy == storage.Empty || f.SortBy == storage.Rank:
+		// без поиска сортировать по совпадениям нечего
 		stmt.sql += fmt.Sprintf(" ORDER BY %s DESC", storage.Date.String())
-		return
+	default:
+		stmt.sql += fmt.Sprintf(" ORDER BY %s DESC", f.SortBy.String())
+	}
+}
+
+// addWhereClause добавляет условия фильтра и поиска.
+func (stmt *statement) addWhereClause(f *storage.Filter, s *search) {
+	var conds []string
+
+	if s.tsq != "" {
+		conds = append(conds, fmt.Sprintf("search @@ to_tsquery('russian', %s)", stmt.arg(s.tsq)))
+	}
+	if s.fuzzy != "" {
+		// '<%' - оператор pg_trgm, использует индекс по триграммам заголовка
+		conds = append(conds, fmt.Sprintf("%s <%% title", stmt.arg(s.fuzzy)))
+	}
+	if s.excl != "" {
+		conds = append(conds, fmt.Sprintf("NOT search @@ to_tsquery('russian', %s)", stmt.arg(s.excl)))
+	}
+	if f.Date.Value > 0 {
+		conds = append(conds, fmt.Sprintf("pub_date %s %s", f.Date.Op(), stmt.arg(f.Date.Value)))
+	}
+	if f.EndDate.Value > 0 {
+		conds = append(conds, fmt.Sprintf("pub_date %s %s", f.EndDate.Op(), stmt.arg(f.EndDate.Value)))
+	}
+	if len(f.Sources) > 0 {
+		conds = append(conds, fmt.Sprintf("news.source_id = ANY(%s)", stmt.arg(f.Sources)))
+	}
+	if f.AfterID > 0 {
+		conds = append(conds, fmt.Sprintf("news.id > %s", stmt.arg(f.AfterID)))
+	}
+	if len(f.IDs) > 0 {
+		conds = append(conds, fmt.Sprintf("news.id = ANY(%s)", stmt.arg(f.IDs)))
+	}
+	if len(f.ExcludeSources) > 0 {
+		// новости без источника не исключаются
+		conds = append(conds, fmt.Sprintf("(news.source_id IS NULL OR news.source_id <> ALL(%s))",
+			stmt.arg(f.ExcludeSources)))
+	}
+
+	if len(conds) > 0 {
+		stmt.sql += " WHERE " + strings.Join(conds, " AND ")
+	}
+}
+
+func calcLimitOffset(pageNum, pageSize int) (int, int) {
+	if pageNum < 1 {
+		return 0, 0
+	}
+	return pageSize, (pageNum - 1) * pageSize
+}
+
+// Suggest подбирает для каждого слова наиболее похожее по триграммам
+// из часто встречающихся в заголовках слов. Если подходящего
+// слова нет, то возвращается исходное слово.
+func (p *Postgres) Suggest(ctx context.Context, terms []string) ([]string, error) {
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	p.refreshTerms()
+
+	stmt := `
+		SELECT COALESCE(s.word, u.term)
+		FROM unnest($1::text[]) WITH ORDINALITY AS u(term, n)
+		LEFT JOIN LATERAL (
+			SELECT word FROM title_terms
+			WHERE word % u.term
+			ORDER BY similarity(word, u.term) DESC, ndoc DESC
+			LIMIT 1
+		) s ON true
+		ORDER BY u.n;`
+
+	rows, err := p.db.Query(ctx, stmt, terms)
+	if err != nil {
+		return nil, err
 	}
-	if f.SortBy == storage.Rank && len(f.TitleSearch) > 0 {
-		stmt.sql += fmt.Sprintf(" ORDER BY ts_rank(title_search, to_tsquery('russian', $%d)) DESC", len(stmt.args)+1)
-		stmt.args = append(stmt.args, searchStr(f))
+	defer rows.Close()
+
+	out := make([]string, 0, len(terms))
+	for rows.Next() {
+		var w string
+		if err := rows.Scan(&w); err != nil {
+			return nil, err
+		}
+		out = append(out, w)
+	}
+
+	return out, rows.Err()
+}
+
+// refreshTerms обновляет в фоне словарь частых слов заголовков,
+// если с последнего обновления прошло больше termsRefreshPeriod.
+func (p *Postgres) refreshTerms() {
+	p.termsMu.Lock()
+	defer p.termsMu.Unlock()
+
+	if time.Since(p.termsRefreshed) < termsRefreshPeriod {
 		return
 	}
-	stmt.sql += fmt.Sprintf(" ORDER BY %s DESC", f.SortBy.String())
+	p.termsRefreshed = time.Now()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		_, err := p.db.Exec(ctx, `REFRESH MATERIALIZED VIEW CONCURRENTLY title_terms;`)
+		if err != nil {
+			// попробуем снова при следующем запросе
+			p.termsMu.Lock()
+			p.termsRefreshed = time.Time{}
+			p.termsMu.Unlock()
+		}
+	}()
 }
 
-func (stmt *statement) addWhereClause(f *storage.Filter) {
-	if len(f.TitleSearch) > 0 {
-		stmt.sql += fmt.Sprintf(` WHERE title_search @@ to_tsquery('russian', $%d)`, len(stmt.args)+1)
-		stmt.args = append(stmt.args, searchStr(f))
+// Stats возвращает сводную статистику по новостям, отобранным фильтром.
+// Все подсчеты выполняются в одной транзакции над одним снимком данных.
+func (p *Postgres) Stats(ctx context.Context, filter storage.Filter, interval storage.Interval) (storage.Stats, error) {
+	s, err := newSearch(&filter)
+	if err != nil {
+		return storage.Stats{}, err
 	}
-	if f.Date.Value > 0 {
-		if len(stmt.args) > 0 {
-			stmt.sql += fmt.Sprintf(" AND pub_date %s $%d", f.Date.Operator, len(stmt.args)+1)
-		} else {
-			stmt.sql += fmt.Sprintf(" WHERE pub_date %s $%d", f.Date.Operator, len(stmt.args)+1)
+
+	var stats storage.Stats
+
+	var counts statement
+	counts.sql = fmt.Sprintf(`SELECT pub_date / %[1]s * %[1]s AS t, COUNT(*) FROM news`,
+		counts.arg(interval.Seconds()))
+	counts.addWhereClause(&filter, &s)
+	counts.sql += ` GROUP BY t ORDER BY t`
+
+	var sources statement
+	sources.sql = `
+		SELECT COALESCE(sources.id, 0), COALESCE(sources.name, ''),
+			COALESCE(sources.url, ''), COALESCE(sources.feed_url, ''), COUNT(*) AS n
+		FROM news` + sourcesJoin
+	sources.addWhereClause(&filter, &s)
+	sources.sql += ` GROUP BY sources.id ORDER BY n DESC, 1`
+
+	// слова считаются без морфологии ('simple'), чтобы в статистике
+	// были слова, а не основы, поэтому служебные слова отбрасываются
+	// по списку storage.StopWords. Каждое слово учитывается один раз
+	// на заголовок
+	var terms statement
+	terms.sql = `SELECT w, COUNT(*) AS n FROM (SELECT title FROM news`
+	terms.addWhereClause(&filter, &s)
+	terms.sql += fmt.Sprintf(`) AS f, unnest(tsvector_to_array(to_tsvector('simple', f.title))) AS w
+		WHERE length(w) >= %s AND w <> ALL(%s) GROUP BY w ORDER BY n DESC, w LIMIT %s`,
+		terms.arg(storage.MinTermLen), terms.arg(storage.StopWords), terms.arg(storage.TopTerms))
+
+	opts := pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly}
+	err = p.db.BeginTxFunc(ctx, opts, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, counts.sql, counts.args...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var c storage.TimeCount
+			if err := rows.Scan(&c.Time, &c.Count); err != nil {
+				rows.Close()
+				return err
+			}
+			stats.Total += c.Count
+			stats.Counts = append(stats.Counts, c)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		rows, err = tx.Query(ctx, sources.sql, sources.args...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var c storage.SourceCount
+			err := rows.Scan(&c.Source.Id, &c.Source.Name, &c.Source.URL, &c.Source.FeedURL, &c.Count)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			stats.Sources = append(stats.Sources, c)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
 		}
-		stmt.args = append(stmt.args, f.Date.Value)
+
+		rows, err = tx.Query(ctx, terms.sql, terms.args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var c storage.TermCount
+			if err := rows.Scan(&c.Term, &c.Count); err != nil {
+				return err
+			}
+			stats.Terms = append(stats.Terms, c)
+		}
+		return rows.Err()
+	})
+
+	return stats, err
+}
+
+// Related возвращает новости, похожие по тексту на новость id.
+// Из слов заголовка и описания новости строится запрос, в котором
+// достаточно совпадения любого слова, а похожесть определяет ts_rank.
+func (p *Postgres) Related(ctx context.Context, id int64, window int64, limit int) ([]storage.Item, error) {
+	stmt := fmt.Sprintf(`
+		WITH src AS (
+			SELECT id, title, pub_date,
+				-- plainto_tsquery соединяет слова через &, заменяем на |
+				replace(plainto_tsquery('russian', title || ' ' || description)::text, '&', '|')::tsquery AS q
+			FROM news WHERE id = $1
+		), related AS (
+			-- из новостей с одинаковым заголовком остается самая похожая
+			SELECT DISTINCT ON (lower(n.title)) n.id, ts_rank('%s', n.search, src.q) AS rank
+			FROM news n, src
+			WHERE n.search @@ src.q
+				AND n.id <> src.id
+				AND lower(n.title) <> lower(src.title)
+				AND n.pub_date BETWEEN src.pub_date - $2 AND src.pub_date + $2
+			ORDER BY lower(n.title), rank DESC, n.id
+		)
+		%s FROM related JOIN news ON news.id = related.id %s
+		ORDER BY related.rank DESC, news.pub_date DESC, news.id
+		LIMIT $3;`, rankWeights, itemColumns, sourcesJoin)
+
+	rows, err := p.db.Query(ctx, stmt, id, window, limit)
+	if err != nil {
+		return nil, err
 	}
-	if f.Date.Value > 0 && f.EndDate.Value > 0 {
-		if len(stmt.args) > 0 {
-			stmt.sql += fmt.Sprintf(" AND pub_date %s $%d", f.EndDate.Operator, len(stmt.args)+1)
+	defer rows.Close()
+
+	var items []storage.Item
+	for rows.Next() {
+		var item storage.Item
+		if err := rows.Scan(itemDest(&item)...); err != nil {
+			return nil, err
 		}
-		stmt.args = append(stmt.args, f.EndDate.Value)
+		items = append(items, item)
 	}
+
+	return items, rows.Err()
 }
 
-func searchStr(f *storage.Filter) string {
-	var b strings.Builder
+// matchChunk - сколько фильтров сопоставляется с новостями одним запросом.
+const matchChunk = 100
+
+// MatchFilters сопоставляет новости ids с фильтрами filters. Фильтры
+// проверяются пачками по matchChunk одним запросом: для каждого фильтра
+// из новостей ids выбираются те, что подходят под условия, как в Items.
+func (p *Postgres) MatchFilters(ctx context.Context, ids []int64, filters []storage.Filter) ([][]int64, error) {
+	matched := make([][]int64, len(filters))
+	if len(ids) == 0 {
+		return matched, nil
+	}
+
+	for start := 0; start < len(filters); start += matchChunk {
+		end := start + matchChunk
+		if end > len(filters) {
+			end = len(filters)
+		}
 
-	b.WriteString(strings.Join(f.TitleSearch, "&"))
+		var stmt statement
+		selects := make([]string, 0, end-start)
+		for i := start; i < end; i++ {
+			f := filters[i]
+			f.IDs = ids
+			s, err := newSearch(&f)
+			if err != nil {
+				return nil, err
+			}
+			stmt.sql = fmt.Sprintf(`SELECT %d, news.id FROM news`, i)
+			stmt.addWhereClause(&f, &s)
+			selects = append(selects, stmt.sql)
+		}
+		stmt.sql = strings.Join(selects, ` UNION ALL `) + ` ORDER BY 1, 2;`
 
-	if len(f.Exclude) > 0 {
-		b.WriteString("&!")
+		rows, err := p.db.Query(ctx, stmt.sql, stmt.args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var i int
+			var id int64
+			if err := rows.Scan(&i, &id); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			matched[i] = append(matched[i], id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
 	}
-	b.WriteString(strings.Join(f.Exclude, "&!"))
-	return b.String()
+
+	return matched, nil
 }
 
-func calcLimitOffset(pageNum, pageSize int) (int, int) {
-	if pageNum < 1 {
-		return 0, 0
+// exportBatch - сколько новостей за раз читается из БД при выгрузке.
+const exportBatch = 500
+
+// Export вызывает fn для каждой новости, отобранной фильтром, по возрастанию id.
+// Новости читаются пачками по exportBatch, каждая следующая пачка начинается
+// после последней выгруженной новости. Пока работает fn, соединение с БД
+// не удерживается, так что медленный получатель не занимает пул соединений.
+func (p *Postgres) Export(ctx context.Context, filter storage.Filter, fn func(storage.Item) error) error {
+	s, err := newSearch(&filter)
+	if err != nil {
+		return err
+	}
+
+	batch := make([]storage.Item, 0, exportBatch)
+	for {
+		var stmt statement
+		stmt.sql = itemColumns + ` FROM news` + sourcesJoin
+		stmt.addWhereClause(&filter, &s)
+		stmt.sql += fmt.Sprintf(` ORDER BY news.id LIMIT %d`, exportBatch)
+
+		batch, err = p.exportPage(ctx, &stmt, batch[:0])
+		if err != nil {
+			return err
+		}
+
+		for i := range batch {
+			if err := fn(batch[i]); err != nil {
+				return err
+			}
+		}
+
+		if len(batch) < exportBatch {
+			return nil
+		}
+		filter.AfterID = batch[len(batch)-1].Id
 	}
-	return pageSize, (pageNum - 1) * pageSize
 }
 
-// AddItems добавляет в БД слайс rss-новостей,
-// ингорирует те новости, что уже есть в БД
-func (p *Postgres) AddItems(ctx context.Context, items []storage.Item) error {
-	return p.addItemsByBatch(ctx, items)
+// exportPage читает в items одну пачку выгрузки.
+func (p *Postgres) exportPage(ctx context.Context, stmt *statement, items []storage.Item) ([]storage.Item, error) {
+	rows, err := p.db.Query(ctx, stmt.sql, stmt.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item storage.Item
+		if err := rows.Scan(itemDest(&item)...); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
 }
 
-// addItemsByBatch вносит в БД слайс rss-новостей,
-// используя [*pgx.Batch]
-func (p *Postgres) addItemsByBatch(ctx context.Context, items []storage.Item) error {
+// upsertSourceStmt добавляет источник или обновляет
+// название и адрес сайта уже известного канала.
+const upsertSourceStmt = `
+	INSERT INTO sources(name, url, feed_url)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (feed_url) DO UPDATE SET name = EXCLUDED.name, url = EXCLUDED.url;`
 
-	return p.db.BeginFunc(ctx, func(tx pgx.Tx) error {
+// insertChunk - сколько новостей добавляется одним запросом
+// (у запроса может быть не больше 65535 параметров).
+const insertChunk = 1000
 
-		b := new(pgx.Batch) // создаем объект pgx.Batch
+// AddItems добавляет в БД слайс rss-новостей, обновляет
+// изменившиеся и пропускает те новости, что уже есть в БД
+func (p *Postgres) AddItems(ctx context.Context, items []storage.Item) (storage.AddResult, error) {
+	items, dups := storage.UniqueByLink(items)
 
-		stmt := `
-		INSERT INTO news(title, description, pub_date, link)
-		VALUES ($1, $2, $3, $4)
-		ON CONFLICT (link) DO NOTHING;`
+	var res storage.AddResult
+	err := p.db.BeginFunc(ctx, func(tx pgx.Tx) error {
+		res = dups
 
-		// добавляем все запросы в очередь
-		for i := range items {
-			b.Queue(stmt, items[i].Title, items[i].Description,
-				items[i].PubDate, items[i].Link)
+		if err := upsertSources(ctx, tx, items); err != nil {
+			return err
 		}
 
-		return tx.SendBatch(ctx, b).Close() // исполняем запросы и закрываем операцию
+		for len(items) > 0 {
+			n := len(items)
+			if n > insertChunk {
+				n = insertChunk
+			}
+			if err := upsertItems(ctx, tx, items[:n], &res); err != nil {
+				return err
+			}
+			items = items[n:]
+		}
 
+		return nil
 	})
+	if err != nil {
+		return storage.AddResult{}, err
+	}
+
+	return res, nil
+}
+
+// upsertSources добавляет одним запросом источники новостей,
+// чтобы новости могли на них сослаться.
+func upsertSources(ctx context.Context, tx pgx.Tx, items []storage.Item) error {
+	var stmt statement
+	var values []string
+
+	seen := make(map[string]bool)
+	for i := range items {
+		src := items[i].Source
+		if src.FeedURL == "" || seen[src.FeedURL] {
+			continue
+		}
+		seen[src.FeedURL] = true
+		values = append(values, fmt.Sprintf("(%s, %s, %s)",
+			stmt.arg(src.Name), stmt.arg(src.URL), stmt.arg(src.FeedURL)))
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	stmt.sql = `INSERT INTO sources(name, url, feed_url) VALUES ` + strings.Join(values, ", ") + `
+		ON CONFLICT (feed_url) DO UPDATE SET name = EXCLUDED.name, url = EXCLUDED.url;`
+
+	_, err := tx.Exec(ctx, stmt.sql, stmt.args...)
+	return err
+}
+
+// upsertItems добавляет новости без повторов ссылок одним запросом.
+// Уже известная новость обновляется, только если изменилась.
+// Запрос возвращает id, ссылки и id источников добавленных и обновленных
+// новостей и true для добавленных (xmax = 0 только у версии строки,
+// созданной вставкой), остальные новости пропущены.
+func upsertItems(ctx context.Context, tx pgx.Tx, items []storage.Item, res *storage.AddResult) error {
+	var stmt statement
+	values := make([]string, len(items))
+	for i := range items {
+		values[i] = fmt.Sprintf("(%s, %s, %s, %s, (SELECT id FROM sources WHERE feed_url = NULLIF(%s, '')))",
+			stmt.arg(items[i].Title), stmt.arg(items[i].Description), stmt.arg(items[i].PubDate),
+			stmt.arg(items[i].Link), stmt.arg(items[i].Source.FeedURL))
+	}
+
+	stmt.sql = `
+		INSERT INTO news(title, description, pub_date, link, source_id)
+		VALUES ` + strings.Join(values, ", ") + `
+		ON CONFLICT (link) DO UPDATE SET title = EXCLUDED.title, description = EXCLUDED.description,
+			pub_date = EXCLUDED.pub_date, source_id = EXCLUDED.source_id
+		WHERE (news.title, news.description, news.pub_date, news.source_id)
+			IS DISTINCT FROM (EXCLUDED.title, EXCLUDED.description, EXCLUDED.pub_date, EXCLUDED.source_id)
+		RETURNING id, link, COALESCE(source_id, 0), xmax = 0;`
+
+	type row struct {
+		id, source int64
+		st         storage.AddStatus
+	}
+	written := make(map[string]row, len(items))
+	rows, err := tx.Query(ctx, stmt.sql, stmt.args...)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var r row
+		var link string
+		var inserted bool
+		if err := rows.Scan(&r.id, &link, &r.source, &inserted); err != nil {
+			rows.Close()
+			return err
+		}
+		r.st = storage.AddUpdated
+		if inserted {
+			r.st = storage.AddInserted
+		}
+		written[link] = r
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range items {
+		r, ok := written[items[i].Link]
+		if !ok {
+			r.st = storage.AddSkipped
+		}
+		res.Count(items[i].Source.FeedURL, r.st)
+
+		if r.st == storage.AddInserted {
+			it := items[i]
+			it.Id, it.Source.Id = r.id, r.source
+			it.TitleSnippet, it.ContentSnippet = "", ""
+			res.Added = append(res.Added, it)
+		}
+	}
+
+	return nil
 }
 
 // AddItem добавляет в БД rss-новость, если новость уже
 // есть в БД, то no-op
 func (p *Postgres) AddItem(ctx context.Context, item storage.Item) error {
-	stmt := `
-		INSERT INTO news(title, description, pub_date, link)
-		VALUES ($1, $2, $3, $4)
-		ON CONFLICT (link) DO NOTHING;`
+	_, err := p.AddItems(ctx, []storage.Item{item})
+	return err
+}
+
+// UpdateItem обновляет rss-новость по id,
+// если новости нет, то возвращает ErrNoRows.
+func (p *Postgres) UpdateItem(ctx context.Context, item storage.Item) error {
+	return p.db.BeginFunc(ctx, func(tx pgx.Tx) error {
+		src := item.Source
+		if src.FeedURL != "" {
+			if _, err := tx.Exec(ctx, upsertSourceStmt, src.Name, src.URL, src.FeedURL); err != nil {
+				return err
+			}
+		}
+
+		tag, err := tx.Exec(ctx, `
+			UPDATE news SET title = $1, description = $2, pub_date = $3, link = $4,
+				source_id = (SELECT id FROM sources WHERE feed_url = NULLIF($5, ''))
+			WHERE id = $6;`,
+			item.Title, item.Description, item.PubDate, item.Link, src.FeedURL, item.Id)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrNoRows
+		}
+
+		return nil
+	})
+}
+
+// DeleteItems удаляет rss-новости по id,
+// возвращает количество удаленных.
+func (p *Postgres) DeleteItems(ctx context.Context, ids []int64) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	tag, err := p.db.Exec(ctx, `DELETE FROM news WHERE id = ANY($1);`, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(tag.RowsAffected()), nil
+}
+
+// Sources возвращает все источники по возрастанию id.
+func (p *Postgres) Sources(ctx context.Context) ([]storage.Source, error) {
+	rows, err := p.db.Query(ctx, `SELECT id, name, url, feed_url FROM sources ORDER BY id;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sources []storage.Source
+	for rows.Next() {
+		var src storage.Source
+		if err := rows.Scan(&src.Id, &src.Name, &src.URL, &src.FeedURL); err != nil {
+			return nil, err
+		}
+		sources = append(sources, src)
+	}
+
+	return sources, rows.Err()
+}
+
+// Webhooks возвращает все подписки по возрастанию id.
+func (p *Postgres) Webhooks(ctx context.Context) ([]storage.Webhook, error) {
+	rows, err := p.db.Query(ctx, `SELECT id, url, secret, terms, sources, created FROM webhooks ORDER BY id;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []storage.Webhook
+	for rows.Next() {
+		var wh storage.Webhook
+		if err := rows.Scan(webhookDest(&wh)...); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, wh)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// webhookDest возвращает поля подписки для сканирования
+// в порядке id, url, secret, terms, sources, created.
+func webhookDest(wh *storage.Webhook) []any {
+	return []any{&wh.Id, &wh.URL, &wh.Secret, &wh.Terms, &wh.Sources, &wh.Created}
+}
+
+// Webhook возвращает подписку по id,
+// если подписки нет, то возвращает ErrNoRows.
+func (p *Postgres) Webhook(ctx context.Context, id int64) (storage.Webhook, error) {
+	var wh storage.Webhook
+	err := p.db.QueryRow(ctx, `
+		SELECT id, url, secret, terms, sources, created FROM webhooks WHERE id = $1;`, id).
+		Scan(webhookDest(&wh)...)
+
+	return wh, err
+}
+
+// AddWebhook добавляет подписку и возвращает ее id.
+func (p *Postgres) AddWebhook(ctx context.Context, wh storage.Webhook) (int64, error) {
+	var id int64
+	err := p.db.QueryRow(ctx, `
+		INSERT INTO webhooks(url, secret, terms, sources, created)
+		VALUES ($1, $2, COALESCE($3, '{}'::TEXT[]), COALESCE($4, '{}'::BIGINT[]), $5)
+		RETURNING id;`,
+		wh.URL, wh.Secret, wh.Terms, wh.Sources, wh.Created).Scan(&id)
 
-	return p.exec(ctx, stmt, item.Title, item.Description, item.PubDate, item.Link)
+	return id, err
 }
 
-// exec вспомогательная функция, выполняет
-// *pgx.conn.Exec() в транзакции
-func (p *Postgres) exec(ctx context.Context, sql string, args ...any) error {
-	tx, err := p.db.Begin(ctx)
+// DeleteWebhook удаляет подписку вместе с журналом доставки,
+// если подписки нет, то возвращает ErrNoRows.
+func (p *Postgres) DeleteWebhook(ctx context.Context, id int64) error {
+	tag, err := p.db.Exec(ctx, `DELETE FROM webhooks WHERE id = $1;`, id)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback(ctx)
+	if tag.RowsAffected() == 0 {
+		return ErrNoRows
+	}
 
-	_, err = p.db.Exec(ctx, sql, args...)
+	return nil
+}
+
+// AddDelivery записывает в журнал попытку доставки.
+func (p *Postgres) AddDelivery(ctx context.Context, d storage.Delivery) error {
+	_, err := p.db.Exec(ctx, `
+		INSERT INTO webhook_deliveries(webhook_id, event, items, attempt, status, error, time, duration_ms)
+		VALUES ($1, $2, COALESCE($3, '{}'::BIGINT[]), $4, $5, $6, $7, $8);`,
+		d.WebhookID, d.Event, d.Items, d.Attempt, d.Status, d.Error, d.Time, d.Duration)
+
+	return err
+}
+
+// Deliveries возвращает до limit последних
+// попыток доставки по подписке, последние первыми.
+func (p *Postgres) Deliveries(ctx context.Context, webhookID int64, limit int) ([]storage.Delivery, error) {
+	rows, err := p.db.Query(ctx, `
+		SELECT id, webhook_id, event, items, attempt, status, error, time, duration_ms
+		FROM webhook_deliveries
+		WHERE webhook_id = $1
+		ORDER BY id DESC
+		LIMIT $2;`, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []storage.Delivery
+	for rows.Next() {
+		var d storage.Delivery
+		err := rows.Scan(&d.Id, &d.WebhookID, &d.Event, &d.Items, &d.Attempt,
+			&d.Status, &d.Error, &d.Time, &d.Duration)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// savedSearchColumns - колонки сохраненного поиска вместе с количеством
+// непрочитанных совпадений, поиск присоединяется как saved_searches.
+const savedSearchColumns = `SELECT id, name, owner, filter, created, last_read,
+	(SELECT COUNT(*) FROM saved_search_matches m
+		WHERE m.search_id = saved_searches.id AND m.item_id > saved_searches.last_read)`
+
+// scanSavedSearch сканирует сохраненный поиск из строки
+// с колонками savedSearchColumns. Фильтр хранится в JSON.
+func scanSavedSearch(row pgx.Row) (storage.SavedSearch, error) {
+	var s storage.SavedSearch
+	var filter []byte
+	if err := row.Scan(&s.Id, &s.Name, &s.Owner, &filter, &s.Created, &s.LastRead, &s.Unread); err != nil {
+		return storage.SavedSearch{}, err
+	}
+	if err := json.Unmarshal(filter, &s.Filter); err != nil {
+		return storage.SavedSearch{}, err
+	}
+
+	return s, nil
+}
+
+// SavedSearches возвращает сохраненные поиски владельца
+// (пустой owner - всех) по возрастанию id.
+func (p *Postgres) SavedSearches(ctx context.Context, owner string) ([]storage.SavedSearch, error) {
+	rows, err := p.db.Query(ctx, savedSearchColumns+`
+		FROM saved_searches
+		WHERE $1 = '' OR owner = $1
+		ORDER BY id;`, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var searches []storage.SavedSearch
+	for rows.Next() {
+		s, err := scanSavedSearch(rows)
+		if err != nil {
+			return nil, err
+		}
+		searches = append(searches, s)
+	}
+
+	return searches, rows.Err()
+}
+
+// SavedSearch возвращает сохраненный поиск по id,
+// если поиска нет, то возвращает ErrNoRows.
+func (p *Postgres) SavedSearch(ctx context.Context, id int64) (storage.SavedSearch, error) {
+	return scanSavedSearch(p.db.QueryRow(ctx, savedSearchColumns+`
+		FROM saved_searches WHERE id = $1;`, id))
+}
+
+// AddSavedSearch добавляет сохраненный поиск и возвращает его id.
+func (p *Postgres) AddSavedSearch(ctx context.Context, s storage.SavedSearch) (int64, error) {
+	filter, err := json.Marshal(s.Filter)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err = p.db.QueryRow(ctx, `
+		INSERT INTO saved_searches(name, owner, filter, created, last_read)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id;`,
+		s.Name, s.Owner, string(filter), s.Created, s.LastRead).Scan(&id)
+
+	return id, err
+}
+
+// DeleteSavedSearch удаляет сохраненный поиск вместе с совпадениями,
+// если поиска нет, то возвращает ErrNoRows.
+func (p *Postgres) DeleteSavedSearch(ctx context.Context, id int64) error {
+	tag, err := p.db.Exec(ctx, `DELETE FROM saved_searches WHERE id = $1;`, id)
 	if err != nil {
 		return err
 	}
+	if tag.RowsAffected() == 0 {
+		return ErrNoRows
+	}
 
-	return tx.Commit(ctx)
+	return nil
+}
+
+// AddSearchMatches записывает совпадения новостей с сохраненными
+// поисками одним запросом.
+func (p *Postgres) AddSearchMatches(ctx context.Context, matches map[int64][]int64) error {
+	var searchIDs, itemIDs []int64
+	for searchID, ids := range matches {
+		for _, id := range ids {
+			searchIDs = append(searchIDs, searchID)
+			itemIDs = append(itemIDs, id)
+		}
+	}
+	if len(searchIDs) == 0 {
+		return nil
+	}
+
+	_, err := p.db.Exec(ctx, `
+		INSERT INTO saved_search_matches(search_id, item_id)
+		SELECT m.search_id, m.item_id
+		FROM unnest($1::BIGINT[], $2::BIGINT[]) AS m(search_id, item_id)
+		WHERE EXISTS (SELECT 1 FROM saved_searches WHERE id = m.search_id)
+			AND EXISTS (SELECT 1 FROM news WHERE id = m.item_id)
+		ON CONFLICT DO NOTHING;`, searchIDs, itemIDs)
+
+	return err
+}
+
+// SearchMatches возвращает до limit новостей, совпавших с поиском,
+// с id больше afterID, последние добавленные первыми.
+func (p *Postgres) SearchMatches(ctx context.Context, id int64, afterID int64, limit int) ([]storage.Item, error) {
+	rows, err := p.db.Query(ctx, itemColumns+`
+		FROM saved_search_matches m
+		JOIN news ON news.id = m.item_id`+sourcesJoin+`
+		WHERE m.search_id = $1 AND m.item_id > $2
+		ORDER BY m.item_id DESC
+		LIMIT $3;`, id, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []storage.Item
+	for rows.Next() {
+		var item storage.Item
+		if err := rows.Scan(itemDest(&item)...); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// MarkSearchRead отмечает прочитанными новости поиска до lastID
+// включительно, если поиска нет, то возвращает ErrNoRows.
+func (p *Postgres) MarkSearchRead(ctx context.Context, id int64, lastID int64) error {
+	tag, err := p.db.Exec(ctx, `UPDATE saved_searches SET last_read = $2 WHERE id = $1;`, id, lastID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNoRows
+	}
+
+	return nil
+}
+
+// newsChannel - канал уведомлений о новых новостях,
+// уведомления шлет триггер на вставку в news.
+const newsChannel = "news_added"
+
+// Listen вызывает fn на каждое уведомление о новых новостях, пока
+// не закрыт контекст или не разорвано подключение. Для уведомлений
+// занимает отдельное подключение из пула.
+func (p *Postgres) Listen(ctx context.Context, fn func()) error {
+	conn, err := p.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+newsChannel+";"); err != nil {
+		return err
+	}
+
+	for {
+		_, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			// подключение могло остаться подписанным или прерванным
+			// посреди ожидания, поэтому в пул оно не возвращается
+			_ = conn.Conn().Close(context.Background())
+			return err
+		}
+		fn()
+	}
 }
@@ -2,27 +2,43 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
 	"testing"
 
 	"github.com/joho/godotenv"
-	"github.com/rtemka/agg/news/pkg/storage"
+	"github.com/rtemka/agg/news/pkg/storage/postgres/migrate"
+	"github.com/rtemka/agg/news/pkg/storage/storagetest"
 )
 
 var tdb *Postgres // тестовая БД
 
 const dbEnv = "TEST_DB_URL"
 
-func restoreTestDB(testdb *Postgres) error {
+// restoreTestDB откатывает все миграции тестовой БД
+// и применяет их заново, получая пустую БД.
+func restoreTestDB(connstr string) error {
+	ctx := context.Background()
 
-	b, err := os.ReadFile(filepath.Join("testdata", "testdb.sql"))
+	m, err := migrate.Connect(ctx, connstr)
 	if err != nil {
 		return err
 	}
+	defer m.Close()
 
-	return tdb.exec(context.Background(), string(b))
+	for {
+		_, err := m.Down(ctx)
+		if errors.Is(err, migrate.ErrNoChange) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = m.Up(ctx)
+	return err
 }
 
 func TestMain(m *testing.M) {
@@ -39,7 +55,7 @@ func TestMain(m *testing.M) {
 		os.Exit(1)
 	}
 
-	if err := restoreTestDB(tdb); err != nil {
+	if err := restoreTestDB(connstr); err != nil {
 		tdb.Close()
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
@@ -55,32 +71,12 @@ func TestPostgres(t *testing.T) {
 		t.Skipf("environment variable %s not set, skipping tests", dbEnv)
 	}
 
-	t.Run("AddItems()", func(t *testing.T) {
-		wantItems := []storage.Item{testItem1, testItem2, testItem3, testItem4}
-
-		err := tdb.AddItems(context.Background(), wantItems)
-		if err != nil {
-			t.Fatalf("AddItems() error = %v", err)
-		}
-
-		gotItems, err := tdb.Items(context.Background(), storage.Filter{Page: 1})
-		if err != nil {
-			t.Fatalf("Items() error = %v", err)
-		}
-
-		if len(gotItems) != len(wantItems) {
-			t.Fatalf("Items() got items = %d, want = %d", len(gotItems), len(wantItems))
-		}
+	storagetest.Run(t, tdb)
 
-		for i := range wantItems {
-			if gotItems[i] != wantItems[i] {
-				t.Fatalf("AddItems() got = %v, want = %v", gotItems[i], wantItems[i])
-			}
-		}
-	})
+	// тесты, специфичные для Postgres
 
 	t.Run("ItemByLink()", func(t *testing.T) {
-		want := testItem1
+		want := storagetest.Item1
 
 		got, err := tdb.ItemByLink(context.Background(), want.Link)
 		if err != nil {
@@ -92,185 +88,28 @@ func TestPostgres(t *testing.T) {
 		}
 	})
 
-	t.Run("Item()", func(t *testing.T) {
-		want := testItem1
-
-		got, err := tdb.Item(context.Background(), want.Id)
+	t.Run("Suggest()", func(t *testing.T) {
+		_, err := tdb.db.Exec(context.Background(), `REFRESH MATERIALIZED VIEW title_terms;`)
 		if err != nil {
-			t.Fatalf("Item() error = %v", err)
+			t.Fatalf("refresh title_terms error = %v", err)
 		}
 
-		if got != want {
-			t.Fatalf("Item() got = %v, want = %v", got, want)
-		}
-	})
+		terms := []string{"заголвок", "неизвестное"}
+		want := []string{"заголовок", "неизвестное"}
 
-	t.Run("Items()_title_search", func(t *testing.T) {
-		want := testItem3
-
-		v, err := tdb.Items(context.Background(), storage.Filter{TitleSearch: []string{"голэнг", "go"}})
+		got, err := tdb.Suggest(context.Background(), terms)
 		if err != nil {
-			t.Fatalf("Items() error = %v", err)
-		}
-
-		if len(v) != 1 {
-			t.Fatalf("Items() got items = %d, want = %d", len(v), 1)
-		}
-
-		got := v[0]
-
-		if got != want {
-			t.Fatalf("Items() got = %v, want = %v", got, want)
-		}
-	})
-
-	t.Run("Items()_title_search_exclude", func(t *testing.T) {
-		want := []storage.Item{testItem1, testItem2}
-
-		got, err := tdb.Items(context.Background(), storage.Filter{TitleSearch: []string{"go"}, Exclude: []string{"голэнг"}})
-		if err != nil {
-			t.Fatalf("Items() error = %v", err)
+			t.Fatalf("Suggest() error = %v", err)
 		}
 
 		if len(got) != len(want) {
-			t.Fatalf("Items() got items = %d, want = %d", len(got), len(want))
+			t.Fatalf("Suggest() got terms = %d, want = %d", len(got), len(want))
 		}
 
 		for i := range want {
 			if got[i] != want[i] {
-				t.Fatalf("Items() got = %v, want = %v", got[i], want[i])
+				t.Fatalf("Suggest() got = %q, want = %q", got[i], want[i])
 			}
 		}
 	})
-
-	t.Run("Items()_date_search_==", func(t *testing.T) {
-		want := testItem4
-
-		v, err := tdb.Items(context.Background(), storage.Filter{Date: storage.TimeFilter{Value: 1659344500, Operator: "="}})
-		if err != nil {
-			t.Fatalf("Items() error = %v", err)
-		}
-
-		if len(v) != 1 {
-			t.Fatalf("Items() got items = %d, want = %d", len(v), 1)
-		}
-
-		got := v[0]
-
-		if got != want {
-			t.Fatalf("Items() got = %v, want = %v", got, want)
-		}
-	})
-
-	t.Run("Items()_date_search_>=", func(t *testing.T) {
-		want := 4
-
-		v, err := tdb.Items(context.Background(), storage.Filter{Date: storage.TimeFilter{Value: 1659344500, Operator: ">="}})
-		if err != nil {
-			t.Fatalf("Items() error = %v", err)
-		}
-
-		if len(v) != want {
-			t.Fatalf("Items() got items = %d, want = %d", len(v), want)
-		}
-
-	})
-
-	t.Run("Items()_date_search_IN", func(t *testing.T) {
-		want := []storage.Item{testItem2, testItem3}
-
-		got, err := tdb.Items(context.Background(),
-			storage.Filter{
-				Date:    storage.TimeFilter{Value: 1659344500, Operator: ">"},
-				EndDate: storage.TimeFilter{Value: 1659517300, Operator: "<="},
-			})
-		if err != nil {
-			t.Fatalf("Items() error = %v", err)
-		}
-
-		if len(got) != len(want) {
-			t.Fatalf("Items() got items = %d, want = %d", len(got), len(want))
-		}
-
-		for i := range want {
-			if got[i] != want[i] {
-				t.Fatalf("Items() got = %v, want = %v", got[i], want[i])
-			}
-		}
-
-	})
-
-	t.Run("Items()_sort_by_date", func(t *testing.T) {
-		want := []storage.Item{testItem4, testItem3, testItem2, testItem1}
-
-		got, err := tdb.Items(context.Background(),
-			storage.Filter{Page: 1, SortBy: storage.Date})
-		if err != nil {
-			t.Fatalf("Items() error = %v", err)
-		}
-
-		if len(got) != len(want) {
-			t.Fatalf("Items() got items = %d, want = %d", len(got), len(want))
-		}
-
-		for i := range want {
-			if got[i] != want[i] {
-				t.Fatalf("Items() got = %v, want = %v", got[i], want[i])
-			}
-		}
-
-	})
-
-	t.Run("Items()_sort_by_rank", func(t *testing.T) {
-		want := []storage.Item{testItem1, testItem2, testItem3}
-
-		got, err := tdb.Items(context.Background(),
-			storage.Filter{Page: 1, SortBy: storage.Rank, TitleSearch: []string{"go"}})
-		if err != nil {
-			t.Fatalf("Items() error = %v", err)
-		}
-
-		if len(got) != len(want) {
-			t.Fatalf("Items() got items = %d, want = %d", len(got), len(want))
-		}
-
-		for i := range want {
-			if got[i] != want[i] {
-				t.Fatalf("Items() got = %v, want = %v", got[i], want[i])
-			}
-		}
-
-	})
-}
-
-var testItem1 = storage.Item{
-	Id:          1,
-	Title:       "Заголовок 1; go go go go",
-	Description: "Описание 1",
-	PubDate:     1659603700,
-	Link:        "https://test.com/14987527",
-}
-
-var testItem2 = storage.Item{
-	Id:          2,
-	Title:       "Заголовок 2; база данных база данных база данных go go",
-	Description: "Описание 2",
-	PubDate:     1659517300,
-	Link:        "https://test.com/14987528",
-}
-
-var testItem3 = storage.Item{
-	Id:          3,
-	Title:       "Заголовок 3; голэнг go",
-	Description: "Описание 3",
-	PubDate:     1659430900,
-	Link:        "https://test.com/14987529",
-}
-
-var testItem4 = storage.Item{
-	Id:          4,
-	Title:       "Заголовок 4; индепотентность",
-	Description: "Описание 4",
-	PubDate:     1659344500,
-	Link:        "https://test.com/149875210",
 }
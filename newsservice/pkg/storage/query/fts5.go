@@ -0,0 +1,75 @@
+package query
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrFTS5Unsupported - запрос нельзя записать в синтаксисе FTS5. Исключение
+// внутри OR запрещено уже при разборе запроса, так что ошибка не ожидается.
+var ErrFTS5Unsupported = errors.New("query: exclusion inside OR is not supported by FTS5")
+
+// FTS5 возвращает запрос в синтаксисе MATCH полнотекстового
+// индекса FTS5 SQLite. Все слова запроса заключены в кавычки,
+// поэтому результат безопасно передавать в MATCH.
+//
+// В FTS5 нет унарного отрицания, поэтому исключения
+// записываются как '(условия) NOT исключение'.
+func (q *Query) FTS5() (string, error) {
+	if q.Empty() {
+		return "", nil
+	}
+
+	var pos, neg []string
+	for _, n := range q.root {
+		if nn, ok := n.(not); ok {
+			s, err := fts5(nn.n)
+			if err != nil {
+				return "", err
+			}
+			neg = append(neg, s)
+			continue
+		}
+		s, err := fts5(n)
+		if err != nil {
+			return "", err
+		}
+		pos = append(pos, s)
+	}
+
+	var b strings.Builder
+	b.WriteByte('(')
+	b.WriteString(strings.Join(pos, " AND "))
+	b.WriteByte(')')
+	for _, s := range neg {
+		b.WriteString(" NOT ")
+		b.WriteString(s)
+	}
+
+	return b.String(), nil
+}
+
+func fts5(n node) (string, error) {
+	switch n := n.(type) {
+	case term:
+		// фраза FTS5 - слова через пробел в кавычках,
+		// слова состоят только из букв и цифр
+		s := `"` + strings.Join(n.words, " ") + `"`
+		if n.prefix {
+			s += "*"
+		}
+		return s, nil
+	case or:
+		parts := make([]string, 0, len(n))
+		for _, c := range n {
+			s, err := fts5(c)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, s)
+		}
+		return "(" + strings.Join(parts, " OR ") + ")", nil
+	default:
+		return "", ErrFTS5Unsupported
+	}
+}
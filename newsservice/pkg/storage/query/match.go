@@ -0,0 +1,170 @@
+package query
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Words разбивает текст на слова в нижнем регистре
+// по тем же правилам, что и запрос.
+func Words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Match сообщает, соответствует ли запросу текст,
+// разбитый на слова функцией Words.
+// В отличие от PostgreSQL, слова сравниваются
+// без учета словоформ.
+func (q *Query) Match(words []string) bool {
+	if q.Empty() {
+		return true
+	}
+	return q.root.match(words)
+}
+
+// Hits возвращает количество вхождений в текст
+// слов и фраз запроса, кроме исключенных.
+func (q *Query) Hits(words []string) int {
+	if q.Empty() {
+		return 0
+	}
+	return q.root.hits(words)
+}
+
+// Highlight обрамляет в тексте слова, совпавшие
+// со словами запроса, маркерами startSel и stopSel.
+func (q *Query) Highlight(text, startSel, stopSel string) string {
+	if q.Empty() {
+		return text
+	}
+
+	var terms []term
+	var walk func(n node)
+	walk = func(n node) {
+		switch n := n.(type) {
+		case term:
+			terms = append(terms, n)
+		case or:
+			for _, c := range n {
+				walk(c)
+			}
+		case and:
+			for _, c := range n {
+				walk(c)
+			}
+		}
+	}
+	walk(q.root)
+
+	highlighted := func(w string) bool {
+		w = strings.ToLower(w)
+		for _, t := range terms {
+			for i, tw := range t.words {
+				if t.matchWord(i, w, tw) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	var b strings.Builder
+	rs := []rune(text)
+	for i := 0; i < len(rs); {
+		if !unicode.IsLetter(rs[i]) && !unicode.IsDigit(rs[i]) {
+			b.WriteRune(rs[i])
+			i++
+			continue
+		}
+		end := i
+		for end < len(rs) && (unicode.IsLetter(rs[end]) || unicode.IsDigit(rs[end])) {
+			end++
+		}
+		w := string(rs[i:end])
+		if highlighted(w) {
+			b.WriteString(startSel)
+			b.WriteString(w)
+			b.WriteString(stopSel)
+		} else {
+			b.WriteString(w)
+		}
+		i = end
+	}
+
+	return b.String()
+}
+
+func (a and) match(words []string) bool {
+	for _, n := range a {
+		if !n.match(words) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a and) hits(words []string) int {
+	var c int
+	for _, n := range a {
+		c += n.hits(words)
+	}
+	return c
+}
+
+func (o or) match(words []string) bool {
+	for _, n := range o {
+		if n.match(words) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o or) hits(words []string) int {
+	var c int
+	for _, n := range o {
+		c += n.hits(words)
+	}
+	return c
+}
+
+func (n not) match(words []string) bool {
+	return !n.n.match(words)
+}
+
+func (n not) hits(_ []string) int {
+	return 0
+}
+
+func (t term) match(words []string) bool {
+	return t.hits(words) > 0
+}
+
+// hits считает вхождения слова или фразы в текст.
+func (t term) hits(words []string) int {
+	var c int
+	for i := 0; i+len(t.words) <= len(words); i++ {
+		ok := true
+		for j, tw := range t.words {
+			if !t.matchWord(j, words[i+j], tw) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			c++
+		}
+	}
+	return c
+}
+
+// matchWord сравнивает слово текста w с i-ым словом tw
+// фразы, учитывая, что последнее слово может быть префиксом.
+func (t term) matchWord(i int, w, tw string) bool {
+	if t.prefix && i == len(t.words)-1 {
+		return strings.HasPrefix(w, tw)
+	}
+	return w == tw
+}
@@ -0,0 +1,91 @@
+package query
+
+import (
+	"math"
+	"testing"
+)
+
+func TestQuery_Match(t *testing.T) {
+	text := Words("Заголовок 2; база данных база данных go go")
+
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{in: "go", want: true},
+		{in: "GO база", want: true},
+		{in: "go rust", want: false},
+		{in: "go OR rust", want: true},
+		{in: `"база данных"`, want: true},
+		{in: `"данных база"`, want: true},
+		{in: `"go база"`, want: false},
+		{in: "go -база", want: false},
+		{in: "go -rust", want: true},
+		{in: "заголов*", want: true},
+		{in: `"база дан*"`, want: true},
+		{in: "заголов", want: false},
+	}
+
+	for _, tt := range tests {
+		q, err := Parse(tt.in)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", tt.in, err)
+		}
+		if got := q.Match(text); got != tt.want {
+			t.Errorf("Parse(%q).Match() = %v, want = %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestQuery_Hits(t *testing.T) {
+	q, err := Parse(`go "база данных" -rust`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if got, want := q.Hits(Words("база данных база данных go go")), 4; got != want {
+		t.Errorf("Query.Hits() = %d, want = %d", got, want)
+	}
+}
+
+func TestQuery_Highlight(t *testing.T) {
+	q, err := Parse("go заголов*")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	got := q.Highlight("Заголовок 1; Go, goto", "<b>", "</b>")
+	want := "<b>Заголовок</b> 1; <b>Go</b>, goto"
+
+	if got != want {
+		t.Errorf("Query.Highlight() = %q, want = %q", got, want)
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{a: "word", b: "word", want: 1},
+		{a: "word", b: "", want: 0},
+		{a: "word", b: "two", want: 0},
+		// триграммы: '  w',' wo','wor','ord','rd ' и '  w',' wo','wor','ork','rk '
+		{a: "word", b: "work", want: 3.0 / 7.0},
+	}
+
+	for _, tt := range tests {
+		if got := Similarity(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Similarity(%q, %q) = %v, want = %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestWordSimilarity(t *testing.T) {
+	if got := WordSimilarity("индепотентнсть", "Заголовок 4; индепотентность"); got < 0.6 {
+		t.Errorf("WordSimilarity() = %v, want >= 0.6", got)
+	}
+	if got := WordSimilarity("база", "Заголовок 4; индепотентность"); got > 0.3 {
+		t.Errorf("WordSimilarity() = %v, want <= 0.3", got)
+	}
+}
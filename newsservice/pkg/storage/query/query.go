@@ -0,0 +1,476 @@
+// пакет query разбирает поисковые запросы пользователя
+// и преобразует их в запросы к хранилищу.
+//
+// Синтаксис запроса:
+//
+//	слово             - новость должна содержать слово;
+//	слово1 слово2     - должна содержать оба слова;
+//	"слово1 слово2"   - должна содержать фразу (слова подряд);
+//	слово1 OR слово2  - должна содержать хотя бы одно из слов (также '|');
+//	-слово, -"фраза"  - не должна содержать слово или фразу (не внутри OR);
+//	слов*             - слово, начинающееся с 'слов'.
+package query
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Ограничения на размер запроса.
+const (
+	MaxLen   = 256 // максимальная длина запроса в символах.
+	MaxTerms = 32  // максимальное количество слов в запросе.
+)
+
+// SyntaxError - ошибка разбора запроса.
+type SyntaxError struct {
+	Pos int    // позиция символа, на котором возникла ошибка (с 1).
+	Msg string // описание ошибки.
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Pos > 0 {
+		return fmt.Sprintf("query syntax error at position %d: %s", e.Pos, e.Msg)
+	}
+	return fmt.Sprintf("query syntax error: %s", e.Msg)
+}
+
+// Query - разобранный поисковый запрос.
+type Query struct {
+	root and
+}
+
+// node - узел дерева запроса.
+type node interface {
+	tsquery(b *strings.Builder)
+	size() int
+	match(words []string) bool
+	hits(words []string) int
+}
+
+// term - слово или фраза (слова подряд).
+type term struct {
+	words  []string
+	prefix bool // последнее слово - префикс.
+}
+
+// not - отрицание.
+type not struct {
+	n node
+}
+
+// or - хотя бы одно из.
+type or []node
+
+// and - все из.
+type and []node
+
+// Parse разбирает строку запроса. Запрос должен содержать
+// хотя бы одно слово для поиска, а не только исключения.
+func Parse(s string) (*Query, error) {
+	q, err := parseAny(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if !q.Empty() && !q.HasTerms() {
+		return nil, &SyntaxError{Msg: "query must contain at least one term to search for, not only exclusions"}
+	}
+
+	return q, nil
+}
+
+// parseAny разбирает строку запроса, которая
+// может состоять только из исключений.
+func parseAny(s string) (*Query, error) {
+	if utf8.RuneCountInString(s) > MaxLen {
+		return nil, &SyntaxError{Msg: fmt.Sprintf("query is too long, maximum is %d characters", MaxLen)}
+	}
+
+	toks, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := parse(toks)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &Query{root: root}
+	if q.root.size() > MaxTerms {
+		return nil, &SyntaxError{Msg: fmt.Sprintf("too many terms, maximum is %d", MaxTerms)}
+	}
+
+	return q, nil
+}
+
+// Build разбирает поисковые фразы и фразы-исключения
+// и объединяет их в один запрос: новость должна соответствовать
+// каждой из фраз search и не содержать ни одного слова из exclude.
+// Без фраз search запрос состоит только из исключений (см. HasTerms),
+// '-слово' в exclude - тоже исключение. Возвращает nil, если запрос пуст.
+func Build(search, exclude []string) (*Query, error) {
+	var root and
+
+	for _, s := range search {
+		q, err := Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		root = append(root, q.root...)
+	}
+
+	for _, s := range exclude {
+		q, err := parseAny(s)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range q.root {
+			if neg, ok := n.(not); ok {
+				// '-слово' в исключениях - тоже исключение
+				root = append(root, neg)
+				continue
+			}
+			root = append(root, not{n: n})
+		}
+	}
+
+	if len(root) == 0 {
+		return nil, nil
+	}
+
+	if root.size() > MaxTerms {
+		return nil, &SyntaxError{Msg: fmt.Sprintf("too many terms, maximum is %d", MaxTerms)}
+	}
+
+	return &Query{root: root}, nil
+}
+
+// Empty сообщает, что запрос не содержит условий.
+func (q *Query) Empty() bool {
+	return q == nil || len(q.root) == 0
+}
+
+// HasTerms сообщает, что запрос содержит слова для поиска, а не только
+// исключения. Запрос из одних исключений не ищет, а только отсеивает
+// новости (см. Exclusions): по нему нет фрагментов и сортировки по совпадениям.
+func (q *Query) HasTerms() bool {
+	return !q.Empty() && q.root.positive()
+}
+
+// TSQuery возвращает запрос в синтаксисе to_tsquery PostgreSQL.
+// Все слова запроса экранированы, поэтому результат безопасно
+// передавать в to_tsquery.
+func (q *Query) TSQuery() string {
+	if q.Empty() {
+		return ""
+	}
+	var b strings.Builder
+	q.root.tsquery(&b)
+	return b.String()
+}
+
+// Terms возвращает слова запроса, кроме исключенных,
+// в порядке их следования.
+func (q *Query) Terms() []string {
+	if q.Empty() {
+		return nil
+	}
+	var terms []string
+	var walk func(n node)
+	walk = func(n node) {
+		switch n := n.(type) {
+		case term:
+			terms = append(terms, n.words...)
+		case or:
+			for _, c := range n {
+				walk(c)
+			}
+		case and:
+			for _, c := range n {
+				walk(c)
+			}
+		}
+	}
+	walk(q.root)
+	return terms
+}
+
+// Exclusions возвращает запрос, которому соответствует
+// новость, содержащая хотя бы одно из исключений
+// исходного запроса. Возвращает nil, если исключений нет.
+func (q *Query) Exclusions() *Query {
+	if q.Empty() {
+		return nil
+	}
+	var excl or
+	for _, n := range q.root {
+		if neg, ok := n.(not); ok {
+			excl = append(excl, neg.n)
+		}
+	}
+	switch len(excl) {
+	case 0:
+		return nil
+	case 1:
+		return &Query{root: and{excl[0]}}
+	default:
+		return &Query{root: and{excl}}
+	}
+}
+
+func (q *Query) String() string {
+	return q.TSQuery()
+}
+
+// positive сообщает, что запрос содержит хотя бы
+// одно условие, не являющееся отрицанием.
+func (a and) positive() bool {
+	for _, n := range a {
+		if _, ok := n.(not); !ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (a and) size() int {
+	var c int
+	for _, n := range a {
+		c += n.size()
+	}
+	return c
+}
+
+func (a and) tsquery(b *strings.Builder) {
+	for i, n := range a {
+		if i > 0 {
+			b.WriteString(" & ")
+		}
+		n.tsquery(b)
+	}
+}
+
+func (o or) size() int {
+	var c int
+	for _, n := range o {
+		c += n.size()
+	}
+	return c
+}
+
+func (o or) tsquery(b *strings.Builder) {
+	b.WriteByte('(')
+	for i, n := range o {
+		if i > 0 {
+			b.WriteString(" | ")
+		}
+		n.tsquery(b)
+	}
+	b.WriteByte(')')
+}
+
+func (n not) size() int {
+	return n.n.size()
+}
+
+func (n not) tsquery(b *strings.Builder) {
+	b.WriteByte('!')
+	n.n.tsquery(b)
+}
+
+func (t term) size() int {
+	return len(t.words)
+}
+
+func (t term) tsquery(b *strings.Builder) {
+	if len(t.words) > 1 {
+		b.WriteByte('(')
+	}
+	for i, w := range t.words {
+		if i > 0 {
+			b.WriteString(" <-> ")
+		}
+		// слова состоят только из букв и цифр,
+		// поэтому кавычки внутри слова невозможны
+		b.WriteByte('\'')
+		b.WriteString(w)
+		b.WriteByte('\'')
+		if t.prefix && i == len(t.words)-1 {
+			b.WriteString(":*")
+		}
+	}
+	if len(t.words) > 1 {
+		b.WriteByte(')')
+	}
+}
+
+// tokenKind - тип лексемы.
+type tokenKind int
+
+const (
+	tokTerm tokenKind = iota
+	tokOr
+)
+
+type token struct {
+	kind    tokenKind
+	pos     int
+	term    term
+	negated bool
+}
+
+// lex разбивает строку запроса на лексемы.
+func lex(s string) ([]token, error) {
+	var toks []token
+	rs := []rune(s)
+
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '|':
+			toks = append(toks, token{kind: tokOr, pos: i + 1})
+			i++
+
+		default:
+			pos := i + 1
+			negated := false
+			if r == '-' {
+				negated = true
+				i++
+				if i == len(rs) || unicode.IsSpace(rs[i]) || rs[i] == '|' {
+					return nil, &SyntaxError{Pos: pos, Msg: "'-' must be followed by a term to exclude"}
+				}
+			}
+
+			var (
+				raw    string
+				quoted bool
+			)
+			if rs[i] == '"' {
+				end := i + 1
+				for end < len(rs) && rs[end] != '"' {
+					end++
+				}
+				if end == len(rs) {
+					return nil, &SyntaxError{Pos: i + 1, Msg: "unterminated quote"}
+				}
+				raw, quoted = string(rs[i+1:end]), true
+				i = end + 1
+			} else {
+				end := i
+				for end < len(rs) && !unicode.IsSpace(rs[end]) && rs[end] != '"' && rs[end] != '|' {
+					end++
+				}
+				raw = string(rs[i:end])
+				i = end
+			}
+
+			if !quoted && !negated && raw == "OR" {
+				toks = append(toks, token{kind: tokOr, pos: pos})
+				continue
+			}
+
+			t, err := newTerm(raw, pos)
+			if err != nil {
+				return nil, err
+			}
+			if quoted && len(t.words) == 0 {
+				return nil, &SyntaxError{Pos: pos, Msg: "empty phrase"}
+			}
+			if len(t.words) == 0 {
+				return nil, &SyntaxError{Pos: pos, Msg: fmt.Sprintf("term %q contains no letters or digits", raw)}
+			}
+
+			toks = append(toks, token{kind: tokTerm, pos: pos, term: t, negated: negated})
+		}
+	}
+
+	return toks, nil
+}
+
+// newTerm разбивает слово или фразу на слова из букв и цифр.
+// Знаки препинания внутри слова считаются разделителями,
+// поэтому 'covid-19' означает фразу 'covid 19'.
+func newTerm(raw string, pos int) (term, error) {
+	var t term
+
+	rs := []rune(raw)
+	if n := len(rs); n > 0 && rs[n-1] == '*' {
+		t.prefix = true
+		rs = rs[:n-1]
+	}
+
+	var w strings.Builder
+	flush := func() {
+		if w.Len() > 0 {
+			t.words = append(t.words, strings.ToLower(w.String()))
+			w.Reset()
+		}
+	}
+
+	for _, r := range rs {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			w.WriteRune(r)
+		case r == '*':
+			return t, &SyntaxError{Pos: pos, Msg: "'*' is only allowed at the end of a term"}
+		default:
+			flush()
+		}
+	}
+	flush()
+
+	return t, nil
+}
+
+// parse строит дерево запроса из лексем.
+// OR связывает соседние слова сильнее, чем неявное И:
+// 'a b OR c' означает 'a И (b ИЛИ c)'. Исключение внутри OR
+// ('a OR -b') запрещено: не все хранилища могут его выполнить.
+func parse(toks []token) (and, error) {
+	var root and
+
+	for i := 0; i < len(toks); i++ {
+		tok := toks[i]
+		if tok.kind == tokOr {
+			return nil, &SyntaxError{Pos: tok.pos, Msg: "OR must be placed between two terms"}
+		}
+
+		group := or{tok.node()}
+		for i+1 < len(toks) && toks[i+1].kind == tokOr {
+			if i+2 >= len(toks) || toks[i+2].kind != tokTerm {
+				return nil, &SyntaxError{Pos: toks[i+1].pos, Msg: "OR must be placed between two terms"}
+			}
+			group = append(group, toks[i+2].node())
+			i += 2
+		}
+
+		if len(group) == 1 {
+			root = append(root, group[0])
+			continue
+		}
+		for j := i - 2*(len(group)-1); j <= i; j += 2 {
+			if toks[j].negated {
+				return nil, &SyntaxError{Pos: toks[j].pos,
+					Msg: "exclusion can't be a part of OR, place it separately: 'go OR rust -java'"}
+			}
+		}
+		root = append(root, group)
+	}
+
+	return root, nil
+}
+
+func (t token) node() node {
+	if t.negated {
+		return not{n: t.term}
+	}
+	return t.term
+}
@@ -0,0 +1,202 @@
+package query
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "word", in: "go", want: "'go'"},
+		{name: "implicit_and", in: "голэнг go", want: "'голэнг' & 'go'"},
+		{name: "lowercase", in: "Go ПУТИН", want: "'go' & 'путин'"},
+		{name: "phrase", in: `"база данных"`, want: "('база' <-> 'данных')"},
+		{name: "or", in: "go OR rust", want: "('go' | 'rust')"},
+		{name: "pipe", in: "go|rust", want: "('go' | 'rust')"},
+		{name: "or_binds_tighter", in: "новости go OR rust", want: "'новости' & ('go' | 'rust')"},
+		{name: "or_chain", in: "a OR b OR c", want: "('a' | 'b' | 'c')"},
+		{name: "lowercase_or_is_word", in: "go or rust", want: "'go' & 'or' & 'rust'"},
+		{name: "exclude", in: "go -rust", want: "'go' & !'rust'"},
+		{name: "exclude_phrase", in: `go -"база данных"`, want: "'go' & !('база' <-> 'данных')"},
+		{name: "prefix", in: "прогр*", want: "'прогр':*"},
+		{name: "prefix_in_phrase", in: `"язык прогр*"`, want: "('язык' <-> 'прогр':*)"},
+		{name: "punctuation_splits_words", in: "covid-19", want: "('covid' <-> '19')"},
+		{name: "special_chars_escaped", in: "a:b 'c' d&e!", want: "('a' <-> 'b') & 'c' & ('d' <-> 'e')"},
+		{name: "empty", in: "  ", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := Parse(tt.in)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.in, err)
+			}
+			if got := q.TSQuery(); got != tt.want {
+				t.Errorf("Parse(%q).TSQuery() = %q, want = %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		wantMsg string
+	}{
+		{name: "unterminated_quote", in: `go "база данных`, wantMsg: "position 4: unterminated quote"},
+		{name: "empty_phrase", in: `go ""`, wantMsg: "empty phrase"},
+		{name: "leading_or", in: "OR go", wantMsg: "OR must be placed between two terms"},
+		{name: "trailing_or", in: "go |", wantMsg: "OR must be placed between two terms"},
+		{name: "double_or", in: "go OR OR rust", wantMsg: "OR must be placed between two terms"},
+		{name: "lone_minus", in: "go - rust", wantMsg: "'-' must be followed by a term"},
+		{name: "only_exclusions", in: "-go", wantMsg: "not only exclusions"},
+		{name: "no_letters", in: "go ???", wantMsg: "contains no letters or digits"},
+		{name: "star_in_middle", in: "g*o", wantMsg: "'*' is only allowed at the end"},
+		{name: "exclusion_in_or", in: "go OR -java", wantMsg: "position 7: exclusion can't be a part of OR"},
+		{name: "exclusion_in_or_first", in: "-java | go", wantMsg: "position 1: exclusion can't be a part of OR"},
+		{name: "too_long", in: strings.Repeat("a", MaxLen+1), wantMsg: "too long"},
+		{name: "too_many_terms", in: strings.Repeat("a ", MaxTerms+1), wantMsg: "too many terms"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.in)
+			var serr *SyntaxError
+			if !errors.As(err, &serr) {
+				t.Fatalf("Parse(%q) error = %v, want *SyntaxError", tt.in, err)
+			}
+			if !strings.Contains(err.Error(), tt.wantMsg) {
+				t.Errorf("Parse(%q) error = %q, want it to contain %q", tt.in, err, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	tests := []struct {
+		name    string
+		search  []string
+		exclude []string
+		want    string
+		wantErr bool
+	}{
+		{name: "none", want: ""},
+		{name: "search_values_joined", search: []string{"голэнг", "go"}, want: "'голэнг' & 'go'"},
+		{name: "exclude", search: []string{"go"}, exclude: []string{"голэнг"}, want: "'go' & !'голэнг'"},
+		{name: "exclude_every_word", search: []string{"go"}, exclude: []string{"a b"}, want: "'go' & !'a' & !'b'"},
+		{name: "exclude_only", exclude: []string{"go"}, want: "!'go'"},
+		{name: "exclude_negated", exclude: []string{"-go rust"}, want: "!'go' & !'rust'"},
+		{name: "search_only_exclusions", search: []string{"-go"}, exclude: []string{"rust"}, wantErr: true},
+		{name: "bad_exclude", search: []string{"go"}, exclude: []string{`"go`}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := Build(tt.search, tt.exclude)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Build() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := q.TSQuery(); got != tt.want {
+				t.Errorf("Build().TSQuery() = %q, want = %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuery_Terms(t *testing.T) {
+	q, err := Parse(`новости "база данных" go OR rust* -java`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := []string{"новости", "база", "данных", "go", "rust"}
+	got := q.Terms()
+
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Query.Terms() = %v, want = %v", got, want)
+	}
+}
+
+func TestQuery_Exclusions(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "go", want: ""},
+		{in: "go -java", want: "'java'"},
+		{in: `go -java -"c sharp"`, want: "('java' | ('c' <-> 'sharp'))"},
+	}
+
+	for _, tt := range tests {
+		q, err := Parse(tt.in)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", tt.in, err)
+		}
+		if got := q.Exclusions().TSQuery(); got != tt.want {
+			t.Errorf("Parse(%q).Exclusions().TSQuery() = %q, want = %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestQuery_FTS5(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "go", want: `("go")`},
+		{in: "голэнг go", want: `("голэнг" AND "go")`},
+		{in: `"база данных" прогр*`, want: `("база данных" AND "прогр"*)`},
+		{in: "go OR rust", want: `(("go" OR "rust"))`},
+		{in: `go -java -"c sharp"`, want: `("go") NOT "java" NOT "c sharp"`},
+	}
+
+	for _, tt := range tests {
+		q, err := Parse(tt.in)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", tt.in, err)
+		}
+		got, err := q.FTS5()
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("Parse(%q).FTS5() error = %v, wantErr = %v", tt.in, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%q).FTS5() = %q, want = %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestQuery_HasTerms(t *testing.T) {
+	tests := []struct {
+		name    string
+		search  []string
+		exclude []string
+		want    bool
+	}{
+		{name: "none"},
+		{name: "search", search: []string{"go"}, want: true},
+		{name: "search_exclude", search: []string{"go -java"}, exclude: []string{"rust"}, want: true},
+		{name: "exclude_only", exclude: []string{"rust"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := Build(tt.search, tt.exclude)
+			if err != nil {
+				t.Fatalf("Build() error = %v", err)
+			}
+			if got := q.HasTerms(); got != tt.want {
+				t.Errorf("Build().HasTerms() = %v, want = %v", got, tt.want)
+			}
+		})
+	}
+}
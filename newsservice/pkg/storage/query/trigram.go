@@ -0,0 +1,59 @@
+package query
+
+import "strings"
+
+// Similarity возвращает схожесть слов от 0 до 1 по набору
+// триграмм так же, как функция similarity расширения pg_trgm.
+func Similarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+
+	var common int
+	for t := range ta {
+		if _, ok := tb[t]; ok {
+			common++
+		}
+	}
+
+	return float64(common) / float64(len(ta)+len(tb)-common)
+}
+
+// WordSimilarity возвращает схожесть запроса q с наиболее
+// похожими словами текста: для каждого слова запроса находится
+// самое похожее слово текста, результат - среднее по словам запроса.
+// Это приближение функции word_similarity расширения pg_trgm.
+func WordSimilarity(q, text string) float64 {
+	qw, tw := Words(q), Words(text)
+	if len(qw) == 0 || len(tw) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, w := range qw {
+		var best float64
+		for _, t := range tw {
+			if s := Similarity(w, t); s > best {
+				best = s
+			}
+		}
+		sum += best
+	}
+
+	return sum / float64(len(qw))
+}
+
+// trigrams возвращает набор триграмм слов строки,
+// каждое слово дополняется двумя пробелами в начале
+// и одним в конце, как в pg_trgm.
+func trigrams(s string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, w := range Words(s) {
+		rs := []rune("  " + strings.ToLower(w) + " ")
+		for i := 0; i+3 <= len(rs); i++ {
+			set[string(rs[i:i+3])] = struct{}{}
+		}
+	}
+	return set
+}
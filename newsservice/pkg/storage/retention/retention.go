@@ -0,0 +1,285 @@
+package retention
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rtemka/agg/news/pkg/storage"
+)
+
+// BatchSize - размер пачки удаляемых новостей по умолчанию.
+const BatchSize = 500
+
+// batchPause - пауза между пачками, чтобы не занимать БД надолго.
+const batchPause = 100 * time.Millisecond
+
+// Policy - правила хранения новостей.
+type Policy struct {
+	// MaxAge - сколько хранятся новости, если 0, то бессрочно.
+	MaxAge time.Duration
+	// Sources - сроки хранения новостей отдельных источников
+	// по адресу rss-канала, вместо MaxAge. 0 - бессрочно.
+	Sources map[string]time.Duration
+	// ArchiveDir - каталог, в который перед удалением выгружаются
+	// новости (сжатый NDJSON), если пустой, то новости просто удаляются.
+	ArchiveDir string
+	// BatchSize - сколько новостей удаляется за раз,
+	// если 0, то используется BatchSize.
+	BatchSize int
+}
+
+// Enabled сообщает, удаляет ли политика хоть какие-то новости.
+func (p *Policy) Enabled() bool {
+	if p.MaxAge > 0 {
+		return true
+	}
+	for _, age := range p.Sources {
+		if age > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Retention удаляет или архивирует устаревшие новости.
+type Retention struct {
+	log     *log.Logger
+	storage storage.Storage
+	policy  Policy
+	pause   time.Duration
+	now     func() time.Time
+	// когда установлен в true, логгирует каждую пачку,
+	// по-умолчанию false
+	debugMode bool
+}
+
+// New возвращает новый объект *Retention.
+func New(log *log.Logger, storage storage.Storage, policy Policy) *Retention {
+	if policy.BatchSize <= 0 {
+		policy.BatchSize = BatchSize
+	}
+	return &Retention{
+		log:     log,
+		storage: storage,
+		policy:  policy,
+		pause:   batchPause,
+		now:     time.Now,
+	}
+}
+
+// DebugMode переключает debug режим у *Retention
+func (r *Retention) DebugMode(on bool) *Retention {
+	r.debugMode = on
+	return r
+}
+
+// Stats - итоги одного прохода *Retention.
+type Stats struct {
+	Deleted  int    // удаленные новости.
+	Archived int    // выгруженные в архив новости.
+	Archive  string // файл архива, если в него что-то выгружено.
+}
+
+// rule - новости, отобранные фильтром, старше cutoff удаляются.
+type rule struct {
+	name   string
+	filter storage.Filter
+	cutoff int64
+}
+
+// Run удаляет устаревшие новости сразу и затем
+// каждый период period, пока не закрыт контекст.
+func (r *Retention) Run(ctx context.Context, period time.Duration) {
+	t := time.NewTicker(period)
+	defer t.Stop()
+
+	for {
+		stats, err := r.Purge(ctx)
+		if err != nil && ctx.Err() == nil {
+			r.log.Printf("[ERROR] retention: deleted=%d archived=%d error=%v",
+				stats.Deleted, stats.Archived, err)
+		} else if err == nil {
+			r.log.Printf("[INFO] retention: deleted=%d archived=%d archive=%q",
+				stats.Deleted, stats.Archived, stats.Archive)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Purge за один проход удаляет пачками все новости старше срока
+// хранения их источника, предварительно выгружая их в архив.
+func (r *Retention) Purge(ctx context.Context) (Stats, error) {
+	var stats Stats
+
+	rules, err := r.rules(ctx)
+	if err != nil {
+		return stats, err
+	}
+
+	var arch *archive
+	defer func() {
+		if arch != nil {
+			if err := arch.close(); err != nil {
+				r.log.Printf("[ERROR] retention: archive=%q error=%v", arch.name, err)
+			}
+		}
+	}()
+
+	for _, rl := range rules {
+		f := rl.filter
+		f.Date = storage.TimeFilter{Value: rl.cutoff, Operator: "<"}
+		f.SortBy = storage.Date
+		f.Page, f.PageSize = 1, r.policy.BatchSize
+
+		for {
+			items, err := r.storage.Items(ctx, f)
+			if err != nil {
+				return stats, err
+			}
+			if len(items) == 0 {
+				break
+			}
+
+			if r.policy.ArchiveDir != "" {
+				if arch == nil {
+					if arch, err = newArchive(r.policy.ArchiveDir, r.now()); err != nil {
+						return stats, err
+					}
+					stats.Archive = arch.name
+				}
+				// новости удаляются, только когда они уже на диске
+				if err := arch.write(items); err != nil {
+					return stats, err
+				}
+				stats.Archived += len(items)
+			}
+
+			ids := make([]int64, len(items))
+			for i := range items {
+				ids[i] = items[i].Id
+			}
+			n, err := r.storage.DeleteItems(ctx, ids)
+			if err != nil {
+				return stats, err
+			}
+			stats.Deleted += n
+
+			if r.debugMode {
+				r.log.Printf("[DEBUG] retention: rule=%s deleted=%d", rl.name, n)
+			}
+
+			if n == 0 || len(items) < f.PageSize {
+				break
+			}
+
+			select {
+			case <-ctx.Done():
+				return stats, ctx.Err()
+			case <-time.After(r.pause):
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+// rules составляет правила удаления: по одному для каждого источника
+// со своим сроком и общее для остальных новостей.
+func (r *Retention) rules(ctx context.Context) ([]rule, error) {
+	now := r.now()
+	var rules []rule
+	var own []int64 // источники со своим сроком хранения.
+
+	if len(r.policy.Sources) > 0 {
+		sources, err := r.storage.Sources(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, src := range sources {
+			age, ok := r.policy.Sources[src.FeedURL]
+			if !ok {
+				continue
+			}
+			own = append(own, src.Id)
+			if age > 0 {
+				rules = append(rules, rule{
+					name:   src.FeedURL,
+					filter: storage.Filter{Sources: []int64{src.Id}},
+					cutoff: now.Add(-age).Unix(),
+				})
+			}
+		}
+	}
+
+	if r.policy.MaxAge > 0 {
+		rules = append(rules, rule{
+			name:   "default",
+			filter: storage.Filter{ExcludeSources: own},
+			cutoff: now.Add(-r.policy.MaxAge).Unix(),
+		})
+	}
+
+	return rules, nil
+}
+
+// archive - файл архива новостей в формате NDJSON, сжатом gzip.
+type archive struct {
+	name string
+	f    *os.File
+	gz   *gzip.Writer
+	enc  *json.Encoder
+}
+
+// newArchive создает в каталоге dir новый файл архива.
+func newArchive(dir string, now time.Time) (*archive, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	name := filepath.Join(dir, fmt.Sprintf("news-%s.ndjson.gz", now.UTC().Format("20060102T150405Z")))
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	gz := gzip.NewWriter(f)
+	return &archive{name: name, f: f, gz: gz, enc: json.NewEncoder(gz)}, nil
+}
+
+// write дописывает новости в архив и сбрасывает их на диск.
+func (a *archive) write(items []storage.Item) error {
+	for i := range items {
+		if err := a.enc.Encode(items[i]); err != nil {
+			return err
+		}
+	}
+	if err := a.gz.Flush(); err != nil {
+		return err
+	}
+	return a.f.Sync()
+}
+
+// close завершает сжатый поток и закрывает файл.
+func (a *archive) close() error {
+	if err := a.gz.Close(); err != nil {
+		a.f.Close()
+		return err
+	}
+	if err := a.f.Sync(); err != nil {
+		a.f.Close()
+		return err
+	}
+	return a.f.Close()
+}
@@ -0,0 +1,187 @@
+package retention
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rtemka/agg/news/pkg/storage"
+	"github.com/rtemka/agg/news/pkg/storage/memdb"
+	"github.com/rtemka/agg/news/pkg/storage/storagetest"
+)
+
+const day = 24 * time.Hour
+
+// now - текущее время в тестах.
+var now = time.Unix(1659690000, 0)
+
+// testDB возвращает хранилище с n новостями без источника,
+// n новостями Коммерсанта и n новостями РИА, опубликованными
+// за 1, 2 ... n дней до now.
+func testDB(t *testing.T, n int) *memdb.MemDB {
+	db := memdb.New()
+
+	var items []storage.Item
+	for i := 1; i <= n; i++ {
+		for j, src := range []storage.Source{{}, storagetest.Kommersant, storagetest.RIA} {
+			items = append(items, storage.Item{
+				Title:   fmt.Sprintf("Новость %d", i),
+				PubDate: now.Add(-time.Duration(i)*day + time.Hour).Unix(),
+				Link:    fmt.Sprintf("https://test.com/%d/%d", j, i),
+				Source:  src,
+			})
+		}
+	}
+	if _, err := db.AddItems(context.Background(), items); err != nil {
+		t.Fatalf("AddItems() error = %v", err)
+	}
+
+	return db
+}
+
+func newTestRetention(db storage.Storage, p Policy) *Retention {
+	r := New(log.New(io.Discard, "", 0), db, p)
+	r.pause = 0
+	r.now = func() time.Time { return now }
+	return r
+}
+
+// count возвращает количество новостей источника src, 0 - всех новостей.
+func count(t *testing.T, db storage.Storage, src int64) int {
+	f := storage.Filter{}
+	if src > 0 {
+		f.Sources = []int64{src}
+	}
+	n, err := db.CountItems(context.Background(), f)
+	if err != nil {
+		t.Fatalf("CountItems() error = %v", err)
+	}
+	return n
+}
+
+func TestRetention_Purge(t *testing.T) {
+	db := testDB(t, 10)
+
+	// у Коммерсанта свой срок, РИА хранится бессрочно
+	r := newTestRetention(db, Policy{
+		MaxAge: 3 * day,
+		Sources: map[string]time.Duration{
+			storagetest.Kommersant.FeedURL: 5 * day,
+			storagetest.RIA.FeedURL:        0,
+			"https://unknown.com/rss":      day,
+		},
+		BatchSize: 2,
+	})
+
+	stats, err := r.Purge(context.Background())
+	if err != nil {
+		t.Fatalf("Purge() error = %v", err)
+	}
+
+	// без источника остаются 3 новости, Коммерсанта - 5, РИА - 10
+	if want := 7 + 5; stats.Deleted != want {
+		t.Fatalf("Purge() got deleted = %d, want = %d", stats.Deleted, want)
+	}
+	if stats.Archived != 0 || stats.Archive != "" {
+		t.Fatalf("Purge() got archived = %d to %q, want none", stats.Archived, stats.Archive)
+	}
+
+	sources, err := db.Sources(context.Background())
+	if err != nil {
+		t.Fatalf("Sources() error = %v", err)
+	}
+	if got := count(t, db, 0); got != 3+5+10 {
+		t.Fatalf("CountItems() got = %d, want = %d", got, 3+5+10)
+	}
+	if got := count(t, db, sources[0].Id); got != 5 {
+		t.Fatalf("CountItems(kommersant) got = %d, want = %d", got, 5)
+	}
+	if got := count(t, db, sources[1].Id); got != 10 {
+		t.Fatalf("CountItems(ria) got = %d, want = %d", got, 10)
+	}
+
+	// повторный проход ничего не удаляет
+	if stats, err = r.Purge(context.Background()); err != nil || stats.Deleted != 0 {
+		t.Fatalf("Purge() got deleted = %d, %v, want = 0", stats.Deleted, err)
+	}
+}
+
+func TestRetention_Purge_archive(t *testing.T) {
+	db := testDB(t, 5)
+	dir := t.TempDir()
+
+	r := newTestRetention(db, Policy{MaxAge: 2 * day, ArchiveDir: dir, BatchSize: 4})
+
+	stats, err := r.Purge(context.Background())
+	if err != nil {
+		t.Fatalf("Purge() error = %v", err)
+	}
+	if want := 3 * 3; stats.Deleted != want || stats.Archived != want {
+		t.Fatalf("Purge() got deleted = %d, archived = %d, want = %d", stats.Deleted, stats.Archived, want)
+	}
+
+	f, err := os.Open(stats.Archive)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("gzip.NewReader() error = %v", err)
+	}
+
+	cutoff := now.Add(-2 * day).Unix()
+	seen := make(map[int64]bool)
+	sc := bufio.NewScanner(gz)
+	for sc.Scan() {
+		var it storage.Item
+		if err := json.Unmarshal(sc.Bytes(), &it); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		if it.PubDate >= cutoff {
+			t.Fatalf("archive got fresh item = %v", it)
+		}
+		if seen[it.Id] {
+			t.Fatalf("archive got item %d twice", it.Id)
+		}
+		seen[it.Id] = true
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatalf("archive read error = %v", err)
+	}
+	if len(seen) != stats.Archived {
+		t.Fatalf("archive got items = %d, want = %d", len(seen), stats.Archived)
+	}
+
+	// если удалять нечего, то архив не создается
+	if stats, err = r.Purge(context.Background()); err != nil || stats.Archive != "" {
+		t.Fatalf("Purge() got archive = %q, %v, want none", stats.Archive, err)
+	}
+}
+
+func TestPolicy_Enabled(t *testing.T) {
+	tests := []struct {
+		name string
+		p    Policy
+		want bool
+	}{
+		{name: "empty", p: Policy{}, want: false},
+		{name: "max_age", p: Policy{MaxAge: day}, want: true},
+		{name: "keep_source", p: Policy{Sources: map[string]time.Duration{"a": 0}}, want: false},
+		{name: "source", p: Policy{Sources: map[string]time.Duration{"a": day}}, want: true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.p.Enabled(); got != tt.want {
+			t.Fatalf("Enabled(%s) got = %v, want = %v", tt.name, got, tt.want)
+		}
+	}
+}
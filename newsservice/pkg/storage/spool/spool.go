@@ -0,0 +1,517 @@
+// пакет spool реализует журнал на диске для контейнеров новостей,
+// которые не удалось записать в БД.
+//
+// Контейнеры дописываются в файлы-сегменты каталога по порядку и
+// читаются обратно в том же порядке. Каждая запись сегмента - это
+// длина и контрольная сумма CRC-32C данных (по 4 байта, big endian),
+// за которыми следует контейнер в JSON. Запись считается сохраненной
+// только после fsync, так что при падении процесса теряется не больше
+// одной недописанной записи, которая отбрасывается при открытии.
+// Позиция чтения хранится в отдельном файле и обновляется атомарно
+// после каждой прочитанной записи, поэтому после падения записи могут
+// быть прочитаны повторно, но не теряются.
+package spool
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rtemka/agg/news/pkg/storage"
+)
+
+// Ограничения размера по умолчанию.
+const (
+	SegmentSize = 16 << 20  // размер сегмента, после которого начинается новый.
+	MaxSize     = 512 << 20 // размер всех сегментов.
+)
+
+// ErrFull - запись превысила бы допустимый размер спула.
+var ErrFull = errors.New("spool: size limit exceeded")
+
+const (
+	headerSize   = 8 // длина и контрольная сумма записи.
+	segmentExt   = ".seg"
+	positionFile = "position"
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Options - ограничения размера спула,
+// нулевые значения заменяются значениями по умолчанию.
+type Options struct {
+	SegmentSize int64 // размер сегмента, после которого начинается новый.
+	MaxSize     int64 // размер всех сегментов.
+}
+
+// position - позиция чтения: сегмент и смещение в нем.
+type position struct {
+	seq uint64
+	off int64
+}
+
+// Spool - журнал контейнеров новостей на диске.
+// Безопасен для конкурентного использования.
+type Spool struct {
+	mu   sync.Mutex
+	dir  string
+	opts Options
+
+	segments []uint64 // номера сегментов по порядку.
+	next     uint64   // номер следующего сегмента.
+	w        *os.File // сегмент, в который идет запись.
+	wsize    int64    // размер сегмента, в который идет запись.
+	size     int64    // размер всех сегментов.
+	pos      position // позиция чтения в первом сегменте.
+
+	corrupted int // пропущенные поврежденные записи.
+}
+
+// Open открывает спул в каталоге dir, создавая каталог при
+// необходимости. Недописанная запись в конце последнего
+// сегмента, оставшаяся после падения, отбрасывается.
+func Open(dir string, opts Options) (*Spool, error) {
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = SegmentSize
+	}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = MaxSize
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	s := &Spool{dir: dir, opts: opts, next: 1}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		s.segments = append(s.segments, seq)
+	}
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i] < s.segments[j] })
+
+	if err := s.readPosition(); err != nil {
+		return nil, err
+	}
+
+	// сегменты до позиции чтения уже прочитаны
+	for len(s.segments) > 0 && s.segments[0] < s.pos.seq {
+		if err := os.Remove(s.segmentPath(s.segments[0])); err != nil {
+			return nil, err
+		}
+		s.segments = s.segments[1:]
+	}
+	if len(s.segments) == 0 || s.segments[0] != s.pos.seq {
+		s.pos = position{}
+		if len(s.segments) > 0 {
+			s.pos.seq = s.segments[0]
+		}
+	}
+
+	for i, seq := range s.segments {
+		fi, err := os.Stat(s.segmentPath(seq))
+		if err != nil {
+			return nil, err
+		}
+		size := fi.Size()
+
+		if i == len(s.segments)-1 {
+			if size, err = s.repair(seq, size); err != nil {
+				return nil, err
+			}
+			s.next = seq + 1
+		}
+		s.size += size
+	}
+
+	return s, nil
+}
+
+// repair отбрасывает недописанную запись в конце сегмента seq и все,
+// что за ней. Записи с неверной контрольной суммой остаются, их
+// пропустит Replay. Возвращает новый размер сегмента.
+func (s *Spool) repair(seq uint64, size int64) (int64, error) {
+	f, err := os.OpenFile(s.segmentPath(seq), os.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var valid int64
+	for {
+		_, n, err := s.readRecord(r)
+		if err != nil && (err != errCorrupted || n == 0) {
+			break
+		}
+		valid += n
+	}
+
+	if valid == size {
+		return size, nil
+	}
+	if err := f.Truncate(valid); err != nil {
+		return 0, err
+	}
+	if s.pos.seq == seq && s.pos.off > valid {
+		s.pos.off = valid
+	}
+
+	return valid, f.Sync()
+}
+
+// errCorrupted - запись повреждена.
+var errCorrupted = errors.New("spool: corrupted record")
+
+// readRecord читает запись и возвращает ее данные и размер вместе
+// с заголовком. Возвращает io.EOF в конце сегмента, io.ErrUnexpectedEOF
+// для недописанной записи и errCorrupted для поврежденной.
+func (s *Spool) readRecord(r *bufio.Reader) ([]byte, int64, error) {
+	var h [headerSize]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		return nil, 0, err
+	}
+
+	n := binary.BigEndian.Uint32(h[:4])
+	if int64(n) > s.opts.MaxSize {
+		return nil, 0, errCorrupted
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, 0, err
+	}
+
+	size := int64(headerSize) + int64(n)
+	if crc32.Checksum(b, crcTable) != binary.BigEndian.Uint32(h[4:]) {
+		return nil, size, errCorrupted
+	}
+
+	return b, size, nil
+}
+
+// Append дописывает контейнер в спул и сбрасывает его на диск.
+// Если спул превысил бы MaxSize, то возвращается ErrFull.
+func (s *Spool) Append(c storage.ItemContainer) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	rec := make([]byte, headerSize+len(b))
+	binary.BigEndian.PutUint32(rec[:4], uint32(len(b)))
+	binary.BigEndian.PutUint32(rec[4:8], crc32.Checksum(b, crcTable))
+	copy(rec[headerSize:], b)
+	n := int64(len(rec))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.size+n > s.opts.MaxSize {
+		return ErrFull
+	}
+
+	if s.w == nil || s.wsize > 0 && s.wsize+n > s.opts.SegmentSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := s.w.Write(rec); err != nil {
+		// не оставляем недописанную запись
+		_ = s.w.Truncate(s.wsize)
+		return err
+	}
+	if err := s.w.Sync(); err != nil {
+		_ = s.w.Truncate(s.wsize)
+		return err
+	}
+
+	s.wsize += n
+	s.size += n
+
+	return nil
+}
+
+// rotate открывает для записи последний сегмент, если в нем
+// есть место, иначе начинает новый. Вызывается под блокировкой.
+func (s *Spool) rotate() error {
+	if s.w != nil {
+		if err := s.w.Close(); err != nil {
+			return err
+		}
+		s.w = nil
+	}
+
+	if n := len(s.segments); n > 0 && s.wsize == 0 {
+		// после открытия спула дописываем последний сегмент
+		seq := s.segments[n-1]
+		fi, err := os.Stat(s.segmentPath(seq))
+		if err != nil {
+			return err
+		}
+		if fi.Size() < s.opts.SegmentSize {
+			f, err := os.OpenFile(s.segmentPath(seq), os.O_WRONLY|os.O_APPEND, 0)
+			if err != nil {
+				return err
+			}
+			s.w, s.wsize = f, fi.Size()
+			return nil
+		}
+	}
+
+	seq := s.next
+	f, err := os.OpenFile(s.segmentPath(seq), os.O_WRONLY|os.O_CREATE|os.O_EXCL|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := syncDir(s.dir); err != nil {
+		f.Close()
+		return err
+	}
+
+	s.w, s.wsize = f, 0
+	s.next++
+	s.segments = append(s.segments, seq)
+	if len(s.segments) == 1 {
+		s.pos = position{seq: seq}
+	}
+
+	return nil
+}
+
+// Replay по порядку передает fn контейнеры спула. Если fn возвращает
+// ошибку, то чтение останавливается, и при следующем вызове Replay
+// начинается с того же контейнера. Прочитанные сегменты удаляются.
+// Возвращает количество переданных контейнеров.
+func (s *Spool) Replay(fn func(storage.ItemContainer) error) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var replayed int
+	for len(s.segments) > 0 {
+		n, err := s.replaySegment(fn)
+		replayed += n
+		if err != nil {
+			return replayed, err
+		}
+
+		if err := s.dropHead(); err != nil {
+			return replayed, err
+		}
+	}
+
+	return replayed, nil
+}
+
+// replaySegment передает fn контейнеры первого сегмента,
+// начиная с позиции чтения. Вызывается под блокировкой.
+func (s *Spool) replaySegment(fn func(storage.ItemContainer) error) (int, error) {
+	f, err := os.Open(s.segmentPath(s.pos.seq))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(s.pos.off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	r := bufio.NewReader(f)
+
+	var replayed int
+	for {
+		b, n, err := s.readRecord(r)
+		switch {
+		case err == io.EOF:
+			return replayed, nil
+		case err == io.ErrUnexpectedEOF || err == errCorrupted && n == 0:
+			// дальше в сегменте читать нечего
+			s.corrupted++
+			return replayed, nil
+		case err != nil && err != errCorrupted:
+			return replayed, err
+		}
+
+		var c storage.ItemContainer
+		if err == nil {
+			err = json.Unmarshal(b, &c)
+		}
+		if err != nil {
+			// поврежденную запись пропускаем
+			s.corrupted++
+		} else if err := fn(c); err != nil {
+			return replayed, err
+		} else {
+			replayed++
+		}
+
+		s.pos.off += n
+		if err := s.writePosition(); err != nil {
+			return replayed, err
+		}
+	}
+}
+
+// dropHead удаляет прочитанный первый сегмент.
+// Вызывается под блокировкой.
+func (s *Spool) dropHead() error {
+	seq := s.segments[0]
+	fi, err := os.Stat(s.segmentPath(seq))
+	if err != nil {
+		return err
+	}
+
+	if len(s.segments) == 1 {
+		// прочитано все: удаляем сегмент, затем позицию
+		if s.w != nil {
+			if err := s.w.Close(); err != nil {
+				return err
+			}
+			s.w, s.wsize = nil, 0
+		}
+		if err := os.Remove(s.segmentPath(seq)); err != nil {
+			return err
+		}
+		if err := os.Remove(filepath.Join(s.dir, positionFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		s.segments, s.pos, s.size = nil, position{}, 0
+		return syncDir(s.dir)
+	}
+
+	// сначала переносим позицию, затем удаляем сегмент,
+	// оставшийся после падения сегмент удалит Open
+	s.segments = s.segments[1:]
+	s.pos = position{seq: s.segments[0]}
+	if err := s.writePosition(); err != nil {
+		return err
+	}
+	if err := os.Remove(s.segmentPath(seq)); err != nil {
+		return err
+	}
+	s.size -= fi.Size()
+
+	return nil
+}
+
+// Empty сообщает, что в спуле нет непрочитанных контейнеров.
+func (s *Spool) Empty() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.size-s.pos.off <= 0 && len(s.segments) <= 1
+}
+
+// Size возвращает размер всех сегментов спула в байтах.
+func (s *Spool) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.size
+}
+
+// Corrupted возвращает количество поврежденных
+// записей, пропущенных при чтении.
+func (s *Spool) Corrupted() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.corrupted
+}
+
+// Close закрывает сегмент, в который идет запись.
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.w == nil {
+		return nil
+	}
+	err := s.w.Close()
+	s.w = nil
+	return err
+}
+
+func (s *Spool) segmentPath(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", seq, segmentExt))
+}
+
+// readPosition читает сохраненную позицию чтения.
+func (s *Spool) readPosition() error {
+	b, err := os.ReadFile(filepath.Join(s.dir, positionFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Sscanf(string(b), "%d %d", &s.pos.seq, &s.pos.off); err != nil {
+		// испорченная позиция: читаем с начала, записи
+		// могут повториться, но не потеряются
+		s.pos = position{}
+	}
+
+	return nil
+}
+
+// writePosition атомарно сохраняет позицию чтения.
+func (s *Spool) writePosition() error {
+	tmp := filepath.Join(s.dir, positionFile+".tmp")
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(f, "%d %d\n", s.pos.seq, s.pos.off); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, filepath.Join(s.dir, positionFile)); err != nil {
+		return err
+	}
+	return syncDir(s.dir)
+}
+
+// syncDir сбрасывает на диск изменения каталога (создание,
+// удаление и переименование файлов).
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
@@ -0,0 +1,236 @@
+package spool
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rtemka/agg/news/pkg/storage"
+)
+
+// cont возвращает контейнер с одной новостью i.
+func cont(i int) storage.ItemContainer {
+	return storage.ItemContainer{
+		Source: storage.Source{FeedURL: "https://test.com/rss"},
+		Items:  []storage.Item{{Title: fmt.Sprintf("Новость %d", i), Link: fmt.Sprintf("https://test.com/%d", i)}},
+	}
+}
+
+// replayAll читает все контейнеры спула и возвращает номера их новостей.
+func replayAll(t *testing.T, s *Spool) []string {
+	var got []string
+	_, err := s.Replay(func(c storage.ItemContainer) error {
+		got = append(got, c.Items[0].Title)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	return got
+}
+
+func appendN(t *testing.T, s *Spool, from, to int) {
+	for i := from; i < to; i++ {
+		if err := s.Append(cont(i)); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+}
+
+func checkTitles(t *testing.T, got []string, from, to int) {
+	if len(got) != to-from {
+		t.Fatalf("Replay() got = %v, want %d containers from %d", got, to-from, from)
+	}
+	for i := range got {
+		if want := fmt.Sprintf("Новость %d", from+i); got[i] != want {
+			t.Fatalf("Replay() got = %q, want = %q", got[i], want)
+		}
+	}
+}
+
+func segmentsIn(t *testing.T, dir string) []string {
+	m, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if err != nil {
+		t.Fatalf("Glob() error = %v", err)
+	}
+	return m
+}
+
+func TestSpool_AppendReplay(t *testing.T) {
+	dir := t.TempDir()
+
+	// маленькие сегменты, чтобы записи разошлись по нескольким файлам
+	s, err := Open(dir, Options{SegmentSize: 256})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Close()
+
+	if !s.Empty() {
+		t.Fatalf("Empty() got = false for new spool")
+	}
+
+	appendN(t, s, 0, 10)
+	if s.Empty() {
+		t.Fatalf("Empty() got = true, want = false")
+	}
+	if n := len(segmentsIn(t, dir)); n < 2 {
+		t.Fatalf("got segments = %d, want several", n)
+	}
+
+	// БД недоступна на пятом контейнере
+	errDB := errors.New("db is down")
+	var got []string
+	n, err := s.Replay(func(c storage.ItemContainer) error {
+		if len(got) == 4 {
+			return errDB
+		}
+		got = append(got, c.Items[0].Title)
+		return nil
+	})
+	if !errors.Is(err, errDB) || n != 4 {
+		t.Fatalf("Replay() got = %d, %v, want = 4, %v", n, err, errDB)
+	}
+	checkTitles(t, got, 0, 4)
+
+	// продолжаем с того же контейнера, новые идут за старыми
+	appendN(t, s, 10, 12)
+	checkTitles(t, replayAll(t, s), 4, 12)
+
+	if !s.Empty() || s.Size() != 0 {
+		t.Fatalf("Empty() got = %v, Size() = %d, want empty spool", s.Empty(), s.Size())
+	}
+	if m := segmentsIn(t, dir); len(m) != 0 {
+		t.Fatalf("got segments = %v, want none", m)
+	}
+
+	// после полного чтения спул снова принимает записи
+	appendN(t, s, 12, 13)
+	checkTitles(t, replayAll(t, s), 12, 13)
+}
+
+func TestSpool_reopen(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := Open(dir, Options{SegmentSize: 256})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	appendN(t, s, 0, 6)
+
+	// часть прочитана до "падения"
+	var read int
+	_, _ = s.Replay(func(storage.ItemContainer) error {
+		if read == 3 {
+			return errors.New("stop")
+		}
+		read++
+		return nil
+	})
+	s.Close()
+
+	s, err = Open(dir, Options{SegmentSize: 256})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Close()
+
+	appendN(t, s, 6, 8)
+	checkTitles(t, replayAll(t, s), 3, 8)
+}
+
+func TestSpool_torn_tail(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := Open(dir, Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	appendN(t, s, 0, 3)
+	s.Close()
+
+	// недописанная запись после падения
+	seg := segmentsIn(t, dir)[0]
+	f, err := os.OpenFile(seg, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	if _, err := f.Write([]byte{0, 0, 1, 0, 1, 2, 3, 4, '{', '"'}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	f.Close()
+
+	s, err = Open(dir, Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Close()
+
+	appendN(t, s, 3, 4)
+	checkTitles(t, replayAll(t, s), 0, 4)
+	if n := s.Corrupted(); n != 0 {
+		t.Fatalf("Corrupted() got = %d, want = 0", n)
+	}
+}
+
+func TestSpool_checksum(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := Open(dir, Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	appendN(t, s, 0, 3)
+	s.Close()
+
+	// портим данные первой записи
+	seg := segmentsIn(t, dir)[0]
+	b, err := os.ReadFile(seg)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	b[headerSize+2] ^= 0xff
+	if err := os.WriteFile(seg, b, 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	s, err = Open(dir, Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Close()
+
+	checkTitles(t, replayAll(t, s), 1, 3)
+	if n := s.Corrupted(); n != 1 {
+		t.Fatalf("Corrupted() got = %d, want = 1", n)
+	}
+}
+
+func TestSpool_full(t *testing.T) {
+	s, err := Open(t.TempDir(), Options{MaxSize: 300})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Close()
+
+	var n int
+	for ; n < 10; n++ {
+		if err = s.Append(cont(n)); err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, ErrFull) {
+		t.Fatalf("Append() error = %v, want = %v", err, ErrFull)
+	}
+	if s.Size() > 300 {
+		t.Fatalf("Size() got = %d, want <= 300", s.Size())
+	}
+
+	// прочитанное место освобождается
+	checkTitles(t, replayAll(t, s), 0, n)
+	if err := s.Append(cont(n)); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+}
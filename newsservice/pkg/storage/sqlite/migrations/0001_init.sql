@@ -0,0 +1,41 @@
+-- исходная схема БД сервиса новостей для SQLite.
+
+CREATE TABLE IF NOT EXISTS news (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    title TEXT NOT NULL,
+    description TEXT NOT NULL,
+    pub_date INTEGER NOT NULL CHECK (pub_date > 0),
+    link TEXT NOT NULL UNIQUE
+);
+
+CREATE INDEX IF NOT EXISTS pub_date_idx ON news (pub_date DESC);
+
+-- полнотекстовый индекс по заголовку и описанию,
+-- содержимое берется из таблицы news.
+CREATE VIRTUAL TABLE IF NOT EXISTS news_fts USING fts5 (
+    title,
+    description,
+    content = 'news',
+    content_rowid = 'id',
+    tokenize = 'unicode61 remove_diacritics 2'
+);
+
+-- словарь индекса: сколько новостей содержат слово в каждой из колонок.
+CREATE VIRTUAL TABLE IF NOT EXISTS news_terms USING fts5vocab (news_fts, 'col');
+
+CREATE TRIGGER IF NOT EXISTS news_ai AFTER INSERT ON news BEGIN
+    INSERT INTO news_fts (rowid, title, description)
+    VALUES (new.id, new.title, new.description);
+END;
+
+CREATE TRIGGER IF NOT EXISTS news_ad AFTER DELETE ON news BEGIN
+    INSERT INTO news_fts (news_fts, rowid, title, description)
+    VALUES ('delete', old.id, old.title, old.description);
+END;
+
+CREATE TRIGGER IF NOT EXISTS news_au AFTER UPDATE ON news BEGIN
+    INSERT INTO news_fts (news_fts, rowid, title, description)
+    VALUES ('delete', old.id, old.title, old.description);
+    INSERT INTO news_fts (rowid, title, description)
+    VALUES (new.id, new.title, new.description);
+END;
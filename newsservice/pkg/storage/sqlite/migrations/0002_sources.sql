@@ -0,0 +1,12 @@
+-- rss-каналы, из которых получены новости
+CREATE TABLE IF NOT EXISTS sources (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL DEFAULT '',
+    url TEXT NOT NULL DEFAULT '',
+    feed_url TEXT NOT NULL UNIQUE
+);
+
+-- у новостей, добавленных до появления источников, источника нет
+ALTER TABLE news ADD COLUMN source_id INTEGER REFERENCES sources(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS news_source_idx ON news (source_id);
@@ -0,0 +1,19 @@
+-- индекс пересоздается без удаления диакритических знаков,
+-- чтобы 'й' и 'ё' не превращались в 'и' и 'е' в словах статистики
+DROP TABLE IF EXISTS news_terms;
+DROP TABLE IF EXISTS news_fts;
+
+CREATE VIRTUAL TABLE news_fts USING fts5 (
+    title,
+    description,
+    content = 'news',
+    content_rowid = 'id',
+    tokenize = 'unicode61 remove_diacritics 0'
+);
+
+INSERT INTO news_fts (news_fts) VALUES ('rebuild');
+
+CREATE VIRTUAL TABLE news_terms USING fts5vocab (news_fts, 'col');
+
+-- вхождения слов индекса по новостям для статистики слов заголовков
+CREATE VIRTUAL TABLE news_instances USING fts5vocab (news_fts, 'instance');
@@ -0,0 +1,24 @@
+-- сохраненные поиски, фильтр хранится в JSON
+CREATE TABLE IF NOT EXISTS saved_searches (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    owner TEXT NOT NULL DEFAULT '',
+    filter TEXT NOT NULL DEFAULT '{}',
+    created INTEGER NOT NULL DEFAULT 0,
+    last_read INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS saved_searches_owner_idx ON saved_searches (owner, id);
+
+-- новости, совпавшие с сохраненными поисками
+CREATE TABLE IF NOT EXISTS saved_search_matches (
+    search_id INTEGER NOT NULL REFERENCES saved_searches(id) ON DELETE CASCADE,
+    item_id INTEGER NOT NULL REFERENCES news(id) ON DELETE CASCADE,
+    PRIMARY KEY (search_id, item_id)
+) WITHOUT ROWID;
+
+-- внешние ключи в подключениях не включены,
+-- поэтому совпадения удаляются вместе с новостями триггером
+CREATE TRIGGER IF NOT EXISTS saved_search_matches_news_ad AFTER DELETE ON news BEGIN
+    DELETE FROM saved_search_matches WHERE item_id = old.id;
+END;
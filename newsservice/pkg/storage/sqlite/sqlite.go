@@ -0,0 +1,1282 @@
+// пакет sqlite реализует хранилище новостей в SQLite.
+//
+// Подходит для небольших установок на одном узле и для
+// локальной разработки, когда поднимать Postgres незачем.
+// Полнотекстовый поиск выполняется по индексу FTS5,
+// нечеткий - функцией word_similarity, повторяющей pg_trgm.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rtemka/agg/news/pkg/storage"
+	"github.com/rtemka/agg/news/pkg/storage/query"
+	"modernc.org/sqlite"
+)
+
+var ErrNoRows = sql.ErrNoRows
+
+// migrationsFS - шаги схемы БД, применяются по порядку имен файлов.
+// Номер последнего примененного шага хранится в PRAGMA user_version.
+//
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// Настройки поиска, повторяющие настройки Postgres.
+const (
+	// порог схожести для нечеткого поиска (pg_trgm.word_similarity_threshold).
+	fuzzyThreshold = 0.6
+	// порог схожести для подсказок (pg_trgm.similarity_threshold).
+	suggestThreshold = 0.3
+	// веса колонок title и description для bm25: совпадение
+	// в заголовке значит больше, чем в описании.
+	rankWeights = "1.0, 0.4"
+	// максимальное количество слов во фрагменте описания.
+	snippetWords = 35
+)
+
+// pragmas - настройки каждого подключения: WAL позволяет читать
+// во время записи, а при занятой БД подключение ждет, а не падает.
+var pragmas = []string{
+	"_pragma=journal_mode(WAL)",
+	"_pragma=busy_timeout(5000)",
+	"_pragma=synchronous(NORMAL)",
+	"_txlock=immediate",
+}
+
+func init() {
+	// функции pg_trgm для нечеткого поиска и подсказок
+	sqlite.MustRegisterDeterministicScalarFunction("word_similarity", 2, similarityFunc(query.WordSimilarity))
+	sqlite.MustRegisterDeterministicScalarFunction("similarity", 2, similarityFunc(query.Similarity))
+}
+
+func similarityFunc(f func(a, b string) float64) func(*sqlite.FunctionContext, []driver.Value) (driver.Value, error) {
+	return func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		a, _ := args[0].(string)
+		b, _ := args[1].(string)
+		return f(a, b), nil
+	}
+}
+
+type statement struct {
+	sql  string
+	args []any
+}
+
+// SQLite выполняет CRUD операции с БД
+type SQLite struct {
+	db *sql.DB
+}
+
+// New открывает файл БД path, при необходимости
+// создает или обновляет схему и возвращает объект для взаимодействия с БД.
+func New(path string) (*SQLite, error) {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	dsn := "file:" + strings.TrimPrefix(path, "file:") + sep + strings.Join(pragmas, "&")
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLite{db: db}, nil
+}
+
+// migrate применяет к БД еще не примененные шаги схемы.
+func migrate(db *sql.DB) error {
+	entries, err := fs.ReadDir(migrationsFS, "migrations")
+	if err != nil {
+		return err
+	}
+
+	for i, e := range entries {
+		version := i + 1
+
+		b, err := fs.ReadFile(migrationsFS, path.Join("migrations", e.Name()))
+		if err != nil {
+			return err
+		}
+
+		err = inTx(db, func(tx *sql.Tx) error {
+			// версию читаем в транзакции, чтобы два процесса
+			// не применили один и тот же шаг
+			var current int
+			if err := tx.QueryRow(`PRAGMA user_version;`).Scan(&current); err != nil {
+				return err
+			}
+			if version <= current {
+				return nil
+			}
+
+			if _, err := tx.Exec(string(b)); err != nil {
+				return err
+			}
+			_, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d;`, version))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("sqlite: migrate %s: %w", e.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+// inTx выполняет f в транзакции.
+func inTx(db *sql.DB, f func(*sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := f(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Close выполняет закрытие подключения к БД
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
+
+// ItemByLink находит по ссылке и возвращает rss-новость
+func (s *SQLite) ItemByLink(ctx context.Context, link string) (storage.Item, error) {
+	stmt := itemColumns + ` FROM news` + sourcesJoin + ` WHERE news.link = ?;`
+
+	var item storage.Item
+
+	err := s.db.QueryRowContext(ctx, stmt, link).Scan(itemDest(&item)...)
+	if err != nil {
+		return storage.Item{}, err
+	}
+
+	return item, nil
+}
+
+// Item находит по id и возвращает rss-новость
+func (s *SQLite) Item(ctx context.Context, id int64) (storage.Item, error) {
+	stmt := itemColumns + ` FROM news` + sourcesJoin + ` WHERE news.id = ?;`
+
+	var item storage.Item
+
+	err := s.db.QueryRowContext(ctx, stmt, id).Scan(itemDest(&item)...)
+	if err != nil {
+		return storage.Item{}, err
+	}
+
+	return item, nil
+}
+
+// CountItems возвращает количество строк, которое будет задействовано в запросе.
+func (s *SQLite) CountItems(ctx context.Context, filter storage.Filter) (int, error) {
+	sr, err := newSearch(&filter)
+	if err != nil {
+		return 0, err
+	}
+
+	var stmt statement
+	stmt.sql = `SELECT COUNT(news.id)`
+	stmt.addFrom(&sr)
+	stmt.addWhereClause(&filter, &sr)
+
+	var c int
+
+	return c, s.db.QueryRowContext(ctx, stmt.sql, stmt.args...).Scan(&c)
+}
+
+// Items возвращает списком новости отобранные согласно фильтру.
+// При полнотекстовом поиске каждая новость содержит фрагменты
+// заголовка и описания с выделенными совпадениями. Из БД выбираются
+// только поля filter.Fields, описание обрезается в запросе.
+func (s *SQLite) Items(ctx context.Context, filter storage.Filter) ([]storage.Item, error) {
+	sr, err := newSearch(&filter)
+	if err != nil {
+		return nil, err
+	}
+	headlines := sr.match != "" && filter.Fields.Has(storage.FieldSnippets)
+
+	var stmt statement
+	fieldsDest := stmt.addItemColumns(&filter)
+	if headlines {
+		stmt.addHeadlines()
+	}
+	stmt.addFrom(&sr)
+	if filter.Fields.Has(storage.FieldSource) {
+		stmt.sql += sourcesJoin
+	}
+	stmt.addWhereClause(&filter, &sr)
+	stmt.addOrderBy(&filter, &sr)
+	stmt.addLimitOffsetClause(&filter)
+
+	var items []storage.Item
+
+	rows, err := s.db.QueryContext(ctx, stmt.sql, stmt.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var item storage.Item
+
+		dest := fieldsDest(&item)
+		if headlines {
+			dest = append(dest, &item.TitleSnippet, &item.ContentSnippet)
+		}
+
+		err := rows.Scan(dest...)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// itemColumns - колонки новости вместе с источником, который
+// присоединяется через sourcesJoin и может отсутствовать.
+const (
+	itemColumns = `SELECT news.id, news.title, news.description, news.pub_date, news.link,
+		COALESCE(sources.id, 0), COALESCE(sources.name, ''),
+		COALESCE(sources.url, ''), COALESCE(sources.feed_url, '')`
+	sourcesJoin = ` LEFT JOIN sources ON sources.id = news.source_id`
+)
+
+// itemDest возвращает поля новости в порядке itemColumns.
+func itemDest(item *storage.Item) []any {
+	return []any{&item.Id, &item.Title, &item.Description, &item.PubDate, &item.Link,
+		&item.Source.Id, &item.Source.Name, &item.Source.URL, &item.Source.FeedURL}
+}
+
+// itemField - поле новости и колонки, из которых оно выбирается.
+type itemField struct {
+	field storage.Fields
+	cols  string
+	dest  func(item *storage.Item) []any
+}
+
+// itemFields - поля новости в порядке itemColumns.
+var itemFields = []itemField{
+	{storage.FieldTitle, "news.title", func(item *storage.Item) []any { return []any{&item.Title} }},
+	{storage.FieldDescription, "news.description", func(item *storage.Item) []any { return []any{&item.Description} }},
+	{storage.FieldPubDate, "news.pub_date", func(item *storage.Item) []any { return []any{&item.PubDate} }},
+	{storage.FieldLink, "news.link", func(item *storage.Item) []any { return []any{&item.Link} }},
+	{storage.FieldSource, `COALESCE(sources.id, 0), COALESCE(sources.name, ''),
+		COALESCE(sources.url, ''), COALESCE(sources.feed_url, '')`,
+		func(item *storage.Item) []any {
+			return []any{&item.Source.Id, &item.Source.Name, &item.Source.URL, &item.Source.FeedURL}
+		}},
+}
+
+// addItemColumns начинает выборку с колонок полей новости, выбранных
+// фильтром, описание обрезается до f.DescriptionLimit символов.
+// Возвращает функцию, которая возвращает поля новости в порядке колонок.
+// Источник выбирается из sourcesJoin, его присоединяет вызывающий.
+func (stmt *statement) addItemColumns(f *storage.Filter) func(item *storage.Item) []any {
+	cols := []string{"news.id"}
+	var fields []itemField
+	for _, fl := range itemFields {
+		if !f.Fields.Has(fl.field) {
+			continue
+		}
+		col := fl.cols
+		if fl.field == storage.FieldDescription && f.DescriptionLimit > 0 {
+			col = fmt.Sprintf("substr(news.description, 1, %s)", stmt.arg(f.DescriptionLimit))
+		}
+		cols = append(cols, col)
+		fields = append(fields, fl)
+	}
+	stmt.sql = "SELECT " + strings.Join(cols, ", ")
+
+	return func(item *storage.Item) []any {
+		dest := []any{&item.Id}
+		for _, fl := range fields {
+			dest = append(dest, fl.dest(item)...)
+		}
+		return dest
+	}
+}
+
+// search - условия поиска для запроса к БД.
+type search struct {
+	match string // запрос MATCH для полнотекстового поиска.
+	fuzzy string // строка для нечеткого поиска по заголовку.
+	excl  string // запрос MATCH исключений при нечетком поиске.
+}
+
+// newSearch строит условия поиска согласно фильтру.
+func newSearch(f *storage.Filter) (search, error) {
+	var s search
+
+	q, err := f.Query()
+	if err != nil || q.Empty() {
+		return s, err
+	}
+
+	// без слов для поиска новости только отсеиваются исключениями
+	if f.Match == storage.MatchFuzzy || !q.HasTerms() {
+		s.fuzzy = strings.Join(q.Terms(), " ")
+		s.excl, err = q.Exclusions().FTS5()
+		return s, err
+	}
+
+	s.match, err = q.FTS5()
+	return s, err
+}
+
+// arg добавляет аргумент запроса и возвращает его плейсхолдер.
+func (stmt *statement) arg(v any) string {
+	stmt.args = append(stmt.args, v)
+	return "?"
+}
+
+// list добавляет аргументы запроса и возвращает
+// их плейсхолдеры через запятую для IN (...).
+func (stmt *statement) list(ids []int64) string {
+	ph := make([]string, len(ids))
+	for i, id := range ids {
+		ph[i] = stmt.arg(id)
+	}
+	return strings.Join(ph, ", ")
+}
+
+// addHeadlines добавляет в выборку фрагменты заголовка
+// и описания с выделенными совпадениями.
+func (stmt *statement) addHeadlines() {
+	stmt.sql += fmt.Sprintf(`,
+		highlight(news_fts, 0, '%[1]s', '%[2]s'),
+		snippet(news_fts, 1, '%[1]s', '%[2]s', ' ... ', %[3]d)`,
+		storage.SnippetStartSel, storage.SnippetStopSel, snippetWords)
+}
+
+// addFrom добавляет источник выборки, при полнотекстовом
+// поиске новости соединяются с индексом FTS5.
+func (stmt *statement) addFrom(s *search) {
+	stmt.sql += ` FROM news`
+	if s.match != "" {
+		stmt.sql += ` JOIN news_fts ON news_fts.rowid = news.id`
+	}
+}
+
+func (stmt *statement) addLimitOffsetClause(f *storage.Filter) {
+	l, o := calcLimitOffset(f.Page, f.Limit())
+	if l > 0 {
+		stmt.sql += " LIMIT " + stmt.arg(l)
+	}
+	if o > 0 {
+		stmt.sql += " OFFSET " + stmt.arg(o)
+	}
+}
+
+func (stmt *statement) addOrderBy(f *storage.Filter, s *search) {
+	switch {
+	case f.SortBy == storage.Rank && s.match != "":
+		// чем меньше значение bm25, тем лучше совпадение
+		stmt.sql += fmt.Sprintf(" ORDER BY bm25(news_fts, %s)", rankWeights)
+	case f.SortBy == storage.Rank && s.fuzzy != "":
+		stmt.sql += fmt.Sprintf(" ORDER BY word_similarity(%s, news.title) DESC", stmt.arg(s.fuzzy))
+	case f.SortBy == storage.Empty || f.SortBy == storage.Rank:
+		// без поиска сортировать по совпадениям нечего
+		stmt.sql += fmt.Sprintf(" ORDER BY news.%s DESC", storage.Date.String())
+	default:
+		stmt.sql += fmt.Sprintf(" ORDER BY news.%s DESC", f.SortBy.String())
+	}
+}
+
+// addWhereClause добавляет условия фильтра и поиска.
+func (stmt *statement) addWhereClause(f *storage.Filter, s *search) {
+	var conds []string
+
+	if s.match != "" {
+		conds = append(conds, "news_fts MATCH "+stmt.arg(s.match))
+	}
+	if s.fuzzy != "" {
+		conds = append(conds, fmt.Sprintf("word_similarity(%s, news.title) >= %v",
+			stmt.arg(s.fuzzy), fuzzyThreshold))
+	}
+	if s.excl != "" {
+		conds = append(conds, "news.id NOT IN (SELECT rowid FROM news_fts WHERE news_fts MATCH "+
+			stmt.arg(s.excl)+")")
+	}
+	if f.Date.Value > 0 {
+		conds = append(conds, fmt.Sprintf("news.pub_date %s %s", f.Date.Op(), stmt.arg(f.Date.Value)))
+	}
+	if f.EndDate.Value > 0 {
+		conds = append(conds, fmt.Sprintf("news.pub_date %s %s", f.EndDate.Op(), stmt.arg(f.EndDate.Value)))
+	}
+	if len(f.Sources) > 0 {
+		conds = append(conds, fmt.Sprintf("news.source_id IN (%s)", stmt.list(f.Sources)))
+	}
+	if f.AfterID > 0 {
+		conds = append(conds, "news.id > "+stmt.arg(f.AfterID))
+	}
+	if len(f.IDs) > 0 {
+		conds = append(conds, fmt.Sprintf("news.id IN (%s)", stmt.list(f.IDs)))
+	}
+	if len(f.ExcludeSources) > 0 {
+		// новости без источника не исключаются
+		conds = append(conds, fmt.Sprintf("(news.source_id IS NULL OR news.source_id NOT IN (%s))",
+			stmt.list(f.ExcludeSources)))
+	}
+
+	if len(conds) > 0 {
+		stmt.sql += " WHERE " + strings.Join(conds, " AND ")
+	}
+}
+
+func calcLimitOffset(pageNum, pageSize int) (int, int) {
+	if pageNum < 1 {
+		return 0, 0
+	}
+	return pageSize, (pageNum - 1) * pageSize
+}
+
+// Suggest подбирает для каждого слова наиболее похожее по триграммам
+// из слов, встречающихся более чем в одном заголовке. Если подходящего
+// слова нет, то возвращается исходное слово.
+func (s *SQLite) Suggest(ctx context.Context, terms []string) ([]string, error) {
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	stmt := fmt.Sprintf(`
+		SELECT term FROM news_terms
+		WHERE col = 'title' AND doc > 1 AND similarity(term, ?1) > %v
+		ORDER BY similarity(term, ?1) DESC, doc DESC, term
+		LIMIT 1;`, suggestThreshold)
+
+	out := make([]string, 0, len(terms))
+	for _, t := range terms {
+		var w string
+		err := s.db.QueryRowContext(ctx, stmt, t).Scan(&w)
+		if errors.Is(err, sql.ErrNoRows) {
+			w = t
+		} else if err != nil {
+			return nil, err
+		}
+		out = append(out, w)
+	}
+
+	return out, nil
+}
+
+// Stats возвращает сводную статистику по новостям, отобранным фильтром.
+func (s *SQLite) Stats(ctx context.Context, filter storage.Filter, interval storage.Interval) (storage.Stats, error) {
+	sr, err := newSearch(&filter)
+	if err != nil {
+		return storage.Stats{}, err
+	}
+
+	var stats storage.Stats
+
+	var counts statement
+	secs := interval.Seconds()
+	counts.sql = fmt.Sprintf(`SELECT news.pub_date / %s * %s AS t, COUNT(*)`, counts.arg(secs), counts.arg(secs))
+	counts.addFrom(&sr)
+	counts.addWhereClause(&filter, &sr)
+	counts.sql += ` GROUP BY t ORDER BY t`
+
+	err = s.query(ctx, &counts, func(rows *sql.Rows) error {
+		var c storage.TimeCount
+		if err := rows.Scan(&c.Time, &c.Count); err != nil {
+			return err
+		}
+		stats.Total += c.Count
+		stats.Counts = append(stats.Counts, c)
+		return nil
+	})
+	if err != nil {
+		return storage.Stats{}, err
+	}
+
+	var sources statement
+	sources.sql = `
+		SELECT COALESCE(sources.id, 0), COALESCE(sources.name, ''),
+			COALESCE(sources.url, ''), COALESCE(sources.feed_url, ''), COUNT(*) AS n`
+	sources.addFrom(&sr)
+	sources.sql += sourcesJoin
+	sources.addWhereClause(&filter, &sr)
+	sources.sql += ` GROUP BY sources.id ORDER BY n DESC, 1`
+
+	err = s.query(ctx, &sources, func(rows *sql.Rows) error {
+		var c storage.SourceCount
+		err := rows.Scan(&c.Source.Id, &c.Source.Name, &c.Source.URL, &c.Source.FeedURL, &c.Count)
+		if err != nil {
+			return err
+		}
+		stats.Sources = append(stats.Sources, c)
+		return nil
+	})
+	if err != nil {
+		return storage.Stats{}, err
+	}
+
+	// вхождения слов берутся из индекса, каждое слово учитывается
+	// один раз на заголовок, служебные слова отбрасываются
+	var terms statement
+	terms.sql = `
+		SELECT term, COUNT(DISTINCT doc) AS n FROM news_instances
+		WHERE col = 'title' AND length(term) >= ` + terms.arg(storage.MinTermLen)
+	stop := make([]string, len(storage.StopWords))
+	for i, w := range storage.StopWords {
+		stop[i] = terms.arg(w)
+	}
+	terms.sql += `
+		AND term NOT IN (` + strings.Join(stop, ", ") + `)
+		AND doc IN (SELECT news.id`
+	terms.addFrom(&sr)
+	terms.addWhereClause(&filter, &sr)
+	terms.sql += `) GROUP BY term ORDER BY n DESC, term LIMIT ` + terms.arg(storage.TopTerms)
+
+	err = s.query(ctx, &terms, func(rows *sql.Rows) error {
+		var c storage.TermCount
+		if err := rows.Scan(&c.Term, &c.Count); err != nil {
+			return err
+		}
+		stats.Terms = append(stats.Terms, c)
+		return nil
+	})
+	if err != nil {
+		return storage.Stats{}, err
+	}
+
+	return stats, nil
+}
+
+// matchChunk - сколько фильтров сопоставляется с новостями одним
+// запросом (в составном запросе SQLite не больше 500 выборок).
+const matchChunk = 100
+
+// MatchFilters сопоставляет новости ids с фильтрами filters. Фильтры
+// проверяются пачками по matchChunk одним запросом: для каждого фильтра
+// из новостей ids выбираются те, что подходят под условия, как в Items.
+func (s *SQLite) MatchFilters(ctx context.Context, ids []int64, filters []storage.Filter) ([][]int64, error) {
+	matched := make([][]int64, len(filters))
+	if len(ids) == 0 {
+		return matched, nil
+	}
+
+	for start := 0; start < len(filters); start += matchChunk {
+		end := start + matchChunk
+		if end > len(filters) {
+			end = len(filters)
+		}
+
+		var stmt statement
+		selects := make([]string, 0, end-start)
+		for i := start; i < end; i++ {
+			f := filters[i]
+			f.IDs = ids
+			sr, err := newSearch(&f)
+			if err != nil {
+				return nil, err
+			}
+			stmt.sql = fmt.Sprintf(`SELECT %d, news.id`, i)
+			stmt.addFrom(&sr)
+			stmt.addWhereClause(&f, &sr)
+			selects = append(selects, stmt.sql)
+		}
+		stmt.sql = strings.Join(selects, ` UNION ALL `) + ` ORDER BY 1, 2;`
+
+		err := s.query(ctx, &stmt, func(rows *sql.Rows) error {
+			var i int
+			var id int64
+			if err := rows.Scan(&i, &id); err != nil {
+				return err
+			}
+			matched[i] = append(matched[i], id)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return matched, nil
+}
+
+// Export вызывает fn для каждой новости, отобранной фильтром, по возрастанию id.
+// Новости читаются по одной по мере выполнения запроса.
+func (s *SQLite) Export(ctx context.Context, filter storage.Filter, fn func(storage.Item) error) error {
+	sr, err := newSearch(&filter)
+	if err != nil {
+		return err
+	}
+
+	var stmt statement
+	stmt.sql = itemColumns
+	stmt.addFrom(&sr)
+	stmt.sql += sourcesJoin
+	stmt.addWhereClause(&filter, &sr)
+	stmt.sql += ` ORDER BY news.id`
+
+	return s.query(ctx, &stmt, func(rows *sql.Rows) error {
+		var item storage.Item
+		if err := rows.Scan(itemDest(&item)...); err != nil {
+			return err
+		}
+		return fn(item)
+	})
+}
+
+// Related возвращает новости, похожие по тексту на новость id.
+// Из слов заголовка и описания новости строится запрос FTS5, в котором
+// достаточно совпадения любого слова, а похожесть определяет bm25.
+func (s *SQLite) Related(ctx context.Context, id int64, window int64, limit int) ([]storage.Item, error) {
+	src, err := s.Item(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var terms []string
+	seen := make(map[string]bool)
+	for _, w := range query.Words(src.Title + " " + src.Description) {
+		if seen[w] || utf8.RuneCountInString(w) < storage.MinTermLen {
+			continue
+		}
+		seen[w] = true
+		// слова состоят только из букв и цифр
+		terms = append(terms, `"`+w+`"`)
+		if len(terms) == query.MaxTerms {
+			break
+		}
+	}
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	var stmt statement
+	stmt.sql = itemColumns + ` FROM news JOIN news_fts ON news_fts.rowid = news.id` + sourcesJoin +
+		` WHERE news_fts MATCH ` + stmt.arg(strings.Join(terms, " OR ")) +
+		` AND news.id <> ` + stmt.arg(src.Id) +
+		` AND news.pub_date BETWEEN ` + stmt.arg(src.PubDate-window) + ` AND ` + stmt.arg(src.PubDate+window) +
+		fmt.Sprintf(` ORDER BY bm25(news_fts, %s), news.pub_date DESC, news.id`, rankWeights)
+
+	// новости идут от самой похожей, поэтому из новостей с одинаковым
+	// заголовком остается первая, а чтение прекращается на limit новостях
+	var items []storage.Item
+	titles := map[string]bool{strings.ToLower(src.Title): true}
+	errEnough := errors.New("enough items")
+
+	err = s.query(ctx, &stmt, func(rows *sql.Rows) error {
+		var item storage.Item
+		if err := rows.Scan(itemDest(&item)...); err != nil {
+			return err
+		}
+		title := strings.ToLower(item.Title)
+		if titles[title] {
+			return nil
+		}
+		titles[title] = true
+		items = append(items, item)
+		if len(items) >= limit {
+			return errEnough
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errEnough) {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// query выполняет запрос stmt и вызывает scan для каждой строки.
+func (s *SQLite) query(ctx context.Context, stmt *statement, scan func(*sql.Rows) error) error {
+	rows, err := s.db.QueryContext(ctx, stmt.sql, stmt.args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// insertChunk - сколько новостей добавляется одним запросом
+// (у запроса SQLite может быть не больше 32766 параметров).
+const insertChunk = 500
+
+// AddItems добавляет в БД слайс rss-новостей, обновляет
+// изменившиеся и пропускает те новости, что уже есть в БД
+func (s *SQLite) AddItems(ctx context.Context, items []storage.Item) (storage.AddResult, error) {
+	items, res := storage.UniqueByLink(items)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return storage.AddResult{}, err
+	}
+	defer tx.Rollback()
+
+	if err := upsertSources(ctx, tx, items); err != nil {
+		return storage.AddResult{}, err
+	}
+
+	for len(items) > 0 {
+		n := len(items)
+		if n > insertChunk {
+			n = insertChunk
+		}
+		if err := upsertItems(ctx, tx, items[:n], &res); err != nil {
+			return storage.AddResult{}, err
+		}
+		items = items[n:]
+	}
+
+	if err := tx.Commit(); err != nil {
+		return storage.AddResult{}, err
+	}
+
+	return res, nil
+}
+
+// upsertSources добавляет одним запросом источники новостей,
+// чтобы новости могли на них сослаться.
+func upsertSources(ctx context.Context, tx *sql.Tx, items []storage.Item) error {
+	var stmt statement
+	var values []string
+
+	seen := make(map[string]bool)
+	for i := range items {
+		src := items[i].Source
+		if src.FeedURL == "" || seen[src.FeedURL] {
+			continue
+		}
+		seen[src.FeedURL] = true
+		values = append(values, fmt.Sprintf("(%s, %s, %s)",
+			stmt.arg(src.Name), stmt.arg(src.URL), stmt.arg(src.FeedURL)))
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	stmt.sql = `INSERT INTO sources(name, url, feed_url) VALUES ` + strings.Join(values, ", ") + `
+		ON CONFLICT (feed_url) DO UPDATE SET name = excluded.name, url = excluded.url;`
+
+	_, err := tx.ExecContext(ctx, stmt.sql, stmt.args...)
+	return err
+}
+
+// upsertItems добавляет новости без повторов ссылок одним запросом.
+// Уже известная новость обновляется, только если изменилась.
+// Запрос возвращает id, ссылки и id источников добавленных и обновленных
+// новостей, какие из них уже были в БД, выясняется заранее в той же транзакции.
+func upsertItems(ctx context.Context, tx *sql.Tx, items []storage.Item, res *storage.AddResult) error {
+	links := make([]string, len(items))
+	for i := range items {
+		links[i] = items[i].Link
+	}
+
+	var sel statement
+	ph := make([]string, len(links))
+	for i := range links {
+		ph[i] = sel.arg(links[i])
+	}
+	sel.sql = `SELECT link FROM news WHERE link IN (` + strings.Join(ph, ", ") + `);`
+
+	known := make(map[string]bool, len(items))
+	err := queryTx(ctx, tx, &sel, func(rows *sql.Rows) error {
+		var link string
+		if err := rows.Scan(&link); err != nil {
+			return err
+		}
+		known[link] = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var stmt statement
+	values := make([]string, len(items))
+	for i := range items {
+		values[i] = fmt.Sprintf("(%s, %s, %s, %s, (SELECT id FROM sources WHERE feed_url = NULLIF(%s, '')))",
+			stmt.arg(items[i].Title), stmt.arg(items[i].Description), stmt.arg(items[i].PubDate),
+			stmt.arg(items[i].Link), stmt.arg(items[i].Source.FeedURL))
+	}
+	stmt.sql = `
+		INSERT INTO news(title, description, pub_date, link, source_id)
+		VALUES ` + strings.Join(values, ", ") + `
+		ON CONFLICT (link) DO UPDATE SET title = excluded.title, description = excluded.description,
+			pub_date = excluded.pub_date, source_id = excluded.source_id
+		WHERE title IS NOT excluded.title OR description IS NOT excluded.description
+			OR pub_date IS NOT excluded.pub_date OR source_id IS NOT excluded.source_id
+		RETURNING id, link, COALESCE(source_id, 0);`
+
+	type row struct{ id, source int64 }
+	written := make(map[string]row, len(items))
+	err = queryTx(ctx, tx, &stmt, func(rows *sql.Rows) error {
+		var r row
+		var link string
+		if err := rows.Scan(&r.id, &link, &r.source); err != nil {
+			return err
+		}
+		written[link] = r
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := range items {
+		r, ok := written[items[i].Link]
+		st := storage.AddSkipped
+		switch {
+		case ok && known[items[i].Link]:
+			st = storage.AddUpdated
+		case ok:
+			st = storage.AddInserted
+		}
+		res.Count(items[i].Source.FeedURL, st)
+
+		if st == storage.AddInserted {
+			it := items[i]
+			it.Id, it.Source.Id = r.id, r.source
+			it.TitleSnippet, it.ContentSnippet = "", ""
+			res.Added = append(res.Added, it)
+		}
+	}
+
+	return nil
+}
+
+// queryTx выполняет в транзакции запрос stmt и вызывает scan для каждой строки.
+func queryTx(ctx context.Context, tx *sql.Tx, stmt *statement, scan func(*sql.Rows) error) error {
+	rows, err := tx.QueryContext(ctx, stmt.sql, stmt.args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// AddItem добавляет в БД rss-новость, если новость уже
+// есть в БД, то no-op
+func (s *SQLite) AddItem(ctx context.Context, item storage.Item) error {
+	_, err := s.AddItems(ctx, []storage.Item{item})
+	return err
+}
+
+// UpdateItem обновляет rss-новость по id,
+// если новости нет, то возвращает ErrNoRows.
+func (s *SQLite) UpdateItem(ctx context.Context, item storage.Item) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	src := item.Source
+	if src.FeedURL != "" {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO sources(name, url, feed_url)
+			VALUES (?, ?, ?)
+			ON CONFLICT (feed_url) DO UPDATE SET name = excluded.name, url = excluded.url;`,
+			src.Name, src.URL, src.FeedURL)
+		if err != nil {
+			return err
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE news SET title = ?, description = ?, pub_date = ?, link = ?,
+			source_id = (SELECT id FROM sources WHERE feed_url = NULLIF(?, ''))
+		WHERE id = ?;`,
+		item.Title, item.Description, item.PubDate, item.Link, src.FeedURL, item.Id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNoRows
+	}
+
+	return tx.Commit()
+}
+
+// DeleteItems удаляет rss-новости по id,
+// возвращает количество удаленных.
+func (s *SQLite) DeleteItems(ctx context.Context, ids []int64) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	var stmt statement
+	stmt.sql = `DELETE FROM news WHERE id IN (` + stmt.list(ids) + `);`
+
+	res, err := s.db.ExecContext(ctx, stmt.sql, stmt.args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+
+	return int(n), err
+}
+
+// Sources возвращает все источники по возрастанию id.
+func (s *SQLite) Sources(ctx context.Context) ([]storage.Source, error) {
+	var sources []storage.Source
+
+	stmt := statement{sql: `SELECT id, name, url, feed_url FROM sources ORDER BY id;`}
+	err := s.query(ctx, &stmt, func(rows *sql.Rows) error {
+		var src storage.Source
+		if err := rows.Scan(&src.Id, &src.Name, &src.URL, &src.FeedURL); err != nil {
+			return err
+		}
+		sources = append(sources, src)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sources, nil
+}
+
+// Webhooks возвращает все подписки по возрастанию id.
+func (s *SQLite) Webhooks(ctx context.Context) ([]storage.Webhook, error) {
+	var webhooks []storage.Webhook
+
+	stmt := statement{sql: `SELECT id, url, secret, terms, sources, created FROM webhooks ORDER BY id;`}
+	err := s.query(ctx, &stmt, func(rows *sql.Rows) error {
+		wh, err := scanWebhook(rows)
+		if err != nil {
+			return err
+		}
+		webhooks = append(webhooks, wh)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// scanWebhook сканирует подписку из строки с полями
+// id, url, secret, terms, sources, created.
+// Фразы и источники хранятся в JSON.
+func scanWebhook(row interface{ Scan(...any) error }) (storage.Webhook, error) {
+	var wh storage.Webhook
+	var terms, sources string
+	if err := row.Scan(&wh.Id, &wh.URL, &wh.Secret, &terms, &sources, &wh.Created); err != nil {
+		return storage.Webhook{}, err
+	}
+	if err := json.Unmarshal([]byte(terms), &wh.Terms); err != nil {
+		return storage.Webhook{}, err
+	}
+	if err := json.Unmarshal([]byte(sources), &wh.Sources); err != nil {
+		return storage.Webhook{}, err
+	}
+
+	return wh, nil
+}
+
+// jsonArray возвращает слайс в JSON, nil - пустой массив.
+func jsonArray[T any](v []T) string {
+	if v == nil {
+		return "[]"
+	}
+	b, _ := json.Marshal(v) // слайсы строк и чисел кодируются без ошибок
+	return string(b)
+}
+
+// Webhook возвращает подписку по id,
+// если подписки нет, то возвращает ErrNoRows.
+func (s *SQLite) Webhook(ctx context.Context, id int64) (storage.Webhook, error) {
+	return scanWebhook(s.db.QueryRowContext(ctx, `
+		SELECT id, url, secret, terms, sources, created FROM webhooks WHERE id = ?;`, id))
+}
+
+// AddWebhook добавляет подписку и возвращает ее id.
+func (s *SQLite) AddWebhook(ctx context.Context, wh storage.Webhook) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO webhooks(url, secret, terms, sources, created)
+		VALUES (?, ?, ?, ?, ?);`,
+		wh.URL, wh.Secret, jsonArray(wh.Terms), jsonArray(wh.Sources), wh.Created)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.LastInsertId()
+}
+
+// DeleteWebhook удаляет подписку вместе с журналом доставки,
+// если подписки нет, то возвращает ErrNoRows.
+func (s *SQLite) DeleteWebhook(ctx context.Context, id int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM webhooks WHERE id = ?;`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNoRows
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE webhook_id = ?;`, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// AddDelivery записывает в журнал попытку доставки.
+func (s *SQLite) AddDelivery(ctx context.Context, d storage.Delivery) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries(webhook_id, event, items, attempt, status, error, time, duration_ms)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?);`,
+		d.WebhookID, d.Event, jsonArray(d.Items), d.Attempt, d.Status, d.Error, d.Time, d.Duration)
+
+	return err
+}
+
+// Deliveries возвращает до limit последних
+// попыток доставки по подписке, последние первыми.
+func (s *SQLite) Deliveries(ctx context.Context, webhookID int64, limit int) ([]storage.Delivery, error) {
+	var deliveries []storage.Delivery
+
+	stmt := statement{sql: `
+		SELECT id, webhook_id, event, items, attempt, status, error, time, duration_ms
+		FROM webhook_deliveries
+		WHERE webhook_id = ?
+		ORDER BY id DESC
+		LIMIT ?;`, args: []any{webhookID, limit}}
+	err := s.query(ctx, &stmt, func(rows *sql.Rows) error {
+		var d storage.Delivery
+		var items string
+		err := rows.Scan(&d.Id, &d.WebhookID, &d.Event, &items, &d.Attempt,
+			&d.Status, &d.Error, &d.Time, &d.Duration)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal([]byte(items), &d.Items); err != nil {
+			return err
+		}
+		deliveries = append(deliveries, d)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// savedSearchColumns - колонки сохраненного поиска вместе с количеством
+// непрочитанных совпадений, поиск присоединяется как saved_searches.
+const savedSearchColumns = `SELECT id, name, owner, filter, created, last_read,
+	(SELECT COUNT(*) FROM saved_search_matches m
+		WHERE m.search_id = saved_searches.id AND m.item_id > saved_searches.last_read)`
+
+// scanSavedSearch сканирует сохраненный поиск из строки
+// с колонками savedSearchColumns. Фильтр хранится в JSON.
+func scanSavedSearch(row interface{ Scan(...any) error }) (storage.SavedSearch, error) {
+	var s storage.SavedSearch
+	var filter string
+	if err := row.Scan(&s.Id, &s.Name, &s.Owner, &filter, &s.Created, &s.LastRead, &s.Unread); err != nil {
+		return storage.SavedSearch{}, err
+	}
+	if err := json.Unmarshal([]byte(filter), &s.Filter); err != nil {
+		return storage.SavedSearch{}, err
+	}
+
+	return s, nil
+}
+
+// SavedSearches возвращает сохраненные поиски владельца
+// (пустой owner - всех) по возрастанию id.
+func (s *SQLite) SavedSearches(ctx context.Context, owner string) ([]storage.SavedSearch, error) {
+	var searches []storage.SavedSearch
+
+	stmt := statement{sql: savedSearchColumns + `
+		FROM saved_searches
+		WHERE ?1 = '' OR owner = ?1
+		ORDER BY id;`, args: []any{owner}}
+	err := s.query(ctx, &stmt, func(rows *sql.Rows) error {
+		ss, err := scanSavedSearch(rows)
+		if err != nil {
+			return err
+		}
+		searches = append(searches, ss)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return searches, nil
+}
+
+// SavedSearch возвращает сохраненный поиск по id,
+// если поиска нет, то возвращает ErrNoRows.
+func (s *SQLite) SavedSearch(ctx context.Context, id int64) (storage.SavedSearch, error) {
+	return scanSavedSearch(s.db.QueryRowContext(ctx, savedSearchColumns+`
+		FROM saved_searches WHERE id = ?;`, id))
+}
+
+// AddSavedSearch добавляет сохраненный поиск и возвращает его id.
+func (s *SQLite) AddSavedSearch(ctx context.Context, ss storage.SavedSearch) (int64, error) {
+	filter, err := json.Marshal(ss.Filter)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO saved_searches(name, owner, filter, created, last_read)
+		VALUES (?, ?, ?, ?, ?);`,
+		ss.Name, ss.Owner, string(filter), ss.Created, ss.LastRead)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.LastInsertId()
+}
+
+// DeleteSavedSearch удаляет сохраненный поиск вместе с совпадениями,
+// если поиска нет, то возвращает ErrNoRows.
+func (s *SQLite) DeleteSavedSearch(ctx context.Context, id int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM saved_searches WHERE id = ?;`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNoRows
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM saved_search_matches WHERE search_id = ?;`, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// AddSearchMatches записывает совпадения новостей с сохраненными
+// поисками в одной транзакции.
+func (s *SQLite) AddSearchMatches(ctx context.Context, matches map[int64][]int64) error {
+	if len(matches) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	insert, err := tx.PrepareContext(ctx, `
+		INSERT OR IGNORE INTO saved_search_matches(search_id, item_id)
+		SELECT ?1, ?2
+		WHERE EXISTS (SELECT 1 FROM saved_searches WHERE id = ?1)
+			AND EXISTS (SELECT 1 FROM news WHERE id = ?2);`)
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+
+	for searchID, ids := range matches {
+		for _, id := range ids {
+			if _, err := insert.ExecContext(ctx, searchID, id); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// SearchMatches возвращает до limit новостей, совпавших с поиском,
+// с id больше afterID, последние добавленные первыми.
+func (s *SQLite) SearchMatches(ctx context.Context, id int64, afterID int64, limit int) ([]storage.Item, error) {
+	var items []storage.Item
+
+	stmt := statement{sql: itemColumns + `
+		FROM saved_search_matches m
+		JOIN news ON news.id = m.item_id` + sourcesJoin + `
+		WHERE m.search_id = ? AND m.item_id > ?
+		ORDER BY m.item_id DESC
+		LIMIT ?;`, args: []any{id, afterID, limit}}
+	err := s.query(ctx, &stmt, func(rows *sql.Rows) error {
+		var item storage.Item
+		if err := rows.Scan(itemDest(&item)...); err != nil {
+			return err
+		}
+		items = append(items, item)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// MarkSearchRead отмечает прочитанными новости поиска до lastID
+// включительно, если поиска нет, то возвращает ErrNoRows.
+func (s *SQLite) MarkSearchRead(ctx context.Context, id int64, lastID int64) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE saved_searches SET last_read = ? WHERE id = ?;`, lastID, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package sqlite
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/rtemka/agg/news/pkg/storage/storagetest"
+)
+
+func TestSQLite(t *testing.T) {
+	db, err := New(filepath.Join(t.TempDir(), "news.db"))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer db.Close()
+
+	storagetest.Run(t, db)
+
+	// тесты, специфичные для SQLite
+
+	t.Run("ItemByLink()", func(t *testing.T) {
+		want := storagetest.Item1
+
+		got, err := db.ItemByLink(context.Background(), want.Link)
+		if err != nil {
+			t.Fatalf("ItemByLink() error = %v", err)
+		}
+
+		if got != want {
+			t.Fatalf("ItemByLink() got = %v, want = %v", got, want)
+		}
+	})
+
+	t.Run("Suggest()", func(t *testing.T) {
+		terms := []string{"заголвок", "неизвестное"}
+		want := []string{"заголовок", "неизвестное"}
+
+		got, err := db.Suggest(context.Background(), terms)
+		if err != nil {
+			t.Fatalf("Suggest() error = %v", err)
+		}
+
+		if len(got) != len(want) {
+			t.Fatalf("Suggest() got terms = %d, want = %d", len(got), len(want))
+		}
+
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("Suggest() got = %q, want = %q", got[i], want[i])
+			}
+		}
+	})
+
+	t.Run("New()_existing", func(t *testing.T) {
+		// повторное открытие той же БД не должно ломать схему
+		path := filepath.Join(t.TempDir(), "news.db")
+		for i := 0; i < 2; i++ {
+			db, err := New(path)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			db.Close()
+		}
+	})
+}
@@ -0,0 +1,32 @@
+package storage
+
+// StopWords - служебные слова (предлоги, союзы, местоимения и т.п.)
+// не короче MinTermLen, которые не учитываются в статистике слов.
+// Список составлен по словарю стоп-слов 'russian' PostgreSQL.
+var StopWords = []string{
+	"без", "более", "больше", "будет", "будто", "был", "была", "были", "было", "быть",
+	"вам", "вас", "ведь", "весь", "вдруг", "вот", "впрочем", "все", "всегда", "всего",
+	"всех", "всю", "где", "даже", "два", "для", "другой", "его", "если", "есть",
+	"еще", "зачем", "здесь", "иногда", "какая", "какой", "когда", "конечно", "кто", "куда",
+	"лучше", "между", "меня", "мне", "много", "может", "можно", "моя", "мой", "надо",
+	"наконец", "нас", "него", "нее", "нельзя", "нет", "ней", "нибудь", "никогда", "ним",
+	"них", "ничего", "однако", "один", "она", "они", "опять", "перед", "после", "потом",
+	"потому", "почти", "при", "про", "раз", "разве", "сам", "свою", "себе", "себя",
+	"сейчас", "совсем", "так", "такой", "там", "тебя", "тем", "теперь", "того", "тогда",
+	"тоже", "только", "том", "тот", "три", "тут", "уже", "хорошо", "хоть", "чего",
+	"чем", "через", "что", "чтоб", "чтобы", "чуть", "эти", "этого", "этой", "этом",
+	"этот", "эту",
+}
+
+var stopWords = func() map[string]bool {
+	m := make(map[string]bool, len(StopWords))
+	for _, w := range StopWords {
+		m[w] = true
+	}
+	return m
+}()
+
+// IsStopWord сообщает, что слово w в нижнем регистре служебное.
+func IsStopWord(w string) bool {
+	return stopWords[w]
+}
@@ -4,12 +4,20 @@ import (
 	"context"
 	"encoding/xml"
 	"fmt"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	strip "github.com/grokify/html-strip-tags-go"
+	"github.com/rtemka/agg/news/pkg/storage/query"
 )
 
-const PageSize = 10
+// Размер страницы.
+const (
+	PageSize    = 10  // размер страницы по умолчанию.
+	MinPageSize = 1   // минимально допустимый размер страницы.
+	MaxPageSize = 200 // максимально допустимый размер страницы.
+)
 
 type Sort int
 
@@ -18,10 +26,48 @@ const (
 	Date
 	Title
 	Rank
+	ID // по убыванию id, то есть последние добавленные первыми.
 )
 
 func (i Sort) String() string {
-	return []string{"", "pub_date", "title", "rank"}[i]
+	return []string{"", "pub_date", "title", "rank", "id"}[i]
+}
+
+// Маркеры совпадений во фрагментах TitleSnippet и ContentSnippet.
+const (
+	SnippetStartSel = "<mark>"
+	SnippetStopSel  = "</mark>"
+)
+
+// Match - режим поиска.
+type Match int
+
+const (
+	MatchFullText Match = iota // полнотекстовый поиск по заголовку и описанию.
+	MatchFuzzy                 // нечеткий поиск по заголовку, устойчивый к опечаткам.
+)
+
+func (m Match) String() string {
+	return []string{"fulltext", "fuzzy"}[m]
+}
+
+// Fields - набор полей новости, которые нужно выбрать (битовая маска).
+// Пустой набор означает все поля, Id выбирается всегда.
+type Fields uint
+
+const (
+	FieldTitle       Fields = 1 << iota
+	FieldPubDate            // дата публикации.
+	FieldDescription        // описание.
+	FieldLink               // ссылка.
+	FieldSource             // источник новости.
+	FieldSnippets           // фрагменты TitleSnippet и ContentSnippet.
+)
+
+// Has сообщает, что в наборе есть поля f.
+// Пустой набор содержит все поля.
+func (fs Fields) Has(f Fields) bool {
+	return fs == 0 || fs&f == f
 }
 
 // Filter - структура для фильтрации новостей.
@@ -29,29 +75,338 @@ type Filter struct {
 	Exclude     []string   // Фразы, которые стоит исключить.
 	SortBy      Sort       // Полe для сортировки (по дате, по названию, кол-во совпадений).
 	Page        int        // Номер страницы.
+	PageSize    int        // Размер страницы, если 0, то используется PageSize.
 	Date        TimeFilter // Начальная дата или просто дата.
-	EndDate     TimeFilter // Конечная дата.
-	TitleSearch []string   // Поиск по заголовку.
+	EndDate     TimeFilter // Конечная дата, применяется и без Date.
+	TitleSearch []string   // Поиск по заголовку и описанию (синтаксис см. в пакете query).
+	Match       Match      // Режим поиска.
+	// Sources - id источников, новости которых нужно выбрать.
+	Sources []int64
+	// ExcludeSources - id источников, новости которых нужно исключить.
+	ExcludeSources []int64
+	// AfterID - если больше 0, то выбираются только новости
+	// с большим id (для продолжения выгрузки с последней новости).
+	AfterID int64
+	// IDs - если не пуст, то выбираются только новости с этими id.
+	IDs []int64
+	// Fields - поля новостей, которые выбирает Items,
+	// остальные поля остаются пустыми. По-умолчанию все поля.
+	Fields Fields
+	// DescriptionLimit - если больше 0, то Items обрезает
+	// описание новостей до этого количества символов.
+	DescriptionLimit int
 	// FullMatch bool     // требуется полное совпадение.
 	// HeaderFullMatch  bool     // требуется полное совпадение заголовка.
 	// Content          string   // по тексту.
 	// ContentFullMatch bool     // требуется полное совпадение текста.
 }
 
+// Limit возвращает размер страницы,
+// запрошенный в фильтре, либо размер по умолчанию.
+func (f *Filter) Limit() int {
+	if f.PageSize <= 0 {
+		return PageSize
+	}
+	return f.PageSize
+}
+
+// Query разбирает поисковые фразы и исключения фильтра
+// в поисковый запрос. Возвращает nil, если поиск не задан.
+func (f *Filter) Query() (*query.Query, error) {
+	return query.Build(f.TitleSearch, f.Exclude)
+}
+
+// Trim очищает поля новости, не выбранные фильтром, и обрезает описание
+// до DescriptionLimit символов. Нужен хранилищам, которые не выбирают
+// поля в запросе, чтобы результат не отличался от остальных хранилищ.
+func (f *Filter) Trim(item *Item) {
+	fs := f.Fields
+	if !fs.Has(FieldTitle) {
+		item.Title = ""
+	}
+	if !fs.Has(FieldPubDate) {
+		item.PubDate = 0
+	}
+	if !fs.Has(FieldDescription) {
+		item.Description = ""
+	}
+	if !fs.Has(FieldLink) {
+		item.Link = ""
+	}
+	if !fs.Has(FieldSource) {
+		item.Source = Source{}
+	}
+	if !fs.Has(FieldSnippets) {
+		item.TitleSnippet, item.ContentSnippet = "", ""
+	}
+	if f.DescriptionLimit > 0 && utf8.RuneCountInString(item.Description) > f.DescriptionLimit {
+		item.Description = string([]rune(item.Description)[:f.DescriptionLimit])
+	}
+}
+
+// TotalPages возвращает количество страниц,
+// необходимое для отображения total элементов.
+func (f *Filter) TotalPages(total int) int {
+	size := f.Limit()
+	return (total + size - 1) / size
+}
+
 // TimeFilter содержит время в UNIX формате,
 // а также оператор для сравнения ('<', '>=' и т.д.)
+// Фильтры Date и EndDate применяются независимо друг от друга,
+// фильтр с нулевым Value не применяется.
 type TimeFilter struct {
 	Value    int64
 	Operator string
 }
 
+// Op возвращает оператор сравнения фильтра: '=', '<', '<=', '>'
+// или '>='. Неизвестный или пустой оператор означает '='.
+// Хранилища подставляют в запросы только результат Op.
+func (tf TimeFilter) Op() string {
+	switch tf.Operator {
+	case "<", "<=", ">", ">=":
+		return tf.Operator
+	default:
+		return "="
+	}
+}
+
+// Match сообщает, что время v в UNIX формате подходит под фильтр.
+func (tf TimeFilter) Match(v int64) bool {
+	if tf.Value == 0 {
+		return true
+	}
+	switch tf.Op() {
+	case ">":
+		return v > tf.Value
+	case ">=":
+		return v >= tf.Value
+	case "<":
+		return v < tf.Value
+	case "<=":
+		return v <= tf.Value
+	default:
+		return v == tf.Value
+	}
+}
+
 // Storage - контракт на работу с БД
 type Storage interface {
 	Items(ctx context.Context, filter Filter) ([]Item, error)   // Получить все новости списком.
 	CountItems(ctx context.Context, filter Filter) (int, error) // Получить общее количество элементов по запросу (для пагинации).
 	Item(ctx context.Context, id int64) (Item, error)           // Получить новость по id.
-	AddItems(context.Context, []Item) error                     // Добавить новости списком.
-	Close() error                                               // закрыть БД.
+	// AddItems добавляет новости списком. Уже известные (по ссылке) новости
+	// обновляются, если у них изменились заголовок, описание, дата
+	// публикации или источник, иначе пропускаются как дубликаты.
+	AddItems(context.Context, []Item) (AddResult, error)
+	// UpdateItem обновляет новость по id, источник находится по адресу
+	// rss-канала (пустой адрес - без источника). Если новости нет, то
+	// возвращается ошибка ErrNoRows пакета хранилища.
+	UpdateItem(ctx context.Context, item Item) error
+	// DeleteItems удаляет новости по id и возвращает количество удаленных,
+	// отсутствующие id пропускаются.
+	DeleteItems(ctx context.Context, ids []int64) (int, error)
+	// Sources возвращает все известные источники по возрастанию id.
+	Sources(ctx context.Context) ([]Source, error)
+	// Suggest подбирает для каждого слова наиболее похожее из
+	// часто встречающихся в заголовках слов ("возможно, вы имели в виду").
+	// Если подходящего слова нет, то возвращается исходное слово.
+	Suggest(ctx context.Context, terms []string) ([]string, error)
+	// Stats возвращает сводную статистику по новостям, отобранным фильтром,
+	// количество новостей считается по интервалам interval. Страницы фильтра не учитываются.
+	Stats(ctx context.Context, filter Filter, interval Interval) (Stats, error)
+	// Export вызывает fn для каждой новости, отобранной фильтром, по возрастанию id,
+	// не загружая в память все новости сразу. Сортировка и страницы фильтра не учитываются.
+	// Если fn возвращает ошибку, то выгрузка прерывается и Export возвращает эту ошибку.
+	Export(ctx context.Context, filter Filter, fn func(Item) error) error
+	// Related возвращает до limit новостей, похожих по тексту на новость id и
+	// опубликованных не дальше window секунд от нее, самые похожие первыми.
+	// Сама новость и новости с тем же заголовком не возвращаются,
+	// из новостей с одинаковым заголовком возвращается одна.
+	Related(ctx context.Context, id int64, window int64, limit int) ([]Item, error)
+	// MatchFilters сопоставляет новости ids с фильтрами filters так же, как
+	// их отбирает Items, и возвращает для каждого фильтра id подошедших
+	// новостей по возрастанию. Сортировка, страницы и поля фильтров не
+	// учитываются, фильтры с ошибкой в запросе не передаются.
+	MatchFilters(ctx context.Context, ids []int64, filters []Filter) ([][]int64, error)
+	Close() error // закрыть БД.
+}
+
+// WebhookStore - контракт на хранение подписок на новые новости
+// и журнала их доставки.
+type WebhookStore interface {
+	// Webhooks возвращает все подписки на новые новости по возрастанию id.
+	Webhooks(ctx context.Context) ([]Webhook, error)
+	// Webhook возвращает подписку по id, если подписки нет, то
+	// возвращается ошибка ErrNoRows пакета хранилища.
+	Webhook(ctx context.Context, id int64) (Webhook, error)
+	// AddWebhook добавляет подписку и возвращает ее id.
+	AddWebhook(ctx context.Context, wh Webhook) (int64, error)
+	// DeleteWebhook удаляет подписку вместе с журналом доставки, если
+	// подписки нет, то возвращается ошибка ErrNoRows пакета хранилища.
+	DeleteWebhook(ctx context.Context, id int64) error
+	// AddDelivery записывает в журнал попытку доставки.
+	AddDelivery(ctx context.Context, d Delivery) error
+	// Deliveries возвращает до limit последних попыток доставки
+	// по подписке webhookID, последние первыми.
+	Deliveries(ctx context.Context, webhookID int64, limit int) ([]Delivery, error)
+}
+
+// SavedSearchStore - контракт на хранение сохраненных поисков
+// и совпадений с ними новых новостей.
+type SavedSearchStore interface {
+	// SavedSearches возвращает сохраненные поиски владельца owner
+	// (пустой owner - всех владельцев) с количеством непрочитанных
+	// новостей по возрастанию id.
+	SavedSearches(ctx context.Context, owner string) ([]SavedSearch, error)
+	// SavedSearch возвращает сохраненный поиск по id, если поиска нет,
+	// то возвращается ошибка ErrNoRows пакета хранилища.
+	SavedSearch(ctx context.Context, id int64) (SavedSearch, error)
+	// AddSavedSearch добавляет сохраненный поиск и возвращает его id.
+	AddSavedSearch(ctx context.Context, s SavedSearch) (int64, error)
+	// DeleteSavedSearch удаляет сохраненный поиск вместе с совпадениями,
+	// если поиска нет, то возвращается ошибка ErrNoRows пакета хранилища.
+	DeleteSavedSearch(ctx context.Context, id int64) error
+	// AddSearchMatches записывает совпадения новых новостей с сохраненными
+	// поисками: id поиска - id подошедших новостей. Уже записанные
+	// совпадения, удаленные поиски и новости пропускаются.
+	AddSearchMatches(ctx context.Context, matches map[int64][]int64) error
+	// SearchMatches возвращает до limit новостей, совпавших с сохраненным
+	// поиском id, с id больше afterID, последние добавленные первыми.
+	SearchMatches(ctx context.Context, id int64, afterID int64, limit int) ([]Item, error)
+	// MarkSearchRead отмечает прочитанными новости сохраненного поиска с id
+	// до lastID включительно. Если поиска нет, то возвращается ошибка
+	// ErrNoRows пакета хранилища.
+	MarkSearchRead(ctx context.Context, id int64, lastID int64) error
+}
+
+// AddResult - итоги добавления новостей.
+type AddResult struct {
+	Inserted int // добавленные новости.
+	Updated  int // обновленные новости.
+	Skipped  int // пропущенные дубликаты.
+	// Feeds - итоги по адресам rss-каналов,
+	// новости без канала не учитываются.
+	Feeds map[string]AddResult
+	// Added - добавленные новости с присвоенными id новости
+	// и источника, в итогах по каналам не заполняется.
+	Added []Item
+}
+
+// AddStatus - итог добавления одной новости.
+type AddStatus int
+
+const (
+	AddInserted AddStatus = iota // новость добавлена.
+	AddUpdated                   // новость обновлена.
+	AddSkipped                   // новость пропущена как дубликат.
+)
+
+// Count учитывает итог добавления новости из канала feed.
+func (r *AddResult) Count(feed string, st AddStatus) {
+	r.count(st, 1)
+	if feed == "" {
+		return
+	}
+	if r.Feeds == nil {
+		r.Feeds = make(map[string]AddResult)
+	}
+	f := r.Feeds[feed]
+	f.count(st, 1)
+	r.Feeds[feed] = f
+}
+
+func (r *AddResult) count(st AddStatus, n int) {
+	switch st {
+	case AddInserted:
+		r.Inserted += n
+	case AddUpdated:
+		r.Updated += n
+	default:
+		r.Skipped += n
+	}
+}
+
+// UniqueByLink убирает из новостей повторы по ссылке: остается последняя
+// версия новости на месте первой. Возвращает новости без повторов
+// и итоги, в которых каждый повтор учтен как пропущенный.
+func UniqueByLink(items []Item) ([]Item, AddResult) {
+	var res AddResult
+	index := make(map[string]int, len(items))
+	unique := make([]Item, 0, len(items))
+
+	for _, it := range items {
+		i, ok := index[it.Link]
+		if !ok {
+			index[it.Link] = len(unique)
+			unique = append(unique, it)
+			continue
+		}
+		res.Count(unique[i].Source.FeedURL, AddSkipped)
+		unique[i] = it
+	}
+
+	return unique, res
+}
+
+// TopTerms - сколько самых частых слов заголовков возвращает Stats.
+const TopTerms = 20
+
+// MinTermLen - слова заголовков короче этого (предлоги,
+// союзы и т.п.) не учитываются в статистике слов,
+// как и более длинные служебные слова из StopWords.
+const MinTermLen = 3
+
+// Похожие новости.
+const (
+	RelatedLimit    = 5                // количество похожих новостей по умолчанию.
+	MaxRelatedLimit = 50               // максимальное количество похожих новостей.
+	RelatedWindow   = 7 * 24 * 60 * 60 // окно поиска похожих новостей по умолчанию, в секундах.
+)
+
+// Interval - интервал, по которому группируется количество новостей.
+type Interval int
+
+const (
+	Day Interval = iota
+	Hour
+)
+
+func (i Interval) String() string {
+	return []string{"day", "hour"}[i]
+}
+
+// Seconds возвращает длину интервала в секундах.
+func (i Interval) Seconds() int64 {
+	return []int64{24 * 60 * 60, 60 * 60}[i]
+}
+
+// Stats - сводная статистика по новостям.
+type Stats struct {
+	Total   int           `json:"total"`   // всего новостей.
+	Counts  []TimeCount   `json:"counts"`  // количество новостей по интервалам, по возрастанию времени.
+	Sources []SourceCount `json:"sources"` // количество новостей по источникам, по убыванию.
+	Terms   []TermCount   `json:"terms"`   // самые частые слова заголовков, по убыванию.
+}
+
+// TimeCount - количество новостей за интервал.
+type TimeCount struct {
+	Time  int64 `json:"time"` // начало интервала (UTC) в UNIX формате.
+	Count int   `json:"count"`
+}
+
+// SourceCount - количество новостей источника,
+// у новостей без источника Source пустой.
+type SourceCount struct {
+	Source Source `json:"source"`
+	Count  int    `json:"count"`
+}
+
+// TermCount - количество заголовков, в которых встречается слово.
+type TermCount struct {
+	Term  string `json:"term"`
+	Count int    `json:"count"`
 }
 
 // Item - модель данных rss-новости
@@ -61,6 +416,66 @@ type Item struct {
 	PubDate     int64  `json:"pubTime" bson:"pubDate"`
 	Description string `json:"content" bson:"description"`
 	Link        string `json:"link" bson:"link"`
+	// Фрагменты заголовка и описания с выделенными
+	// совпадениями, заполняются только при поиске.
+	TitleSnippet   string `json:"titleSnippet,omitempty" bson:"-"`
+	ContentSnippet string `json:"contentSnippet,omitempty" bson:"-"`
+	Source         Source `json:"source" bson:"-"` // rss-канал, из которого получена новость.
+}
+
+// Webhook - подписка на новые новости: подходящие под фильтр
+// новости отправляются POST-запросом на URL. Фильтра по категориям
+// нет: у новостей нет категорий (обходчик не читает <category> из
+// rss, в хранилищах нет такого поля), API отклоняет его с ошибкой.
+type Webhook struct {
+	Id      int64    `json:"id"`
+	URL     string   `json:"url"`
+	Secret  string   `json:"secret,omitempty"`  // ключ подписи HMAC-SHA256.
+	Terms   []string `json:"terms,omitempty"`   // поисковые фразы (синтаксис см. в пакете query).
+	Sources []int64  `json:"sources,omitempty"` // id источников, пусто - все источники.
+	Created int64    `json:"created"`           // время создания в UNIX формате.
+}
+
+// Filter возвращает фильтр новостей подписки: новость должна
+// соответствовать всем поисковым фразам и быть из одного из
+// источников подписки, если они заданы.
+func (wh *Webhook) Filter() Filter {
+	return Filter{TitleSearch: wh.Terms, Sources: wh.Sources}
+}
+
+// Delivery - попытка доставки новостей по подписке.
+type Delivery struct {
+	Id        int64   `json:"id"`
+	WebhookID int64   `json:"webhook_id"`
+	Event     string  `json:"event"`   // id события, общий для всех попыток.
+	Items     []int64 `json:"items"`   // id отправленных новостей.
+	Attempt   int     `json:"attempt"` // номер попытки, начиная с 1.
+	Status    int     `json:"status"`  // код ответа, 0 - ответа нет.
+	Error     string  `json:"error,omitempty"`
+	Time      int64   `json:"time"`        // время попытки в UNIX формате.
+	Duration  int64   `json:"duration_ms"` // длительность попытки в миллисекундах.
+}
+
+// SavedSearch - сохраненный поиск: новые новости, подходящие
+// под фильтр, записываются как совпадения, пока пользователь не
+// отметит их прочитанными. Фильтр применяется так же, как в Items,
+// кроме сортировки, страниц и выбора полей.
+type SavedSearch struct {
+	Id       int64
+	Name     string
+	Owner    string // владелец, например имя пользователя.
+	Filter   Filter
+	Created  int64 // время создания в UNIX формате.
+	LastRead int64 // id последней прочитанной новости.
+	Unread   int   // количество непрочитанных совпадений, только для чтения.
+}
+
+// Source - rss-канал, источник новостей.
+type Source struct {
+	Id      int64  `json:"id"`
+	Name    string `json:"name"`     // название канала.
+	URL     string `json:"url"`      // адрес сайта.
+	FeedURL string `json:"feed_url"` // адрес rss-канала.
 }
 
 func (i Item) String() string {
@@ -71,7 +486,44 @@ func (i Item) String() string {
 // ItemContainer - контейнер содержащий rss-новости.
 // Используется для декодирования xml
 type ItemContainer struct {
-	Items []Item `xml:"channel>item"`
+	Source Source // канал, название и адрес сайта берутся из xml.
+	Items  []Item
+}
+
+// xmlContainer - копия ItemContainer для декодирования xml.
+// Ссылок в канале может быть несколько (например, atom:link
+// без текста), поэтому берется первая непустая.
+type xmlContainer struct {
+	Title string   `xml:"channel>title"`
+	Links []string `xml:"channel>link"`
+	Items []Item   `xml:"channel>item"`
+}
+
+func (c *ItemContainer) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var xc xmlContainer
+	if err := d.DecodeElement(&xc, &start); err != nil {
+		return err
+	}
+
+	c.Source = Source{Name: strings.TrimSpace(xc.Title)}
+	for _, l := range xc.Links {
+		if l = strings.TrimSpace(l); l != "" {
+			c.Source.URL = l
+			break
+		}
+	}
+	c.Items = xc.Items
+
+	return nil
+}
+
+// SetSource проставляет адрес rss-канала источнику
+// контейнера и связывает с источником все новости.
+func (c *ItemContainer) SetSource(feedURL string) {
+	c.Source.FeedURL = feedURL
+	for i := range c.Items {
+		c.Items[i].Source = c.Source
+	}
 }
 
 // xmlItem - копия Item, единственная польза
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"encoding/xml"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -50,3 +51,45 @@ func TestItem_UnmarshalXML(t *testing.T) {
 	}
 
 }
+
+func TestFilter_TotalPages(t *testing.T) {
+	tests := []struct {
+		total, pageSize, want int
+	}{
+		{total: 0, pageSize: 10, want: 0},
+		{total: 10, pageSize: 10, want: 1},
+		{total: 11, pageSize: 10, want: 2},
+		{total: 199, pageSize: 200, want: 1},
+		{total: 25, pageSize: 0, want: 3}, // размер по умолчанию
+	}
+
+	for _, tt := range tests {
+		f := Filter{PageSize: tt.pageSize}
+		if got := f.TotalPages(tt.total); got != tt.want {
+			t.Errorf("Filter.TotalPages(%d) with page size %d = %d, want = %d",
+				tt.total, tt.pageSize, got, tt.want)
+		}
+	}
+}
+
+func TestUniqueByLink(t *testing.T) {
+	feed := Source{FeedURL: "https://test.com/rss"}
+	items := []Item{
+		{Title: "первая", Link: "https://test.com/1", Source: feed},
+		{Title: "вторая", Link: "https://test.com/2"},
+		{Title: "первая, исправленная", Link: "https://test.com/1", Source: feed},
+		{Title: "вторая", Link: "https://test.com/2"},
+	}
+
+	got, res := UniqueByLink(items)
+
+	want := []Item{items[2], items[1]}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("UniqueByLink() got = %v, want = %v", got, want)
+	}
+
+	wantRes := AddResult{Skipped: 2, Feeds: map[string]AddResult{feed.FeedURL: {Skipped: 1}}}
+	if !reflect.DeepEqual(res, wantRes) {
+		t.Fatalf("UniqueByLink() got result = %+v, want = %+v", res, wantRes)
+	}
+}
@@ -0,0 +1,1223 @@
+// пакет storagetest содержит общий набор тестов, которому
+// должна соответствовать каждая реализация storage.Storage.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rtemka/agg/news/pkg/storage"
+	"github.com/rtemka/agg/news/pkg/storage/query"
+)
+
+// Run проверяет, что реализация db соответствует контракту
+// storage.Storage, а если db реализует storage.WebhookStore
+// и storage.SavedSearchStore, то и этим контрактам.
+// Ожидается, что хранилище db пустое.
+func Run(t *testing.T, db storage.Storage) {
+	t.Run("AddItems()", func(t *testing.T) {
+		wantItems := []storage.Item{Item1, Item2, Item3, Item4}
+
+		res, err := db.AddItems(context.Background(), wantItems)
+		if err != nil {
+			t.Fatalf("AddItems() error = %v", err)
+		}
+		if want := (storage.AddResult{Inserted: len(wantItems), Added: wantItems}); !reflect.DeepEqual(res, want) {
+			t.Fatalf("AddItems() got result = %+v, want = %+v", res, want)
+		}
+
+		gotItems, err := db.Items(context.Background(), storage.Filter{Page: 1})
+		if err != nil {
+			t.Fatalf("Items() error = %v", err)
+		}
+
+		if len(gotItems) != len(wantItems) {
+			t.Fatalf("Items() got items = %d, want = %d", len(gotItems), len(wantItems))
+		}
+
+		for i := range wantItems {
+			if gotItems[i] != wantItems[i] {
+				t.Fatalf("AddItems() got = %v, want = %v", gotItems[i], wantItems[i])
+			}
+		}
+	})
+
+	t.Run("Items()_page_size", func(t *testing.T) {
+		want := []storage.Item{Item3, Item4}
+
+		got, err := db.Items(context.Background(), storage.Filter{Page: 2, PageSize: 2})
+		if err != nil {
+			t.Fatalf("Items() error = %v", err)
+		}
+
+		if len(got) != len(want) {
+			t.Fatalf("Items() got items = %d, want = %d", len(got), len(want))
+		}
+
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("Items() got = %v, want = %v", got[i], want[i])
+			}
+		}
+	})
+
+	t.Run("AddItems()_duplicates", func(t *testing.T) {
+		res, err := db.AddItems(context.Background(), []storage.Item{Item1, Item2})
+		if err != nil {
+			t.Fatalf("AddItems() error = %v", err)
+		}
+		if want := (storage.AddResult{Skipped: 2}); !reflect.DeepEqual(res, want) {
+			t.Fatalf("AddItems() got result = %+v, want = %+v", res, want)
+		}
+
+		got, err := db.CountItems(context.Background(), storage.Filter{})
+		if err != nil {
+			t.Fatalf("CountItems() error = %v", err)
+		}
+
+		if want := 4; got != want {
+			t.Fatalf("CountItems() got = %d, want = %d", got, want)
+		}
+	})
+
+	t.Run("Item()_not_found", func(t *testing.T) {
+		got, err := db.Item(context.Background(), 1000)
+		if err == nil {
+			t.Fatalf("Item() error = nil, want error")
+		}
+
+		if got != (storage.Item{}) {
+			t.Fatalf("Item() got = %v, want empty item", got)
+		}
+	})
+
+	t.Run("Item()", func(t *testing.T) {
+		want := Item1
+
+		got, err := db.Item(context.Background(), want.Id)
+		if err != nil {
+			t.Fatalf("Item() error = %v", err)
+		}
+
+		if got != want {
+			t.Fatalf("Item() got = %v, want = %v", got, want)
+		}
+	})
+
+	t.Run("Items()_title_search", func(t *testing.T) {
+		want := Item3
+
+		v, err := db.Items(context.Background(), storage.Filter{TitleSearch: []string{"голэнг", "go"}})
+		if err != nil {
+			t.Fatalf("Items() error = %v", err)
+		}
+
+		if len(v) != 1 {
+			t.Fatalf("Items() got items = %d, want = %d", len(v), 1)
+		}
+
+		if !strings.Contains(v[0].TitleSnippet, storage.SnippetStartSel) {
+			t.Errorf("Items() got title snippet = %q, want marked matches", v[0].TitleSnippet)
+		}
+
+		got := withoutSnippets(v[0])
+
+		if got != want {
+			t.Fatalf("Items() got = %v, want = %v", got, want)
+		}
+	})
+
+	t.Run("Items()_title_search_exclude", func(t *testing.T) {
+		want := []storage.Item{Item1, Item2}
+
+		got, err := db.Items(context.Background(), storage.Filter{TitleSearch: []string{"go"}, Exclude: []string{"голэнг"}})
+		if err != nil {
+			t.Fatalf("Items() error = %v", err)
+		}
+		for i := range got {
+			got[i] = withoutSnippets(got[i])
+		}
+
+		if len(got) != len(want) {
+			t.Fatalf("Items() got items = %d, want = %d", len(got), len(want))
+		}
+
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("Items() got = %v, want = %v", got[i], want[i])
+			}
+		}
+	})
+
+	t.Run("Items()_exclusion_in_or", func(t *testing.T) {
+		// запрос, который не все хранилища могут выполнить,
+		// отклоняется одинаково во всех хранилищах
+		f := storage.Filter{TitleSearch: []string{"go OR -голэнг"}}
+		var serr *query.SyntaxError
+
+		if _, err := db.Items(context.Background(), f); !errors.As(err, &serr) {
+			t.Fatalf("Items() error = %v, want *query.SyntaxError", err)
+		}
+		if _, err := db.CountItems(context.Background(), f); !errors.As(err, &serr) {
+			t.Fatalf("CountItems() error = %v, want *query.SyntaxError", err)
+		}
+	})
+
+	t.Run("Items()_exclude_only", func(t *testing.T) {
+		want := []storage.Item{Item1, Item2, Item4}
+
+		// без поисковых фраз исключения только отсеивают новости
+		for _, exclude := range []string{"голэнг", "-голэнг"} {
+			f := storage.Filter{Exclude: []string{exclude}}
+			got, err := db.Items(context.Background(), f)
+			if err != nil {
+				t.Fatalf("Items() error = %v", err)
+			}
+
+			if len(got) != len(want) {
+				t.Fatalf("Items(%q) got items = %d, want = %d", exclude, len(got), len(want))
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("Items(%q) got = %v, want = %v", exclude, got[i], want[i])
+				}
+			}
+
+			n, err := db.CountItems(context.Background(), f)
+			if err != nil {
+				t.Fatalf("CountItems() error = %v", err)
+			}
+			if n != len(want) {
+				t.Fatalf("CountItems(%q) got = %d, want = %d", exclude, n, len(want))
+			}
+		}
+	})
+
+	t.Run("Items()_description_search", func(t *testing.T) {
+		want := 4
+
+		got, err := db.Items(context.Background(), storage.Filter{TitleSearch: []string{"описание"}})
+		if err != nil {
+			t.Fatalf("Items() error = %v", err)
+		}
+
+		if len(got) != want {
+			t.Fatalf("Items() got items = %d, want = %d", len(got), want)
+		}
+
+		for i := range got {
+			if !strings.Contains(got[i].ContentSnippet, storage.SnippetStartSel) {
+				t.Errorf("Items() got content snippet = %q, want marked matches", got[i].ContentSnippet)
+			}
+		}
+	})
+
+	t.Run("Items()_fuzzy_search", func(t *testing.T) {
+		want := Item4
+
+		v, err := db.Items(context.Background(),
+			storage.Filter{TitleSearch: []string{"индепотентнсть"}, Match: storage.MatchFuzzy})
+		if err != nil {
+			t.Fatalf("Items() error = %v", err)
+		}
+
+		if len(v) != 1 {
+			t.Fatalf("Items() got items = %d, want = %d", len(v), 1)
+		}
+
+		if got := v[0]; got != want {
+			t.Fatalf("Items() got = %v, want = %v", got, want)
+		}
+	})
+
+	t.Run("Items()_date_search_==", func(t *testing.T) {
+		want := Item4
+
+		v, err := db.Items(context.Background(), storage.Filter{Date: storage.TimeFilter{Value: 1659344500, Operator: "="}})
+		if err != nil {
+			t.Fatalf("Items() error = %v", err)
+		}
+
+		if len(v) != 1 {
+			t.Fatalf("Items() got items = %d, want = %d", len(v), 1)
+		}
+
+		got := v[0]
+
+		if got != want {
+			t.Fatalf("Items() got = %v, want = %v", got, want)
+		}
+	})
+
+	t.Run("Items()_date_search_>=", func(t *testing.T) {
+		want := 4
+
+		v, err := db.Items(context.Background(), storage.Filter{Date: storage.TimeFilter{Value: 1659344500, Operator: ">="}})
+		if err != nil {
+			t.Fatalf("Items() error = %v", err)
+		}
+
+		if len(v) != want {
+			t.Fatalf("Items() got items = %d, want = %d", len(v), want)
+		}
+
+	})
+
+	t.Run("Items()_date_search_IN", func(t *testing.T) {
+		want := []storage.Item{Item2, Item3}
+
+		got, err := db.Items(context.Background(),
+			storage.Filter{
+				Date:    storage.TimeFilter{Value: 1659344500, Operator: ">"},
+				EndDate: storage.TimeFilter{Value: 1659517300, Operator: "<="},
+			})
+		if err != nil {
+			t.Fatalf("Items() error = %v", err)
+		}
+
+		if len(got) != len(want) {
+			t.Fatalf("Items() got items = %d, want = %d", len(got), len(want))
+		}
+
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("Items() got = %v, want = %v", got[i], want[i])
+			}
+		}
+
+	})
+
+	t.Run("Items()_date_search_end_only", func(t *testing.T) {
+		// конечная дата применяется и без начальной,
+		// неизвестный оператор означает равенство
+		tests := []struct {
+			name string
+			f    storage.Filter
+			want []storage.Item
+		}{
+			{name: "end", f: storage.Filter{EndDate: storage.TimeFilter{Value: 1659430900, Operator: "<"}}, want: []storage.Item{Item4}},
+			{name: "operator", f: storage.Filter{Date: storage.TimeFilter{Value: 1659430900, Operator: "<> 0 OR 1 ="}}, want: []storage.Item{Item3}},
+		}
+
+		for _, tt := range tests {
+			got, err := db.Items(context.Background(), tt.f)
+			if err != nil {
+				t.Fatalf("Items(%s) error = %v", tt.name, err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Items(%s) got items = %d, want = %d", tt.name, len(got), len(tt.want))
+			}
+			for i := range tt.want {
+				if withoutSnippets(got[i]) != tt.want[i] {
+					t.Fatalf("Items(%s) got = %v, want = %v", tt.name, got[i], tt.want[i])
+				}
+			}
+		}
+	})
+
+	t.Run("Items()_sort_by_date", func(t *testing.T) {
+		want := []storage.Item{Item1, Item2, Item3, Item4} // новые сверху
+
+		got, err := db.Items(context.Background(),
+			storage.Filter{Page: 1, SortBy: storage.Date})
+		if err != nil {
+			t.Fatalf("Items() error = %v", err)
+		}
+
+		if len(got) != len(want) {
+			t.Fatalf("Items() got items = %d, want = %d", len(got), len(want))
+		}
+
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("Items() got = %v, want = %v", got[i], want[i])
+			}
+		}
+
+	})
+
+	t.Run("Items()_sort_by_rank", func(t *testing.T) {
+		want := []storage.Item{Item1, Item2, Item3}
+
+		got, err := db.Items(context.Background(),
+			storage.Filter{Page: 1, SortBy: storage.Rank, TitleSearch: []string{"go"}})
+		if err != nil {
+			t.Fatalf("Items() error = %v", err)
+		}
+		for i := range got {
+			got[i] = withoutSnippets(got[i])
+		}
+
+		if len(got) != len(want) {
+			t.Fatalf("Items() got items = %d, want = %d", len(got), len(want))
+		}
+
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("Items() got = %v, want = %v", got[i], want[i])
+			}
+		}
+
+	})
+
+	t.Run("CountItems()_search", func(t *testing.T) {
+		got, err := db.CountItems(context.Background(), storage.Filter{TitleSearch: []string{"go"}})
+		if err != nil {
+			t.Fatalf("CountItems() error = %v", err)
+		}
+
+		if want := 3; got != want {
+			t.Fatalf("CountItems() got = %d, want = %d", got, want)
+		}
+	})
+
+	t.Run("Items()_phrase_and_prefix_search", func(t *testing.T) {
+		tests := []struct {
+			search string
+			want   storage.Item
+		}{
+			{search: `"база данных"`, want: Item2},
+			{search: "голэн*", want: Item3},
+			{search: "голэнг OR индепотентность", want: Item3},
+		}
+
+		for _, tt := range tests {
+			got, err := db.Items(context.Background(),
+				storage.Filter{TitleSearch: []string{tt.search}, SortBy: storage.Date})
+			if err != nil {
+				t.Fatalf("Items(%q) error = %v", tt.search, err)
+			}
+
+			if len(got) == 0 {
+				t.Fatalf("Items(%q) got no items", tt.search)
+			}
+
+			if withoutSnippets(got[0]) != tt.want {
+				t.Fatalf("Items(%q) got = %v, want = %v", tt.search, got[0], tt.want)
+			}
+		}
+	})
+
+	t.Run("Items()_sort_by_title", func(t *testing.T) {
+		want := []storage.Item{Item4, Item3, Item2, Item1}
+
+		got, err := db.Items(context.Background(),
+			storage.Filter{Page: 1, SortBy: storage.Title})
+		if err != nil {
+			t.Fatalf("Items() error = %v", err)
+		}
+
+		if len(got) != len(want) {
+			t.Fatalf("Items() got items = %d, want = %d", len(got), len(want))
+		}
+
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("Items() got = %v, want = %v", got[i], want[i])
+			}
+		}
+	})
+
+	t.Run("Items()_sources", func(t *testing.T) {
+		ctx := context.Background()
+
+		items := []storage.Item{
+			{Title: "Новость Коммерсанта", Description: "Описание 5", PubDate: 1659690100,
+				Link: "https://www.kommersant.ru/doc/1", Source: Kommersant},
+			{Title: "Новость РИА", Description: "Описание 6", PubDate: 1659690000,
+				Link: "https://ria.ru/1", Source: RIA},
+		}
+		if _, err := db.AddItems(ctx, items); err != nil {
+			t.Fatalf("AddItems() error = %v", err)
+		}
+
+		// новые новости самые свежие, поэтому первые
+		got, err := db.Items(ctx, storage.Filter{Page: 1, PageSize: len(items)})
+		if err != nil {
+			t.Fatalf("Items() error = %v", err)
+		}
+		if len(got) != len(items) {
+			t.Fatalf("Items() got items = %d, want = %d", len(got), len(items))
+		}
+		for i := range items {
+			src := got[i].Source
+			if src.Id == 0 {
+				t.Fatalf("Items() got source without id: %v", got[i])
+			}
+			src.Id = 0
+			if src != items[i].Source {
+				t.Fatalf("Items() got source = %v, want = %v", src, items[i].Source)
+			}
+		}
+		kommersant, ria := got[0].Source, got[1].Source
+		if kommersant.Id == ria.Id {
+			t.Fatalf("Items() got same id = %d for different sources", ria.Id)
+		}
+
+		// повторно канал не добавляется
+		_, err = db.AddItems(ctx, []storage.Item{{Title: "Вторая новость Коммерсанта", Description: "Описание 7",
+			PubDate: 1659690200, Link: "https://www.kommersant.ru/doc/2", Source: Kommersant}})
+		if err != nil {
+			t.Fatalf("AddItems() error = %v", err)
+		}
+
+		item, err := db.Item(ctx, got[0].Id)
+		if err != nil {
+			t.Fatalf("Item() error = %v", err)
+		}
+		if item.Source != kommersant {
+			t.Fatalf("Item() got source = %v, want = %v", item.Source, kommersant)
+		}
+
+		tests := []struct {
+			name string
+			f    storage.Filter
+			want int
+		}{
+			{name: "source", f: storage.Filter{Sources: []int64{kommersant.Id}}, want: 2},
+			{name: "sources", f: storage.Filter{Sources: []int64{kommersant.Id, ria.Id}}, want: 3},
+			{name: "exclude", f: storage.Filter{ExcludeSources: []int64{kommersant.Id}}, want: 5},
+			{name: "search", f: storage.Filter{Sources: []int64{kommersant.Id}, TitleSearch: []string{"вторая"}}, want: 1},
+		}
+
+		for _, tt := range tests {
+			n, err := db.CountItems(ctx, tt.f)
+			if err != nil {
+				t.Fatalf("CountItems(%s) error = %v", tt.name, err)
+			}
+			if n != tt.want {
+				t.Fatalf("CountItems(%s) got = %d, want = %d", tt.name, n, tt.want)
+			}
+
+			got, err := db.Items(ctx, tt.f)
+			if err != nil {
+				t.Fatalf("Items(%s) error = %v", tt.name, err)
+			}
+			if len(got) != tt.want {
+				t.Fatalf("Items(%s) got items = %d, want = %d", tt.name, len(got), tt.want)
+			}
+			for _, it := range got {
+				if len(tt.f.ExcludeSources) > 0 && it.Source.Id == tt.f.ExcludeSources[0] {
+					t.Fatalf("Items(%s) got excluded source item %v", tt.name, it)
+				}
+			}
+		}
+	})
+
+	t.Run("Items()_fields", func(t *testing.T) {
+		date := storage.TimeFilter{Value: Item4.PubDate, Operator: "="}
+
+		tests := []struct {
+			name string
+			f    storage.Filter
+			want storage.Item
+		}{
+			{name: "title_link", f: storage.Filter{Date: date, Fields: storage.FieldTitle | storage.FieldLink},
+				want: storage.Item{Id: Item4.Id, Title: Item4.Title, Link: Item4.Link}},
+			{name: "all_truncated", f: storage.Filter{Date: date, DescriptionLimit: 3},
+				want: storage.Item{Id: Item4.Id, Title: Item4.Title, Description: "Опи", PubDate: Item4.PubDate, Link: Item4.Link}},
+			{name: "description_short", f: storage.Filter{Date: date, Fields: storage.FieldDescription, DescriptionLimit: 100},
+				want: storage.Item{Id: Item4.Id, Description: Item4.Description}},
+			{name: "no_snippets", f: storage.Filter{TitleSearch: []string{"индепотентность"}, Fields: storage.FieldPubDate},
+				want: storage.Item{Id: Item4.Id, PubDate: Item4.PubDate}},
+		}
+
+		for _, tt := range tests {
+			got, err := db.Items(context.Background(), tt.f)
+			if err != nil {
+				t.Fatalf("Items(%s) error = %v", tt.name, err)
+			}
+			if len(got) != 1 || got[0] != tt.want {
+				t.Fatalf("Items(%s) got = %+v, want = %+v", tt.name, got, tt.want)
+			}
+		}
+
+		got, err := db.Items(context.Background(), storage.Filter{TitleSearch: []string{"индепотентность"},
+			Fields: storage.FieldSnippets, DescriptionLimit: 1})
+		if err != nil {
+			t.Fatalf("Items() error = %v", err)
+		}
+		if len(got) != 1 || got[0].Title != "" || !strings.Contains(got[0].TitleSnippet, storage.SnippetStartSel) {
+			t.Fatalf("Items() got = %+v, want only snippets", got)
+		}
+	})
+
+	t.Run("Stats()", func(t *testing.T) {
+		ctx := context.Background()
+
+		latest, err := db.Items(ctx, storage.Filter{Page: 1, PageSize: 1})
+		if err != nil || len(latest) != 1 {
+			t.Fatalf("Items() got = %v, error = %v", latest, err)
+		}
+		kommersant := latest[0].Source
+
+		tests := []struct {
+			name     string
+			f        storage.Filter
+			interval storage.Interval
+			want     storage.Stats
+		}{
+			{
+				name: "all",
+				want: storage.Stats{
+					Total: 7,
+					Counts: []storage.TimeCount{
+						{Time: 1659312000, Count: 1}, {Time: 1659398400, Count: 1}, {Time: 1659484800, Count: 1},
+						{Time: 1659571200, Count: 1}, {Time: 1659657600, Count: 3},
+					},
+					Sources: []storage.SourceCount{{Count: 4}, {Source: kommersant, Count: 2}},
+					Terms: []storage.TermCount{
+						{Term: "заголовок", Count: 4}, {Term: "новость", Count: 3}, {Term: "коммерсанта", Count: 2},
+					},
+				},
+			},
+			{
+				name:     "source_by_hour",
+				f:        storage.Filter{Sources: []int64{kommersant.Id}},
+				interval: storage.Hour,
+				want: storage.Stats{
+					Total:   2,
+					Counts:  []storage.TimeCount{{Time: 1659690000, Count: 2}},
+					Sources: []storage.SourceCount{{Source: kommersant, Count: 2}},
+					Terms: []storage.TermCount{
+						{Term: "коммерсанта", Count: 2}, {Term: "новость", Count: 2}, {Term: "вторая", Count: 1},
+					},
+				},
+			},
+			{
+				name: "search",
+				f:    storage.Filter{TitleSearch: []string{"голэнг"}},
+				want: storage.Stats{
+					Total:   1,
+					Counts:  []storage.TimeCount{{Time: 1659398400, Count: 1}},
+					Sources: []storage.SourceCount{{Count: 1}},
+					Terms:   []storage.TermCount{{Term: "голэнг", Count: 1}, {Term: "заголовок", Count: 1}},
+				},
+			},
+		}
+
+		for _, tt := range tests {
+			got, err := db.Stats(ctx, tt.f, tt.interval)
+			if err != nil {
+				t.Fatalf("Stats(%s) error = %v", tt.name, err)
+			}
+
+			if got.Total != tt.want.Total {
+				t.Fatalf("Stats(%s) got total = %d, want = %d", tt.name, got.Total, tt.want.Total)
+			}
+			if !reflect.DeepEqual(got.Counts, tt.want.Counts) {
+				t.Fatalf("Stats(%s) got counts = %v, want = %v", tt.name, got.Counts, tt.want.Counts)
+			}
+			// сравниваем только первые, остальные могут совпадать по количеству
+			if len(got.Sources) < len(tt.want.Sources) ||
+				!reflect.DeepEqual(got.Sources[:len(tt.want.Sources)], tt.want.Sources) {
+				t.Fatalf("Stats(%s) got sources = %v, want = %v", tt.name, got.Sources, tt.want.Sources)
+			}
+			if len(got.Terms) < len(tt.want.Terms) ||
+				!reflect.DeepEqual(got.Terms[:len(tt.want.Terms)], tt.want.Terms) {
+				t.Fatalf("Stats(%s) got terms = %v, want = %v", tt.name, got.Terms, tt.want.Terms)
+			}
+		}
+	})
+
+	t.Run("Export()", func(t *testing.T) {
+		ctx := context.Background()
+
+		var all []storage.Item
+		err := db.Export(ctx, storage.Filter{}, func(it storage.Item) error {
+			all = append(all, it)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Export() error = %v", err)
+		}
+		if want := 7; len(all) != want {
+			t.Fatalf("Export() got items = %d, want = %d", len(all), want)
+		}
+		if all[0] != Item1 {
+			t.Fatalf("Export() got first = %v, want = %v", all[0], Item1)
+		}
+		for i := 1; i < len(all); i++ {
+			if all[i].Id <= all[i-1].Id {
+				t.Fatalf("Export() got id %d after %d, want ascending ids", all[i].Id, all[i-1].Id)
+			}
+		}
+
+		// продолжение выгрузки после второй новости
+		var got []storage.Item
+		err = db.Export(ctx, storage.Filter{AfterID: Item2.Id, TitleSearch: []string{"go"}},
+			func(it storage.Item) error {
+				got = append(got, it)
+				return nil
+			})
+		if err != nil {
+			t.Fatalf("Export() error = %v", err)
+		}
+		if len(got) != 1 || withoutSnippets(got[0]) != Item3 {
+			t.Fatalf("Export() got = %v, want = [%v]", got, Item3)
+		}
+
+		// ошибка fn прерывает выгрузку
+		errStop := errors.New("stop")
+		var n int
+		err = db.Export(ctx, storage.Filter{}, func(storage.Item) error {
+			n++
+			return errStop
+		})
+		if !errors.Is(err, errStop) || n != 1 {
+			t.Fatalf("Export() got error = %v after %d items, want = %v after 1", err, n, errStop)
+		}
+	})
+
+	t.Run("MatchFilters()", func(t *testing.T) {
+		ctx := context.Background()
+
+		ids := []int64{Item1.Id, Item2.Id, Item3.Id, Item4.Id}
+		cases := []struct {
+			f    storage.Filter
+			want []int64
+		}{
+			{f: storage.Filter{TitleSearch: []string{"go"}}, want: []int64{Item1.Id, Item2.Id, Item3.Id}},
+			{f: storage.Filter{TitleSearch: []string{"go"}, Exclude: []string{"голэнг"}}, want: []int64{Item1.Id, Item2.Id}},
+			{f: storage.Filter{Date: storage.TimeFilter{Value: Item4.PubDate, Operator: "="}}, want: []int64{Item4.Id}},
+			{f: storage.Filter{}, want: ids},
+			{f: storage.Filter{TitleSearch: []string{"rust"}}},
+		}
+
+		// фильтров больше, чем проверяется одним запросом
+		filters := make([]storage.Filter, 250)
+		for i := range filters {
+			filters[i] = cases[i%len(cases)].f
+		}
+
+		got, err := db.MatchFilters(ctx, ids, filters)
+		if err != nil {
+			t.Fatalf("MatchFilters() error = %v", err)
+		}
+		if len(got) != len(filters) {
+			t.Fatalf("MatchFilters() got = %d results, want = %d", len(got), len(filters))
+		}
+		for i := range got {
+			if want := cases[i%len(cases)].want; !reflect.DeepEqual(got[i], want) {
+				t.Fatalf("MatchFilters() got for filter %d = %v, want = %v", i, got[i], want)
+			}
+		}
+
+		// новости не из ids не сопоставляются
+		got, err = db.MatchFilters(ctx, []int64{Item4.Id}, filters[:1])
+		if err != nil || len(got) != 1 || got[0] != nil {
+			t.Fatalf("MatchFilters() got = %v, %v, want no matches", got, err)
+		}
+	})
+
+	t.Run("Related()", func(t *testing.T) {
+		ctx := context.Background()
+		const day = 24 * 60 * 60
+
+		// копия Item3 под другой ссылкой
+		dup := Item3
+		dup.Id, dup.Link, dup.PubDate = 0, "https://test.com/14987529-copy", Item3.PubDate-100
+		if _, err := db.AddItems(ctx, []storage.Item{dup}); err != nil {
+			t.Fatalf("AddItems() error = %v", err)
+		}
+
+		tests := []struct {
+			name    string
+			id      int64
+			window  int64
+			limit   int
+			wantLen int
+			want    []storage.Item // в любом порядке, пустая новость - любая из копий Item3.
+		}{
+			// копия Item3 не возвращается, как и сама новость
+			{name: "duplicate_of_item", id: Item3.Id, window: day, limit: 10,
+				wantLen: 2, want: []storage.Item{Item2, Item4}},
+			// из Item3 и ее копии возвращается одна
+			{name: "duplicates_in_result", id: Item2.Id, window: day, limit: 10,
+				wantLen: 2, want: []storage.Item{Item1, {}}},
+			{name: "limit", id: Item2.Id, window: storage.RelatedWindow, limit: 1, wantLen: 1},
+			{name: "not_found", id: 1000, window: day, limit: 10},
+		}
+
+		for _, tt := range tests {
+			got, err := db.Related(ctx, tt.id, tt.window, tt.limit)
+			if err != nil {
+				t.Fatalf("Related(%s) error = %v", tt.name, err)
+			}
+			if len(got) != tt.wantLen {
+				t.Fatalf("Related(%s) got = %v, want %d items", tt.name, got, tt.wantLen)
+			}
+			for _, g := range got {
+				if g.Id == tt.id {
+					t.Fatalf("Related(%s) got the item itself", tt.name)
+				}
+			}
+
+			for _, w := range tt.want {
+				found := false
+				for _, g := range got {
+					if w == (storage.Item{}) && g.Title == Item3.Title || w != (storage.Item{}) && g == w {
+						found = true
+					}
+				}
+				if !found {
+					t.Fatalf("Related(%s) got = %v, want it to contain = %v", tt.name, got, w)
+				}
+			}
+		}
+	})
+
+	t.Run("Sources()", func(t *testing.T) {
+		got, err := db.Sources(context.Background())
+		if err != nil {
+			t.Fatalf("Sources() error = %v", err)
+		}
+		if len(got) != 2 {
+			t.Fatalf("Sources() got = %v, want 2 sources", got)
+		}
+		if got[0].Id >= got[1].Id {
+			t.Fatalf("Sources() got = %v, want sorted by id", got)
+		}
+		for i, want := range []storage.Source{Kommersant, RIA} {
+			src := got[i]
+			src.Id = 0
+			if src != want {
+				t.Fatalf("Sources() got = %v, want = %v", src, want)
+			}
+		}
+	})
+
+	t.Run("UpdateItem()", func(t *testing.T) {
+		ctx := context.Background()
+
+		sources, err := db.Sources(ctx)
+		if err != nil {
+			t.Fatalf("Sources() error = %v", err)
+		}
+
+		want := Item4
+		want.Title, want.Description = "Обновленный заголовок 4", "Обновленное описание 4"
+		want.Source = sources[1]
+
+		if err := db.UpdateItem(ctx, want); err != nil {
+			t.Fatalf("UpdateItem() error = %v", err)
+		}
+
+		got, err := db.Item(ctx, want.Id)
+		if err != nil {
+			t.Fatalf("Item() error = %v", err)
+		}
+		if got != want {
+			t.Fatalf("Item() got = %v, want = %v", got, want)
+		}
+
+		// поиск находит новость по новому заголовку
+		n, err := db.CountItems(ctx, storage.Filter{TitleSearch: []string{"обновленный"}})
+		if err != nil {
+			t.Fatalf("CountItems() error = %v", err)
+		}
+		if n != 1 {
+			t.Fatalf("CountItems() got = %d, want = %d", n, 1)
+		}
+
+		// пустой адрес канала убирает источник
+		if err := db.UpdateItem(ctx, Item4); err != nil {
+			t.Fatalf("UpdateItem() error = %v", err)
+		}
+		if got, err = db.Item(ctx, Item4.Id); err != nil || got != Item4 {
+			t.Fatalf("Item() got = %v, %v, want = %v", got, err, Item4)
+		}
+
+		missing := Item4
+		missing.Id = 1000
+		if err := db.UpdateItem(ctx, missing); err == nil {
+			t.Fatalf("UpdateItem() error = nil, want error for missing item")
+		}
+	})
+
+	t.Run("DeleteItems()", func(t *testing.T) {
+		ctx := context.Background()
+
+		before, err := db.CountItems(ctx, storage.Filter{})
+		if err != nil {
+			t.Fatalf("CountItems() error = %v", err)
+		}
+
+		n, err := db.DeleteItems(ctx, []int64{Item4.Id, 1000})
+		if err != nil {
+			t.Fatalf("DeleteItems() error = %v", err)
+		}
+		if n != 1 {
+			t.Fatalf("DeleteItems() got = %d, want = %d", n, 1)
+		}
+
+		if _, err := db.Item(ctx, Item4.Id); err == nil {
+			t.Fatalf("Item() error = nil, want error for deleted item")
+		}
+
+		after, err := db.CountItems(ctx, storage.Filter{})
+		if err != nil {
+			t.Fatalf("CountItems() error = %v", err)
+		}
+		if after != before-1 {
+			t.Fatalf("CountItems() got = %d, want = %d", after, before-1)
+		}
+
+		// удаленная новость не находится поиском
+		if n, err = db.CountItems(ctx, storage.Filter{TitleSearch: []string{"индепотентность"}}); err != nil || n != 0 {
+			t.Fatalf("CountItems() got = %d, %v, want = 0", n, err)
+		}
+
+		if n, err = db.DeleteItems(ctx, nil); err != nil || n != 0 {
+			t.Fatalf("DeleteItems(nil) got = %d, %v, want = 0", n, err)
+		}
+
+		// ссылку удаленной новости можно добавить заново
+		if _, err := db.AddItems(ctx, []storage.Item{Item4}); err != nil {
+			t.Fatalf("AddItems() error = %v", err)
+		}
+		if n, err = db.CountItems(ctx, storage.Filter{}); err != nil || n != before {
+			t.Fatalf("CountItems() got = %d, %v, want = %d", n, err, before)
+		}
+	})
+
+	t.Run("AddItems()_result", func(t *testing.T) {
+		ctx := context.Background()
+
+		changed := Item1
+		changed.Title = "Заголовок 1; исправленный"
+		added := storage.Item{Title: "Новая новость", Description: "Описание 8",
+			PubDate: 1659690300, Link: "https://test.com/14987530", Source: RIA}
+
+		res, err := db.AddItems(ctx, []storage.Item{Item2, changed, added, added})
+		if err != nil {
+			t.Fatalf("AddItems() error = %v", err)
+		}
+		// добавленная новость возвращается с id новости и источника
+		if len(res.Added) != 1 || res.Added[0].Id == 0 || res.Added[0].Source.Id == 0 ||
+			res.Added[0].Link != added.Link || res.Added[0].Source.FeedURL != RIA.FeedURL {
+			t.Fatalf("AddItems() got added = %+v, want = %+v with ids", res.Added, added)
+		}
+		res.Added = nil
+
+		// повтор в одном вызове учитывается как пропущенный
+		want := storage.AddResult{Inserted: 1, Updated: 1, Skipped: 2,
+			Feeds: map[string]storage.AddResult{RIA.FeedURL: {Inserted: 1, Skipped: 1}}}
+		if !reflect.DeepEqual(res, want) {
+			t.Fatalf("AddItems() got result = %+v, want = %+v", res, want)
+		}
+
+		got, err := db.Item(ctx, Item1.Id)
+		if err != nil {
+			t.Fatalf("Item() error = %v", err)
+		}
+		if got != changed {
+			t.Fatalf("Item() got = %v, want = %v", got, changed)
+		}
+
+		// новость без изменений не обновляется повторно
+		if res, err = db.AddItems(ctx, []storage.Item{changed}); err != nil || !reflect.DeepEqual(res, storage.AddResult{Skipped: 1}) {
+			t.Fatalf("AddItems() got result = %+v, %v, want = %+v", res, err, storage.AddResult{Skipped: 1})
+		}
+
+		// возвращаем новость, как было, для тестов конкретных хранилищ
+		if _, err := db.AddItems(ctx, []storage.Item{Item1}); err != nil {
+			t.Fatalf("AddItems() error = %v", err)
+		}
+	})
+
+	t.Run("Webhooks()", func(t *testing.T) {
+		db, ok := db.(storage.WebhookStore)
+		if !ok {
+			t.Skip("storage doesn't implement storage.WebhookStore")
+		}
+		ctx := context.Background()
+
+		whs := []storage.Webhook{
+			{URL: "https://hooks.test.com/1", Secret: "s1", Terms: []string{"go", "база данных"}, Sources: []int64{1, 2}, Created: 1659690300},
+			{URL: "https://hooks.test.com/2", Created: 1659690400},
+		}
+		for i := range whs {
+			id, err := db.AddWebhook(ctx, whs[i])
+			if err != nil {
+				t.Fatalf("AddWebhook() error = %v", err)
+			}
+			if id <= 0 || i > 0 && id <= whs[i-1].Id {
+				t.Fatalf("AddWebhook() got id = %d, want increasing id", id)
+			}
+			whs[i].Id = id
+		}
+
+		got, err := db.Webhooks(ctx)
+		if err != nil {
+			t.Fatalf("Webhooks() error = %v", err)
+		}
+		if len(got) != len(whs) {
+			t.Fatalf("Webhooks() got = %+v, want = %+v", got, whs)
+		}
+		for i := range whs {
+			if !equalWebhooks(got[i], whs[i]) {
+				t.Fatalf("Webhooks() got = %+v, want = %+v", got[i], whs[i])
+			}
+		}
+
+		wh, err := db.Webhook(ctx, whs[0].Id)
+		if err != nil || !equalWebhooks(wh, whs[0]) {
+			t.Fatalf("Webhook() got = %+v, %v, want = %+v", wh, err, whs[0])
+		}
+
+		// журнал доставки, последние попытки первыми
+		for i := 1; i <= 3; i++ {
+			d := storage.Delivery{WebhookID: whs[0].Id, Event: "e1", Items: []int64{1, 2},
+				Attempt: i, Status: 500, Error: "status 500", Time: 1659690300 + int64(i), Duration: 12}
+			if err := db.AddDelivery(ctx, d); err != nil {
+				t.Fatalf("AddDelivery() error = %v", err)
+			}
+		}
+		if err := db.AddDelivery(ctx, storage.Delivery{WebhookID: whs[1].Id, Event: "e2", Items: []int64{3}, Attempt: 1, Status: 200}); err != nil {
+			t.Fatalf("AddDelivery() error = %v", err)
+		}
+
+		ds, err := db.Deliveries(ctx, whs[0].Id, 2)
+		if err != nil {
+			t.Fatalf("Deliveries() error = %v", err)
+		}
+		if len(ds) != 2 || ds[0].Attempt != 3 || ds[1].Attempt != 2 {
+			t.Fatalf("Deliveries() got = %+v, want attempts 3, 2", ds)
+		}
+		if d := ds[0]; d.Id == 0 || d.WebhookID != whs[0].Id || d.Event != "e1" || !reflect.DeepEqual(d.Items, []int64{1, 2}) ||
+			d.Status != 500 || d.Error != "status 500" || d.Time != 1659690303 || d.Duration != 12 {
+			t.Fatalf("Deliveries() got = %+v", d)
+		}
+
+		// удаление подписки удаляет и журнал
+		if err := db.DeleteWebhook(ctx, whs[0].Id); err != nil {
+			t.Fatalf("DeleteWebhook() error = %v", err)
+		}
+		if err := db.DeleteWebhook(ctx, whs[0].Id); err == nil {
+			t.Fatalf("DeleteWebhook() got no error for deleted webhook")
+		}
+		if _, err := db.Webhook(ctx, whs[0].Id); err == nil {
+			t.Fatalf("Webhook() got no error for deleted webhook")
+		}
+		if ds, err := db.Deliveries(ctx, whs[0].Id, 10); err != nil || len(ds) != 0 {
+			t.Fatalf("Deliveries() got = %+v, %v, want none", ds, err)
+		}
+		if ds, err := db.Deliveries(ctx, whs[1].Id, 10); err != nil || len(ds) != 1 {
+			t.Fatalf("Deliveries() got = %+v, %v, want 1", ds, err)
+		}
+
+		if err := db.DeleteWebhook(ctx, whs[1].Id); err != nil {
+			t.Fatalf("DeleteWebhook() error = %v", err)
+		}
+	})
+
+	t.Run("Items()_sort_by_id", func(t *testing.T) {
+		got, err := db.Items(context.Background(), storage.Filter{Page: 1, PageSize: storage.MaxPageSize, SortBy: storage.ID})
+		if err != nil {
+			t.Fatalf("Items() error = %v", err)
+		}
+
+		if len(got) < 2 {
+			t.Fatalf("Items() got items = %d, want several", len(got))
+		}
+		for i := 1; i < len(got); i++ {
+			if got[i].Id >= got[i-1].Id {
+				t.Fatalf("Items() got ids %d, %d, want descending", got[i-1].Id, got[i].Id)
+			}
+		}
+	})
+
+	t.Run("SavedSearches()", func(t *testing.T) {
+		ss, ok := db.(storage.SavedSearchStore)
+		if !ok {
+			t.Skip("storage doesn't implement storage.SavedSearchStore")
+		}
+		ctx := context.Background()
+
+		items, err := db.Items(ctx, storage.Filter{Page: 1, PageSize: 3, SortBy: storage.ID})
+		if err != nil || len(items) != 3 {
+			t.Fatalf("Items() got = %d items, %v, want 3", len(items), err)
+		}
+		// id по возрастанию
+		ids := []int64{items[2].Id, items[1].Id, items[0].Id}
+
+		searches := []storage.SavedSearch{
+			{Name: "go", Owner: "user1", Created: 1659690300,
+				Filter: storage.Filter{TitleSearch: []string{"go"}, Exclude: []string{"rust"}, Sources: []int64{1}}},
+			{Name: "все", Owner: "user2", Created: 1659690400},
+		}
+		for i := range searches {
+			id, err := ss.AddSavedSearch(ctx, searches[i])
+			if err != nil {
+				t.Fatalf("AddSavedSearch() error = %v", err)
+			}
+			if id <= 0 || i > 0 && id <= searches[i-1].Id {
+				t.Fatalf("AddSavedSearch() got id = %d, want increasing id", id)
+			}
+			searches[i].Id = id
+		}
+
+		got, err := ss.SavedSearches(ctx, "user1")
+		if err != nil {
+			t.Fatalf("SavedSearches() error = %v", err)
+		}
+		if len(got) != 1 || !reflect.DeepEqual(got[0], searches[0]) {
+			t.Fatalf("SavedSearches() got = %+v, want = %+v", got, searches[:1])
+		}
+
+		// повторы, удаленные поиски и отсутствующие новости пропускаются
+		err = ss.AddSearchMatches(ctx, map[int64][]int64{
+			searches[0].Id:       {ids[0], ids[2]},
+			searches[1].Id:       ids,
+			searches[1].Id + 100: {ids[0]},
+		})
+		if err != nil {
+			t.Fatalf("AddSearchMatches() error = %v", err)
+		}
+		err = ss.AddSearchMatches(ctx, map[int64][]int64{searches[0].Id: {ids[2], 1 << 40}})
+		if err != nil {
+			t.Fatalf("AddSearchMatches() error = %v", err)
+		}
+
+		got, err = ss.SavedSearches(ctx, "")
+		if err != nil {
+			t.Fatalf("SavedSearches() error = %v", err)
+		}
+		if len(got) != 2 || got[0].Unread != 2 || got[1].Unread != 3 {
+			t.Fatalf("SavedSearches() got = %+v, want unread 2 and 3", got)
+		}
+
+		matched, err := ss.SearchMatches(ctx, searches[1].Id, ids[0], 10)
+		if err != nil {
+			t.Fatalf("SearchMatches() error = %v", err)
+		}
+		if len(matched) != 2 || matched[0].Id != ids[2] || matched[1].Id != ids[1] {
+			t.Fatalf("SearchMatches() got = %+v, want ids %d, %d", matched, ids[2], ids[1])
+		}
+		if withoutSnippets(matched[0]) != withoutSnippets(items[0]) {
+			t.Fatalf("SearchMatches() got = %+v, want = %+v", matched[0], items[0])
+		}
+		if matched, err := ss.SearchMatches(ctx, searches[1].Id, 0, 1); err != nil || len(matched) != 1 {
+			t.Fatalf("SearchMatches() got = %+v, %v, want 1 item", matched, err)
+		}
+
+		if err := ss.MarkSearchRead(ctx, searches[1].Id, ids[1]); err != nil {
+			t.Fatalf("MarkSearchRead() error = %v", err)
+		}
+		s, err := ss.SavedSearch(ctx, searches[1].Id)
+		if err != nil || s.LastRead != ids[1] || s.Unread != 1 {
+			t.Fatalf("SavedSearch() got = %+v, %v, want last read %d and 1 unread", s, err, ids[1])
+		}
+		if err := ss.MarkSearchRead(ctx, searches[1].Id+100, ids[1]); err == nil {
+			t.Fatalf("MarkSearchRead() got no error for unknown search")
+		}
+
+		// удаление поиска удаляет и совпадения
+		if err := ss.DeleteSavedSearch(ctx, searches[0].Id); err != nil {
+			t.Fatalf("DeleteSavedSearch() error = %v", err)
+		}
+		if err := ss.DeleteSavedSearch(ctx, searches[0].Id); err == nil {
+			t.Fatalf("DeleteSavedSearch() got no error for deleted search")
+		}
+		if _, err := ss.SavedSearch(ctx, searches[0].Id); err == nil {
+			t.Fatalf("SavedSearch() got no error for deleted search")
+		}
+		if matched, err := ss.SearchMatches(ctx, searches[0].Id, 0, 10); err != nil || len(matched) != 0 {
+			t.Fatalf("SearchMatches() got = %+v, %v, want none", matched, err)
+		}
+
+		if err := ss.DeleteSavedSearch(ctx, searches[1].Id); err != nil {
+			t.Fatalf("DeleteSavedSearch() error = %v", err)
+		}
+	})
+
+	t.Run("Stats()_stop_words", func(t *testing.T) {
+		ctx := context.Background()
+
+		const pubDate = 1700000000
+		_, err := db.AddItems(ctx, []storage.Item{{Title: "Что будет после выборов для страны при этом",
+			Description: "Описание 9", PubDate: pubDate, Link: "https://test.com/stop-words"}})
+		if err != nil {
+			t.Fatalf("AddItems() error = %v", err)
+		}
+
+		got, err := db.Stats(ctx, storage.Filter{Date: storage.TimeFilter{Value: pubDate, Operator: "="}}, storage.Day)
+		if err != nil {
+			t.Fatalf("Stats() error = %v", err)
+		}
+		want := []storage.TermCount{{Term: "выборов", Count: 1}, {Term: "страны", Count: 1}}
+		if !reflect.DeepEqual(got.Terms, want) {
+			t.Fatalf("Stats() got terms = %v, want = %v", got.Terms, want)
+		}
+	})
+}
+
+// equalWebhooks сравнивает подписки, не различая
+// пустые и nil слайсы фраз и источников.
+func equalWebhooks(a, b storage.Webhook) bool {
+	if len(a.Terms) == 0 && len(b.Terms) == 0 {
+		a.Terms, b.Terms = nil, nil
+	}
+	if len(a.Sources) == 0 && len(b.Sources) == 0 {
+		a.Sources, b.Sources = nil, nil
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// withoutSnippets возвращает новость без фрагментов
+// с выделенными совпадениями для сравнения с ожидаемой.
+func withoutSnippets(item storage.Item) storage.Item {
+	item.TitleSnippet, item.ContentSnippet = "", ""
+	return item
+}
+
+// Тестовые новости. Ожидается, что хранилище присвоит
+// им id по порядку добавления, начиная с 1.
+var Item1 = storage.Item{
+	Id:          1,
+	Title:       "Заголовок 1; go go go go",
+	Description: "Описание 1",
+	PubDate:     1659603700,
+	Link:        "https://test.com/14987527",
+}
+
+var Item2 = storage.Item{
+	Id:          2,
+	Title:       "Заголовок 2; база данных база данных база данных go go",
+	Description: "Описание 2",
+	PubDate:     1659517300,
+	Link:        "https://test.com/14987528",
+}
+
+var Item3 = storage.Item{
+	Id:          3,
+	Title:       "Заголовок 3; голэнг go",
+	Description: "Описание 3",
+	PubDate:     1659430900,
+	Link:        "https://test.com/14987529",
+}
+
+var Item4 = storage.Item{
+	Id:          4,
+	Title:       "Заголовок 4; индепотентность",
+	Description: "Описание 4",
+	PubDate:     1659344500,
+	Link:        "https://test.com/149875210",
+}
+
+// Тестовые источники новостей.
+var Kommersant = storage.Source{
+	Name:    "Коммерсантъ",
+	URL:     "https://www.kommersant.ru",
+	FeedURL: "https://www.kommersant.ru/RSS/news.xml",
+}
+
+var RIA = storage.Source{
+	Name:    "РИА Новости",
+	URL:     "https://ria.ru",
+	FeedURL: "https://ria.ru/export/rss2/archive/index.xml",
+}
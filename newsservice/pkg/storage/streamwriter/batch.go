@@ -0,0 +1,148 @@
+package streamwriter
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/rtemka/agg/news/pkg/storage"
+)
+
+// Пакетная запись по умолчанию.
+const (
+	BatchSize = 500             // сколько новостей набирается в пачку.
+	BatchWait = 2 * time.Second // сколько пачка ждет новых контейнеров.
+)
+
+// batchQueue - сколько собранных пачек могут ждать записи в БД,
+// пока очередь занята, новые контейнеры из канала не читаются.
+const batchQueue = 1
+
+// BatchStats - метрики пакетной записи.
+type BatchStats struct {
+	Batches  uint          // собранные пачки
+	BySize   uint          // пачки, отправленные по размеру
+	ByTime   uint          // пачки, отправленные по времени
+	Deduped  uint          // повторы ссылок, убранные из пачек
+	MaxItems uint          // новостей в самой большой пачке
+	Blocked  time.Duration // сколько сборка пачек ждала записи в БД
+	Written  time.Duration // сколько заняла запись пачек в БД
+}
+
+// batch - пачка новостей из нескольких контейнеров.
+type batch struct {
+	items      []item
+	index      map[string]int // индекс новости в items по ссылке
+	containers uint
+	received   map[string]uint   // полученные новости по адресу rss-канала
+	dups       storage.AddResult // повторы ссылок внутри пачки
+	byTime     bool              // пачка отправлена по времени
+	bySize     bool              // пачка отправлена по размеру
+}
+
+func newBatch() *batch {
+	return &batch{
+		index:    make(map[string]int),
+		received: make(map[string]uint),
+	}
+}
+
+// add добавляет в пачку новости контейнера. Новость с уже
+// известной ссылкой заменяет более раннюю версию.
+func (b *batch) add(c container) {
+	b.containers++
+
+	for _, it := range withSource(c) {
+		b.received[it.Source.FeedURL]++
+
+		if i, ok := b.index[it.Link]; ok {
+			b.dups.Count(b.items[i].Source.FeedURL, storage.AddSkipped)
+			b.items[i] = it
+			continue
+		}
+		b.index[it.Link] = len(b.items)
+		b.items = append(b.items, it)
+	}
+}
+
+// withSource возвращает новости контейнера, у новостей
+// без канала канал берется из контейнера.
+func withSource(c container) []item {
+	if c.Source.FeedURL == "" {
+		return c.Items
+	}
+
+	items := make([]item, len(c.Items))
+	for i, it := range c.Items {
+		if it.Source.FeedURL == "" {
+			it.Source = c.Source
+		}
+		items[i] = it
+	}
+	return items
+}
+
+// batcher собирает контейнеры из in в пачки и передает их в out,
+// когда в пачке набирается batchSize новостей или первый контейнер
+// пачки ждет дольше batchWait. Пока out занят, контейнеры из in не
+// читаются, так что медленная БД притормаживает и сбор новостей.
+// Время ожидания out прибавляется к blocked. Закрывает out, когда
+// закрыт in (собранное к этому времени отправляется) или done.
+func (sw *StreamWriter) batcher(in <-chan container, out chan<- *batch, done <-chan struct{}, blocked *atomic.Int64) {
+	defer close(out)
+
+	b := newBatch()
+	var timer *time.Timer
+	var timeout <-chan time.Time
+
+	send := func() bool {
+		if timer != nil {
+			timer.Stop()
+			timer, timeout = nil, nil
+		}
+
+		start := time.Now()
+		select {
+		case out <- b:
+		case <-done:
+			return false
+		}
+		blocked.Add(int64(time.Since(start)))
+
+		b = newBatch()
+		return true
+	}
+
+	for {
+		select {
+		case c, ok := <-in:
+			if !ok {
+				if b.containers > 0 {
+					send()
+				}
+				return
+			}
+
+			if b.containers == 0 {
+				timer = time.NewTimer(sw.batchWait)
+				timeout = timer.C
+			}
+			b.add(c)
+
+			if len(b.items) >= sw.batchSize {
+				b.bySize = true
+				if !send() {
+					return
+				}
+			}
+
+		case <-timeout:
+			b.byTime = true
+			if !send() {
+				return
+			}
+
+		case <-done:
+			return
+		}
+	}
+}
@@ -3,10 +3,13 @@ package streamwriter
 import (
 	"context"
 	"log"
+	"sort"
+	"sync/atomic"
 
 	"time"
 
 	"github.com/rtemka/agg/news/pkg/storage"
+	"github.com/rtemka/agg/news/pkg/storage/spool"
 )
 
 // container - объекты которые получает streamwriter
@@ -20,16 +23,34 @@ type stor = storage.Storage
 type StreamWriter struct {
 	log     *log.Logger
 	storage storage.Storage
+	// спул для пачек, которые не удалось записать в БД,
+	// по-умолчанию не используется
+	spool *spool.Spool
+	// получатели добавленных в БД новостей
+	notify []Notifier
+	// размер пачки и время ее сбора,
+	// по-умолчанию BatchSize и BatchWait
+	batchSize int
+	batchWait time.Duration
 	// когда установлен в true, логгирует промежуточные итоги,
 	// по-умолчанию false
 	debugMode bool
 }
 
+// replayPeriod - как часто StreamWriter пробует дописать
+// в БД пачки из спула, если новых пачек нет
+const replayPeriod = 10 * time.Second
+
+// writeTimeout - сколько может длиться запись пачки в БД
+const writeTimeout = 5 * time.Second
+
 // NewStreamWriter возвращает новый объект *StreamWriter
 func NewStreamWriter(log *log.Logger, storage stor) *StreamWriter {
 	return &StreamWriter{
 		log:       log,
 		storage:   storage,
+		batchSize: BatchSize,
+		batchWait: BatchWait,
 		debugMode: false,
 	}
 }
@@ -40,54 +61,287 @@ func (sw *StreamWriter) DebugMode(on bool) *StreamWriter {
 	return sw
 }
 
+// WithSpool включает сохранение в спул sp тех пачек,
+// которые не удалось записать в БД
+func (sw *StreamWriter) WithSpool(sp *spool.Spool) *StreamWriter {
+	sw.spool = sp
+	return sw
+}
+
+// Notifier получает новости, добавленные в БД. Notify вызывается
+// после каждой записи и не должен надолго ее задерживать.
+type Notifier interface {
+	Notify(items []storage.Item)
+}
+
+// WithNotifier добавляет получателя новостей, добавленных в БД
+// (обновленные и пропущенные новости не передаются).
+func (sw *StreamWriter) WithNotifier(n Notifier) *StreamWriter {
+	sw.notify = append(sw.notify, n)
+	return sw
+}
+
+// Batching задает, сколько новостей набирается в пачку и сколько
+// пачка ждет новых контейнеров, прежде чем будет записана в БД
+func (sw *StreamWriter) Batching(size int, wait time.Duration) *StreamWriter {
+	if size > 0 {
+		sw.batchSize = size
+	}
+	if wait > 0 {
+		sw.batchWait = wait
+	}
+	return sw
+}
+
 // Stats - статистика работы *StreamWriter
 type Stats struct {
 	Containers uint // обработанные контейнеры
 	Items      uint // обработанные новости
+	Inserted   uint // добавленные в БД новости
+	Updated    uint // обновленные в БД новости
+	Skipped    uint // пропущенные дубликаты
 	Errs       uint // полученные ошибки
+	Spooled    uint // пачки, сохраненные в спул
+	Replayed   uint // пачки, дописанные в БД из спула
+	// Feeds - статистика по адресам rss-каналов,
+	// новости без адреса канала не учитываются
+	Feeds map[string]FeedStats
+	Batch BatchStats // метрики пакетной записи
+}
+
+// FeedStats - статистика по одному rss-каналу
+type FeedStats struct {
+	Items    uint // полученные новости
+	Inserted uint // добавленные в БД новости
+	Updated  uint // обновленные в БД новости
+	Skipped  uint // пропущенные дубликаты
+	Errs     uint // ошибки записи в БД
+}
+
+// add учитывает итоги записи в БД
+func (fs *FeedStats) add(res storage.AddResult) {
+	fs.Inserted += uint(res.Inserted)
+	fs.Updated += uint(res.Updated)
+	fs.Skipped += uint(res.Skipped)
+}
+
+// count учитывает итоги записи в БД в общей
+// статистике и в статистике каналов
+func (s *Stats) count(res storage.AddResult) {
+	s.Inserted += uint(res.Inserted)
+	s.Updated += uint(res.Updated)
+	s.Skipped += uint(res.Skipped)
+
+	for url, r := range res.Feeds {
+		f := s.Feeds[url]
+		f.add(r)
+		s.Feeds[url] = f
+	}
 }
 
 // WriteToStorage пишет в БД поступающие данные из канала,
 // возвращает статистику своей работы и ошибку, если БД мертва
-// или все приходящие данные не удается записать
+// или все приходящие данные не удается записать.
+//
+// Новости из нескольких контейнеров собираются в пачки (см. Batching),
+// повторы ссылок внутри пачки убираются, и пачка записывается в БД
+// за один раз. Пока пачка пишется, следующая собирается, но не
+// больше одной: дальше контейнеры из канала не читаются.
+//
+// Если задан спул, то пачки, которые не удалось записать в БД,
+// сохраняются в спул и дописываются в БД по порядку, как только она
+// снова доступна, а работа прерывается, только если не удается
+// записать и в спул.
 func (sw *StreamWriter) WriteToStorage(ctx context.Context, in <-chan container) (Stats, error) {
-	var stats Stats
+	var stats = Stats{Feeds: make(map[string]FeedStats)}
 	var threshold = cap(in)
+	var lost uint // пачки, которые не удалось сохранить
 
 	statsCh := make(chan Stats)
 	defer close(statsCh)
 	go sw.logDebug(statsCh, cap(in)) // логгирование промежуточных итогов
 
-	for v := range in {
+	batches := make(chan *batch, batchQueue)
+	done := make(chan struct{})
+	defer close(done)
+	var blocked atomic.Int64
+	go sw.batcher(in, batches, done, &blocked) // сбор пачек
+
+	var replay <-chan time.Time
+	if sw.spool != nil {
+		t := time.NewTicker(replayPeriod)
+		defer t.Stop()
+		replay = t.C
+	}
+
+loop:
+	for {
+		var b *batch
+		var ok bool
+
+		select {
+		case b, ok = <-batches:
+			if !ok {
+				break loop
+			}
+		case <-replay:
+			if !sw.spool.Empty() {
+				sw.replay(ctx, &stats)
+				statsCh <- stats
+			}
+			continue
+		}
 
-		dbctx, cancel := context.WithTimeout(ctx, 5*time.Second)
-		defer cancel()
+		sw.received(&stats, b)
+		stats.Batch.Blocked = time.Duration(blocked.Load())
 
-		err := sw.storage.AddItems(dbctx, v.Items)
-		if err != nil {
-			sw.log.Printf("[ERROR] db_error=%v", err) // логгируем ошибку
-			stats.Errs++
+		if len(b.items) == 0 {
+			statsCh <- stats
+			continue
+		}
+
+		if err := sw.store(ctx, b.items, &stats); err != nil {
+			lost++
 			statsCh <- stats
 			// если беда со всей пачкой пришедших значений, то
 			// смысла продолжать нет
-			if stats.Errs >= uint(threshold) {
+			if lost >= uint(threshold) {
 				return stats, err
 			}
+			continue
 		}
 
-		stats.Containers++
-		stats.Items += uint(len(v.Items))
-
 		statsCh <- stats
 	}
 
 	// лог общий итог
-	sw.log.Printf("[INFO] totals: received_containers=%d received_items=%d db_errors=%d",
-		stats.Containers, stats.Items, stats.Errs)
+	sw.log.Printf("[INFO] totals: received_containers=%d received_items=%d inserted=%d updated=%d skipped=%d db_errors=%d spooled=%d replayed=%d",
+		stats.Containers, stats.Items, stats.Inserted, stats.Updated, stats.Skipped, stats.Errs, stats.Spooled, stats.Replayed)
+	sw.log.Printf("[INFO] batch totals: batches=%d by_size=%d by_time=%d deduped=%d max_items=%d blocked=%s written=%s",
+		stats.Batch.Batches, stats.Batch.BySize, stats.Batch.ByTime, stats.Batch.Deduped,
+		stats.Batch.MaxItems, stats.Batch.Blocked, stats.Batch.Written)
+
+	feeds := make([]string, 0, len(stats.Feeds))
+	for url := range stats.Feeds {
+		feeds = append(feeds, url)
+	}
+	sort.Strings(feeds)
+	for _, url := range feeds {
+		f := stats.Feeds[url]
+		sw.log.Printf("[INFO] feed totals: feed=%s received_items=%d inserted=%d updated=%d skipped=%d db_errors=%d",
+			url, f.Items, f.Inserted, f.Updated, f.Skipped, f.Errs)
+	}
 
 	return stats, nil
 }
 
+// received учитывает полученные в пачке контейнеры
+// и новости, а также повторы внутри пачки
+func (sw *StreamWriter) received(stats *Stats, b *batch) {
+	stats.Containers += b.containers
+	for url, n := range b.received {
+		stats.Items += n
+		if url != "" {
+			f := stats.Feeds[url]
+			f.Items += n
+			stats.Feeds[url] = f
+		}
+	}
+	stats.count(b.dups)
+
+	stats.Batch.Batches++
+	if b.bySize {
+		stats.Batch.BySize++
+	}
+	if b.byTime {
+		stats.Batch.ByTime++
+	}
+	stats.Batch.Deduped += uint(b.dups.Skipped)
+	if n := uint(len(b.items)); n > stats.Batch.MaxItems {
+		stats.Batch.MaxItems = n
+	}
+}
+
+// store пишет пачку в БД, а если это не удается - в спул.
+// Пока в спуле есть пачки, новые пишутся за ними, чтобы
+// новости попадали в БД в порядке получения.
+func (sw *StreamWriter) store(ctx context.Context, items []item, stats *Stats) error {
+	if sw.spool != nil && !sw.spool.Empty() {
+		sw.replay(ctx, stats)
+	}
+
+	var err error
+	if sw.spool == nil || sw.spool.Empty() {
+		if err = sw.add(ctx, items, stats); err == nil || sw.spool == nil {
+			return err
+		}
+	}
+
+	if err := sw.spool.Append(container{Items: items}); err != nil {
+		sw.log.Printf("[ERROR] items=%d spool_error=%v", len(items), err)
+		return err
+	}
+	stats.Spooled++
+
+	return nil
+}
+
+// add пишет пачку в БД и учитывает итоги в статистике.
+func (sw *StreamWriter) add(ctx context.Context, items []item, stats *Stats) error {
+	dbctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	res, err := sw.storage.AddItems(dbctx, items)
+	stats.Batch.Written += time.Since(start)
+
+	if err != nil {
+		sw.log.Printf("[ERROR] items=%d db_error=%v", len(items), err) // логгируем ошибку
+		stats.Errs++
+
+		seen := make(map[string]bool)
+		for i := range items {
+			url := items[i].Source.FeedURL
+			if url != "" && !seen[url] {
+				seen[url] = true
+				f := stats.Feeds[url]
+				f.Errs++
+				stats.Feeds[url] = f
+			}
+		}
+		return err
+	}
+
+	stats.count(res)
+
+	if len(res.Added) > 0 {
+		for _, n := range sw.notify {
+			n.Notify(res.Added)
+		}
+	}
+
+	return nil
+}
+
+// replay дописывает в БД пачки из спула,
+// пока БД их принимает.
+func (sw *StreamWriter) replay(ctx context.Context, stats *Stats) {
+	n, err := sw.spool.Replay(func(c container) error {
+		return sw.add(ctx, withSource(c), stats)
+	})
+	stats.Replayed += uint(n)
+
+	if err != nil {
+		sw.log.Printf("[ERROR] spool replay: replayed_batches=%d spool_bytes=%d corrupted=%d error=%v",
+			n, sw.spool.Size(), sw.spool.Corrupted(), err)
+		return
+	}
+	if n > 0 {
+		sw.log.Printf("[INFO] spool replay: replayed_batches=%d spool_bytes=%d corrupted=%d",
+			n, sw.spool.Size(), sw.spool.Corrupted())
+	}
+}
+
 func (sw *StreamWriter) logDebug(in <-chan Stats, cycle int) {
 
 	logcycle := cycle
@@ -96,8 +350,8 @@ func (sw *StreamWriter) logDebug(in <-chan Stats, cycle int) {
 		for s := range in {
 
 			if logcycle <= 0 {
-				sw.log.Printf("[DEBUG] running totals: received_containers=%d received_items=%d db_errors=%d",
-					s.Containers, s.Items, s.Errs)
+				sw.log.Printf("[DEBUG] running totals: received_containers=%d received_items=%d inserted=%d updated=%d skipped=%d db_errors=%d batches=%d",
+					s.Containers, s.Items, s.Inserted, s.Updated, s.Skipped, s.Errs, s.Batch.Batches)
 			} else {
 				logcycle--
 			}
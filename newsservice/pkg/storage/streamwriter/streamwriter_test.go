@@ -2,20 +2,24 @@ package streamwriter
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 
 	"testing"
 	"time"
 
+	"github.com/rtemka/agg/news/pkg/storage"
 	"github.com/rtemka/agg/news/pkg/storage/memdb"
+	"github.com/rtemka/agg/news/pkg/storage/spool"
 )
 
 func TestStreamWriter_WriteToStorage(t *testing.T) {
 
 	sw := NewStreamWriter(log.New(io.Discard, "", 0), memdb.New())
 
-	cont := container{Items: []item{
+	cont := container{Source: storage.Source{FeedURL: "https://test.com/rss"}, Items: []item{
 		{
 			Id:          1,
 			PubDate:     5555555,
@@ -66,4 +70,210 @@ func TestStreamWriter_WriteToStorage(t *testing.T) {
 		t.Errorf("StreamWriter.WriteToStorage() got items = %d, want = %d",
 			stats.Items, want*2)
 	}
+
+	// обе новости одинаковые, поэтому добавляется только первая
+	if stats.Inserted != 1 || stats.Updated != 0 || stats.Skipped != want*2-1 {
+		t.Errorf("StreamWriter.WriteToStorage() got inserted = %d, updated = %d, skipped = %d, want = %d, %d, %d",
+			stats.Inserted, stats.Updated, stats.Skipped, 1, 0, want*2-1)
+	}
+
+	feed := FeedStats{Items: want * 2, Inserted: 1, Skipped: want*2 - 1}
+	if got := stats.Feeds[cont.Source.FeedURL]; len(stats.Feeds) != 1 || got != feed {
+		t.Errorf("StreamWriter.WriteToStorage() got feeds = %+v, want = %+v", stats.Feeds, feed)
+	}
+}
+
+// flakyDB - хранилище, которое отказывает первые fails раз.
+type flakyDB struct {
+	*memdb.MemDB
+	fails int
+}
+
+func (db *flakyDB) AddItems(ctx context.Context, items []item) (storage.AddResult, error) {
+	if db.fails > 0 {
+		db.fails--
+		return storage.AddResult{}, errors.New("db is down")
+	}
+	return db.MemDB.AddItems(ctx, items)
+}
+
+func TestStreamWriter_WriteToStorage_spool(t *testing.T) {
+	sp, err := spool.Open(t.TempDir(), spool.Options{})
+	if err != nil {
+		t.Fatalf("spool.Open() error = %v", err)
+	}
+	defer sp.Close()
+
+	// первый контейнер и попытки дописать спул перед вторым
+	// и третьим не удаются, перед четвертым БД снова доступна
+	db := &flakyDB{MemDB: memdb.New(), fails: 3}
+	// каждый контейнер - отдельная пачка
+	sw := NewStreamWriter(log.New(io.Discard, "", 0), db).WithSpool(sp).Batching(1, time.Second)
+
+	cont := func(i int) container {
+		return container{Items: []item{{Title: fmt.Sprintf("news %d", i), PubDate: int64(i),
+			Link: fmt.Sprintf("https://test.com/%d", i)}}}
+	}
+
+	// без спула первый контейнер был бы потерян
+	ch := make(chan container, 4)
+	for i := 0; i < 4; i++ {
+		ch <- cont(i)
+	}
+	close(ch)
+
+	stats, err := sw.WriteToStorage(context.Background(), ch)
+
+	if err != nil {
+		t.Fatalf("StreamWriter.WriteToStorage() error = %v", err)
+	}
+	if stats.Spooled != 3 || stats.Replayed != 3 || stats.Inserted != 4 {
+		t.Fatalf("StreamWriter.WriteToStorage() got spooled = %d, replayed = %d, inserted = %d, want = 3, 3, 4",
+			stats.Spooled, stats.Replayed, stats.Inserted)
+	}
+	if !sp.Empty() {
+		t.Fatalf("spool is not empty")
+	}
+
+	// новости записаны в порядке получения
+	for i := 0; i < 4; i++ {
+		got, err := db.Item(context.Background(), int64(i+1))
+		if err != nil {
+			t.Fatalf("Item() error = %v", err)
+		}
+		if want := cont(i).Items[0].Title; got.Title != want {
+			t.Fatalf("Item() got = %q, want = %q", got.Title, want)
+		}
+	}
+}
+
+// slowDB - хранилище, которое пишет медленно.
+type slowDB struct {
+	*memdb.MemDB
+	delay time.Duration
+}
+
+func (db *slowDB) AddItems(ctx context.Context, items []item) (storage.AddResult, error) {
+	time.Sleep(db.delay)
+	return db.MemDB.AddItems(ctx, items)
+}
+
+func TestStreamWriter_WriteToStorage_batch_size(t *testing.T) {
+	db := &slowDB{MemDB: memdb.New(), delay: 20 * time.Millisecond}
+	sw := NewStreamWriter(log.New(io.Discard, "", 0), db).Batching(2, time.Hour)
+
+	src := storage.Source{FeedURL: "https://test.com/rss"}
+	ch := make(chan container)
+	go func() {
+		defer close(ch)
+		for i := 0; i < 8; i++ {
+			ch <- container{Source: src, Items: []item{
+				{Title: fmt.Sprintf("news %d", i), Link: fmt.Sprintf("https://test.com/%d", i)},
+				// повтор ссылки внутри пачки
+				{Title: fmt.Sprintf("news %d", i), Link: fmt.Sprintf("https://test.com/%d", i)},
+			}}
+		}
+	}()
+
+	stats, err := sw.WriteToStorage(context.Background(), ch)
+	if err != nil {
+		t.Fatalf("StreamWriter.WriteToStorage() error = %v", err)
+	}
+
+	b := stats.Batch
+	// по новости из двух контейнеров в пачке
+	if b.Batches != 4 || b.BySize != 4 || b.ByTime != 0 || b.MaxItems != 2 || b.Deduped != 8 {
+		t.Errorf("StreamWriter.WriteToStorage() got batch stats = %+v, want 4 batches by size, 8 deduped", b)
+	}
+	// сбор пачек ждал медленную БД
+	if b.Blocked <= 0 || b.Written <= 0 {
+		t.Errorf("StreamWriter.WriteToStorage() got blocked = %s, written = %s, want > 0", b.Blocked, b.Written)
+	}
+	if stats.Inserted != 8 || stats.Skipped != 8 {
+		t.Errorf("StreamWriter.WriteToStorage() got inserted = %d, skipped = %d, want = 8, 8",
+			stats.Inserted, stats.Skipped)
+	}
+	feed := FeedStats{Items: 16, Inserted: 8, Skipped: 8}
+	if got := stats.Feeds[src.FeedURL]; got != feed {
+		t.Errorf("StreamWriter.WriteToStorage() got feed = %+v, want = %+v", got, feed)
+	}
+}
+
+func TestStreamWriter_WriteToStorage_batch_time(t *testing.T) {
+	db := memdb.New()
+	sw := NewStreamWriter(log.New(io.Discard, "", 0), db).Batching(100, 10*time.Millisecond)
+
+	ch := make(chan container)
+	type result struct {
+		stats Stats
+		err   error
+	}
+	res := make(chan result, 1)
+	go func() {
+		stats, err := sw.WriteToStorage(context.Background(), ch)
+		res <- result{stats, err}
+	}()
+
+	ch <- container{Items: []item{{Title: "news", Link: "https://test.com/1"}}}
+
+	// пачка не заполнена, но записывается по времени, не дожидаясь
+	// закрытия канала
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		n, err := db.CountItems(context.Background(), storage.Filter{})
+		if err != nil {
+			t.Fatalf("CountItems() error = %v", err)
+		}
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("batch was not written by time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	close(ch)
+
+	r := <-res
+	if r.err != nil {
+		t.Fatalf("StreamWriter.WriteToStorage() error = %v", r.err)
+	}
+	if r.stats.Batch.Batches != 1 || r.stats.Batch.ByTime != 1 || r.stats.Inserted != 1 {
+		t.Errorf("StreamWriter.WriteToStorage() got stats = %+v, want 1 batch by time", r.stats)
+	}
+}
+
+// notifier запоминает переданные новости.
+type notifier struct {
+	items []item
+}
+
+func (n *notifier) Notify(items []item) {
+	n.items = append(n.items, items...)
+}
+
+func TestStreamWriter_WriteToStorage_notify(t *testing.T) {
+	db := memdb.New()
+	if _, err := db.AddItems(context.Background(), []item{{Title: "old", Link: "https://test.com/0"}}); err != nil {
+		t.Fatalf("AddItems() error = %v", err)
+	}
+
+	n := &notifier{}
+	sw := NewStreamWriter(log.New(io.Discard, "", 0), db).WithNotifier(n)
+
+	ch := make(chan container, 1)
+	ch <- container{Items: []item{
+		{Title: "old", Link: "https://test.com/0"},
+		{Title: "new", Link: "https://test.com/1"},
+	}}
+	close(ch)
+
+	if _, err := sw.WriteToStorage(context.Background(), ch); err != nil {
+		t.Fatalf("StreamWriter.WriteToStorage() error = %v", err)
+	}
+
+	// уже известная новость не передается
+	if len(n.items) != 1 || n.items[0].Id != 2 || n.items[0].Title != "new" {
+		t.Fatalf("Notify() got = %+v, want only new item with id 2", n.items)
+	}
 }
@@ -0,0 +1,393 @@
+// пакет webhook рассылает подписчикам новые новости.
+//
+// Новости, добавленные в БД, передаются диспетчеру через Notify,
+// каждой подписке, под фильтр которой подошла хоть одна новость,
+// отправляется POST-запрос с этими новостями в JSON. Фильтры всех
+// подписок сверяются с пачкой новостей в БД тем же поиском, что и
+// в /news (см. storage.Storage.MatchFilters). Очередь новостей не
+// ограничена (см. пакет idqueue): пачки не теряются, а если подписки
+// не удалось сопоставить из-за ошибки БД, то это повторяется. Тело запроса
+// подписывается HMAC-SHA256 секретом подписки (см. Sign). Неудачная
+// доставка повторяется с растущей паузой, каждая попытка
+// записывается в журнал доставки в БД.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rtemka/agg/news/pkg/idqueue"
+	"github.com/rtemka/agg/news/pkg/storage"
+)
+
+// Заголовки запроса с новостями.
+const (
+	EventHeader     = "X-News-Event"     // id события, общий для всех попыток.
+	TimestampHeader = "X-News-Timestamp" // время отправки в UNIX формате.
+	// SignatureHeader - подпись "sha256=<hex>", заголовок
+	// отсутствует, если у подписки нет секрета.
+	SignatureHeader = "X-News-Signature"
+)
+
+// Повторы доставки по умолчанию.
+const (
+	Attempts = 6               // сколько всего попыток доставки.
+	Backoff  = 2 * time.Second // пауза перед первым повтором, дальше удваивается.
+)
+
+// maxBackoff - максимальная пауза между попытками.
+const maxBackoff = 5 * time.Minute
+
+// deliveryTimeout - сколько ждать ответа подписчика.
+const deliveryTimeout = 10 * time.Second
+
+// dbTimeout - сколько ждать записи в журнал доставки.
+const dbTimeout = 5 * time.Second
+
+// batchSize - сколько новостей из очереди сопоставляется за раз.
+const batchSize = 1000
+
+// retryDelay - пауза перед повтором сопоставления после ошибки БД.
+const retryDelay = 5 * time.Second
+
+// maxInFlight - сколько доставок может идти одновременно.
+const maxInFlight = 32
+
+// Payload - тело запроса с новостями.
+type Payload struct {
+	Event   string         `json:"event"`   // id события.
+	Webhook int64          `json:"webhook"` // id подписки.
+	Items   []storage.Item `json:"items"`
+}
+
+// Dispatcher рассылает подписчикам новые новости.
+type Dispatcher struct {
+	log      *log.Logger
+	news     storage.Storage      // новости, с которыми сверяются подписки.
+	webhooks storage.WebhookStore // подписки и журнал доставки.
+	client   *http.Client
+	queue    *idqueue.Queue
+	retry    time.Duration // пауза перед повтором после ошибки БД.
+	attempts int
+	backoff  time.Duration
+	// когда установлен в true, логгирует каждую попытку доставки,
+	// по-умолчанию false
+	debugMode bool
+}
+
+// New возвращает новый объект *Dispatcher.
+func New(log *log.Logger, news storage.Storage, webhooks storage.WebhookStore) *Dispatcher {
+	return &Dispatcher{
+		log:      log,
+		news:     news,
+		webhooks: webhooks,
+		client:   &http.Client{Timeout: deliveryTimeout},
+		queue:    idqueue.New(),
+		retry:    retryDelay,
+		attempts: Attempts,
+		backoff:  Backoff,
+	}
+}
+
+// DebugMode переключает debug режим у *Dispatcher
+func (d *Dispatcher) DebugMode(on bool) *Dispatcher {
+	d.debugMode = on
+	return d
+}
+
+// Retry задает количество попыток доставки и паузу перед первым
+// повтором, значения меньше или равные 0 не меняют настройку.
+func (d *Dispatcher) Retry(attempts int, backoff time.Duration) *Dispatcher {
+	if attempts > 0 {
+		d.attempts = attempts
+	}
+	if backoff > 0 {
+		d.backoff = backoff
+	}
+	return d
+}
+
+// Notify ставит новости в очередь рассылки, не блокируя вызывающего.
+func (d *Dispatcher) Notify(items []storage.Item) {
+	d.queue.Push(items)
+}
+
+// Run рассылает новости из очереди, пока не закрыт контекст.
+// Пока идут maxInFlight доставок, новые новости ждут в очереди.
+// Если БД недоступна, новости возвращаются в очередь и сопоставляются
+// повторно после паузы. Перед выходом дожидается начатых доставок,
+// их повторы прерываются.
+func (d *Dispatcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	inFlight := make(chan struct{}, maxInFlight)
+
+	for {
+		ids, ok := d.queue.Next(ctx, batchSize)
+		if !ok {
+			return
+		}
+
+		batches, err := d.match(ctx, ids)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			d.queue.Requeue(ids)
+			d.log.Printf("[ERROR] webhook: items=%d queued=%d db_error=%v, retry in %s",
+				len(ids), d.queue.Len(), err, d.retry)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(d.retry):
+			}
+			continue
+		}
+
+		for _, b := range batches {
+			select {
+			case <-ctx.Done():
+				return
+			case inFlight <- struct{}{}:
+			}
+
+			wg.Add(1)
+			go func(b batch) {
+				defer wg.Done()
+				defer func() { <-inFlight }()
+				d.deliver(ctx, b.webhook, b.items)
+			}(b)
+		}
+	}
+}
+
+// batch - новости для отправки одной подписке.
+type batch struct {
+	webhook storage.Webhook
+	items   []storage.Item
+}
+
+// match сопоставляет новости ids с фильтрами всех подписок и
+// возвращает новости для каждой подписки, под которую они подошли.
+func (d *Dispatcher) match(ctx context.Context, ids []int64) ([]batch, error) {
+	webhooks, err := d.webhooks.Webhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filters := make([]storage.Filter, 0, len(webhooks))
+	hooks := make([]storage.Webhook, 0, len(webhooks))
+	for _, wh := range webhooks {
+		f := wh.Filter()
+		// фразы проверяет API при добавлении подписки
+		if _, err := f.Query(); err != nil {
+			d.log.Printf("[ERROR] webhook: id=%d filter_error=%v", wh.Id, err)
+			continue
+		}
+		filters = append(filters, f)
+		hooks = append(hooks, wh)
+	}
+	if len(filters) == 0 {
+		return nil, nil
+	}
+
+	matched, err := d.news.MatchFilters(ctx, ids, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	// новости всех подписок читаются одним запросом
+	var found []int64
+	seen := make(map[int64]bool)
+	for _, m := range matched {
+		for _, id := range m {
+			if !seen[id] {
+				seen[id] = true
+				found = append(found, id)
+			}
+		}
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+	items, err := d.news.Items(ctx, storage.Filter{IDs: found})
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]storage.Item, len(items))
+	for _, it := range items {
+		byID[it.Id] = it
+	}
+
+	var batches []batch
+	for i, m := range matched {
+		b := batch{webhook: hooks[i]}
+		for _, id := range m {
+			// новость могли удалить после сопоставления
+			if it, ok := byID[id]; ok {
+				b.items = append(b.items, it)
+			}
+		}
+		if len(b.items) > 0 {
+			batches = append(batches, b)
+		}
+	}
+
+	return batches, nil
+}
+
+// deliver отправляет новости подписчику, повторяя неудачные попытки.
+// Ответ 2xx - успех, 4xx (кроме 408 и 429) не повторяется.
+func (d *Dispatcher) deliver(ctx context.Context, wh storage.Webhook, items []storage.Item) {
+	event, err := newEvent()
+	if err != nil {
+		d.log.Printf("[ERROR] webhook: id=%d error=%v", wh.Id, err)
+		return
+	}
+
+	body, err := json.Marshal(Payload{Event: event, Webhook: wh.Id, Items: items})
+	if err != nil {
+		d.log.Printf("[ERROR] webhook: id=%d error=%v", wh.Id, err)
+		return
+	}
+
+	ids := make([]int64, len(items))
+	for i := range items {
+		ids[i] = items[i].Id
+	}
+
+	backoff := d.backoff
+	for attempt := 1; ; attempt++ {
+		rec := d.post(ctx, wh, event, body)
+		rec.WebhookID, rec.Event, rec.Items, rec.Attempt = wh.Id, event, ids, attempt
+
+		// попытка записывается в журнал и при остановке рассылки
+		dbctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+		if err := d.webhooks.AddDelivery(dbctx, rec); err != nil {
+			d.log.Printf("[ERROR] webhook: id=%d event=%s db_error=%v", wh.Id, event, err)
+		}
+		cancel()
+		if d.debugMode {
+			d.log.Printf("[DEBUG] webhook: id=%d event=%s attempt=%d items=%d status=%d error=%q",
+				wh.Id, event, attempt, len(items), rec.Status, rec.Error)
+		}
+
+		if rec.Error == "" {
+			return
+		}
+		if !retryable(rec.Status) || attempt >= d.attempts {
+			d.log.Printf("[ERROR] webhook: id=%d event=%s attempts=%d items=%d undelivered: %s",
+				wh.Id, event, attempt, len(items), rec.Error)
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// post выполняет одну попытку доставки и возвращает
+// запись журнала с кодом ответа, ошибкой и временем.
+func (d *Dispatcher) post(ctx context.Context, wh storage.Webhook, event string, body []byte) (rec storage.Delivery) {
+	start := time.Now()
+	rec.Time = start.Unix()
+
+	defer func() { rec.Duration = time.Since(start).Milliseconds() }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		rec.Error = err.Error()
+		return rec
+	}
+
+	ts := strconv.FormatInt(start.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(TimestampHeader, ts)
+	if wh.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(wh.Secret, ts, body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		rec.Error = err.Error()
+		return rec
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+
+	rec.Status = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		rec.Error = fmt.Sprintf("unexpected status %s", resp.Status)
+	}
+
+	return rec
+}
+
+// retryable сообщает, стоит ли повторять попытку
+// после ответа status (0 - ответа не было).
+func retryable(status int) bool {
+	switch {
+	case status == http.StatusRequestTimeout, status == http.StatusTooManyRequests:
+		return true
+	case status >= 400 && status < 500:
+		return false
+	}
+	return true
+}
+
+// Sign возвращает подпись тела запроса body, отправленного во время
+// timestamp (значение заголовка TimestampHeader): "sha256=" и
+// HMAC-SHA256 строки "<timestamp>.<body>" с ключом secret в hex.
+// Подписчик проверяет подпись, вычисляя ее так же.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify сообщает, что подпись signature
+// соответствует телу запроса и времени отправки.
+func Verify(secret, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// NewSecret возвращает случайный секрет для подписи.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// newEvent возвращает случайный id события.
+func newEvent() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
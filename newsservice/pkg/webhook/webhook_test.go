@@ -0,0 +1,284 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rtemka/agg/news/pkg/storage"
+	"github.com/rtemka/agg/news/pkg/storage/memdb"
+)
+
+var (
+	ria = storage.Source{Name: "РИА Новости", FeedURL: "https://ria.ru/export/rss2/archive/index.xml"}
+	kom = storage.Source{Name: "Коммерсантъ", FeedURL: "https://www.kommersant.ru/RSS/news.xml"}
+)
+
+// testItems добавляет в БД новости и возвращает их с id.
+func testItems(t *testing.T, db *memdb.MemDB) []storage.Item {
+	res, err := db.AddItems(context.Background(), []storage.Item{
+		{Title: "Вышел go 1.19", Description: "Новая версия языка", Link: "https://test.com/1", Source: ria},
+		{Title: "Курс рубля", Description: "Биржевые новости", Link: "https://test.com/2", Source: ria},
+		{Title: "Go в банках", Description: "Язык go набирает популярность", Link: "https://test.com/3", Source: kom},
+	})
+	if err != nil {
+		t.Fatalf("AddItems() error = %v", err)
+	}
+	return res.Added
+}
+
+func TestDispatcher_match(t *testing.T) {
+	db := memdb.New()
+	items := testItems(t, db)
+
+	webhooks := []storage.Webhook{
+		{URL: "https://hooks.test.com/all"},
+		{URL: "https://hooks.test.com/terms", Terms: []string{"go"}},
+		{URL: "https://hooks.test.com/sources", Sources: []int64{items[0].Source.Id}},
+		{URL: "https://hooks.test.com/terms_and_sources", Terms: []string{"go"}, Sources: []int64{items[2].Source.Id}},
+		{URL: "https://hooks.test.com/none", Terms: []string{"погода"}},
+		{URL: "https://hooks.test.com/bad", Terms: []string{"-go"}}, // ошибка, пропускается
+		{URL: "https://hooks.test.com/prefix", Terms: []string{"язык*"}},
+	}
+	for _, wh := range webhooks {
+		if _, err := db.AddWebhook(context.Background(), wh); err != nil {
+			t.Fatalf("AddWebhook() error = %v", err)
+		}
+	}
+
+	d := New(log.New(io.Discard, "", 0), db, db)
+	batches, err := d.match(context.Background(), []int64{items[0].Id, items[1].Id, items[2].Id})
+	if err != nil {
+		t.Fatalf("match() error = %v", err)
+	}
+
+	want := map[string][]int64{
+		"https://hooks.test.com/all":               {1, 2, 3},
+		"https://hooks.test.com/terms":             {1, 3},
+		"https://hooks.test.com/sources":           {1, 2},
+		"https://hooks.test.com/terms_and_sources": {3},
+		"https://hooks.test.com/prefix":            {1, 3},
+	}
+	got := make(map[string][]int64)
+	for _, b := range batches {
+		for _, it := range b.items {
+			if it.Title == "" || it.Link == "" {
+				t.Fatalf("match() got item without fields = %+v", it)
+			}
+			got[b.webhook.URL] = append(got[b.webhook.URL], it.Id)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("match() got = %v, want = %v", got, want)
+	}
+}
+
+func TestDispatcher_Run(t *testing.T) {
+	db := memdb.New()
+	items := testItems(t, db)
+
+	const secret = "secret"
+	var calls atomic.Int32
+	got := make(chan Payload, 1)
+
+	// подписчик дважды отвечает ошибкой, на третий раз принимает
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !Verify(secret, r.Header.Get(TimestampHeader), body, r.Header.Get(SignatureHeader)) {
+			t.Errorf("bad signature %q", r.Header.Get(SignatureHeader))
+		}
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var p Payload
+		if err := json.Unmarshal(body, &p); err != nil {
+			t.Errorf("Unmarshal() error = %v", err)
+		}
+		if p.Event != r.Header.Get(EventHeader) {
+			t.Errorf("got event = %q, header = %q", p.Event, r.Header.Get(EventHeader))
+		}
+		got <- p
+	}))
+	defer srv.Close()
+
+	id, err := db.AddWebhook(context.Background(), storage.Webhook{URL: srv.URL, Secret: secret, Terms: []string{"go"}})
+	if err != nil {
+		t.Fatalf("AddWebhook() error = %v", err)
+	}
+
+	d := New(log.New(io.Discard, "", 0), db, db).Retry(5, time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+
+	d.Notify(items)
+
+	var p Payload
+	select {
+	case p = <-got:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("webhook was not delivered")
+	}
+
+	// в журнале все три попытки одного события
+	var ds []storage.Delivery
+	deadline := time.Now().Add(5 * time.Second)
+	for len(ds) < 3 {
+		if ds, err = db.Deliveries(context.Background(), id, 10); err != nil {
+			t.Fatalf("Deliveries() error = %v", err)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Deliveries() got = %+v, want 3 attempts", ds)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if p.Webhook != id || len(p.Items) != 2 || p.Items[0].Id != 1 || p.Items[1].Id != 3 {
+		t.Fatalf("got payload = %+v, want items 1 and 3", p)
+	}
+
+	for i, d := range ds {
+		if d.Attempt != 3-i || d.Event != p.Event || len(d.Items) != 2 {
+			t.Fatalf("Deliveries() got = %+v", d)
+		}
+	}
+	if ds[0].Status != http.StatusOK || ds[0].Error != "" || ds[1].Status != http.StatusServiceUnavailable || ds[1].Error == "" {
+		t.Fatalf("Deliveries() got = %+v", ds)
+	}
+}
+
+func TestDispatcher_Run_client_error(t *testing.T) {
+	db := memdb.New()
+	items := testItems(t, db)
+
+	// ошибка подписчика 4xx не повторяется
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer srv.Close()
+
+	id, err := db.AddWebhook(context.Background(), storage.Webhook{URL: srv.URL})
+	if err != nil {
+		t.Fatalf("AddWebhook() error = %v", err)
+	}
+
+	d := New(log.New(io.Discard, "", 0), db, db).Retry(5, time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+
+	d.Notify(items)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ds, err := db.Deliveries(context.Background(), id, 10)
+		if err != nil {
+			t.Fatalf("Deliveries() error = %v", err)
+		}
+		if len(ds) > 0 {
+			if ds[0].Status != http.StatusGone || ds[0].Error == "" || len(ds[0].Items) != 3 {
+				t.Fatalf("Deliveries() got = %+v", ds[0])
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("delivery was not logged")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("got calls = %d, want = 1", n)
+	}
+}
+
+// flakyDB - БД, у которой первые fails вызовов MatchFilters завершаются ошибкой.
+type flakyDB struct {
+	*memdb.MemDB
+	mu    sync.Mutex
+	fails int
+}
+
+func (db *flakyDB) MatchFilters(ctx context.Context, ids []int64, filters []storage.Filter) ([][]int64, error) {
+	db.mu.Lock()
+	if db.fails > 0 {
+		db.fails--
+		db.mu.Unlock()
+		return nil, errors.New("db is down")
+	}
+	db.mu.Unlock()
+	return db.MemDB.MatchFilters(ctx, ids, filters)
+}
+
+func TestDispatcher_Run_retry(t *testing.T) {
+	db := &flakyDB{MemDB: memdb.New(), fails: 2}
+
+	var mu sync.Mutex
+	got := make(map[int64]bool)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p Payload
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			t.Errorf("Decode() error = %v", err)
+		}
+		mu.Lock()
+		for _, it := range p.Items {
+			got[it.Id] = true
+		}
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	if _, err := db.AddWebhook(context.Background(), storage.Webhook{URL: srv.URL}); err != nil {
+		t.Fatalf("AddWebhook() error = %v", err)
+	}
+
+	d := New(log.New(io.Discard, "", 0), db, db)
+	d.retry = time.Millisecond
+	// пачек больше, чем влезало в прежнюю очередь, ни одна не теряется
+	const n = 150
+	for i := 0; i < n; i++ {
+		res, err := db.AddItems(context.Background(), []storage.Item{{Title: "go", Link: fmt.Sprintf("https://test.com/%d", i), PubDate: int64(i + 1)}})
+		if err != nil {
+			t.Fatalf("AddItems() error = %v", err)
+		}
+		d.Notify(res.Added)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		delivered := len(got)
+		mu.Unlock()
+		if delivered == n {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got delivered items = %d, want = %d", delivered, n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
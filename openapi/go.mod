@@ -0,0 +1,5 @@
+module github.com/rtemka/agg/openapi
+
+go 1.19
+
+require github.com/gorilla/mux v1.8.0
@@ -0,0 +1,373 @@
+// пакет openapi - описание API сервисов в формате OpenAPI 3 и проверка
+// запросов по нему.
+//
+// Поддерживается то подмножество спецификации, которое нужно API сервиса:
+// операции с параметрами пути, запроса и заголовков, JSON-тело запроса,
+// схемы и параметры с $ref на components. Ответы только описываются.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Spec - спецификация OpenAPI 3.
+type Spec struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+
+	raw []byte // исходный документ, отдается клиентам как есть.
+}
+
+// Info - сведения об API.
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem - операции пути, ключ - метод в нижнем регистре.
+type PathItem map[string]*Operation
+
+// Operation - операция (метод и путь).
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter - параметр операции.
+type Parameter struct {
+	Ref         string  `json:"$ref,omitempty"`
+	Name        string  `json:"name"`
+	In          string  `json:"in"` // path, query или header.
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody - тело запроса.
+type RequestBody struct {
+	Description string                `json:"description,omitempty"`
+	Required    bool                  `json:"required,omitempty"`
+	Content     map[string]*MediaType `json:"content"`
+}
+
+// Response - ответ операции.
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType - содержимое тела запроса или ответа.
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// Components - общие схемы и параметры, на которые ссылаются через $ref.
+type Components struct {
+	Schemas    map[string]*Schema    `json:"schemas,omitempty"`
+	Parameters map[string]*Parameter `json:"parameters,omitempty"`
+}
+
+// Schema - схема значения.
+type Schema struct {
+	Ref         string             `json:"$ref,omitempty"`
+	Type        string             `json:"type,omitempty"` // object, array, string, integer, number или boolean.
+	Format      string             `json:"format,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Nullable    bool               `json:"nullable,omitempty"`
+	Enum        []any              `json:"enum,omitempty"`
+	Minimum     *float64           `json:"minimum,omitempty"`
+	Maximum     *float64           `json:"maximum,omitempty"`
+	MinLength   *int               `json:"minLength,omitempty"`
+	MaxLength   *int               `json:"maxLength,omitempty"`
+	Pattern     string             `json:"pattern,omitempty"`
+	MinItems    *int               `json:"minItems,omitempty"`
+	MaxItems    *int               `json:"maxItems,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	// AdditionalProperties - разрешены ли поля объекта, которых нет в
+	// Properties, поддерживается только логическое значение.
+	AdditionalProperties *bool `json:"additionalProperties,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// ссылки на components.
+const (
+	schemaRef    = "#/components/schemas/"
+	parameterRef = "#/components/parameters/"
+)
+
+// pathVar - переменная в шаблоне пути.
+var pathVar = regexp.MustCompile(`\{([^}/]+)\}`)
+
+// Load разбирает спецификацию в JSON и разрешает ссылки $ref.
+func Load(b []byte) (*Spec, error) {
+	var s Spec
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("openapi: %w", err)
+	}
+	if !strings.HasPrefix(s.OpenAPI, "3.") {
+		return nil, fmt.Errorf("openapi: unsupported version %q", s.OpenAPI)
+	}
+	s.raw = b
+
+	for name, sc := range s.Components.Schemas {
+		if err := s.resolve(sc, map[*Schema]bool{}); err != nil {
+			return nil, fmt.Errorf("openapi: schema %q: %w", name, err)
+		}
+	}
+
+	for path, item := range s.Paths {
+		for method, op := range item {
+			if op == nil {
+				return nil, fmt.Errorf("openapi: %s %s: empty operation", method, path)
+			}
+			if err := s.resolveOperation(path, op); err != nil {
+				return nil, fmt.Errorf("openapi: %s %s: %w", strings.ToUpper(method), path, err)
+			}
+		}
+	}
+
+	return &s, nil
+}
+
+// MustLoad - как Load, но паникует при ошибке,
+// для спецификаций, встроенных в программу.
+func MustLoad(b []byte) *Spec {
+	s, err := Load(b)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// resolveOperation разрешает ссылки в параметрах и теле операции и проверяет,
+// что у каждой переменной пути есть обязательный параметр.
+func (s *Spec) resolveOperation(path string, op *Operation) error {
+	for i, p := range op.Parameters {
+		if p.Ref != "" {
+			ref, ok := s.Components.Parameters[strings.TrimPrefix(p.Ref, parameterRef)]
+			if !ok || !strings.HasPrefix(p.Ref, parameterRef) {
+				return fmt.Errorf("unknown parameter %q", p.Ref)
+			}
+			op.Parameters[i], p = ref, ref
+		}
+		switch p.In {
+		case "path", "query", "header":
+		default:
+			return fmt.Errorf("parameter %q: unsupported location %q", p.Name, p.In)
+		}
+		if p.Schema == nil {
+			return fmt.Errorf("parameter %q: no schema", p.Name)
+		}
+		if err := s.resolve(p.Schema, map[*Schema]bool{}); err != nil {
+			return fmt.Errorf("parameter %q: %w", p.Name, err)
+		}
+	}
+
+	for _, m := range pathVar.FindAllStringSubmatch(path, -1) {
+		if p := op.parameter("path", m[1]); p == nil || !p.Required {
+			return fmt.Errorf("no required path parameter %q", m[1])
+		}
+	}
+
+	if op.RequestBody != nil {
+		if err := s.resolveContent(op.RequestBody.Content); err != nil {
+			return fmt.Errorf("request body: %w", err)
+		}
+	}
+	for code, resp := range op.Responses {
+		if resp == nil {
+			return fmt.Errorf("response %s: empty response", code)
+		}
+		if err := s.resolveContent(resp.Content); err != nil {
+			return fmt.Errorf("response %s: %w", code, err)
+		}
+	}
+
+	return nil
+}
+
+// resolveContent разрешает ссылки в схемах содержимого тела.
+func (s *Spec) resolveContent(content map[string]*MediaType) error {
+	for _, mt := range content {
+		if mt == nil || mt.Schema == nil {
+			continue
+		}
+		if err := s.resolve(mt.Schema, map[*Schema]bool{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve заменяет ссылки $ref в схеме и ее вложенных схемах на схемы
+// из components и компилирует шаблоны строк.
+func (s *Spec) resolve(sc *Schema, seen map[*Schema]bool) error {
+	if seen[sc] {
+		return nil
+	}
+	seen[sc] = true
+
+	if sc.Ref != "" {
+		ref, ok := s.Components.Schemas[strings.TrimPrefix(sc.Ref, schemaRef)]
+		if !ok || !strings.HasPrefix(sc.Ref, schemaRef) {
+			return fmt.Errorf("unknown schema %q", sc.Ref)
+		}
+		if err := s.resolve(ref, seen); err != nil {
+			return err
+		}
+		*sc = *ref
+		return nil
+	}
+
+	if sc.Pattern != "" && sc.pattern == nil {
+		re, err := regexp.Compile(sc.Pattern)
+		if err != nil {
+			return fmt.Errorf("pattern %q: %w", sc.Pattern, err)
+		}
+		sc.pattern = re
+	}
+
+	if sc.Items != nil {
+		if err := s.resolve(sc.Items, seen); err != nil {
+			return err
+		}
+	}
+	for name, p := range sc.Properties {
+		if err := s.resolve(p, seen); err != nil {
+			return fmt.Errorf("property %q: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// Operation возвращает операцию по методу и шаблону пути
+// (например, /news/{id}), nil - операции нет.
+func (s *Spec) Operation(method, path string) *Operation {
+	return s.Paths[path][strings.ToLower(method)]
+}
+
+// parameter возвращает параметр операции по месту и имени.
+func (op *Operation) parameter(in, name string) *Parameter {
+	for _, p := range op.Parameters {
+		if p.In == in && p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// Handler отдает спецификацию в JSON.
+func (s *Spec) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(s.raw)
+	}
+}
+
+// Validator возвращает middleware, которое проверяет запросы по спецификации.
+// Если параметр пути не подходит под схему, то ресурса нет и ответ - 404,
+// при остальных ошибках - 400. Ответ с ошибкой пишет fail.
+// Запросы OPTIONS и запросы к маршрутам, которых нет в спецификации,
+// не проверяются, как и параметры, не описанные в спецификации.
+func (s *Spec) Validator(fail func(w http.ResponseWriter, err error, code int)) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := mux.CurrentRoute(r)
+			if r.Method == http.MethodOptions || route == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			path, err := route.GetPathTemplate()
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			op := s.Operation(r.Method, path)
+			if op == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if err := op.Validate(r, mux.Vars(r)); err != nil {
+				code := http.StatusBadRequest
+				if err.In == "path" {
+					code = http.StatusNotFound
+				}
+				fail(w, err, code)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// CheckRouter сравнивает маршруты router со спецификацией: для каждого
+// маршрута (кроме методов OPTIONS) должна быть операция в спецификации,
+// а для каждой операции - маршрут.
+func (s *Spec) CheckRouter(router *mux.Router) error {
+	routes := make(map[string]bool)
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil // маршрут без пути, например только с префиксом
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return fmt.Errorf("route %s: no methods", path)
+		}
+		for _, m := range methods {
+			if m != http.MethodOptions {
+				routes[m+" "+path] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	ops := make(map[string]bool)
+	for path, item := range s.Paths {
+		for method := range item {
+			ops[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	var diff []string
+	for r := range routes {
+		if !ops[r] {
+			diff = append(diff, "route is not in spec: "+r)
+		}
+	}
+	for op := range ops {
+		if !routes[op] {
+			diff = append(diff, "spec operation has no route: "+op)
+		}
+	}
+	if len(diff) > 0 {
+		sort.Strings(diff)
+		return fmt.Errorf("openapi: routes do not match spec:\n%s", strings.Join(diff, "\n"))
+	}
+
+	return nil
+}
@@ -0,0 +1,189 @@
+package openapi
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+const testSpec = `{
+  "openapi": "3.0.3",
+  "info": {"title": "test", "version": "1"},
+  "paths": {
+    "/items/{id}": {
+      "get": {
+        "operationId": "getItem",
+        "parameters": [
+          {"$ref": "#/components/parameters/id"},
+          {"name": "tag", "in": "query", "schema": {"type": "array", "maxItems": 2, "items": {"type": "string", "pattern": "^[a-z]+$"}}},
+          {"name": "full", "in": "query", "schema": {"type": "boolean"}}
+        ],
+        "responses": {"200": {"description": "ok", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Item"}}}}}
+      }
+    },
+    "/items": {
+      "post": {
+        "operationId": "addItem",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Item"}}}},
+        "responses": {"201": {"description": "created"}}
+      }
+    }
+  },
+  "components": {
+    "parameters": {
+      "id": {"name": "id", "in": "path", "required": true, "schema": {"type": "integer", "minimum": 1}}
+    },
+    "schemas": {
+      "Item": {
+        "type": "object",
+        "required": ["title"],
+        "additionalProperties": false,
+        "properties": {
+          "title": {"type": "string", "minLength": 1, "maxLength": 5},
+          "link": {"type": "string", "format": "uri"},
+          "kind": {"type": "string", "enum": ["a", "b"]},
+          "score": {"type": "number", "maximum": 10, "nullable": true},
+          "tags": {"type": "array", "items": {"$ref": "#/components/schemas/Tag"}}
+        }
+      },
+      "Tag": {"type": "object", "required": ["id"], "properties": {"id": {"type": "integer"}}}
+    }
+  }
+}`
+
+func TestLoad(t *testing.T) {
+	if _, err := Load([]byte(testSpec)); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		old     string
+		new     string
+		wantErr string
+	}{
+		{name: "version", old: `"3.0.3"`, new: `"2.0"`, wantErr: "unsupported version"},
+		{name: "schema_ref", old: `"#/components/schemas/Tag"`, new: `"#/components/schemas/Tags"`, wantErr: "unknown schema"},
+		{name: "parameter_ref", old: `"#/components/parameters/id"`, new: `"#/components/parameters/ID"`, wantErr: "unknown parameter"},
+		{name: "path_parameter", old: `"/items/{id}"`, new: `"/items/{item}"`, wantErr: `no required path parameter "item"`},
+		{name: "pattern", old: `"^[a-z]+$"`, new: `"^[a-z+$"`, wantErr: "pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load([]byte(strings.Replace(testSpec, tt.old, tt.new, 1)))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Load() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSpec_Validator(t *testing.T) {
+	spec := MustLoad([]byte(testSpec))
+
+	var gotBody string
+	r := mux.NewRouter()
+	r.Use(spec.Validator(func(w http.ResponseWriter, err error, code int) {
+		http.Error(w, err.Error(), code)
+	}))
+	r.HandleFunc("/items/{id}", func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodGet)
+	r.HandleFunc("/items", func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+		w.WriteHeader(http.StatusCreated)
+	}).Methods(http.MethodPost)
+	r.HandleFunc("/other", func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodGet)
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		body      string
+		wantCode  int
+		wantError string
+	}{
+		{name: "get", method: http.MethodGet, path: "/items/1?tag=go&tag=db&full=true&other=x", wantCode: http.StatusOK},
+		{name: "path", method: http.MethodGet, path: "/items/abc", wantCode: http.StatusNotFound,
+			wantError: `bad "id" parameter: must be an integer`},
+		{name: "path_minimum", method: http.MethodGet, path: "/items/0", wantCode: http.StatusNotFound,
+			wantError: `bad "id" parameter: must be at least 1`},
+		{name: "query_pattern", method: http.MethodGet, path: "/items/1?tag=Go", wantCode: http.StatusBadRequest,
+			wantError: `bad "tag" parameter: must match ^[a-z]+$`},
+		{name: "query_repeated", method: http.MethodGet, path: "/items/1?tag=a&tag=b&tag=c", wantCode: http.StatusBadRequest,
+			wantError: `bad "tag" parameter: must be given at most 2 times`},
+		{name: "query_boolean", method: http.MethodGet, path: "/items/1?full=yes", wantCode: http.StatusBadRequest,
+			wantError: `bad "full" parameter: must be true or false`},
+		{name: "not_in_spec", method: http.MethodGet, path: "/other?tag=Go", wantCode: http.StatusOK},
+		{name: "body", method: http.MethodPost, path: "/items",
+			body:     `{"title": "новая", "link": "https://test.com", "kind": "a", "score": null, "tags": [{"id": 1}]}`,
+			wantCode: http.StatusCreated},
+		{name: "body_required", method: http.MethodPost, path: "/items", body: `{}`, wantCode: http.StatusBadRequest,
+			wantError: `bad request body: "title": is required`},
+		{name: "body_unknown_field", method: http.MethodPost, path: "/items", body: `{"title": "a", "author": "b"}`,
+			wantCode: http.StatusBadRequest, wantError: `bad request body: "author": unknown field`},
+		{name: "body_max_length", method: http.MethodPost, path: "/items", body: `{"title": "новость!"}`,
+			wantCode: http.StatusBadRequest, wantError: `bad request body: "title": must be at most 5 characters`},
+		{name: "body_uri", method: http.MethodPost, path: "/items", body: `{"title": "a", "link": "test.com"}`,
+			wantCode: http.StatusBadRequest, wantError: `bad request body: "link": must be an absolute URL`},
+		{name: "body_enum", method: http.MethodPost, path: "/items", body: `{"title": "a", "kind": "c"}`,
+			wantCode: http.StatusBadRequest, wantError: `bad request body: "kind": must be one of: 'a', 'b'`},
+		{name: "body_maximum", method: http.MethodPost, path: "/items", body: `{"title": "a", "score": 10.5}`,
+			wantCode: http.StatusBadRequest, wantError: `bad request body: "score": must be at most 10`},
+		{name: "body_nested", method: http.MethodPost, path: "/items", body: `{"title": "a", "tags": [{"id": 1}, {"id": 1.5}]}`,
+			wantCode: http.StatusBadRequest, wantError: `bad request body: "tags[1].id": must be an integer`},
+		{name: "body_type", method: http.MethodPost, path: "/items", body: `[]`,
+			wantCode: http.StatusBadRequest, wantError: `bad request body: must be an object`},
+		{name: "body_trailing", method: http.MethodPost, path: "/items", body: `{"title": "a"} {}`,
+			wantCode: http.StatusBadRequest, wantError: `bad request body: invalid JSON: unexpected data after top-level value`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotBody = ""
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("Validator() got response code = %d, want = %d, body = %s", rr.Code, tt.wantCode, rr.Body)
+			}
+			if got := strings.TrimSpace(rr.Body.String()); tt.wantError != "" && got != tt.wantError {
+				t.Errorf("Validator() got error = %q, want = %q", got, tt.wantError)
+			}
+			// обработчик получает тело запроса целиком
+			if tt.wantCode == http.StatusCreated && gotBody != tt.body {
+				t.Errorf("Validator() handler got body = %q, want = %q", gotBody, tt.body)
+			}
+		})
+	}
+}
+
+func TestSpec_CheckRouter(t *testing.T) {
+	spec := MustLoad([]byte(testSpec))
+	h := func(w http.ResponseWriter, r *http.Request) {}
+
+	r := mux.NewRouter()
+	r.HandleFunc("/items/{id}", h).Methods(http.MethodGet, http.MethodOptions)
+	r.HandleFunc("/items", h).Methods(http.MethodPost)
+	if err := spec.CheckRouter(r); err != nil {
+		t.Fatalf("CheckRouter() error = %v", err)
+	}
+
+	r.HandleFunc("/items/{id}", h).Methods(http.MethodDelete)
+	err := spec.CheckRouter(r)
+	if err == nil || !strings.Contains(err.Error(), "route is not in spec: DELETE /items/{id}") {
+		t.Fatalf("CheckRouter() error = %v, want route is not in spec", err)
+	}
+
+	r = mux.NewRouter()
+	r.HandleFunc("/items", h).Methods(http.MethodPost)
+	err = spec.CheckRouter(r)
+	if err == nil || !strings.Contains(err.Error(), "spec operation has no route: GET /items/{id}") {
+		t.Fatalf("CheckRouter() error = %v, want operation has no route", err)
+	}
+}
@@ -0,0 +1,299 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxBodySize - максимальный размер проверяемого тела запроса.
+const MaxBodySize = 1 << 20
+
+// Error - запрос не соответствует спецификации.
+type Error struct {
+	In     string // path, query, header или body.
+	Name   string // имя параметра или путь к полю тела, например items[0].url.
+	Reason string
+}
+
+func (e *Error) Error() string {
+	if e.In != "body" {
+		return fmt.Sprintf("bad %q parameter: %s", e.Name, e.Reason)
+	}
+	if e.Name == "" {
+		return "bad request body: " + e.Reason
+	}
+	return fmt.Sprintf("bad request body: %q: %s", e.Name, e.Reason)
+}
+
+// Validate проверяет параметры и тело запроса r, vars - переменные пути.
+// Тело запроса читается и заменяется копией, так что обработчик
+// может прочитать его снова.
+func (op *Operation) Validate(r *http.Request, vars map[string]string) *Error {
+	query := r.URL.Query()
+
+	for _, p := range op.Parameters {
+		var values []string
+		switch p.In {
+		case "path":
+			if v, ok := vars[p.Name]; ok {
+				values = []string{v}
+			}
+		case "query":
+			values = query[p.Name]
+		case "header":
+			values = r.Header.Values(p.Name)
+		}
+
+		if len(values) == 0 {
+			if p.Required {
+				return &Error{In: p.In, Name: p.Name, Reason: "is required"}
+			}
+			continue
+		}
+
+		if err := p.validate(values); err != nil {
+			return &Error{In: p.In, Name: p.Name, Reason: err.Error()}
+		}
+	}
+
+	if op.RequestBody != nil {
+		if mt, ok := op.RequestBody.Content["application/json"]; ok {
+			return validateBody(r, op.RequestBody.Required, mt.Schema)
+		}
+	}
+
+	return nil
+}
+
+// validate проверяет значения параметра. Параметр-массив может
+// повторяться в запросе (?source=1&source=2), каждое значение
+// проверяется по схеме элемента массива.
+func (p *Parameter) validate(values []string) error {
+	sc := p.Schema
+	if sc.Type == "array" {
+		if sc.MinItems != nil && len(values) < *sc.MinItems {
+			return fmt.Errorf("must be given at least %d times", *sc.MinItems)
+		}
+		if sc.MaxItems != nil && len(values) > *sc.MaxItems {
+			return fmt.Errorf("must be given at most %d times", *sc.MaxItems)
+		}
+		sc = sc.Items
+	}
+	if sc == nil {
+		return nil
+	}
+
+	for _, s := range values {
+		v, err := sc.parse(s)
+		if err != nil {
+			return err
+		}
+		if _, err := sc.check(v, ""); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parse приводит строковое значение параметра к типу схемы.
+func (sc *Schema) parse(s string) (any, error) {
+	switch sc.Type {
+	case "integer":
+		if _, err := strconv.ParseInt(s, 10, 64); err != nil {
+			return nil, fmt.Errorf("must be an integer")
+		}
+		return json.Number(s), nil
+	case "number":
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			return nil, fmt.Errorf("must be a number")
+		}
+		return json.Number(s), nil
+	case "boolean":
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("must be true or false")
+		}
+		return b, nil
+	default:
+		return s, nil
+	}
+}
+
+// validateBody проверяет JSON-тело запроса по схеме.
+func validateBody(r *http.Request, required bool, sc *Schema) *Error {
+	b, err := io.ReadAll(io.LimitReader(r.Body, MaxBodySize+1))
+	if err != nil {
+		return &Error{In: "body", Reason: err.Error()}
+	}
+	r.Body = io.NopCloser(bytes.NewReader(b))
+
+	if len(bytes.TrimSpace(b)) == 0 {
+		if required {
+			return &Error{In: "body", Reason: "is required"}
+		}
+		return nil
+	}
+	if len(b) > MaxBodySize {
+		return &Error{In: "body", Reason: fmt.Sprintf("must be at most %d bytes", MaxBodySize)}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return &Error{In: "body", Reason: "invalid JSON: " + err.Error()}
+	}
+	if dec.More() {
+		return &Error{In: "body", Reason: "invalid JSON: unexpected data after top-level value"}
+	}
+
+	if sc == nil {
+		return nil
+	}
+	if at, err := sc.check(v, ""); err != nil {
+		return &Error{In: "body", Name: at, Reason: err.Error()}
+	}
+
+	return nil
+}
+
+// check проверяет значение v, разобранное из JSON с UseNumber, по схеме.
+// at - путь к значению, при ошибке возвращается путь к неподходящему полю.
+func (sc *Schema) check(v any, at string) (string, error) {
+	if v == nil {
+		if sc.Nullable || sc.Type == "" {
+			return "", nil
+		}
+		return at, fmt.Errorf("must not be null")
+	}
+
+	if len(sc.Enum) > 0 {
+		found := false
+		for _, e := range sc.Enum {
+			if fmt.Sprint(e) == fmt.Sprint(v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return at, fmt.Errorf("must be one of: %s", enumList(sc.Enum))
+		}
+	}
+
+	switch sc.Type {
+	case "object":
+		m, ok := v.(map[string]any)
+		if !ok {
+			return at, fmt.Errorf("must be an object")
+		}
+		for _, name := range sc.Required {
+			if _, ok := m[name]; !ok {
+				return join(at, name), fmt.Errorf("is required")
+			}
+		}
+		for name, fv := range m {
+			ps, ok := sc.Properties[name]
+			if !ok {
+				if sc.AdditionalProperties != nil && !*sc.AdditionalProperties {
+					return join(at, name), fmt.Errorf("unknown field")
+				}
+				continue
+			}
+			if at, err := ps.check(fv, join(at, name)); err != nil {
+				return at, err
+			}
+		}
+
+	case "array":
+		a, ok := v.([]any)
+		if !ok {
+			return at, fmt.Errorf("must be an array")
+		}
+		if sc.MinItems != nil && len(a) < *sc.MinItems {
+			return at, fmt.Errorf("must have at least %d items", *sc.MinItems)
+		}
+		if sc.MaxItems != nil && len(a) > *sc.MaxItems {
+			return at, fmt.Errorf("must have at most %d items", *sc.MaxItems)
+		}
+		if sc.Items != nil {
+			for i, iv := range a {
+				if at, err := sc.Items.check(iv, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+					return at, err
+				}
+			}
+		}
+
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			return at, fmt.Errorf("must be a string")
+		}
+		n := utf8.RuneCountInString(s)
+		if sc.MinLength != nil && n < *sc.MinLength {
+			return at, fmt.Errorf("must be at least %d characters", *sc.MinLength)
+		}
+		if sc.MaxLength != nil && n > *sc.MaxLength {
+			return at, fmt.Errorf("must be at most %d characters", *sc.MaxLength)
+		}
+		if sc.pattern != nil && !sc.pattern.MatchString(s) {
+			return at, fmt.Errorf("must match %s", sc.Pattern)
+		}
+		if sc.Format == "uri" {
+			if u, err := url.ParseRequestURI(s); err != nil || u.Scheme == "" || u.Host == "" {
+				return at, fmt.Errorf("must be an absolute URL")
+			}
+		}
+
+	case "integer", "number":
+		n, ok := v.(json.Number)
+		if !ok {
+			return at, fmt.Errorf("must be a number")
+		}
+		if sc.Type == "integer" {
+			if _, err := n.Int64(); err != nil {
+				return at, fmt.Errorf("must be an integer")
+			}
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return at, fmt.Errorf("must be a number")
+		}
+		if sc.Minimum != nil && f < *sc.Minimum {
+			return at, fmt.Errorf("must be at least %v", *sc.Minimum)
+		}
+		if sc.Maximum != nil && f > *sc.Maximum {
+			return at, fmt.Errorf("must be at most %v", *sc.Maximum)
+		}
+
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return at, fmt.Errorf("must be true or false")
+		}
+	}
+
+	return "", nil
+}
+
+// join возвращает путь к полю name объекта по пути at.
+func join(at, name string) string {
+	if at == "" {
+		return name
+	}
+	return at + "." + name
+}
+
+func enumList(enum []any) string {
+	s := make([]string, len(enum))
+	for i, e := range enum {
+		s[i] = fmt.Sprintf("'%v'", e)
+	}
+	return strings.Join(s, ", ")
+}